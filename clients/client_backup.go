@@ -0,0 +1,125 @@
+//go:build clients
+// +build clients
+
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cliBackupServer string
+	cliBackupJson   bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backups [show|pin|unpin|delete|restore|retention|purge|pitr] [backup id]",
+	Short: "Manage the backup catalog",
+	Long: `List the logical, physical, binary log and restic backups of a cluster, print a backup,
+pin it to exempt it from the purges, delete it or restore it to the server given by --server.
+retention lists the backups the retention policy would purge, purge deletes them,
+pitr prints the state of the last point in time recovery job`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cliInit(true)
+		urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/backups/catalog"
+		if len(args) == 0 {
+			res, err := cliAPICmd(urlpost, nil)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "API call %s", err)
+				os.Exit(1)
+			}
+			if cliBackupJson {
+				fmt.Fprintf(os.Stdout, "%s\n", res)
+				return
+			}
+			var entries []config.BackupCatalogEntry
+			err = json.Unmarshal([]byte(res), &entries)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "API call %s", err)
+				os.Exit(2)
+			}
+			fmt.Printf("%-30s %9s %12s %12s %25s %25s %12s %9s %14s %s\n", "Id", "Type", "Tool", "Strategy", "Source", "Start", "Size", "Verify", "Retention", "GTID")
+			for _, e := range entries {
+				fmt.Printf("%-30s %9s %12s %12s %25s %25s %12d %9s %14s %s\n", e.Id, e.Type, e.Tool, e.Strategy, e.Source, e.StartTime.Format(time.RFC3339), e.Size, e.VerifyState, e.RetentionClass, e.BinLogGtid)
+			}
+			return
+		}
+		if args[0] == "retention" || args[0] == "purge" || args[0] == "pitr" {
+			urlpost = "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/backups/retention"
+			if args[0] == "purge" {
+				urlpost += "/actions/purge"
+			}
+			if args[0] == "pitr" {
+				urlpost = "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/backups/pitr"
+			}
+			res, err := cliAPICmd(urlpost, nil)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "API call %s", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stdout, "%s\n", res)
+			return
+		}
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Missing backup id\n")
+			os.Exit(1)
+		}
+		action, id := args[0], url.PathEscape(args[1])
+		switch action {
+		case "show":
+			urlpost += "/" + id
+		case "pin", "unpin", "delete":
+			urlpost += "/" + id + "/actions/" + action
+		case "restore":
+			server, err := cliGetBackupServerId(cliBackupServer)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			urlpost += "/" + id + "/actions/restore/" + server
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown backup action %s\n", action)
+			os.Exit(1)
+		}
+		res, err := cliAPICmd(urlpost, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "API call %s", err)
+			os.Exit(1)
+		}
+		if action == "show" {
+			fmt.Fprintf(os.Stdout, "%s\n", res)
+			return
+		}
+		fmt.Printf("Backup %s %s requested\n", args[1], action)
+	},
+}
+
+// cliGetBackupServerId returns the id of a server given by its id or host:port
+func cliGetBackupServerId(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("Missing --server to restore to")
+	}
+	servers, err := cliGetServers()
+	if err != nil {
+		return "", err
+	}
+	for i := range servers {
+		if servers[i].Id == name || servers[i].URL == name {
+			return servers[i].Id, nil
+		}
+	}
+	return "", fmt.Errorf("Server %s not found", name)
+}
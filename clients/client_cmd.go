@@ -270,6 +270,31 @@ func initConfiguratorFlags(cmd *cobra.Command) {
 	viper.BindPFlags(cmd.Flags())
 }
 
+func initJournalFlags(cmd *cobra.Command) {
+	initServerApiFlags(journalCmd)
+	journalCmd.Flags().StringVar(&cliJournalFrom, "from", "", "Start time in RFC3339 format")
+	journalCmd.Flags().StringVar(&cliJournalTo, "to", "", "End time in RFC3339 format")
+	journalCmd.Flags().StringVar(&cliJournalKey, "key", "", "State key prefix like ERR00042 or WARN")
+	journalCmd.Flags().StringVar(&cliJournalServer, "server", "", "Server URL host:port")
+	journalCmd.Flags().IntVar(&cliJournalLimit, "limit", 0, "Only print the last transitions")
+	journalCmd.Flags().BoolVar(&cliJournalJson, "json", false, "Print the JSON response")
+	viper.BindPFlags(cmd.Flags())
+}
+
+func initBackupFlags(cmd *cobra.Command) {
+	initServerApiFlags(backupCmd)
+	backupCmd.Flags().StringVar(&cliBackupServer, "server", "", "Server to restore the backup to, id or host:port")
+	backupCmd.Flags().BoolVar(&cliBackupJson, "json", false, "Print the JSON response")
+	viper.BindPFlags(cmd.Flags())
+}
+
+func initSimulateFlags(cmd *cobra.Command) {
+	initServerApiFlags(simulateCmd)
+	simulateCmd.Flags().StringVar(&cliSimulateType, "type", "failover", "Election to simulate failover|switchover")
+	simulateCmd.Flags().BoolVar(&cliSimulateJson, "json", false, "Print the JSON response")
+	viper.BindPFlags(cmd.Flags())
+}
+
 func initSwitchoverFlags(cmd *cobra.Command) {
 	initServerApiFlags(switchoverCmd)
 	switchoverCmd.Flags().StringVar(&cliPrefMaster, "db-servers-prefered-master", "", "Database preferred candidate in election,  host:[port] format")
@@ -338,6 +363,18 @@ func init() {
 	initShowFlags(showCmd)
 	initClusterFlags(showCmd)
 
+	rootClientCmd.AddCommand(journalCmd)
+	initJournalFlags(journalCmd)
+	initClusterFlags(journalCmd)
+
+	rootClientCmd.AddCommand(backupCmd)
+	initBackupFlags(backupCmd)
+	initClusterFlags(backupCmd)
+
+	rootClientCmd.AddCommand(simulateCmd)
+	initSimulateFlags(simulateCmd)
+	initClusterFlags(simulateCmd)
+
 	rootClientCmd.AddCommand(configuratorCmd)
 	initConfiguratorFlags(showCmd)
 
@@ -0,0 +1,72 @@
+//go:build clients
+// +build clients
+
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cliJournalFrom   string
+	cliJournalTo     string
+	cliJournalKey    string
+	cliJournalServer string
+	cliJournalLimit  int
+	cliJournalJson   bool
+)
+
+var journalCmd = &cobra.Command{
+	Use:   "journal",
+	Short: "Print state transitions journal",
+	Long:  `Print the journaled open and resolve state transitions of a cluster filtered by time range, key and server`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cliInit(true)
+		params := url.Values{}
+		for k, v := range map[string]string{"from": cliJournalFrom, "to": cliJournalTo, "key": cliJournalKey, "server": cliJournalServer} {
+			if v != "" {
+				params.Set(k, v)
+			}
+		}
+		if cliJournalLimit > 0 {
+			params.Set("limit", strconv.Itoa(cliJournalLimit))
+		}
+		urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/topology/journal?" + params.Encode()
+		res, err := cliAPICmd(urlpost, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "API call %s", err)
+			os.Exit(1)
+		}
+		if cliJournalJson {
+			fmt.Fprintf(os.Stdout, "%s\n", res)
+			return
+		}
+		var transitions []state.Transition
+		err = json.Unmarshal([]byte(res), &transitions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "API call %s", err)
+			os.Exit(2)
+		}
+		fmt.Printf("%25s %8s %9s %8s %25s %9s %s\n", "Time", "Action", "Key", "Type", "Server", "Duration", "Description")
+		for _, t := range transitions {
+			duration := ""
+			if t.Action == state.TransitionResolve && !t.Opened.IsZero() {
+				duration = (time.Duration(t.Duration) * time.Second).String()
+			}
+			fmt.Printf("%25s %8s %9s %8s %25s %9s %s\n", t.Time.Format(time.RFC3339), t.Action, t.ErrKey, t.ErrType, t.ServerUrl, duration, t.ErrDesc)
+		}
+	},
+}
@@ -0,0 +1,62 @@
+//go:build clients
+// +build clients
+
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/signal18/replication-manager/cluster"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cliSimulateType string
+	cliSimulateJson bool
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Simulate a failover or switchover election",
+	Long:  `Run the failover or switchover election against the current topology without changing it, print the ranked candidates with the reasons of rejection and the checks that would block`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cliSimulateType != "failover" && cliSimulateType != "switchover" {
+			fmt.Fprintf(os.Stderr, "Invalid type %s, expecting failover or switchover\n", cliSimulateType)
+			os.Exit(1)
+		}
+		cliInit(true)
+		urlpost := "https://" + cliHost + ":" + cliPort + "/api/clusters/" + cliClusters[cliClusterIndex] + "/actions/simulate-" + cliSimulateType
+		res, err := cliAPICmd(urlpost, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "API call %s", err)
+			os.Exit(1)
+		}
+		if cliSimulateJson {
+			fmt.Fprintf(os.Stdout, "%s\n", res)
+			return
+		}
+		var sim cluster.ElectionSimulation
+		err = json.Unmarshal([]byte(res), &sim)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "API call %s", err)
+			os.Exit(2)
+		}
+		fmt.Printf("Simulated %s, master: %s, elected: %s, blocked: %t\n\n", sim.Type, sim.Master, sim.Elected, sim.Blocked)
+		fmt.Printf("%25s %8s %8s %s\n", "Check", "Passed", "Blocking", "Detail")
+		for _, c := range sim.Checks {
+			fmt.Printf("%25s %8t %8t %s\n", c.Name, c.Passed, c.Blocking, c.Detail)
+		}
+		fmt.Printf("\n%25s %12s %12s %9s %s\n", "Candidate", "Seq", "Pos", "Prefered", "Rejections")
+		for _, c := range sim.Candidates {
+			fmt.Printf("%25s %12d %12d %9t %s\n", c.URL, c.Seq, c.Pos, c.Prefered, strings.Join(c.Rejections, ", "))
+		}
+	},
+}
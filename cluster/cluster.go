@@ -24,12 +24,12 @@ import (
 	"sync"
 	"time"
 
-	"github.com/bluele/logrus_slack"
 	"github.com/go-git/go-git/v5"
 	git_obj "github.com/go-git/go-git/v5/plumbing/object"
 	vault "github.com/hashicorp/vault/api"
 
 	git_https "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/jmoiron/sqlx"
 	"github.com/pelletier/go-toml"
 	"github.com/signal18/replication-manager/cluster/configurator"
 	"github.com/signal18/replication-manager/cluster/nbc"
@@ -37,12 +37,13 @@ import (
 	v3 "github.com/signal18/replication-manager/repmanv3"
 	"github.com/signal18/replication-manager/router/maxscale"
 	"github.com/signal18/replication-manager/utils/alert"
+	"github.com/signal18/replication-manager/utils/audit"
 	"github.com/signal18/replication-manager/utils/cron"
 	"github.com/signal18/replication-manager/utils/dbhelper"
-	"github.com/signal18/replication-manager/utils/logrus/hooks/pushover"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/s18log"
 	"github.com/signal18/replication-manager/utils/state"
+	"github.com/signal18/replication-manager/utils/tracing"
 	clog "github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	logsql "github.com/sirupsen/logrus"
@@ -121,10 +122,13 @@ type Cluster struct {
 	MonitorSpin                   string               `json:"monitorSpin"`
 	WorkLoad                      config.WorkLoad      `json:"workLoad"`
 	Logrus                        *log.Logger          `json:"-"`
-	LogPushover                   *log.Logger          `json:"-"`
 	Log                           s18log.HttpLog       `json:"log"`
 	LogTask                       s18log.HttpLog       `json:"logTask"`
-	LogSlack                      *log.Logger          `json:"-"`
+	AlertRoutes                   []alert.Route        `json:"-"`
+	AlertGrouper                  *alert.Grouper       `json:"-"`
+	AlertSilences                 *alert.Silences      `json:"-"`
+	StateJournal                  *state.Journal       `json:"-"`
+	RemediationLog                *audit.Log           `json:"-"`
 	JobResults                    *config.TasksMap     `json:"jobResults"`
 	Grants                        map[string]string    `json:"-"`
 	tlog                          *s18log.TermLog      `json:"-"`
@@ -143,6 +147,10 @@ type Cluster struct {
 	slaves                        serverList           `json:"slaves"`
 	master                        *ServerMonitor       `json:"master"`
 	oldMaster                     *ServerMonitor       `json:"oldmaster"`
+	failoverTimeline              *FailoverTimeline    `json:"-"`
+	topologyZones                 config.Zones         `json:"-"`
+	failoverHistograms            histogramMap         `json:"-"`
+	traceCtx                      tracing.Context      `json:"-"`
 	vmaster                       *ServerMonitor       `json:"vmaster"`
 	mxs                           *maxscale.MaxScale   `json:"-"`
 	CheckSumConfig                map[string]hash.Hash `json:"-"`
@@ -190,10 +198,25 @@ type Cluster struct {
 	BackupMetaMap             *config.BackupMetaMap       `json:"backupList"`
 	SLAHistory                []state.Sla                 `json:"slaHistory"`
 	APIUsers                  map[string]APIUser          `json:"apiUsers"`
+	apiUsersLock              sync.Mutex                  `json:"-"`
 	Schedule                  map[string]cron.Entry       `json:"-"`
 	scheduler                 *cron.Cron                  `json:"-"`
 	idSchedulerPhysicalBackup cron.EntryID                `json:"-"`
 	idSchedulerLogicalBackup  cron.EntryID                `json:"-"`
+	idSchedulerIncrBackup     cron.EntryID                `json:"-"`
+	idSchedulerDiffBackup     cron.EntryID                `json:"-"`
+	idSchedulerBackupVerify   cron.EntryID                `json:"-"`
+	backupVerifyLock          sync.Mutex                  `json:"-"`
+	backupRetentionLock       sync.Mutex                  `json:"-"`
+	pitrLock                  sync.Mutex                  `json:"-"`
+	pitrJob                   *config.PointInTimeJob      `json:"-"`
+	pitrStandalone            *exec.Cmd                   `json:"-"`
+	pitrStandaloneDB          *sqlx.DB                    `json:"-"`
+	binlogArchiver            binlogArchiver              `json:"-"`
+	backupThrottle            backupThrottle              `json:"-"`
+	streamedChecksums         sync.Map                    `json:"-"`
+	tableChecksum             tableChecksum               `json:"-"`
+	notifiers                 alertNotifiers              `json:"-"`
 	idSchedulerOptimize       cron.EntryID                `json:"-"`
 	idSchedulerAnalyze        cron.EntryID                `json:"-"`
 	idSchedulerErrorLogs      cron.EntryID                `json:"-"`
@@ -223,6 +246,7 @@ type Cluster struct {
 	InPhysicalBackup          bool                        `json:"inPhysicalBackup"`
 	InLogicalBackup           bool                        `json:"inLogicalBackup"`
 	InBinlogBackup            bool                        `json:"inBinlogBackup"`
+	binlogBackupLock          sync.Mutex                  `json:"-"`
 	InResticBackup            bool                        `json:"inResticBackup"`
 	InRollingRestart          bool                        `json:"inRollingRestart"`
 	LastDelayStatPrint        time.Time
@@ -273,6 +297,8 @@ type Agent struct {
 	OsName       string `json:"osName"`
 	Status       string `json:"status"`
 	Version      string `json:"version"`
+	Region       string `json:"region"`
+	Zone         string `json:"zone"`
 }
 
 type Alerts struct {
@@ -354,6 +380,7 @@ func (cluster *Cluster) InitFromConf() {
 	cluster.testStartCluster = true
 	cluster.BackupMetaMap = config.NewBackupMetaMap()
 	cluster.VersionsMap = config.NewVersionsMap()
+	cluster.topologyZones = cluster.Conf.GetTopologyZones()
 
 	cluster.WorkingDir = cluster.Conf.WorkingDir + "/" + cluster.Name
 	if cluster.Conf.Arbitration {
@@ -409,38 +436,13 @@ func (cluster *Cluster) InitFromConf() {
 	cluster.LoadAPIUsers()
 	cluster.GetPersitentState()
 
-	cluster.LogPushover = log.New()
-	cluster.LogPushover.SetFormatter(&log.TextFormatter{FullTimestamp: true})
-
-	if cluster.Conf.PushoverAppToken != "" && cluster.Conf.PushoverUserToken != "" {
-		cluster.LogPushover.AddHook(
-			pushover.NewHook(cluster.Conf.GetDecryptedValue("alert-pushover-app-token"), cluster.Conf.GetDecryptedValue("alert-pushover-user-token")),
-		)
-		cluster.LogPushover.SetLevel(log.WarnLevel)
-	}
-
-	cluster.LogSlack = log.New()
-	cluster.LogSlack.SetFormatter(&log.TextFormatter{FullTimestamp: true})
-
-	if cluster.Conf.SlackURL != "" {
-		cluster.LogSlack.AddHook(&logrus_slack.SlackHook{
-			HookURL:        cluster.Conf.SlackURL,
-			AcceptedLevels: logrus_slack.LevelThreshold(log.WarnLevel),
-			Channel:        cluster.Conf.SlackChannel,
-			IconEmoji:      ":ghost:",
-			Username:       cluster.Conf.SlackUser,
-			Timeout:        5 * time.Second, // request timeout for calling slack api
-		})
-	}
+	cluster.initNotifiers()
+	cluster.initAlertRouting()
+	cluster.StateJournal = state.NewJournal(cluster.WorkingDir + "/statejournal.jsonl")
+	cluster.RemediationLog = audit.NewLog(cluster.WorkingDir + "/remediation.jsonl")
+	cluster.failoverHistograms = newFailoverHistograms()
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "START", "Replication manager started with version: %s", cluster.Conf.Version)
-
-	if cluster.Conf.MailTo != "" {
-		msg := "Replication-Manager started\nVersion: " + cluster.Conf.Version
-		subj := "Replication-Manager started"
-		alert := alert.Alert{}
-		alert.Cluster = cluster.Name
-		go alert.EmailMessage(msg, subj, cluster.Conf)
-	}
+	cluster.SendMessage("Replication-Manager started", "Replication-Manager started\nVersion: "+cluster.Conf.Version)
 
 	hookerr, err := s18log.NewRotateFileHook(s18log.RotateFileConfig{
 		Filename:   cluster.WorkingDir + "/sql_error.log",
@@ -542,6 +544,9 @@ func (cluster *Cluster) initScheduler() {
 		cluster.SetSchedulerBackupLogical()
 		cluster.SetSchedulerLogsTableRotate()
 		cluster.SetSchedulerBackupPhysical()
+		cluster.SetSchedulerBackupPhysicalIncremental()
+		cluster.SetSchedulerBackupPhysicalDifferential()
+		cluster.SetSchedulerBackupVerify()
 		cluster.SetSchedulerBackupLogs()
 		cluster.SetSchedulerOptimize()
 		cluster.SetSchedulerAnalyze()
@@ -603,6 +608,7 @@ func (cluster *Cluster) Run() {
 						}
 					}
 				}
+				span := cluster.startMonitorSpan()
 				wg := new(sync.WaitGroup)
 				wg.Add(1)
 				go cluster.TopologyDiscover(wg)
@@ -638,12 +644,13 @@ func (cluster *Cluster) Run() {
 							cluster.MonitorVariablesDiff()
 							go cluster.ResticFetchRepo()
 							cluster.IsValidBackup = cluster.HasValidBackup()
+							cluster.CheckBackupVerify()
 							go cluster.CheckCredentialRotation()
 							cluster.CheckCanSaveDynamicConfig()
 							cluster.CheckIsOverwrite()
 
 						} else {
-							cluster.StateMachine.PreserveState("WARN0093", "WARN0084", "WARN0095", "WARN0101", "WARN0111", "WARN0112", "ERR00090", "WARN0102")
+							cluster.StateMachine.PreserveState("WARN0093", "WARN0084", "WARN0095", "WARN0101", "WARN0111", "WARN0112", "ERR00090", "WARN0102", "ERR00097")
 						}
 						if !cluster.CanInitNodes {
 							cluster.SetState("ERR00082", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00082"], cluster.errorInitNodes), ErrFrom: "OPENSVC"})
@@ -657,7 +664,11 @@ func (cluster *Cluster) Run() {
 
 						if cluster.StateMachine.GetHeartbeats()%36000 == 0 {
 							// Set in parallel since it will wait for fetch to finish
-							go cluster.ResticPurgeRepo()
+							if cluster.Conf.BackupRetention {
+								go cluster.PurgeBackupRetention(false)
+							} else {
+								go cluster.ResticPurgeRepo()
+							}
 						} else {
 							// Preserve tools if not installed or has problem
 							cluster.StateMachine.PreserveState("WARN0094", "WARN0117", "WARN0118", "WARN0119", "WARN0120", "WARN0121")
@@ -665,6 +676,9 @@ func (cluster *Cluster) Run() {
 						if cluster.SlavesOldestMasterFile.Suffix == 0 {
 							go cluster.CheckSlavesReplicationsPurge()
 						}
+						cluster.CheckBinlogArchiver()
+						cluster.CheckBackupThrottle()
+						cluster.ResumeTableChecksum()
 						cluster.PrintDelayStat()
 					}
 					wg.Wait()
@@ -683,6 +697,7 @@ func (cluster *Cluster) Run() {
 				cluster.SetStatus()
 				cluster.StateProcessing()
 				go cluster.GetSlowLogTable() // prevent blocking cycle
+				cluster.endMonitorSpan(span)
 			}
 		}
 
@@ -699,6 +714,8 @@ func (cluster *Cluster) Run() {
 }
 
 func (cluster *Cluster) StateProcessing() {
+	_, span := cluster.StartSpan("Cluster.StateProcessing")
+	defer span.End()
 	if !cluster.StateMachine.IsInFailover() {
 		// trigger action on resolving states
 		cstates := cluster.StateMachine.GetResolvedStates()
@@ -809,6 +826,7 @@ func (cluster *Cluster) StateProcessing() {
 			//		cluster.statecloseChan <- s
 			cluster.CheckAlert(s, true)
 			cluster.BashScriptCloseSate(s)
+			cluster.JournalState(s, true)
 		}
 
 		//Replace old state print
@@ -824,8 +842,10 @@ func (cluster *Cluster) StateProcessing() {
 
 			cluster.CheckAlert(s, false)
 			cluster.BashScriptOpenSate(s)
+			cluster.JournalState(s, false)
 
 		}
+		cluster.FlushGroupedAlerts()
 
 		cluster.StateMachine.ClearState()
 		if cluster.StateMachine.GetHeartbeats()%60 == 0 {
@@ -842,6 +862,7 @@ func (cluster *Cluster) Stop() {
 	cluster.Save()
 	cluster.PushConfigs()
 	cluster.exit = true
+	go cluster.StopBinlogArchiver()
 
 }
 
@@ -1274,6 +1295,8 @@ func (cluster *Cluster) GetEncryptedValueFromMemory(key string) string {
 		return cluster.GetShardUser() + ":" + cluster.Conf.GetEncryptedString(cluster.GetShardPass())
 	case "backup-restic-password":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("backup-restic-password"))
+	case "backup-encryption-master-key":
+		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("backup-encryption-master-key"))
 	case "haproxy-password":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("haproxy-password"))
 	case "maxscale-pass":
@@ -1295,12 +1318,18 @@ func (cluster *Cluster) GetEncryptedValueFromMemory(key string) string {
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("backup-restic-aws-access-secret"))
 	case "backup-streaming-aws-access-secret":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("backup-streaming-aws-access-secret"))
+	case "backup-storage-azure-key":
+		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("backup-storage-azure-key"))
 	case "arbitration-external-secret":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("arbitration-external-secret"))
 	case "alert-pushover-user-token":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("alert-pushover-user-token"))
 	case "alert-pushover-app-token":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("alert-pushover-app-token"))
+	case "alert-pagerduty-routing-key":
+		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("alert-pagerduty-routing-key"))
+	case "alert-opsgenie-api-key":
+		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("alert-opsgenie-api-key"))
 	case "mail-smtp-password":
 		return cluster.Conf.GetEncryptedString(cluster.Conf.GetDecryptedValue("mail-smtp-password"))
 	case "api-oauth-client-secret":
@@ -1468,6 +1497,8 @@ func (cluster *Cluster) ResetCrashes() {
 }
 
 func (cluster *Cluster) MonitorVariablesDiff() {
+	_, span := cluster.StartSpan("Cluster.MonitorVariablesDiff")
+	defer span.End()
 	if !cluster.Conf.MonitorVariableDiff || cluster.GetMaster() == nil {
 		return
 	}
@@ -1544,6 +1575,8 @@ func (cluster *Cluster) MonitorVariablesDiff() {
 }
 
 func (cluster *Cluster) MonitorSchema() {
+	_, span := cluster.StartSpan("Cluster.MonitorSchema")
+	defer span.End()
 	if !cluster.Conf.MonitorSchemaChange {
 		return
 	}
@@ -1633,6 +1666,8 @@ func (cluster *Cluster) MonitorSchema() {
 }
 
 func (cluster *Cluster) MonitorQueryRules() {
+	_, span := cluster.StartSpan("Cluster.MonitorQueryRules")
+	defer span.End()
 	if !cluster.Conf.MonitorQueryRules {
 		return
 	}
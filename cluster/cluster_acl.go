@@ -8,10 +8,14 @@ package cluster
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/signal18/replication-manager/config"
 	v3 "github.com/signal18/replication-manager/repmanv3"
+	"github.com/signal18/replication-manager/utils/apitoken"
+	"github.com/signal18/replication-manager/utils/crypto"
 	"github.com/signal18/replication-manager/utils/misc"
 	"google.golang.org/grpc/codes"
 )
@@ -22,6 +26,13 @@ type APIUser struct {
 	GitToken string          `json:"-"`
 	GitUser  string          `json:"-"`
 	Grants   map[string]bool `json:"grants"`
+	Roles    []string        `json:"roles"`
+	Groups   []string        `json:"groups"`
+	OAuth    bool            `json:"oauth"` // created from its OAuth groups, no password login
+	// grants and roles given by the OAuth groups only, revoked on the next
+	// login before the roles of the current groups are granted
+	groupGrants []string
+	groupRoles  int
 }
 
 func (u *APIUser) Granted(grant string) error {
@@ -35,22 +46,71 @@ func (u *APIUser) Granted(grant string) error {
 	return v3.NewErrorResource(codes.PermissionDenied, v3.ErrGrantNotFound, "grant not found", "").Err()
 }
 
-func (cluster *Cluster) IsValidACL(strUser string, strPassword string, URL string, AuthMethod string) bool {
+// IsValidACL checks the user credentials, the grants of the routes are
+// checked by IsValidRouteACL
+func (cluster *Cluster) IsValidACL(strUser string, strPassword string, AuthMethod string) bool {
 	if user, ok := cluster.APIUsers[strUser]; ok {
-		//		fmt.Printf("password :" + user.Password)
-		if user.Password == strPassword || AuthMethod == "oidc" {
-			return cluster.IsURLPassACL(strUser, URL, true)
-		}
+		return (crypto.CheckPassword(user.Password, strPassword) && !user.OAuth) || AuthMethod == "oidc"
+	}
+	return false
+}
+
+// IsValidTokenACL checks a personal access token user on the grants of a
+// route, a scoped token keeps the user grants in its scopes. A route declaring
+// no grant is open to the unscoped tokens of the cluster users.
+func (cluster *Cluster) IsValidTokenACL(tok apitoken.Token, grants []string) bool {
+	user, ok := cluster.APIUsers[tok.User]
+	if !ok || user.OAuth {
 		return false
 	}
-	//	for key, value := range cluster.Grants {
+	if len(grants) == 0 {
+		return len(tok.Scopes) == 0
+	}
+	for _, grant := range grants {
+		if user.Grants[grant] && tok.HasScope(grant) {
+			return true
+		}
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL check failed for token %s of user %s, route needs one of %s", tok.Id, tok.User, strings.Join(grants, " "))
+	return false
+}
 
+// GetTokenAPIUser returns the user of a token with the grants kept by its scopes
+func (cluster *Cluster) GetTokenAPIUser(tok apitoken.Token) (APIUser, error) {
+	user, ok := cluster.APIUsers[tok.User]
+	if !ok || user.OAuth {
+		return APIUser{}, fmt.Errorf("user not found for token %s", tok.Id)
+	}
+	grants := make(map[string]bool)
+	for grant, value := range user.Grants {
+		grants[grant] = value && tok.HasScope(grant)
+	}
+	user.Grants = grants
+	return user, nil
+}
+
+// IsValidRouteACL checks the user against the grants declared by a route, any
+// of them pass and a route declaring no grant is open to the cluster users
+func (cluster *Cluster) IsValidRouteACL(strUser string, strPassword string, grants []string, AuthMethod string) bool {
+	if !cluster.IsValidACL(strUser, strPassword, AuthMethod) {
+		return false
+	}
+	if len(grants) == 0 {
+		return true
+	}
+	user := cluster.APIUsers[strUser]
+	for _, grant := range grants {
+		if user.Grants[grant] {
+			return true
+		}
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL check failed for user %s, route needs one of %s", strUser, strings.Join(grants, " "))
 	return false
 }
 
 func (cluster *Cluster) GetAPIUser(strUser string, strPassword string) (APIUser, error) {
 	if user, ok := cluster.APIUsers[strUser]; ok {
-		if user.Password == strPassword {
+		if crypto.CheckPassword(user.Password, strPassword) && !user.OAuth {
 			return user, nil
 		}
 		return APIUser{}, fmt.Errorf("incorrect password")
@@ -78,19 +138,67 @@ func (cluster *Cluster) SaveAcls() {
 }
 
 func (cluster *Cluster) SetGrant(user string, grant string, enable bool) {
-	if _, ok := cluster.APIUsers[user].Grants[grant]; ok {
-		cluster.APIUsers[user].Grants[grant] = enable
+	cluster.apiUsersLock.Lock()
+	if u, ok := cluster.APIUsers[user]; ok && hasGrant(u.Grants, grant) {
+		u.Grants = copyGrants(u.Grants)
+		u.Grants[grant] = enable
+		cluster.setAPIUser(u)
 	} else {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Failed grant not found for user %s, grant %s ", user, grant)
 	}
+	cluster.apiUsersLock.Unlock()
 
 	cluster.SaveAcls()
 }
 
+func hasGrant(grants map[string]bool, grant string) bool {
+	_, ok := grants[grant]
+	return ok
+}
+
+func copyGrants(grants map[string]bool) map[string]bool {
+	res := make(map[string]bool, len(grants))
+	for k, v := range grants {
+		res[k] = v
+	}
+	return res
+}
+
+// SetAPIUser adds or replaces a user. The users are never written in place,
+// a new map is swapped in so that the ACL checks can read it concurrently.
+func (cluster *Cluster) SetAPIUser(user APIUser) {
+	cluster.apiUsersLock.Lock()
+	defer cluster.apiUsersLock.Unlock()
+	cluster.setAPIUser(user)
+}
+
+func (cluster *Cluster) setAPIUser(user APIUser) {
+	users := make(map[string]APIUser, len(cluster.APIUsers)+1)
+	for k, v := range cluster.APIUsers {
+		users[k] = v
+	}
+	users[user.User] = user
+	cluster.APIUsers = users
+}
+
+func (cluster *Cluster) deleteAPIUser(name string) {
+	users := make(map[string]APIUser, len(cluster.APIUsers))
+	for k, v := range cluster.APIUsers {
+		if k != name {
+			users[k] = v
+		}
+	}
+	cluster.APIUsers = users
+}
+
 func (cluster *Cluster) LoadAPIUsers() error {
 	credentials := strings.Split(cluster.Conf.Secrets["api-credentials"].Value+","+cluster.Conf.Secrets["api-credentials-external"].Value, ",")
 	//	fmt.Printf(cluster.Conf.Secrets["api-credentials"].Value + "," + cluster.Conf.Secrets["api-credentials-external"].Value)
 	meUsers := make(map[string]APIUser)
+	bindings, err := config.ParseRoleBindings(cluster.Conf.APIUsersRoles)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Failed to parse api-credentials-roles: %s", err)
+	}
 	for _, credential := range credentials {
 		var newapiuser APIUser
 
@@ -124,6 +232,7 @@ func (cluster *Cluster) LoadAPIUsers() error {
 				}
 			}
 		}
+		cluster.grantRoles(&newapiuser, bindings[newapiuser.User])
 		if cluster.Conf.GitAccesToken != "" {
 			newapiuser.GitToken = cluster.Conf.GetDecryptedPassword("api-credentials", cluster.Conf.GitAccesToken)
 			newapiuser.GitUser = cluster.Conf.GitUsername
@@ -131,562 +240,87 @@ func (cluster *Cluster) LoadAPIUsers() error {
 		}
 		meUsers[newapiuser.User] = newapiuser
 	}
+	cluster.apiUsersLock.Lock()
 	cluster.APIUsers = meUsers
+	cluster.apiUsersLock.Unlock()
 	return nil
 }
 
-func (cluster *Cluster) IsURLPassDatabasesACL(strUser string, URL string) bool {
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterProcess] {
-		if strings.Contains(URL, "/actions/run-jobs") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProvDBProvision] {
-		if strings.Contains(URL, "/actions/provision") {
-			return true
-		}
-		if strings.Contains(URL, "/service-opensvc") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProvDBUnprovision] {
-		if strings.Contains(URL, "/actions/unprovision") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBStart] {
-		if strings.Contains(URL, "/actions/start") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBStop] {
-		if strings.Contains(URL, "/actions/stop") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterSwitchover] {
-		if strings.Contains(URL, "/actions/switchover") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterFailover] {
-		if strings.Contains(URL, "/actions/set-prefered") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/set-unrated") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/set-ignored") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBKill] {
-		if strings.Contains(URL, "/actions/kill") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBOptimize] {
-		if strings.Contains(URL, "/actions/analyze-pfs") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBAnalyse] {
-		if strings.Contains(URL, "/actions/analyze-pfs") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/analyze-slowlog") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/reset-pfs-queries") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBReplication] {
-		if strings.Contains(URL, "/all-slaves-status") {
-			return true
-		}
-		if strings.Contains(URL, "/master-status") {
-			return true
-		}
-		if strings.Contains(URL, "actions/start-slave") {
-			return true
-		}
-		if strings.Contains(URL, "actions/stop-slave") {
-			return true
-		}
-		if strings.Contains(URL, "actions/skip-replication-event") {
-			return true
-		}
-		if strings.Contains(URL, "actions/reset-master") {
-			return true
-		}
-		if strings.Contains(URL, "actions/reset-slave-all") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBBackup] {
-		if strings.Contains(URL, "/actions/backup-logical") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/backup-error-log") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/backup-physical") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/backup-slowquery-log") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/flush-logs") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBRestore] {
-		if strings.Contains(URL, "/actions/reseed/") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/pitr") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/reseed-cancel") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterProcess] {
-		if strings.Contains(URL, "/actions/job-cancel/") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/reseed-cancel") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBReadOnly] {
-		if strings.Contains(URL, "actions/toogle-read-only") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProxyConfigFlag] {
-		if strings.Contains(URL, "/config") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBLogs] {
-		if strings.Contains(URL, "/processlist") {
-			return true
-		}
-		if strings.Contains(URL, "/status-innodb") {
-			return true
-		}
-		if strings.Contains(URL, "/errorlog") {
-			return true
-		}
-		if strings.Contains(URL, "/slow-queries") {
-			return true
-		}
-		if strings.Contains(URL, "/query-response-time") {
-			return true
-		}
-		if strings.Contains(URL, "/meta-data-locks") {
-			return true
-		}
-		if strings.Contains(URL, "/digest-statements-pfs") {
-			return true
-		}
-		if strings.Contains(URL, "/digest-statements-slow") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-sql-error-log") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-sql-error-log") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-query-response-time") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-meta-data-locks") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-slow-query-table") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-slow-query-capture") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-slow-query") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/set-long-query-time") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-pfs-slow-query") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/toogle-slow-query") {
-			return true
-		}
-		if strings.Contains(URL, "actions/toogle-innodb-monitor") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/explain-pfs") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/explain-slowlog") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBCapture] {
-		if strings.Contains(URL, "/actions/toogle-slow-query-capture") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBMaintenance] {
-		if strings.Contains(URL, "/actions/optimize") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/maintenance") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/set-maintenance") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/del-maintenance") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/wait-innodb-purge") {
-			return true
-		}
-	}
-	/*	if cluster.APIUsers[strUser].Grants[config.GrantDBConfigCreate] {
-			if strings.Contains(URL, "/kill") {
-				return true
-			}
-		}
-		if cluster.APIUsers[strUser].Grants[config.GrantDBConfigGet] {
-			if strings.Contains(URL, "/kill") {
-				return true
-			}
-		}
-		if cluster.APIUsers[strUser].Grants[config.GrantDBConfigFlag] {
-			if strings.Contains(URL, "/kill") {
-				return true
-			}
-		}*/
-	if cluster.APIUsers[strUser].Grants[config.GrantDBShowVariables] {
-		if strings.Contains(URL, "/variables") {
-			return true
-		}
+// grantRoles adds the grants of the roles bound to the user on this cluster
+func (cluster *Cluster) grantRoles(user *APIUser, bindings []config.RoleBinding) {
+	if len(bindings) == 0 {
+		return
 	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBShowSchema] {
-		if strings.Contains(URL, "/tables") {
-			return true
-		}
-		if strings.Contains(URL, "/vtables") {
-			return true
-		}
-		if strings.Contains(URL, "/tables") {
-			return true
-		}
-		if strings.Contains(URL, "/schemas") {
-			return true
-		}
+	roles, err := cluster.Conf.GetRoles()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Failed to parse api-roles: %s", err)
 	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBShowStatus] {
-		if strings.Contains(URL, "/status") {
-			return true
-		}
-		if strings.Contains(URL, "/status-delta") {
-			return true
+	for _, rb := range bindings {
+		if !rb.Match(cluster.Name) {
+			continue
 		}
-	}
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL check failed for user %s : %s ", strUser, URL)
-	return false
-}
-
-func (cluster *Cluster) IsURLPassProxiesACL(strUser string, URL string) bool {
-
-	if cluster.APIUsers[strUser].Grants[config.GrantProvProxyProvision] {
-		if strings.Contains(URL, "/actions/provision") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProvProxyUnprovision] {
-		if strings.Contains(URL, "/actions/unprovision") {
-			return true
+		prefixes, ok := roles[rb.Role]
+		if !ok {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Unknown role %s bound to user %s", rb.Role, user.User)
+			continue
 		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProxyStart] {
-		if strings.Contains(URL, "/actions/start") {
-			return true
+		if user.Grants == nil {
+			user.Grants = make(map[string]bool)
 		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProxyStop] {
-		if strings.Contains(URL, "/actions/stop") {
-			return true
+		for _, grant := range config.GetRoleGrants(prefixes, cluster.Grants) {
+			user.Grants[grant] = true
 		}
+		user.Roles = append(user.Roles, rb.Role)
 	}
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL proxy check failed for user %s : %s ", strUser, URL)
-
-	return false
 }
 
-func (cluster *Cluster) IsURLPassACL(strUser string, URL string, errorPrint bool) bool {
-	switch URL {
-	case "/api/login":
-		return true
-	case "/api/auth/callback":
-		return true
-	case "/api/clusters":
-		return true
-	case "/api/monitor":
-		return true
-	case "/api/clusters/" + cluster.Name + "/actions/waitdatabases":
-		return true
-	case "/api/clusters/" + cluster.Name:
-		return true
-	case "/api/clusters/" + cluster.Name + "/diffvariables":
-		return true
-	}
-
-	if strings.Contains(URL, "/api/clusters/settings/actions/switch") {
-		return cluster.APIUsers[strUser].Grants[config.GrantGlobalSettings]
-	}
-	if strings.Contains(URL, "/api/clusters/settings/actions/set") {
-		return cluster.APIUsers[strUser].Grants[config.GrantGlobalSettings]
-	}
-
-	if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/servers") {
-		return cluster.IsURLPassDatabasesACL(strUser, URL)
-	}
-	if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/proxies") {
-		return cluster.IsURLPassProxiesACL(strUser, URL)
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterSharding] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/schema") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/shardclusters") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterProcess] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/jobs") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterProcess] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/top") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterShowBackups] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/backups") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterShowRoutes] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/queryrules") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterShowCertificates] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/certificates") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterCertificatesReload] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/certificates-reload") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterCertificatesRotate] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/certificates-rotate") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterResetSLA] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/reset-sla") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterCreateMonitor] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/addserver") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterDropMonitor] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/dropserver") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterSwitchover] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/switchover") {
-			return true
-		}
-	}
-
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterTraffic] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/stop-traffic") {
-			return true
-
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/start-traffic") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBBackup] {
-		if strings.Contains(URL, "/actions/master-logical-backup") {
-			return true
-		}
-		if strings.Contains(URL, "/actions/master-physical-backup") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterBench] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/sysbench") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterTest] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/sysbench") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/tests/") {
-			return true
-		}
-
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterFailover] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/failover") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterReplication] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/replication/bootstrap") {
-			return true
-		}
-
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/replication/cleanup") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterRolling] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/optimize") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/rolling") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/cancel-rolling-restart") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/cancel-rolling-reprov") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterRotatePasswords] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/rotate-passwords") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantDBConfigFlag] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/drop-db-tag") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/add-db-tag") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/apply-dynamic-config") {
-			return true
-		}
-
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProxyConfigFlag] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/drop-proxy-tag") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/add-proxy-tag") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterSettings] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/reload") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/switch") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/set") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/discover") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/reset-failover-control") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterChecksum] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/actions/checksum-all-tables") {
-			return true
-		}
-	}
-
-	if cluster.APIUsers[strUser].Grants[config.GrantProvCluster] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/services/actions/provision") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/actions/add") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantProvClusterUnprovision] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/services/actions/unprovision") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterCreate] {
-		if strings.Contains(URL, "/api/clusters/actions/add") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterDelete] {
-		if strings.Contains(URL, "/api/clusters/actions/delete") {
-			return true
-		}
-	}
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterConfigGraphs] {
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/set-graphite-filterlist") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/reload-graphite-filterlist") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/settings/actions/reset-graphite-filterlist") {
-			return true
-		}
-	}
-
-	if cluster.APIUsers[strUser].Grants[config.GrantClusterGrant] {
-		if strings.Contains(URL, "/api/monitor/actions/adduser/") {
-			return true
-		}
-		if strings.Contains(URL, "/api/clusters/"+cluster.Name+"/users/add") {
-			return true
+// LoadOAuthUser grants an OAuth user the roles bound to its groups, a user
+// without credentials is created when a group role applies to this cluster.
+// The grants of the groups are rebuilt on each login, so that a user removed
+// from a group loses its roles.
+func (cluster *Cluster) LoadOAuthUser(strUser string, groups []string) bool {
+	bindings, err := config.ParseRoleBindings(cluster.Conf.OAuthGroupRoles)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Failed to parse api-oauth-group-roles: %s", err)
+	}
+	var groupBindings []config.RoleBinding
+	for _, group := range groups {
+		groupBindings = append(groupBindings, bindings[group]...)
+	}
+
+	cluster.apiUsersLock.Lock()
+	defer cluster.apiUsersLock.Unlock()
+	prev, ok := cluster.APIUsers[strUser]
+	if !ok {
+		prev = APIUser{User: strUser, OAuth: true}
+	}
+	// Start from the grants of the user without its groups
+	user := prev
+	user.Grants = copyGrants(prev.Grants)
+	for _, grant := range prev.groupGrants {
+		user.Grants[grant] = false
+	}
+	user.Roles = slices.Clone(prev.Roles[:len(prev.Roles)-prev.groupRoles])
+	baseGrants := copyGrants(user.Grants)
+	baseRoles := len(user.Roles)
+	cluster.grantRoles(&user, groupBindings)
+	user.groupRoles = len(user.Roles) - baseRoles
+	user.groupGrants = nil
+	for grant, value := range user.Grants {
+		if value && !baseGrants[grant] {
+			user.groupGrants = append(user.groupGrants, grant)
+		}
+	}
+	user.Groups = groups
+
+	if user.OAuth && user.groupRoles == 0 {
+		if ok {
+			cluster.deleteAPIUser(strUser)
 		}
+		return false
 	}
-
-	/*	case cluster.APIUsers[strUser].Grants[config.GrantClusterGrant] == true:
-			return false
-		case cluster.APIUsers[strUser].Grants[config.GrantClusterDropMonitor] == true:
-			return false
-		case cluster.APIUsers[strUser].Grants[config.GrantClusterCreate] == true:
-			return false
-	*/
-
-	// Print error with no valid ACL
-	if errorPrint {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL check failed for user %s : %s ", strUser, URL)
+	if !ok || !maps.Equal(user.Grants, prev.Grants) || !slices.Equal(user.Roles, prev.Roles) || !slices.Equal(user.Groups, prev.Groups) {
+		cluster.setAPIUser(user)
 	}
-	return false
+	return true
 }
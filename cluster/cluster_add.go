@@ -187,7 +187,13 @@ func (cluster *Cluster) AddUser(userform UserForm) error {
 }
 
 func (cluster *Cluster) AddUserGrants(user string, grants string) {
-
+	cluster.apiUsersLock.Lock()
+	u, ok := cluster.APIUsers[user]
+	if !ok {
+		cluster.apiUsersLock.Unlock()
+		return
+	}
+	u.Grants = copyGrants(u.Grants)
 	acls := strings.Split(grants, " ")
 	for key, value := range cluster.Grants {
 		found := false
@@ -197,8 +203,10 @@ func (cluster *Cluster) AddUserGrants(user string, grants string) {
 				break
 			}
 		}
-		cluster.APIUsers[user].Grants[value] = found
+		u.Grants[value] = found
 	}
+	cluster.setAPIUser(u)
+	cluster.apiUsersLock.Unlock()
 
 	cluster.SaveAcls()
 }
@@ -103,7 +103,9 @@ func (cluster *Cluster) failoverPostScript(fail bool) {
 		if !fail {
 			failtype = "switchover"
 		}
+		step := cluster.failoverTimeline.Step("post-script", "")
 		out, err = exec.Command(cluster.Conf.PostScript, cluster.oldMaster.Host, cluster.GetMaster().Host, cluster.oldMaster.Port, cluster.GetMaster().Port, cluster.oldMaster.MxsServerName, cluster.GetMaster().MxsServerName, failtype).CombinedOutput()
+		step.Done(cluster.Conf.PostScript+" "+failtype, err)
 		if err != nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "%s", err)
 		}
@@ -122,7 +124,9 @@ func (cluster *Cluster) failoverPreScript(fail bool) {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Calling pre-failover script")
 		var out []byte
 		var err error
+		step := cluster.failoverTimeline.Step("pre-script", "")
 		out, err = exec.Command(cluster.Conf.PreScript, cluster.oldMaster.Host, cluster.GetMaster().Host, cluster.oldMaster.Port, cluster.GetMaster().Port, cluster.oldMaster.MxsServerName, cluster.GetMaster().MxsServerName, failtype).CombinedOutput()
+		step.Done(cluster.Conf.PreScript+" "+failtype, err)
 		if err != nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "%s", err)
 		}
@@ -157,14 +161,13 @@ func (cluster *Cluster) BinlogCopyScript(server *ServerMonitor, binlog string, i
 
 	//Skip setting in backup state due to batch purging
 	if !isPurge {
-		if cluster.IsInBackup() {
+		if cluster.IsInBackup() || !cluster.TryInBinlogBackupState() {
 			cluster.SetState("WARN0110", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(cluster.GetErrorList()["WARN0110"], "Binary Log", cluster.Conf.BinlogCopyMode, server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
 			time.Sleep(1 * time.Second)
 			return cluster.BinlogCopyScript(server, binlog, isPurge)
 		}
 
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Initiating backup binlog for %s", binlog)
-		cluster.SetInBinlogBackupState(true)
 		defer cluster.SetInBinlogBackupState(false)
 	}
 
@@ -174,7 +177,7 @@ func (cluster *Cluster) BinlogCopyScript(server *ServerMonitor, binlog string, i
 		out, err := exec.Command(cluster.Conf.BinlogCopyScript, cluster.Name, server.Host, server.Port, strconv.Itoa(cluster.Conf.OnPremiseSSHPort), server.BinaryLogDir, server.GetMyBackupDirectory(), binlog).CombinedOutput()
 		if err != nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "ERROR", "%s", err)
-		} else {
+		} else if err = server.EncryptBinlogBackup(binlog); err == nil {
 			// Skip backup to restic if in purge binlog
 			if !isPurge {
 				if idx := slices.Index(server.BinaryLogMetaToWrite, binlog); idx == -1 {
@@ -0,0 +1,499 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+// binlogArchiver streams the binary logs of the master to its backup
+// directory through a replica connection
+type binlogArchiver struct {
+	sync.Mutex
+	status config.BinlogArchiverStatus
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// GetBinlogArchiverStatus returns the position and lag of the binary logs archiver
+func (cluster *Cluster) GetBinlogArchiverStatus() config.BinlogArchiverStatus {
+	a := &cluster.binlogArchiver
+	a.Lock()
+	defer a.Unlock()
+	return a.status
+}
+
+func (cluster *Cluster) setBinlogArchiverStatus(f func(status *config.BinlogArchiverStatus)) {
+	a := &cluster.binlogArchiver
+	a.Lock()
+	f(&a.status)
+	a.Unlock()
+}
+
+// IsBinlogArchived tells if the binary logs of a server are streamed by the
+// archiver, the periodic copy of the binary logs is then skipped
+func (cluster *Cluster) IsBinlogArchived(server *ServerMonitor) bool {
+	status := cluster.GetBinlogArchiverStatus()
+	return status.Running && status.Source == server.URL
+}
+
+// CheckBinlogArchiver starts or stops the archiver following backup-binlogs-archiver
+func (cluster *Cluster) CheckBinlogArchiver() {
+	a := &cluster.binlogArchiver
+	a.Lock()
+	running := a.stop != nil
+	a.Unlock()
+	if cluster.Conf.BackupBinlogsArchiver && !running {
+		cluster.StartBinlogArchiver()
+	} else if !cluster.Conf.BackupBinlogsArchiver && running {
+		cluster.StopBinlogArchiver()
+	}
+}
+
+// StartBinlogArchiver starts streaming the binary logs of the master from the
+// position saved by the last run
+func (cluster *Cluster) StartBinlogArchiver() {
+	a := &cluster.binlogArchiver
+	a.Lock()
+	defer a.Unlock()
+	if a.stop != nil {
+		return
+	}
+	if a.done != nil {
+		select {
+		case <-a.done:
+		default:
+			// The previous run is still stopping
+			return
+		}
+	}
+	if content, err := os.ReadFile(cluster.getBinlogArchiverStateFile()); err == nil {
+		if err := json.Unmarshal(content, &a.status); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to read binary logs archiver state: %s", err)
+		}
+	}
+	a.status.Running = true
+	a.status.Error = ""
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Starting binary logs archiver")
+	go cluster.runBinlogArchiver(a.stop, a.done)
+}
+
+// StopBinlogArchiver stops the archiver and waits for the current binary log
+// to be flushed
+func (cluster *Cluster) StopBinlogArchiver() {
+	a := &cluster.binlogArchiver
+	a.Lock()
+	stop, done := a.stop, a.done
+	a.stop = nil
+	a.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Stopped binary logs archiver")
+}
+
+func (cluster *Cluster) getBinlogArchiverStateFile() string {
+	return cluster.WorkingDir + "/binlog-archiver.json"
+}
+
+func (cluster *Cluster) saveBinlogArchiverState() error {
+	status := cluster.GetBinlogArchiverStatus()
+	content, err := json.MarshalIndent(status, "", "\t")
+	if err != nil {
+		return err
+	}
+	fname := cluster.getBinlogArchiverStateFile()
+	if err := os.WriteFile(fname+".tmp", content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(fname+".tmp", fname)
+}
+
+func (cluster *Cluster) runBinlogArchiver(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	defer cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) { status.Running = false })
+
+	for {
+		master := cluster.GetMaster()
+		if master != nil && !master.IsDown() && !cluster.IsInFailover() {
+			err := cluster.archiveBinlogs(master, stop)
+			if err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Binary logs archiver error on %s: %s", master.URL, err)
+				cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) { status.Error = err.Error() })
+			}
+			cluster.saveBinlogArchiverState()
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Duration(cluster.Conf.MonitoringTicker) * time.Second):
+		}
+	}
+}
+
+func (cluster *Cluster) getBinlogArchiverSyncerConfig(master *ServerMonitor) replication.BinlogSyncerConfig {
+	port, _ := strconv.Atoi(master.Port)
+	cfg := replication.BinlogSyncerConfig{
+		ServerID:             uint32(cluster.Conf.BackupBinlogsArchiverServerId),
+		Flavor:               mysql.MySQLFlavor,
+		Host:                 master.Host,
+		Port:                 uint16(port),
+		User:                 cluster.GetRplUser(),
+		Password:             cluster.GetRplPass(),
+		HeartbeatPeriod:      time.Second,
+		MaxReconnectAttempts: 3,
+	}
+	if master.IsMariaDB() {
+		cfg.Flavor = mysql.MariaDBFlavor
+	}
+	if cluster.HaveDBTLSCert {
+		cfg.TLSConfig = cluster.tlsconf
+	}
+	return cfg
+}
+
+// archiveBinlogs streams the binary logs of the master until the master
+// changes, an error or the stop of the archiver. The stream resumes at the
+// saved position on the same master, on a new master it starts at the binary
+// log holding the transactions following the saved GTID set.
+func (cluster *Cluster) archiveBinlogs(master *ServerMonitor, stop chan struct{}) error {
+	cfg := cluster.getBinlogArchiverSyncerConfig(master)
+	status := cluster.GetBinlogArchiverStatus()
+	gset, err := mysql.ParseGTIDSet(cfg.Flavor, status.Gtid)
+	if err != nil {
+		gset, _ = mysql.ParseGTIDSet(cfg.Flavor, "")
+		status.Gtid = ""
+	}
+
+	pos := mysql.Position{Name: master.BinaryLogFile, Pos: 4}
+	switch {
+	case status.Source == master.URL && status.File != "":
+		pos = mysql.Position{Name: status.File, Pos: status.Position}
+		info, err := os.Stat(master.GetMyBackupDirectory() + status.File)
+		if err != nil || pos.Pos < 4 || info.Size() < int64(pos.Pos) {
+			// Archive the whole binary log again
+			pos.Pos = 4
+		}
+	case status.Gtid != "":
+		name, err := cluster.locateBinlogArchiverGtid(cfg, gset)
+		if err != nil {
+			return fmt.Errorf("Failed to find the binary log following GTID %s: %s", status.Gtid, err)
+		}
+		pos.Name = name
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Binary logs archiver following %s from %s after GTID %s", master.URL, name, status.Gtid)
+	}
+	if pos.Name == "" {
+		return errors.New("No binary log on master")
+	}
+
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(pos)
+	if err != nil {
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Binary logs archiver streaming %s from %s pos: %d", master.URL, pos.Name, pos.Pos)
+
+	w := &binlogArchiveWriter{cluster: cluster, server: master, name: pos.Name, pos: pos.Pos, gset: gset}
+	defer w.close()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if m := cluster.GetMaster(); m == nil || m.URL != master.URL {
+			// The binary log is not complete, it is not encrypted, uploaded
+			// nor published
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Binary logs archiver master changed, leaving %s of %s incomplete", w.name, master.URL)
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ev, err := streamer.GetEvent(ctx)
+		cancel()
+		if err == context.DeadlineExceeded {
+			w.flush()
+			continue
+		}
+		if err != nil {
+			var myerr *mysql.MyError
+			if errors.As(err, &myerr) && myerr.Code == mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG {
+				// The binary log is purged, start again from the GTID set or the current binary log
+				cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) {
+					status.Source = ""
+					status.File = ""
+				})
+			}
+			return err
+		}
+		if err := w.handle(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// locateBinlogArchiverGtid returns the binary log the master sends first to
+// a replica at a GTID set
+func (cluster *Cluster) locateBinlogArchiverGtid(cfg replication.BinlogSyncerConfig, gset mysql.GTIDSet) (string, error) {
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+	streamer, err := syncer.StartSyncGTID(gset.Clone())
+	if err != nil {
+		return "", err
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ev, err := streamer.GetEvent(ctx)
+		cancel()
+		if err != nil {
+			return "", err
+		}
+		if rotate, ok := ev.Event.(*replication.RotateEvent); ok {
+			return string(rotate.NextLogName), nil
+		}
+	}
+}
+
+// binlogArchiveWriter writes the events received to the binary log files of
+// the backup directory of the master
+type binlogArchiveWriter struct {
+	cluster  *Cluster
+	server   *ServerMonitor
+	name     string
+	pos      uint32
+	start    int64
+	file     *os.File
+	gset     mysql.GTIDSet
+	lastSave time.Time
+}
+
+func (w *binlogArchiveWriter) path() string {
+	return w.server.GetMyBackupDirectory() + w.name
+}
+
+func (w *binlogArchiveWriter) handle(ev *replication.BinlogEvent) error {
+	h := ev.Header
+	switch h.EventType {
+	case replication.HEARTBEAT_EVENT:
+		// The master has nothing more to send
+		w.cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) { status.LastEventTime = time.Now() })
+		return nil
+	case replication.ROTATE_EVENT:
+		rotate := ev.Event.(*replication.RotateEvent)
+		next := string(rotate.NextLogName)
+		if h.Timestamp == 0 || h.LogPos == 0 {
+			// Fake rotate sent at the start of the stream
+			if next != w.name {
+				if err := w.finish(); err != nil {
+					return err
+				}
+				w.name, w.pos = next, uint32(rotate.Position)
+			}
+			return nil
+		}
+		if err := w.append(ev); err != nil {
+			return err
+		}
+		if err := w.finish(); err != nil {
+			return err
+		}
+		w.name, w.pos = next, 4
+		w.cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) {
+			status.File = next
+			status.Position = 4
+		})
+		return nil
+	case replication.FORMAT_DESCRIPTION_EVENT:
+		if h.LogPos == 0 {
+			// Format sent again when the stream starts within the binary log
+			return w.reopen()
+		}
+		if err := w.create(int64(h.Timestamp)); err != nil {
+			return err
+		}
+	}
+
+	switch e := ev.Event.(type) {
+	case *replication.PreviousGTIDsEvent:
+		if gset, err := mysql.ParseMysqlGTIDSet(e.GTIDSets); err == nil {
+			w.gset = gset
+		}
+	case *replication.MariadbGTIDListEvent:
+		gtids := make([]string, 0, len(e.GTIDs))
+		for i := range e.GTIDs {
+			gtids = append(gtids, e.GTIDs[i].String())
+		}
+		if gset, err := mysql.ParseMariadbGTIDSet(strings.Join(gtids, ",")); err == nil {
+			w.gset = gset
+		}
+	case *replication.GTIDEvent:
+		if sid, err := uuid.FromBytes(e.SID); err == nil && w.gset != nil {
+			w.gset.Update(sid.String() + ":" + strconv.FormatInt(e.GNO, 10))
+		}
+	case *replication.MariadbGTIDEvent:
+		if w.gset != nil {
+			w.gset.Update(e.GTID.String())
+		}
+	}
+	return w.append(ev)
+}
+
+func (w *binlogArchiveWriter) append(ev *replication.BinlogEvent) error {
+	if w.file == nil {
+		return fmt.Errorf("Binary log event before the format description of %s", w.name)
+	}
+	if _, err := w.file.Write(ev.RawData); err != nil {
+		return err
+	}
+	if ev.Header.LogPos > 0 {
+		w.pos = ev.Header.LogPos
+	}
+	gtid := ""
+	if w.gset != nil {
+		gtid = w.gset.String()
+	}
+	w.cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) {
+		status.Source = w.server.URL
+		status.File = w.name
+		status.Position = w.pos
+		status.Gtid = gtid
+		status.Bytes += int64(len(ev.RawData))
+		status.Error = ""
+		if ev.Header.Timestamp > 0 {
+			status.LastEventTime = time.Unix(int64(ev.Header.Timestamp), 0)
+		}
+	})
+	if time.Since(w.lastSave) > time.Second {
+		w.flush()
+	}
+	return nil
+}
+
+// flush syncs the binary log being written and saves the archiver position
+func (w *binlogArchiveWriter) flush() {
+	if w.file != nil {
+		w.file.Sync()
+	}
+	w.cluster.saveBinlogArchiverState()
+	w.lastSave = time.Now()
+}
+
+// create starts a new binary log file
+func (w *binlogArchiveWriter) create(start int64) error {
+	if w.file != nil {
+		w.close()
+	}
+	file, err := os.OpenFile(w.path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(replication.BinLogFileHeader); err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.start = file, start
+	w.cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) { status.FileStart = start })
+	w.cluster.LogModulePrintf(w.cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Binary logs archiver writing %s of %s", w.name, w.server.URL)
+	return nil
+}
+
+// reopen appends to the binary log file after the last complete event
+func (w *binlogArchiveWriter) reopen() error {
+	if w.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(w.path(), os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(int64(w.pos)); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.start = w.cluster.GetBinlogArchiverStatus().FileStart
+	return nil
+}
+
+// close closes the binary log file being written, the next run resumes it
+func (w *binlogArchiveWriter) close() {
+	if w.file == nil {
+		return
+	}
+	w.flush()
+	w.file.Close()
+	w.file = nil
+}
+
+// finish closes a complete binary log, encrypts, uploads and publishes it
+// like the binary logs copied at rotation, in the binary logs backup state of
+// the copy and purge jobs
+func (w *binlogArchiveWriter) finish() error {
+	if w.file == nil {
+		return nil
+	}
+	w.close()
+	cluster := w.cluster
+	for !cluster.TryInBinlogBackupState() {
+		time.Sleep(time.Second)
+	}
+	defer cluster.SetInBinlogBackupState(false)
+	// A complete binary log is never resumed
+	w.cluster.setBinlogArchiverStatus(func(status *config.BinlogArchiverStatus) { status.File = "" })
+	if err := w.server.EncryptBinlogBackup(w.name); err != nil {
+		return err
+	}
+	w.cluster.UploadBackupFile(w.path())
+	w.writeMeta()
+	w.cluster.LogModulePrintf(w.cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Binary logs archiver archived %s of %s", w.name, w.server.URL)
+	return nil
+}
+
+// writeMeta records a complete binary log in the binary logs backup metadata
+func (w *binlogArchiveWriter) writeMeta() {
+	server := w.server
+	binlog, ok := server.BinaryLogFiles.CheckAndGet(w.name)
+	if !ok {
+		binlog = &dbhelper.BinaryLogMetadata{Source: server.URL, Filename: w.name}
+		server.BinaryLogFiles.Set(w.name, binlog)
+	}
+	if binlog.Start == 0 {
+		binlog.Start = w.start
+	}
+	if idx := slices.Index(server.BinaryLogMetaToWrite, w.name); idx == -1 {
+		server.BinaryLogMetaToWrite = append(server.BinaryLogMetaToWrite, w.name)
+	}
+	server.WriteBackupBinlogMetadata()
+}
@@ -0,0 +1,364 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/crypto"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+const resticPinnedTag = "pinned"
+
+// GetBackupCatalog returns the logical, physical and binary log backups of all
+// servers and the restic snapshots of the cluster, the newest first. The
+// retention classes are the ones of the retention policy with backup-retention.
+func (cluster *Cluster) GetBackupCatalog() []*config.BackupCatalogEntry {
+	if cluster.Conf.BackupRetention {
+		return cluster.GetBackupRetentionPlan()
+	}
+	return cluster.getBackupCatalog()
+}
+
+func (cluster *Cluster) getBackupCatalog() []*config.BackupCatalogEntry {
+	entries := make([]*config.BackupCatalogEntry, 0)
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		entries = append(entries, backup.GetCatalogEntry())
+		return true
+	})
+
+	for _, server := range cluster.Servers {
+		binlogs, err := server.ReadBackupBinlogMetadata()
+		if err != nil {
+			continue
+		}
+		for _, binlog := range binlogs {
+			dest := server.GetMyBackupDirectory() + binlog.Filename
+			entries = append(entries, &config.BackupCatalogEntry{
+				Id:             "binlog-" + server.Id + "-" + binlog.Filename,
+				Type:           config.BackupCatalogBinlog,
+				Tool:           cluster.Conf.BinlogCopyMode,
+				Source:         binlog.Source,
+				Dest:           dest,
+				StartTime:      time.Unix(binlog.Start, 0),
+				Size:           int64(binlog.Size),
+				BinLogFileName: binlog.Filename,
+				Encrypted:      crypto.IsEncryptedFile(dest),
+				Completed:      true,
+				RetentionClass: config.BackupRetentionBinlogKeep,
+			})
+		}
+	}
+
+	for i := range cluster.Backups {
+		snapshot := &cluster.Backups[i]
+		entry := &config.BackupCatalogEntry{
+			Id:             "restic-" + snapshot.ShortId,
+			Type:           config.BackupCatalogRestic,
+			Tool:           "restic",
+			Source:         snapshot.Hostname,
+			Dest:           strings.Join(snapshot.Paths, ","),
+			Completed:      true,
+			Pinned:         slices.Contains(snapshot.Tags, resticPinnedTag),
+			RetentionClass: config.BackupRetentionRestic,
+		}
+		if start, err := time.Parse(time.RFC3339Nano, snapshot.Time); err == nil {
+			entry.StartTime = start
+		}
+		if entry.Pinned {
+			entry.RetentionClass = config.BackupRetentionPinned
+		}
+		entries = append(entries, entry)
+	}
+
+	config.SortBackupCatalog(entries)
+	return entries
+}
+
+// GetBackupCatalogEntry returns the catalog entry of a backup id
+func (cluster *Cluster) GetBackupCatalogEntry(id string) (*config.BackupCatalogEntry, error) {
+	for _, entry := range cluster.GetBackupCatalog() {
+		if entry.Id == id {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("Backup %s not found in catalog", id)
+}
+
+// getCatalogBackupMeta returns the metadata and the source server of a logical
+// or physical backup of the catalog
+func (cluster *Cluster) getCatalogBackupMeta(entry *config.BackupCatalogEntry) (*config.BackupMetadata, *ServerMonitor, error) {
+	if entry.Type != config.BackupCatalogLogical && entry.Type != config.BackupCatalogPhysical {
+		return nil, nil, fmt.Errorf("Backup %s is a %s backup", entry.Id, entry.Type)
+	}
+	id, err := strconv.ParseInt(entry.Id, 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := cluster.BackupMetaMap.Get(id)
+	if meta == nil {
+		return nil, nil, fmt.Errorf("Backup %s not found in catalog", entry.Id)
+	}
+	server := cluster.GetServerFromURL(meta.Source)
+	if server == nil {
+		return nil, nil, fmt.Errorf("Source server %s of backup %s not found", meta.Source, entry.Id)
+	}
+	return meta, server, nil
+}
+
+// PinBackup exempts a backup from the purges. The files of a logical or
+// physical backup and of the backups it chains to are moved out of the
+// rotation, a restic snapshot is tagged to be kept by restic forget.
+func (cluster *Cluster) PinBackup(id string) error {
+	entry, err := cluster.GetBackupCatalogEntry(id)
+	if err != nil {
+		return err
+	}
+	switch entry.Type {
+	case config.BackupCatalogRestic:
+		err = cluster.ResticRunCommand("tag", "--add", resticPinnedTag, strings.TrimPrefix(entry.Id, "restic-"))
+		if err == nil {
+			cluster.ResticFetchRepo()
+		}
+	case config.BackupCatalogBinlog:
+		err = errors.New("Binary logs can not be pinned, they are kept by backup-binlogs-keep")
+	default:
+		var meta *config.BackupMetadata
+		meta, _, err = cluster.getCatalogBackupMeta(entry)
+		if err != nil {
+			return err
+		}
+		var chain []*config.BackupMetadata
+		chain, err = cluster.BackupMetaMap.GetBackupChain(meta.Id)
+		if err != nil {
+			return err
+		}
+		for _, backup := range chain {
+			if backup.Pinned {
+				continue
+			}
+			server := cluster.GetServerFromURL(backup.Source)
+			if server == nil {
+				return fmt.Errorf("Source server %s of backup %d not found", backup.Source, backup.Id)
+			}
+			if err = server.PinBackup(backup); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to pin backup %s: %s", id, err)
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Pinned backup %s", id)
+	return nil
+}
+
+// PinBackup moves the files of a backup to its pinned directory
+func (server *ServerMonitor) PinBackup(meta *config.BackupMetadata) error {
+	meta.Pinned = true
+	if err := server.MoveBackup(meta, server.GetBackupPinnedDirectory(meta.Id)); err != nil {
+		meta.Pinned = false
+		return err
+	}
+	if meta == server.LastBackupMeta.Logical || meta == server.LastBackupMeta.Physical {
+		server.DelBackupTypeCookie(meta.BackupTool)
+	}
+	return nil
+}
+
+// UnpinBackup returns a backup to the purges, the files of a logical or
+// physical backup stay in the pinned directory until the backup is deleted
+func (cluster *Cluster) UnpinBackup(id string) error {
+	entry, err := cluster.GetBackupCatalogEntry(id)
+	if err != nil {
+		return err
+	}
+	switch entry.Type {
+	case config.BackupCatalogRestic:
+		err = cluster.ResticRunCommand("tag", "--remove", resticPinnedTag, strings.TrimPrefix(entry.Id, "restic-"))
+		if err == nil {
+			cluster.ResticFetchRepo()
+		}
+	case config.BackupCatalogBinlog:
+		err = errors.New("Binary logs can not be pinned, they are kept by backup-binlogs-keep")
+	default:
+		var meta *config.BackupMetadata
+		var server *ServerMonitor
+		meta, server, err = cluster.getCatalogBackupMeta(entry)
+		if err != nil {
+			return err
+		}
+		for _, backup := range cluster.BackupMetaMap.GetDependentBackups(meta.Id) {
+			if backup.Pinned {
+				return fmt.Errorf("Pinned backup %d chains to backup %s", backup.Id, id)
+			}
+		}
+		meta.Pinned = false
+		err = server.SaveBackupMetadata(meta)
+	}
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to unpin backup %s: %s", id, err)
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Unpinned backup %s", id)
+	return nil
+}
+
+// DeleteBackup removes a backup of the catalog, pinned backups and backups
+// other backups chain to are refused
+func (cluster *Cluster) DeleteBackup(id string) error {
+	entry, err := cluster.GetBackupCatalogEntry(id)
+	if err != nil {
+		return err
+	}
+	if entry.Pinned {
+		return fmt.Errorf("Backup %s is pinned", id)
+	}
+	switch entry.Type {
+	case config.BackupCatalogRestic:
+		err = cluster.ResticRunCommand("forget", strings.TrimPrefix(entry.Id, "restic-"))
+		if err == nil {
+			cluster.ResticFetchRepo()
+		}
+	case config.BackupCatalogBinlog:
+		err = cluster.deleteBinlogBackup(entry)
+	default:
+		err = cluster.deleteBackupMeta(entry)
+	}
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to delete backup %s: %s", id, err)
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Deleted backup %s %s %s", id, entry.Tool, entry.Dest)
+	return nil
+}
+
+func (cluster *Cluster) deleteBackupMeta(entry *config.BackupCatalogEntry) error {
+	meta, server, err := cluster.getCatalogBackupMeta(entry)
+	if err != nil {
+		return err
+	}
+	if !meta.Completed && cluster.IsInBackup() {
+		return fmt.Errorf("Backup %s is running", entry.Id)
+	}
+	if deps := cluster.BackupMetaMap.GetDependentBackups(meta.Id); len(deps) > 0 {
+		return fmt.Errorf("Backup %d chains to backup %s", deps[0].Id, entry.Id)
+	}
+	metafile := server.GetBackupMetaFile(meta)
+	if err := os.RemoveAll(meta.Dest); err != nil {
+		return err
+	}
+	os.Remove(metafile)
+	if meta.Archived {
+		os.Remove(filepath.Dir(metafile))
+	}
+	cluster.BackupMetaMap.Delete(meta.Id)
+	if meta == server.LastBackupMeta.Logical {
+		server.LastBackupMeta.Logical = nil
+		server.DelBackupTypeCookie(meta.BackupTool)
+	}
+	if meta == server.LastBackupMeta.Physical {
+		server.LastBackupMeta.Physical = nil
+		server.DelBackupTypeCookie(meta.BackupTool)
+	}
+	return nil
+}
+
+func (cluster *Cluster) deleteBinlogBackup(entry *config.BackupCatalogEntry) error {
+	for _, server := range cluster.Servers {
+		if entry.Id != "binlog-"+server.Id+"-"+entry.BinLogFileName {
+			continue
+		}
+		if err := os.Remove(entry.Dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		server.BinaryLogMetaToRemove = append(server.BinaryLogMetaToRemove, entry.BinLogFileName)
+		server.WriteBackupBinlogMetadata()
+		return nil
+	}
+	return fmt.Errorf("Server of binary log backup %s not found", entry.Id)
+}
+
+// RestoreBackup reseeds a server with a logical or physical backup of the
+// catalog, the restore runs in the background as a point in time recovery
+// without binary logs
+func (cluster *Cluster) RestoreBackup(id string, server *ServerMonitor) error {
+	entry, err := cluster.GetBackupCatalogEntry(id)
+	if err != nil {
+		return err
+	}
+	meta, _, err := cluster.getCatalogBackupMeta(entry)
+	if err != nil {
+		return err
+	}
+	if !meta.Completed {
+		return fmt.Errorf("Backup %s is not completed", id)
+	}
+	if meta.IsPartial() {
+		return fmt.Errorf("Backup %s is a schema backup of %s", id, meta.GetScope())
+	}
+	if server.IsMaster() {
+		return fmt.Errorf("Refusing to restore backup %s on master %s", id, server.URL)
+	}
+	if !cluster.Conf.MonitorScheduler {
+		return fmt.Errorf("Restore on %s can not continue without monitoring scheduler", server.URL)
+	}
+	if server.HasAnyReseedingState() {
+		return fmt.Errorf("Server is in reseeding state by %s", server.IsReseeding)
+	}
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Restoring backup %s %s on %s", id, meta.BackupTool, server.URL)
+	go func() {
+		if err := server.ReseedPointInTime(config.PointInTimeMeta{IsInPITR: true, Backup: meta.Id}); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Restore of backup %s on %s failed: %s", id, server.URL, err)
+			return
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Restore of backup %s on %s finished", id, server.URL)
+	}()
+	return nil
+}
+
+// ReadBackupBinlogMetadata reads the metadata of the binary logs copied to the
+// backup directory of the server
+func (server *ServerMonitor) ReadBackupBinlogMetadata() (map[string]dbhelper.BinaryLogMetadata, error) {
+	content, err := os.ReadFile(server.GetMyBackupDirectory() + "binary-logs.meta.json")
+	if err != nil {
+		return nil, err
+	}
+	meta := make(map[string]dbhelper.BinaryLogMetadata)
+	err = json.Unmarshal(content, &meta)
+	return meta, err
+}
+
+// ResticRunCommand runs a restic command on the repository of the cluster
+func (cluster *Cluster) ResticRunCommand(args ...string) error {
+	if !cluster.Conf.BackupRestic {
+		return errors.New("Restic backup is disabled")
+	}
+	resticcmd := exec.Command(cluster.Conf.BackupResticBinaryPath, args...)
+	resticcmd.Env = cluster.ResticGetEnv()
+	out, err := resticcmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("restic %s: %s %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/crypto"
+	"github.com/signal18/replication-manager/utils/misc"
+	"github.com/signal18/replication-manager/utils/version"
+)
+
+// backupEncryptExtension is appended by the tools encrypting file by file and
+// removed when the backup is decrypted
+const backupEncryptExtension = ".enc"
+
+type backupFileReader struct {
+	io.Reader
+	io.Closer
+}
+
+// NewBackupDataKey returns the key encrypting a new backup when backup-encrypt
+// is enabled, nil otherwise
+func (cluster *Cluster) NewBackupDataKey() (*crypto.DataKey, error) {
+	if !cluster.Conf.BackupEncrypt {
+		return nil, nil
+	}
+	master, err := cluster.Conf.GetBackupMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewDataKey(master)
+}
+
+// SetBackupEncryption records the wrapped data key in the backup metadata
+func (cluster *Cluster) SetBackupEncryption(meta *config.BackupMetadata, key *crypto.DataKey) {
+	if meta == nil || key == nil {
+		return
+	}
+	meta.Encrypted = true
+	meta.EncryptionAlgo = crypto.StreamAlgo
+	meta.EncryptionKey = key.Wrapped
+}
+
+// OpenBackupFile opens a backup file for reading, an encrypted file is
+// decrypted with the master key found in the configuration
+func (cluster *Cluster) OpenBackupFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(file, crypto.StreamChunkSize)
+	if !crypto.IsEncryptedStream(br) {
+		return backupFileReader{Reader: br, Closer: file}, nil
+	}
+	master, err := cluster.Conf.GetBackupMasterKey()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	dr, err := crypto.NewDecryptReader(br, master)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return backupFileReader{Reader: dr, Closer: file}, nil
+}
+
+// EncryptBackupFile encrypts in place a backup file written in clear by an
+// external tool
+func (cluster *Cluster) EncryptBackupFile(path string, key *crypto.DataKey) error {
+	if key == nil || crypto.IsEncryptedFile(path) {
+		return nil
+	}
+	return crypto.EncryptFile(path, key)
+}
+
+// GetMyDumperEncryption returns the mydumper arguments and environment making
+// its threads encrypt each file with the data key as it is written, so no
+// clear file stays in the backup directory
+func (cluster *Cluster) GetMyDumperEncryption(dumper *version.Version, key *crypto.DataKey) ([]string, []string, error) {
+	if key == nil {
+		return nil, nil, nil
+	}
+	if dumper.Lower("0.13.1") {
+		return nil, nil, fmt.Errorf("Encrypted mydumper backups need mydumper 0.13.1 or later, found %s", dumper.ToString())
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+	args := []string{"--exec-per-thread", exe + " backup-encrypt", "--exec-per-thread-extension", backupEncryptExtension}
+	return args, append(os.Environ(), crypto.DataKeyEnv+"="+crypto.EncodeDataKey(key)), nil
+}
+
+// EncryptBackupDir encrypts in place the files of a backup directory
+func (cluster *Cluster) EncryptBackupDir(dir string, key *crypto.DataKey) error {
+	if key == nil {
+		return nil
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.Type().IsRegular() {
+			continue
+		}
+		if err := cluster.EncryptBackupFile(filepath.Join(dir, file.Name()), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetClearBackupDir returns a directory the loaders can read. An encrypted
+// backup directory is decrypted in the working directory and the returned
+// function removes the copy.
+func (cluster *Cluster) GetClearBackupDir(dir string) (string, func(), error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return dir, func() {}, err
+	}
+	encrypted := false
+	for _, file := range files {
+		if file.Type().IsRegular() && crypto.IsEncryptedFile(filepath.Join(dir, file.Name())) {
+			encrypted = true
+			break
+		}
+	}
+	if !encrypted {
+		return dir, func() {}, nil
+	}
+
+	master, err := cluster.Conf.GetBackupMasterKey()
+	if err != nil {
+		return dir, func() {}, err
+	}
+	cleardir := cluster.WorkingDir + "/decrypt-" + filepath.Base(dir) + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	cleanup := func() { os.RemoveAll(cleardir) }
+	if err := os.MkdirAll(cleardir, 0700); err != nil {
+		return dir, func() {}, err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Decrypting backup %s to %s", dir, cleardir)
+	for _, file := range files {
+		if !file.Type().IsRegular() {
+			continue
+		}
+		src := filepath.Join(dir, file.Name())
+		if crypto.IsEncryptedFile(src) {
+			err = crypto.DecryptFile(src, filepath.Join(cleardir, strings.TrimSuffix(file.Name(), backupEncryptExtension)), master)
+		} else {
+			err = misc.CopyFile(src, filepath.Join(cleardir, file.Name()))
+		}
+		if err != nil {
+			cleanup()
+			return dir, func() {}, err
+		}
+	}
+	return cleardir, cleanup, nil
+}
+
+// GetClearBackupFile returns a backup file the tools can read. An encrypted
+// file is decrypted in the working directory and the returned function
+// removes the copy.
+func (cluster *Cluster) GetClearBackupFile(path string) (string, func(), error) {
+	if !crypto.IsEncryptedFile(path) {
+		return path, func() {}, nil
+	}
+	master, err := cluster.Conf.GetBackupMasterKey()
+	if err != nil {
+		return path, func() {}, err
+	}
+	clearfile := cluster.WorkingDir + "/decrypt-" + filepath.Base(path) + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	cleanup := func() { os.Remove(clearfile) }
+	if err := crypto.DecryptFile(path, clearfile, master); err != nil {
+		cleanup()
+		return path, func() {}, err
+	}
+	return clearfile, cleanup, nil
+}
+
+// EncryptBinlogBackup encrypts a binary log copied to the backup directory,
+// the copy is removed when it can not be encrypted
+func (server *ServerMonitor) EncryptBinlogBackup(binlogfile string) error {
+	cluster := server.ClusterGroup
+	localfile := server.GetMyBackupDirectory() + "/" + binlogfile
+	key, err := cluster.NewBackupDataKey()
+	if err == nil {
+		err = cluster.EncryptBackupFile(localfile, key)
+	}
+	if err != nil {
+		os.Remove(localfile)
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to encrypt binlog backup %s of %s: %s", binlogfile, server.URL, err)
+	}
+	return err
+}
@@ -0,0 +1,469 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/signal18/replication-manager/config"
+)
+
+// GetPointInTimeJob returns the state of the last point in time recovery job
+func (cluster *Cluster) GetPointInTimeJob() *config.PointInTimeJob {
+	cluster.pitrLock.Lock()
+	defer cluster.pitrLock.Unlock()
+	if cluster.pitrJob == nil {
+		return nil
+	}
+	job := *cluster.pitrJob
+	return &job
+}
+
+// JobPointInTimeRecovery resolves the base backup and the binary logs of a
+// point in time recovery and runs the restore in the background
+func (cluster *Cluster) JobPointInTimeRecovery(req config.PointInTimeRequest) (*config.PointInTimeJob, error) {
+	plan, err := cluster.GetPointInTimePlan(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var server *ServerMonitor
+	if !req.Standalone {
+		server = cluster.GetServerFromName(req.Server)
+		if server == nil {
+			server = cluster.GetServerFromURL(req.Server)
+		}
+		if server == nil {
+			return nil, fmt.Errorf("Server %s not found", req.Server)
+		}
+		if server.IsMaster() {
+			return nil, fmt.Errorf("Refusing point in time recovery on master %s", server.URL)
+		}
+		if !cluster.Conf.MonitorScheduler {
+			return nil, fmt.Errorf("Point in time recovery on %s can not continue without monitoring scheduler", server.URL)
+		}
+		if server.HasAnyReseedingState() {
+			return nil, fmt.Errorf("Server is in reseeding state by %s", server.IsReseeding)
+		}
+	}
+
+	cluster.pitrLock.Lock()
+	defer cluster.pitrLock.Unlock()
+	if cluster.pitrJob != nil && cluster.pitrJob.State == config.PointInTimeJobRunning {
+		return nil, errors.New("Point in time recovery already running")
+	}
+	if req.Standalone && cluster.pitrStandalone != nil {
+		return nil, errors.New("Standalone point in time recovery instance already running")
+	}
+	cluster.pitrJob = &config.PointInTimeJob{Request: req, Plan: plan, State: config.PointInTimeJobRunning, StartTime: time.Now()}
+	job := *cluster.pitrJob
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Point in time recovery from backup %d of %s until %s pos: %d", plan.Backup, plan.Source, plan.Stop.Filename, plan.Stop.Position)
+	go func() {
+		var target string
+		var err error
+		if req.Standalone {
+			target, err = cluster.restorePointInTimeStandalone(plan)
+		} else {
+			target = server.URL
+			err = cluster.restorePointInTimeServer(plan, server)
+		}
+
+		cluster.pitrLock.Lock()
+		defer cluster.pitrLock.Unlock()
+		cluster.pitrJob.Target = target
+		cluster.pitrJob.EndTime = time.Now()
+		if err != nil {
+			cluster.pitrJob.State = config.PointInTimeJobFailed
+			cluster.pitrJob.Error = err.Error()
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Point in time recovery failed: %s", err)
+			return
+		}
+		cluster.pitrJob.State = config.PointInTimeJobDone
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Point in time recovery to %s finished", target)
+	}()
+	return &job, nil
+}
+
+// GetPointInTimePlan returns the base backup and the archived binary logs to
+// replay for a point in time recovery request
+func (cluster *Cluster) GetPointInTimePlan(req config.PointInTimeRequest) (*config.PointInTimePlan, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	source := cluster.GetMaster()
+	if req.Source != "" {
+		source = cluster.GetServerFromName(req.Source)
+		if source == nil {
+			source = cluster.GetServerFromURL(req.Source)
+		}
+	}
+	if source == nil {
+		return nil, errors.New("Point in time recovery source server not found")
+	}
+
+	backups := make([]*config.BackupMetadata, 0)
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		if (req.Backup == 0 || backup.Id == req.Backup) && backup.Completed && !backup.IsPartial() && backup.Source == source.URL && binlogSequence(backup.BinLogFileName) >= 0 {
+			backups = append(backups, backup)
+		}
+		return true
+	})
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("No completed backup of %s with binary log coordinates", source.URL)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return compareBinlogPosition(backups[i].BinLogFileName, int64(backups[i].BinLogFilePos), backups[j].BinLogFileName, int64(backups[j].BinLogFilePos)) < 0
+	})
+
+	binlogs, err := source.getArchivedBinlogs()
+	if err != nil {
+		return nil, err
+	}
+	stop, err := source.findPointInTimeStop(binlogs, backups[0].BinLogFileName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup *config.BackupMetadata
+	for _, b := range backups {
+		if stop.Position > 0 && compareBinlogPosition(b.BinLogFileName, int64(b.BinLogFilePos), stop.Filename, stop.Position) > 0 {
+			break
+		}
+		if stop.Position == 0 && binlogSequence(b.BinLogFileName) > binlogSequence(stop.Filename) {
+			break
+		}
+		backup = b
+	}
+	if backup == nil {
+		return nil, fmt.Errorf("No backup of %s taken before %s pos: %d", source.URL, stop.Filename, stop.Position)
+	}
+
+	plan := &config.PointInTimePlan{
+		Backup:     backup.Id,
+		BackupTool: backup.BackupTool,
+		Source:     source.URL,
+		Binlogs:    make([]string, 0),
+		Start:      config.ReadBinaryLogsBoundary{Filename: backup.BinLogFileName, Position: int64(backup.BinLogFilePos)},
+		Stop:       stop,
+	}
+	// The binary log base name can hold dots and the sequence more than 6 digits
+	basename := binlogBasename(backup.BinLogFileName)
+	archived := make(map[int]string)
+	for _, name := range binlogs {
+		if binlogBasename(name) == basename {
+			archived[binlogSequence(name)] = name
+		}
+	}
+	for seq := binlogSequence(backup.BinLogFileName); seq <= binlogSequence(stop.Filename); seq++ {
+		name, ok := archived[seq]
+		if !ok {
+			return nil, fmt.Errorf("Binary log %d of %s of %s missing in the backup directory", seq, basename, source.URL)
+		}
+		plan.Binlogs = append(plan.Binlogs, name)
+	}
+	return plan, nil
+}
+
+// getArchivedBinlogs returns the binary logs copied to the backup directory
+// of the server sorted by sequence
+func (server *ServerMonitor) getArchivedBinlogs() ([]string, error) {
+	meta, err := server.ReadBackupBinlogMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("No binary logs backup for %s: %s", server.URL, err)
+	}
+	binlogs := make([]string, 0, len(meta))
+	for name := range meta {
+		if binlogSequence(name) >= 0 {
+			binlogs = append(binlogs, name)
+		}
+	}
+	sort.Slice(binlogs, func(i, j int) bool {
+		return binlogSequence(binlogs[i]) < binlogSequence(binlogs[j])
+	})
+	return binlogs, nil
+}
+
+// findPointInTimeStop scans the archived binary logs from the first one and
+// returns the position the replay stops at, always the start of a transaction
+func (server *ServerMonitor) findPointInTimeStop(binlogs []string, first string, req config.PointInTimeRequest) (config.ReadBinaryLogsBoundary, error) {
+	var stop config.ReadBinaryLogsBoundary
+	meta, err := server.ReadBackupBinlogMetadata()
+	if err != nil {
+		return stop, err
+	}
+	if req.Target == config.PointInTimeTargetPosition && req.Filename == "" {
+		// Just before the first statement logged at the time
+		for _, name := range binlogs {
+			if meta[name].Start <= req.Time {
+				req.Filename = name
+			}
+		}
+		if req.Filename == "" {
+			return stop, fmt.Errorf("No binary log of %s before %s", server.URL, time.Unix(req.Time, 0).Format(time.RFC3339))
+		}
+		file, pos, err := server.FindLogPositionForTimestamp(req.Filename, time.Unix(req.Time, 0), 0)
+		if err != nil {
+			return stop, err
+		}
+		req.Filename, req.Position = file, int64(pos)
+	}
+
+	for i, name := range binlogs {
+		switch {
+		case binlogSequence(name) < binlogSequence(first):
+			continue
+		case req.Target == config.PointInTimeTargetPosition && name != req.Filename:
+			continue
+		case req.Target == config.PointInTimeTargetTime && i+1 < len(binlogs) && meta[binlogs[i+1]].Start <= req.Time:
+			// All the events of the binary log are before the next one starts
+			continue
+		}
+
+		found, matched := false, false
+		var boundary int64 = -1
+		err := server.scanArchivedBinlog(name, func(header *replication.EventHeader, pos int64, gtid string, begin bool) bool {
+			switch req.Target {
+			case config.PointInTimeTargetTime:
+				found = begin && int64(header.Timestamp) > req.Time
+				stop.Position = pos
+			case config.PointInTimeTargetGtid:
+				found = begin && matched
+				matched = matched || (begin && strings.EqualFold(gtid, req.Gtid))
+				stop.Position = pos
+			case config.PointInTimeTargetPosition:
+				if pos >= req.Position {
+					found = true
+					stop.Position = boundary
+					if (begin && pos == req.Position) || boundary < 0 {
+						stop.Position = pos
+					}
+				} else if begin {
+					boundary = pos
+				}
+			}
+			return !found
+		})
+		if err != nil {
+			return stop, fmt.Errorf("Failed to read binary log %s of %s: %s", name, server.URL, err)
+		}
+		stop.Filename = name
+		if found {
+			return stop, nil
+		}
+		if matched {
+			// The transaction is the last one of the binary log
+			stop.Position = 0
+			return stop, nil
+		}
+	}
+
+	switch req.Target {
+	case config.PointInTimeTargetTime:
+		if len(binlogs) > 0 && binlogSequence(binlogs[len(binlogs)-1]) >= binlogSequence(first) {
+			cluster := server.ClusterGroup
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Point in time recovery target is after the last archived binary log of %s, replaying until %s", server.URL, binlogs[len(binlogs)-1])
+			return config.ReadBinaryLogsBoundary{Filename: binlogs[len(binlogs)-1]}, nil
+		}
+	case config.PointInTimeTargetGtid:
+		return stop, fmt.Errorf("GTID %s not found in the archived binary logs of %s", req.Gtid, server.URL)
+	}
+	return stop, fmt.Errorf("Binary log %s of %s not found in the backup directory", req.Filename, server.URL)
+}
+
+// scanArchivedBinlog calls onEvent with the start position of each event of
+// an archived binary log, begin is set on the events starting a transaction
+// together with its gtid. The scan ends when onEvent returns false.
+func (server *ServerMonitor) scanArchivedBinlog(filename string, onEvent func(header *replication.EventHeader, pos int64, gtid string, begin bool) bool) error {
+	parser := replication.NewBinlogParser()
+	parser.SetRawMode(true)
+
+	file, err := server.ClusterGroup.OpenBackupFile(server.GetMyBackupDirectory() + filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	//Binary logs start at 4
+	if _, err := io.CopyN(io.Discard, file, 4); err != nil {
+		return err
+	}
+
+	return parser.ParseReader(file, func(e *replication.BinlogEvent) error {
+		pos := int64(e.Header.LogPos) - int64(e.Header.EventSize)
+		gtid, begin := "", false
+		data := []byte{}
+		if g, ok := e.Event.(*replication.GenericEvent); ok {
+			data = g.Data
+		}
+		switch e.Header.EventType {
+		case replication.GTID_EVENT:
+			begin = true
+			ev := &replication.GTIDEvent{}
+			if ev.Decode(data) == nil {
+				sid, _ := uuid.FromBytes(ev.SID)
+				gtid = sid.String() + ":" + strconv.FormatInt(ev.GNO, 10)
+			}
+		case replication.ANONYMOUS_GTID_EVENT:
+			begin = true
+		case replication.MARIADB_GTID_EVENT:
+			begin = true
+			ev := &replication.MariadbGTIDEvent{}
+			if len(data) >= 13 && ev.Decode(data) == nil {
+				ev.GTID.ServerID = e.Header.ServerID
+				gtid = ev.GTID.String()
+			}
+		}
+		if !onEvent(e.Header, pos, gtid, begin) {
+			parser.Stop()
+		}
+		return nil
+	})
+}
+
+// ApplyArchivedBinaryLogs replays the archived binary logs of a point in time
+// recovery plan with apply, the encrypted binary logs are decrypted first
+func (server *ServerMonitor) ApplyArchivedBinaryLogs(plan *config.PointInTimePlan, apply func(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary) error) error {
+	cluster := server.ClusterGroup
+	for i, name := range plan.Binlogs {
+		path, cleanup, err := cluster.GetClearBackupFile(server.GetMyBackupDirectory() + name)
+		if err != nil {
+			return err
+		}
+		start := config.ReadBinaryLogsBoundary{Filename: name, Position: 4, Path: path}
+		if i == 0 {
+			start.Position = plan.Start.Position
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Point in time recovery replaying binary log %s of %s", name, server.URL)
+		err = apply(start, plan.Stop)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restorePointInTimeServer reseeds a member with the base backup and replays
+// the archived binary logs of the source, the member stays out of replication
+// until the end
+func (cluster *Cluster) restorePointInTimeServer(plan *config.PointInTimePlan, server *ServerMonitor) error {
+	source := cluster.GetServerFromURL(plan.Source)
+	if source == nil {
+		return fmt.Errorf("Source %s not found", plan.Source)
+	}
+	if err := server.ReseedPointInTime(config.PointInTimeMeta{IsInPITR: true, Backup: plan.Backup}); err != nil {
+		return err
+	}
+
+	server.SetPointInTimeMeta(config.PointInTimeMeta{IsInPITR: true, UseBinlog: true, Backup: plan.Backup})
+	defer server.SetPointInTimeMeta(config.PointInTimeMeta{})
+	return source.ApplyArchivedBinaryLogs(plan, func(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary) error {
+		return source.ReadAndApplyBinaryLogsWithinRange(start, end, server)
+	})
+}
+
+// restorePointInTimeStandalone restores the base backup in a mysqld of
+// prov-db-binary-basedir started on a socket of the working directory and
+// replays the archived binary logs, the instance runs until it is stopped
+func (cluster *Cluster) restorePointInTimeStandalone(plan *config.PointInTimePlan) (string, error) {
+	source := cluster.GetServerFromURL(plan.Source)
+	if source == nil {
+		return "", fmt.Errorf("Source %s not found", plan.Source)
+	}
+	meta := cluster.BackupMetaMap.Get(plan.Backup)
+	if meta == nil {
+		return "", fmt.Errorf("Backup with id %d not found in BackupMetaMap", plan.Backup)
+	}
+
+	dir := cluster.WorkingDir + "/pitr-standalone"
+	datadir := dir + "/data"
+	socket := dir + "/mysqld.sock"
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(datadir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	var err error
+	if meta.BackupMethod == config.BackupMethodPhysical {
+		err = cluster.verifyPreparePhysical(meta, dir, datadir)
+	} else {
+		err = cluster.verifyInitDatadir(datadir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	cmd, db, err := cluster.verifyStartInstance(dir, datadir, socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if meta.BackupMethod == config.BackupMethodLogical {
+		err = cluster.verifyLoadLogical(meta, socket)
+	}
+	if err == nil {
+		cliParams := []string{"--no-defaults", "--socket=" + socket, "--user=root", "--force", "--batch", "--verbose"}
+		err = source.ApplyArchivedBinaryLogs(plan, func(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary) error {
+			return source.applyBinaryLogsWithinRange(start, end, cliParams, source)
+		})
+	}
+	if err != nil {
+		cluster.verifyStopInstance(cmd, db)
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	cluster.pitrLock.Lock()
+	cluster.pitrStandalone = cmd
+	cluster.pitrStandaloneDB = db
+	cluster.pitrLock.Unlock()
+	return socket, nil
+}
+
+// StopPointInTimeStandalone shuts down the standalone instance of the last
+// point in time recovery and removes its datadir
+func (cluster *Cluster) StopPointInTimeStandalone() error {
+	cluster.pitrLock.Lock()
+	cmd, db := cluster.pitrStandalone, cluster.pitrStandaloneDB
+	cluster.pitrStandalone, cluster.pitrStandaloneDB = nil, nil
+	cluster.pitrLock.Unlock()
+	if cmd == nil {
+		return errors.New("No standalone point in time recovery instance running")
+	}
+	cluster.verifyStopInstance(cmd, db)
+	os.RemoveAll(cluster.WorkingDir + "/pitr-standalone")
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Stopped standalone point in time recovery instance")
+	return nil
+}
+
+// compareBinlogPosition orders two binary log coordinates
+func compareBinlogPosition(fileA string, posA int64, fileB string, posB int64) int {
+	if seqA, seqB := binlogSequence(fileA), binlogSequence(fileB); seqA != seqB {
+		return seqA - seqB
+	}
+	switch {
+	case posA < posB:
+		return -1
+	case posA > posB:
+		return 1
+	}
+	return 0
+}
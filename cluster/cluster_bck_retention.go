@@ -0,0 +1,253 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+)
+
+// GetBackupRetentionPlan returns the backup catalog with the retention class
+// given by the backup-keep-* policy to every entry, the entries to purge have
+// the purge class. The full backups of a tool and a server and the restic
+// snapshots of a path form the series the policy applies to, the incremental
+// backups are kept with their full backup and the binary logs needed to roll
+// forward from the oldest full backup kept are never purged.
+func (cluster *Cluster) GetBackupRetentionPlan() []*config.BackupCatalogEntry {
+	entries := cluster.getBackupCatalog()
+	policy := cluster.Conf.GetBackupRetentionPolicy()
+	series := make(map[string][]*config.BackupCatalogEntry)
+	keys := make([]string, 0)
+	incrementals := make([]*config.BackupCatalogEntry, 0)
+	binlogs := make([]*config.BackupCatalogEntry, 0)
+
+	for _, entry := range entries {
+		var key string
+		switch entry.Type {
+		case config.BackupCatalogBinlog:
+			binlogs = append(binlogs, entry)
+			continue
+		case config.BackupCatalogRestic:
+			if entry.Pinned {
+				entry.RetentionClass = config.BackupRetentionPinned
+				continue
+			}
+			key = entry.Type + "/" + entry.Source + "/" + entry.Dest
+		default:
+			meta := cluster.getCatalogMeta(entry)
+			switch {
+			case meta == nil:
+				continue
+			case meta.Pinned:
+				entry.RetentionClass = config.BackupRetentionPinned
+				continue
+			case meta.IsIncremental():
+				incrementals = append(incrementals, entry)
+				continue
+			case !meta.Completed:
+				// A running backup is kept, a failed one archived is purged
+				entry.RetentionClass = config.BackupRetentionRotate
+				if meta.Archived {
+					entry.RetentionClass = config.BackupRetentionPurge
+				}
+				continue
+			}
+			key = entry.Type + "/" + entry.Tool + "/" + entry.Source + "/" + entry.Scope
+		}
+		if _, ok := series[key]; !ok {
+			keys = append(keys, key)
+		}
+		series[key] = append(series[key], entry)
+	}
+
+	for _, key := range keys {
+		times := make([]time.Time, len(series[key]))
+		for i, entry := range series[key] {
+			times[i] = entry.StartTime
+		}
+		for i, class := range policy.Select(times) {
+			// The backup of the rotation is only replaced by the next one
+			if meta := cluster.getCatalogMeta(series[key][i]); class == config.BackupRetentionPurge && meta != nil && !meta.Archived {
+				class = config.BackupRetentionRotate
+			}
+			series[key][i].RetentionClass = class
+		}
+	}
+
+	kept := make([]*config.BackupMetadata, 0)
+	for _, entry := range entries {
+		if meta := cluster.getCatalogMeta(entry); meta != nil && !meta.IsIncremental() && !meta.IsPartial() && meta.Completed && entry.RetentionClass != config.BackupRetentionPurge {
+			kept = append(kept, meta)
+		}
+	}
+
+	for _, entry := range incrementals {
+		meta := cluster.getCatalogMeta(entry)
+		entry.RetentionClass = config.BackupRetentionChain
+		chain, err := cluster.BackupMetaMap.GetBackupChain(meta.Id)
+		if err != nil {
+			if meta.Archived {
+				entry.RetentionClass = config.BackupRetentionPurge
+			}
+			continue
+		}
+		found := false
+		for _, full := range kept {
+			if full.Id == chain[0].Id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			entry.RetentionClass = config.BackupRetentionPurge
+		}
+	}
+
+	start := getRollForwardBinlogs(kept)
+	bysource := make(map[string]int)
+	sort.SliceStable(binlogs, func(i, j int) bool {
+		return binlogSequence(binlogs[i].BinLogFileName) > binlogSequence(binlogs[j].BinLogFileName)
+	})
+	for _, entry := range binlogs {
+		switch {
+		case isRollForwardBinlog(start[entry.Source], entry.BinLogFileName):
+			entry.RetentionClass = config.BackupRetentionRollFwd
+		case bysource[entry.Source] < cluster.Conf.BackupBinlogsKeep:
+			entry.RetentionClass = config.BackupRetentionBinlogKeep
+		default:
+			entry.RetentionClass = config.BackupRetentionPurge
+		}
+		bysource[entry.Source]++
+	}
+
+	return entries
+}
+
+// GetBackupRetentionPurge returns the entries of the catalog the retention
+// policy purges, the newest first so incremental backups come before the
+// backups they chain to
+func (cluster *Cluster) GetBackupRetentionPurge() []*config.BackupCatalogEntry {
+	purge := make([]*config.BackupCatalogEntry, 0)
+	for _, entry := range cluster.GetBackupRetentionPlan() {
+		if entry.RetentionClass == config.BackupRetentionPurge {
+			purge = append(purge, entry)
+		}
+	}
+	return purge
+}
+
+// PurgeBackupRetention deletes the backups the retention policy does not keep
+// and returns them, nothing is deleted with dryrun
+func (cluster *Cluster) PurgeBackupRetention(dryrun bool) ([]*config.BackupCatalogEntry, error) {
+	if !cluster.backupRetentionLock.TryLock() {
+		return nil, errors.New("Backup retention purge already running")
+	}
+	defer cluster.backupRetentionLock.Unlock()
+
+	purge := cluster.GetBackupRetentionPurge()
+	if dryrun {
+		return purge, nil
+	}
+	if cluster.IsInBackup() {
+		return nil, errors.New("Backup in progress, retention purge postponed")
+	}
+
+	deleted := make([]*config.BackupCatalogEntry, 0)
+	restic := false
+	for _, entry := range purge {
+		if err := cluster.DeleteBackup(entry.Id); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Retention purge skipped backup %s: %s", entry.Id, err)
+			continue
+		}
+		if entry.Type == config.BackupCatalogRestic {
+			restic = true
+		}
+		deleted = append(deleted, entry)
+	}
+	if restic {
+		if err := cluster.ResticRunCommand("prune"); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Retention purge failed to prune restic repository: %s", err)
+		}
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Backup retention purged %d of %d backups", len(deleted), len(purge))
+	return deleted, nil
+}
+
+func (cluster *Cluster) getCatalogMeta(entry *config.BackupCatalogEntry) *config.BackupMetadata {
+	if entry.Type != config.BackupCatalogLogical && entry.Type != config.BackupCatalogPhysical {
+		return nil
+	}
+	id, err := strconv.ParseInt(entry.Id, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return cluster.BackupMetaMap.Get(id)
+}
+
+// GetRollForwardBinlogs returns by source server the first binary log needed
+// to roll forward from the oldest full backup
+func (cluster *Cluster) GetRollForwardBinlogs() map[string]string {
+	backups := make([]*config.BackupMetadata, 0)
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		if !backup.IsIncremental() && backup.Completed {
+			backups = append(backups, backup)
+		}
+		return true
+	})
+	return getRollForwardBinlogs(backups)
+}
+
+func getRollForwardBinlogs(backups []*config.BackupMetadata) map[string]string {
+	start := make(map[string]string)
+	for _, backup := range backups {
+		if binlogSequence(backup.BinLogFileName) < 0 {
+			continue
+		}
+		if first, ok := start[backup.Source]; !ok || binlogSequence(backup.BinLogFileName) < binlogSequence(first) {
+			start[backup.Source] = backup.BinLogFileName
+		}
+	}
+	return start
+}
+
+// isRollForwardBinlog tells if a binary log follows the first binary log
+// needed to roll forward
+func isRollForwardBinlog(start string, filename string) bool {
+	if start == "" || binlogSequence(filename) < 0 {
+		return false
+	}
+	return binlogBasename(start) == binlogBasename(filename) && binlogSequence(filename) >= binlogSequence(start)
+}
+
+// binlogBasename returns the binary log name before the sequence, the
+// basename may have dots
+func binlogBasename(filename string) string {
+	if i := strings.LastIndex(filename, "."); i >= 0 {
+		return filename[:i]
+	}
+	return filename
+}
+
+func binlogSequence(filename string) int {
+	i := strings.LastIndex(filename, ".")
+	if i < 0 {
+		return -1
+	}
+	seq, err := strconv.Atoi(filename[i+1:])
+	if err != nil {
+		return -1
+	}
+	return seq
+}
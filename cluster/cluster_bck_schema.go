@@ -0,0 +1,411 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gzip "github.com/klauspost/pgzip"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/crypto"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/misc"
+)
+
+// JobBackupSchema starts a logical backup of the schemas and tables of a
+// request with the mysqldump or mydumper backup-logical-type. The backup is
+// written to the archive directory of the source server, out of the rotation
+// of the full backups, and is listed by the catalog with its scope.
+func (cluster *Cluster) JobBackupSchema(req config.SchemaBackupRequest) (*config.BackupMetadata, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	server := cluster.GetBackupServer()
+	if req.Server != "" {
+		server = cluster.GetServerFromName(req.Server)
+		if server == nil {
+			server = cluster.GetServerFromURL(req.Server)
+		}
+	}
+	if server == nil {
+		return nil, errors.New("Schema backup source server not found")
+	}
+	if server.IsDown() {
+		return nil, errors.New("Can't backup when server down")
+	}
+	tool := cluster.Conf.BackupLogicalType
+	switch tool {
+	case config.ConstBackupLogicalTypeMysqldump:
+		if _, err := os.Stat(cluster.GetMysqlDumpPath()); err != nil {
+			return nil, err
+		}
+	case config.ConstBackupLogicalTypeMydumper:
+		if _, err := os.Stat(cluster.GetMyDumperPath()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Schema backup is not supported with %s backup", tool)
+	}
+	if cluster.IsInBackup() {
+		return nil, errors.New("Backup in progress, schema backup cancelled")
+	}
+	key, err := cluster.NewBackupDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	meta := &config.BackupMetadata{
+		Id:             start.Unix(),
+		StartTime:      start,
+		BackupMethod:   config.BackupMethodLogical,
+		BackupTool:     tool,
+		BackupStrategy: config.BackupStrategyFull,
+		Source:         server.URL,
+		Archived:       true,
+		Schemas:        req.Schemas,
+		Tables:         req.Tables,
+	}
+	for cluster.BackupMetaMap.Get(meta.Id) != nil {
+		meta.Id++
+	}
+	// A restore refuses to rename the schemas of a backup holding objects referencing them
+	objects, logs, err := dbhelper.GetSchemasWithObjects(server.Conn, meta.GetSchemaNames())
+	cluster.LogSQL(logs, err, server.URL, "JobBackupSchema", config.LvlErr, "Could not get the views, triggers, routines and events of the schemas on %s: %s", server.URL, err)
+	if err != nil {
+		return nil, err
+	}
+	meta.SchemaObjects = objects
+	dir := server.GetBackupArchiveDirectory(meta.Id)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if tool == config.ConstBackupLogicalTypeMysqldump {
+		meta.Dest = dir + "mysqldump.sql.gz"
+	} else {
+		meta.Dest = dir + "mydumper"
+	}
+	meta.Compressed = true
+	cluster.SetBackupEncryption(meta, key)
+
+	cluster.SetInLogicalBackupState(true)
+	cluster.BackupMetaMap.Set(meta.Id, meta)
+	result := *meta
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Request schema backup %d %s of %s for: %s", meta.Id, tool, meta.GetScope(), server.URL)
+	go func() {
+		defer cluster.SetInLogicalBackupState(false)
+		var err error
+		if tool == config.ConstBackupLogicalTypeMysqldump {
+			err = server.backupSchemaMysqldump(meta, key)
+		} else {
+			err = server.backupSchemaMyDumper(meta, key)
+		}
+		meta.EndTime = time.Now()
+		if err == nil {
+			meta.GetSize()
+			meta.Completed = true
+			cluster.SetBackupChecksum(meta)
+		}
+		if e2 := server.SaveBackupMetadata(meta); e2 != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to write schema backup %d metadata: %s", meta.Id, e2)
+		}
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlWarn, "[ERROR] Finish schema backup %d for: %s: %s", meta.Id, server.URL, err)
+			return
+		}
+		server.UploadBackup(meta, server.GetBackupMetaFile(meta))
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "[SUCCESS] Finish schema backup %d for: %s", meta.Id, server.URL)
+	}()
+	return &result, nil
+}
+
+// backupSchemaMysqldump dumps the schemas of the scope in one mysqldump run
+// and one transaction without replication statements, the USE statements of
+// the dump let the restore rename the schemas
+func (server *ServerMonitor) backupSchemaMysqldump(meta *config.BackupMetadata, key *crypto.DataKey) error {
+	cluster := server.ClusterGroup
+
+	file, err := cluster.CreateTmpClientConfFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	f, err := os.Create(meta.Dest)
+	if err != nil {
+		return err
+	}
+	var out io.Writer = f
+	var ew io.WriteCloser
+	if key != nil {
+		ew, err = crypto.NewEncryptWriter(f, key)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		out = ew
+	}
+	gw := gzip.NewWriter(out)
+
+	err = server.dumpSchemas(gw, meta, file)
+	if cerr := gw.Close(); err == nil {
+		err = cerr
+	}
+	if ew != nil {
+		if cerr := ew.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// dumpSchemas writes the mysqldump of the schemas of a schema backup to w
+func (server *ServerMonitor) dumpSchemas(w io.Writer, meta *config.BackupMetadata, file string) error {
+	cluster := server.ClusterGroup
+	scope := meta.GetSchemaScope()
+	tables := make(map[string][]string)
+	for schema, names := range scope {
+		if names == nil {
+			continue
+		}
+		list, logs, err := dbhelper.GetSchemaTables(server.Conn, schema)
+		cluster.LogSQL(logs, err, server.URL, "JobBackupSchema", config.LvlErr, "Could not get the tables of schema %s on %s: %s", schema, server.URL, err)
+		if err != nil {
+			return err
+		}
+		tables[schema] = list
+	}
+	dumpargs := make([]string, 0)
+	for _, arg := range cluster.GetMysqlDumpOptions(server, "", file) {
+		if !isInstanceDumpOption(arg) {
+			dumpargs = append(dumpargs, arg)
+		}
+	}
+	if !slices.Contains(dumpargs, "--single-transaction") {
+		dumpargs = append(dumpargs, "--single-transaction")
+	}
+	if server.DBVersion.IsMySQLOrPercona() && server.HasMySQLGTID() {
+		dumpargs = append(dumpargs, "--set-gtid-purged=OFF")
+	}
+	dumpargs = append(dumpargs, "--no-create-db")
+	dumpargs = append(dumpargs, meta.GetSchemaDumpArgs(tables)...)
+	dumpCmd := exec.Command(cluster.GetMysqlDumpPath(), dumpargs...)
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Command: %s ", strings.Replace(dumpCmd.String(), cluster.GetDbPass(), "XXXX", -1))
+
+	stdoutIn, _ := dumpCmd.StdoutPipe()
+	stderrIn, _ := dumpCmd.StderrPipe()
+	if err := dumpCmd.Start(); err != nil {
+		return err
+	}
+	go server.copyLogs(stderrIn, config.ConstLogModBackupStream, config.LvlDbg)
+	if _, err := io.Copy(w, cluster.NewBackupThrottleReader(stdoutIn)); err != nil {
+		dumpCmd.Process.Kill()
+		dumpCmd.Wait()
+		return err
+	}
+	if err := dumpCmd.Wait(); err != nil {
+		return fmt.Errorf("Error mysqldump of schemas %s: %w", meta.GetScope(), err)
+	}
+	return nil
+}
+
+// isInstanceDumpOption tells if a mysqldump option only applies to the dump
+// of the whole instance of a reseed
+func isInstanceDumpOption(arg string) bool {
+	name, _, _ := strings.Cut(arg, "=")
+	switch name {
+	case "--all-databases", "-A", "--databases", "-B", "--system", "--master-data", "--dump-slave", "--apply-slave-statements", "--include-master-host-port":
+		return true
+	}
+	return false
+}
+
+// backupSchemaMyDumper dumps the tables of the scope matched by a mydumper
+// regex in a single consistent mydumper run
+func (server *ServerMonitor) backupSchemaMyDumper(meta *config.BackupMetadata, key *crypto.DataKey) error {
+	cluster := server.ClusterGroup
+
+	dumper := cluster.VersionsMap.Get("mydumper")
+	if dumper == nil {
+		if err := cluster.SetMyDumperVersion(); err != nil {
+			return err
+		}
+		dumper = cluster.VersionsMap.Get("mydumper")
+	}
+	encryptArgs, encryptEnv, err := cluster.GetMyDumperEncryption(dumper, key)
+	if err != nil {
+		return err
+	}
+
+	threads := strconv.Itoa(cluster.Conf.GetBackupThreads(cluster.Conf.BackupLogicalDumpThreads))
+	myargs := strings.Split(strings.ReplaceAll(cluster.Conf.BackupMyDumperOptions, "  ", " "), " ")
+	if dumper.GreaterEqual("0.15.3") {
+		myargs = append(myargs, "--clear")
+	}
+	myargs = append(myargs, "--outputdir", meta.Dest+"/", "--threads", threads, "--host", misc.Unbracket(server.Host), "--port", server.Port, "--user", cluster.GetDbUser(), "--password", cluster.GetDbPass(), "--regex", meta.GetMyDumperRegex())
+	myargs = append(myargs, encryptArgs...)
+	dumpCmd := exec.Command(cluster.GetMyDumperPath(), misc.RemoveEmptyString(myargs)...)
+	dumpCmd.Env = encryptEnv
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "%s", strings.Replace(dumpCmd.String(), cluster.GetDbPass(), "XXXX", 1))
+
+	stdoutIn, _ := dumpCmd.StdoutPipe()
+	stderrIn, _ := dumpCmd.StderrPipe()
+	if err := dumpCmd.Start(); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	var valid bool = true
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		server.myDumperCopyLogs(stdoutIn, config.ConstLogModBackupStream, config.LvlDbg)
+	}()
+	go func() {
+		defer wg.Done()
+		valid = server.myDumperCopyLogs(stderrIn, config.ConstLogModBackupStream, config.LvlDbg)
+	}()
+	wg.Wait()
+	if err := dumpCmd.Wait(); err != nil && !valid {
+		return fmt.Errorf("Error mydumper: %w", err)
+	}
+
+	if mymeta, err := server.JobMyLoaderParseMeta(meta.Dest); err == nil {
+		meta.BinLogFileName = mymeta.BinLogFileName
+		meta.BinLogFilePos = mymeta.BinLogFilePos
+		meta.BinLogGtid = mymeta.BinLogUuid
+	}
+	// The metadata file is not written by the mydumper threads
+	return cluster.EncryptBackupDir(meta.Dest, key)
+}
+
+// RestoreSchemaBackup loads a schema backup of the catalog into the master of
+// the target cluster, another cluster of the same replication-manager or the
+// cluster itself. The schemas are created under their new name when renamed
+// and the restore is replicated to the replicas of the target cluster.
+func (cluster *Cluster) RestoreSchemaBackup(id string, target *Cluster, req config.SchemaRestoreRequest) error {
+	entry, err := cluster.GetBackupCatalogEntry(id)
+	if err != nil {
+		return err
+	}
+	meta, _, err := cluster.getCatalogBackupMeta(entry)
+	if err != nil {
+		return err
+	}
+	if err := req.Validate(meta); err != nil {
+		return err
+	}
+	if !meta.Completed {
+		return fmt.Errorf("Backup %s is not completed", id)
+	}
+	server := target.GetMaster()
+	if server == nil || server.IsDown() {
+		return fmt.Errorf("No master in cluster %s to restore backup %s", target.Name, id)
+	}
+	if server.HasAnyReseedingState() {
+		return fmt.Errorf("Server is in reseeding state by %s", server.IsReseeding)
+	}
+
+	var restore func() error
+	switch meta.BackupTool {
+	case config.ConstBackupLogicalTypeMysqldump:
+		restore = func() error { return server.restoreSchemaMysqldump(cluster, meta, req) }
+	case config.ConstBackupLogicalTypeMydumper:
+		restore = func() error { return server.restoreSchemaMyLoader(cluster, meta, req) }
+	default:
+		return fmt.Errorf("Schema restore is not supported for %s backup", meta.BackupTool)
+	}
+
+	server.SetInReseedBackup(meta.BackupTool)
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Restoring schema backup %s of %s to %s in cluster %s", id, entry.Scope, server.URL, target.Name)
+	go func() {
+		defer server.SetInReseedBackup("")
+		if err := restore(); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Restore of schema backup %s to %s failed: %s", id, server.URL, err)
+			return
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Restore of schema backup %s to %s finished", id, server.URL)
+	}()
+	return nil
+}
+
+// restoreSchemaMysqldump streams a schema backup of mysqldump of the source
+// cluster to the mysql client of the server, renaming the schema headers
+func (server *ServerMonitor) restoreSchemaMysqldump(source *Cluster, meta *config.BackupMetadata, req config.SchemaRestoreRequest) error {
+	gzfile, err := source.OpenBackupFile(meta.Dest)
+	if err != nil {
+		return err
+	}
+	defer gzfile.Close()
+
+	fz, err := gzip.NewReader(gzfile)
+	if err != nil {
+		return err
+	}
+	defer fz.Close()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		br := bufio.NewReader(fz)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				if _, werr := io.WriteString(pw, req.RenameSchemaDumpLine(line)); werr != nil {
+					pw.CloseWithError(werr)
+					return
+				}
+			}
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	// The restore stops on the first error
+	header := "set long_query_time=10;\n" + req.GetSchemaRestoreHeader(meta)
+	return server.runMysqlClient(io.MultiReader(bytes.NewBufferString(header), pr), false)
+}
+
+// restoreSchemaMyLoader loads each schema of a schema backup of mydumper of the
+// source cluster into its target schema
+func (server *ServerMonitor) restoreSchemaMyLoader(source *Cluster, meta *config.BackupMetadata, req config.SchemaRestoreRequest) error {
+	backupdir, cleanup, err := source.GetClearBackupDir(meta.Dest)
+	if err != nil {
+		return fmt.Errorf("Failed decrypting backup directory for restore: %s", err)
+	}
+	defer cleanup()
+
+	for _, schema := range meta.GetSchemaNames() {
+		if err := server.runMyLoader(backupdir, "--enable-binlog", "--source-db="+schema, "--database="+req.GetTargetSchema(schema)); err != nil {
+			return fmt.Errorf("Error myloader of schema %s: %w", schema, err)
+		}
+	}
+	return nil
+}
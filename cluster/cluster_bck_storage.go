@@ -0,0 +1,197 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/storage"
+)
+
+// GetBackupStorage returns the object storage backups are copied to, nil
+// when backup-storage is not set
+func (cluster *Cluster) GetBackupStorage() (storage.Backend, error) {
+	conf := storage.Config{
+		Type:     cluster.Conf.BackupStorage,
+		Prefix:   cluster.Conf.BackupStoragePrefix,
+		PartSize: int64(cluster.Conf.BackupStoragePartSize) * 1024 * 1024,
+	}
+	switch cluster.Conf.BackupStorage {
+	case "":
+		return nil, nil
+	case storage.TypeLocal:
+		conf.Path = cluster.Conf.BackupStoragePath
+	case storage.TypeS3:
+		conf.Endpoint = cluster.Conf.BackupStreamingEndpoint
+		conf.Region = cluster.Conf.BackupStreamingRegion
+		conf.Bucket = cluster.Conf.BackupStreamingBucket
+		conf.AccessKey = cluster.Conf.BackupStreamingAwsAccessKeyId
+		conf.SecretKey = cluster.Conf.GetDecryptedValue("backup-streaming-aws-access-secret")
+	case storage.TypeAzure:
+		conf.Endpoint = cluster.Conf.BackupStorageAzureEndpoint
+		conf.Bucket = cluster.Conf.BackupStorageAzureContainer
+		conf.AccessKey = cluster.Conf.BackupStorageAzureAccount
+		conf.SecretKey = cluster.Conf.GetDecryptedValue("backup-storage-azure-key")
+	}
+	return storage.New(conf)
+}
+
+// GetBackupStorageKey returns the object key of a file of the backup
+// directory, the path relative to the backups directory
+func (cluster *Cluster) GetBackupStorageKey(path string) string {
+	root := filepath.Clean(cluster.Conf.WorkingDir + "/" + config.ConstStreamingSubDir)
+	key, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || strings.HasPrefix(key, "..") {
+		key = cluster.Name + "/" + filepath.Base(path)
+	}
+	return filepath.ToSlash(key)
+}
+
+// StreamBackupStorage returns a writer copying what is written to a backup
+// file to the object storage. The returned function ends the upload, the
+// writer is w when no storage is configured.
+func (cluster *Cluster) StreamBackupStorage(path string, w io.Writer) (io.Writer, func()) {
+	b, err := cluster.GetBackupStorage()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Backup storage error: %s", err)
+		return w, func() {}
+	}
+	if b == nil {
+		return w, func() {}
+	}
+	key := cluster.GetBackupStorageKey(path)
+	sw := storage.NewWriter(context.Background(), b, key)
+	return io.MultiWriter(w, sw), func() {
+		obj, err := sw.Close()
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to stream %s to %s storage: %s", key, b.Type(), err)
+			return
+		}
+		cluster.streamedChecksums.Store(filepath.Clean(path), *obj)
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Streamed %s to %s storage, %d bytes sha256 %s", key, b.Type(), obj.Size, obj.Checksum)
+	}
+}
+
+// GetBackupChecksum returns the hex encoded sha256 of a backup file, taken
+// from its upload when it was streamed to the storage. The checksum of a
+// directory is the sha256 of the list of its files with their sha256 in the
+// sha256sum format.
+func (cluster *Cluster) GetBackupChecksum(dest string) (string, error) {
+	info, err := os.Stat(dest)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return cluster.getBackupFileChecksum(dest, info.Size())
+	}
+	h := sha256.New()
+	err = filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		checksum, err := cluster.getBackupFileChecksum(path, info.Size())
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s  %s\n", checksum, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// getBackupFileChecksum returns the checksum of the upload of a streamed file
+// of the same size, the file is read otherwise
+func (cluster *Cluster) getBackupFileChecksum(path string, size int64) (string, error) {
+	if v, ok := cluster.streamedChecksums.LoadAndDelete(filepath.Clean(path)); ok {
+		if obj := v.(storage.Object); obj.Size == size {
+			return obj.Checksum, nil
+		}
+	}
+	return storage.FileChecksum(path)
+}
+
+// SetBackupChecksum stores the checksum of a completed backup in its metadata
+func (cluster *Cluster) SetBackupChecksum(meta *config.BackupMetadata) {
+	if !meta.HasBackupFile() {
+		return
+	}
+	checksum, err := cluster.GetBackupChecksum(meta.Dest)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to checksum backup %d: %s", meta.Id, err)
+		return
+	}
+	meta.Checksum = checksum
+}
+
+// UploadBackupFile copies a backup file to the object storage, an upload
+// interrupted by a previous call is resumed
+func (cluster *Cluster) UploadBackupFile(path string) error {
+	b, err := cluster.GetBackupStorage()
+	if err != nil || b == nil {
+		return err
+	}
+	key := cluster.GetBackupStorageKey(path)
+	obj, err := b.PutFile(context.Background(), key, path)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to upload %s to %s storage: %s", key, b.Type(), err)
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Uploaded %s to %s storage, %d bytes sha256 %s", key, b.Type(), obj.Size, obj.Checksum)
+	return nil
+}
+
+// UploadBackupDir copies the files of a backup directory to the object storage
+func (cluster *Cluster) UploadBackupDir(dir string) error {
+	if cluster.Conf.BackupStorage == "" {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		return cluster.UploadBackupFile(path)
+	})
+}
+
+// UploadBackup copies a completed backup and its metadata file to the object
+// storage, the files already streamed are skipped after their checksum
+func (server *ServerMonitor) UploadBackup(meta *config.BackupMetadata, metafile string) {
+	cluster := server.ClusterGroup
+	if cluster.Conf.BackupStorage == "" {
+		return
+	}
+	info, err := os.Stat(meta.Dest)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		err = cluster.UploadBackupDir(meta.Dest)
+	} else {
+		err = cluster.UploadBackupFile(meta.Dest)
+	}
+	if err == nil {
+		cluster.UploadBackupFile(metafile)
+	}
+}
@@ -0,0 +1,56 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/signal18/replication-manager/utils/storage"
+)
+
+func TestGetBackupChecksum(t *testing.T) {
+	dir := t.TempDir()
+	sum := func(data string) string {
+		h := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(h[:])
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a.sql": "a", "sub/b.sql": "bb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cluster := &Cluster{}
+	got, err := cluster.GetBackupChecksum(filepath.Join(dir, "a.sql"))
+	if err != nil || got != sum("a") {
+		t.Errorf("file checksum %s %v, want %s", got, err, sum("a"))
+	}
+	want := sum(sum("a") + "  a.sql\n" + sum("bb") + "  sub/b.sql\n")
+	if got, err := cluster.GetBackupChecksum(dir); err != nil || got != want {
+		t.Errorf("directory checksum %s %v, want %s", got, err, want)
+	}
+
+	// The upload checksum of a streamed file is used once, if the size matches
+	cluster.streamedChecksums.Store(filepath.Join(dir, "a.sql"), storage.Object{Size: 1, Checksum: "streamed"})
+	cluster.streamedChecksums.Store(filepath.Join(dir, "sub/b.sql"), storage.Object{Size: 1, Checksum: "stale"})
+	if got, _ := cluster.GetBackupChecksum(filepath.Join(dir, "a.sql")); got != "streamed" {
+		t.Errorf("streamed checksum not used: %s", got)
+	}
+	if got, _ := cluster.GetBackupChecksum(filepath.Join(dir, "sub/b.sql")); got != sum("bb") {
+		t.Errorf("stale streamed checksum used: %s", got)
+	}
+}
@@ -0,0 +1,240 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/state"
+	"github.com/signal18/replication-manager/utils/throttle"
+)
+
+// backupThrottle is the reason the backups of a cluster are held back, set
+// by the monitoring loop, and the bandwidth bucket of its backup streams
+type backupThrottle struct {
+	sync.Mutex
+	reason  string
+	waiting int
+	bucket  *throttle.Bucket
+}
+
+// protectedBackupJobs counts the backup jobs running
+type protectedBackupJobs struct {
+	count int
+	sync.Mutex
+}
+
+// backupJobs is shared by all clusters of this replication-manager for
+// backup-throttle-max-jobs
+var backupJobs = protectedBackupJobs{}
+
+// CheckBackupThrottle updates the throttle of the backups from the largest
+// replication delay and the Threads_running of the master, WARN0132 is raised
+// while a backup is paused or deferred
+func (cluster *Cluster) CheckBackupThrottle() {
+	reason := cluster.Conf.GetBackupThrottleReason(cluster.getReplicationLoad())
+
+	cluster.backupThrottle.Lock()
+	cluster.backupThrottle.reason = reason
+	waiting := cluster.backupThrottle.waiting
+	cluster.backupThrottle.Unlock()
+
+	if reason != "" && (waiting > 0 || cluster.IsInBackup()) {
+		cluster.SetState("WARN0132", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["WARN0132"], cluster.Name, reason), ErrFrom: "BACKUP"})
+	}
+}
+
+// getReplicationLoad returns the largest replication delay of the replicas
+// up and the Threads_running of the master
+func (cluster *Cluster) getReplicationLoad() (delay int64, running int64) {
+	for _, server := range cluster.slaves {
+		if !server.IsDown() && server.GetReplicationDelay() > delay {
+			delay = server.GetReplicationDelay()
+		}
+	}
+	if master := cluster.GetMaster(); master != nil {
+		running, _ = strconv.ParseInt(master.Status.Get("THREADS_RUNNING"), 10, 64)
+	}
+	return delay, running
+}
+
+// GetBackupThrottle returns why the backups are held back, empty when they run
+func (cluster *Cluster) GetBackupThrottle() string {
+	cluster.backupThrottle.Lock()
+	defer cluster.backupThrottle.Unlock()
+	return cluster.backupThrottle.reason
+}
+
+// IsBackupPaused tells if the backup streams are paused by the throttle
+func (cluster *Cluster) IsBackupPaused() bool {
+	return cluster.GetBackupThrottle() != ""
+}
+
+// GetBackupBandwidth returns the bytes per second of backup-throttle-bandwidth
+func (cluster *Cluster) GetBackupBandwidth() int64 {
+	return int64(cluster.Conf.BackupThrottleBandwidth) * 1024 * 1024
+}
+
+// getBackupBucket returns the bandwidth bucket shared by the backup streams
+// of the cluster
+func (cluster *Cluster) getBackupBucket() *throttle.Bucket {
+	cluster.backupThrottle.Lock()
+	defer cluster.backupThrottle.Unlock()
+	if cluster.backupThrottle.bucket == nil {
+		cluster.backupThrottle.bucket = throttle.NewBucket(cluster.GetBackupBandwidth)
+	}
+	return cluster.backupThrottle.bucket
+}
+
+// NewBackupThrottleReader returns a reader of a backup stream drawing from
+// the bandwidth budget shared by all the backup streams of the cluster and
+// paused while the backups are throttled. The stream
+// resumes once paused for backup-throttle-max-pause in total, the server
+// can not hold a backup open for long, mariabackup fails when the redo log
+// wraps.
+func (cluster *Cluster) NewBackupThrottleReader(r io.Reader) io.Reader {
+	var total time.Duration
+	var last time.Time
+	paused := func() bool {
+		max := time.Duration(cluster.Conf.BackupThrottleMaxPause) * time.Second
+		if !cluster.IsBackupPaused() || (max > 0 && total >= max) {
+			last = time.Time{}
+			return false
+		}
+		now := time.Now()
+		if !last.IsZero() {
+			total += now.Sub(last)
+		}
+		last = now
+		if max > 0 && total >= max {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Backup stream resumed after being paused %s by the throttle: %s", total.Round(time.Second), cluster.GetBackupThrottle())
+			return false
+		}
+		return true
+	}
+	return throttle.NewBucketReader(r, cluster.getBackupBucket(), paused)
+}
+
+// acquireBackupJob takes a backup job slot of this replication-manager
+func (cluster *Cluster) acquireBackupJob() bool {
+	backupJobs.Lock()
+	defer backupJobs.Unlock()
+	if cluster.Conf.BackupThrottleMaxJobs > 0 && backupJobs.count >= cluster.Conf.BackupThrottleMaxJobs {
+		return false
+	}
+	backupJobs.count++
+	return true
+}
+
+func (cluster *Cluster) releaseBackupJob() {
+	backupJobs.Lock()
+	defer backupJobs.Unlock()
+	backupJobs.count--
+}
+
+// acquireManualBackupJob takes a backup job slot for a backup requested by
+// the API, there is no waiting for a free slot
+func (cluster *Cluster) acquireManualBackupJob(name string) error {
+	if !cluster.acquireBackupJob() {
+		err := fmt.Errorf("Manual %s backup refused, %d backup jobs running", name, cluster.Conf.BackupThrottleMaxJobs)
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "%s", err)
+		return err
+	}
+	return nil
+}
+
+// RunManualBackup runs a backup requested by the API within the
+// backup-throttle-max-jobs budget, the request is refused when no backup job
+// slot is free. The slot is held until the backups of the cluster end.
+func (cluster *Cluster) RunManualBackup(name string, job func() error) error {
+	if err := cluster.acquireManualBackupJob(name); err != nil {
+		return err
+	}
+	err := job()
+	go func() {
+		defer cluster.releaseBackupJob()
+		for cluster.IsInBackup() {
+			time.Sleep(time.Second)
+		}
+	}()
+	return err
+}
+
+// StartManualBackup runs a long backup job requested by the API in the
+// background like RunManualBackup, only the refusal is returned
+func (cluster *Cluster) StartManualBackup(name string, job func() error) error {
+	if err := cluster.acquireManualBackupJob(name); err != nil {
+		return err
+	}
+	go func() {
+		defer cluster.releaseBackupJob()
+		if err := job(); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Manual %s backup failed: %s", name, err)
+		}
+		for cluster.IsInBackup() {
+			time.Sleep(time.Second)
+		}
+	}()
+	return nil
+}
+
+// RunScheduledBackup runs a scheduled backup job once the backups are not
+// throttled and a backup job slot is free, the job is skipped after
+// backup-throttle-defer-timeout. The slot is held until the backups of the
+// cluster end, physical backups end after the job returns.
+func (cluster *Cluster) RunScheduledBackup(name string, job func()) {
+	deadline := time.Now().Add(time.Duration(cluster.Conf.BackupThrottleDeferTimeout) * time.Second)
+	deferred := false
+	for {
+		reason := cluster.GetBackupThrottle()
+		if reason == "" {
+			if cluster.acquireBackupJob() {
+				break
+			}
+			reason = fmt.Sprintf("%d backup jobs running", cluster.Conf.BackupThrottleMaxJobs)
+		}
+		if time.Now().After(deadline) {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Scheduled %s backup skipped after waiting %ds: %s", name, cluster.Conf.BackupThrottleDeferTimeout, reason)
+			cluster.setBackupWaiting(deferred, false)
+			return
+		}
+		if !deferred {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Scheduled %s backup deferred: %s", name, reason)
+			cluster.setBackupWaiting(false, true)
+			deferred = true
+		}
+		time.Sleep(10 * time.Second)
+	}
+	cluster.setBackupWaiting(deferred, false)
+	defer cluster.releaseBackupJob()
+	if deferred {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Starting deferred %s backup", name)
+	}
+	job()
+	for cluster.IsInBackup() {
+		time.Sleep(time.Second)
+	}
+}
+
+func (cluster *Cluster) setBackupWaiting(was bool, is bool) {
+	cluster.backupThrottle.Lock()
+	defer cluster.backupThrottle.Unlock()
+	if was {
+		cluster.backupThrottle.waiting--
+	}
+	if is {
+		cluster.backupThrottle.waiting++
+	}
+}
@@ -0,0 +1,348 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	gzip "github.com/klauspost/pgzip"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// GetBackupToVerify returns the last completed backup of backup-verify-method
+// that can be restored by the verification
+func (cluster *Cluster) GetBackupToVerify() *config.BackupMetadata {
+	var result *config.BackupMetadata
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		if !backup.Completed || backup.IsPartial() {
+			return true
+		}
+		switch backup.BackupTool {
+		case config.ConstBackupLogicalTypeMysqldump, config.ConstBackupLogicalTypeMydumper, config.ConstBackupPhysicalTypeMariaBackup, config.ConstBackupPhysicalTypeXtrabackup:
+		default:
+			return true
+		}
+		switch cluster.Conf.BackupVerifyMethod {
+		case "logical":
+			if backup.BackupMethod != config.BackupMethodLogical {
+				return true
+			}
+		case "physical":
+			if backup.BackupMethod != config.BackupMethodPhysical {
+				return true
+			}
+		}
+		if result == nil || result.Id < backup.Id {
+			result = backup
+		}
+		return true
+	})
+	return result
+}
+
+// JobBackupVerify restores the last backup on backup-verify-server or on a
+// throwaway local instance, compares the row counts of the restored tables to
+// the ones recorded with the backup and marks the backup verified or failed
+func (cluster *Cluster) JobBackupVerify() (*config.BackupMetadata, error) {
+	if !cluster.backupVerifyLock.TryLock() {
+		return nil, errors.New("Backup restore verification already running")
+	}
+	defer cluster.backupVerifyLock.Unlock()
+
+	meta := cluster.GetBackupToVerify()
+	if meta == nil {
+		return nil, errors.New("No completed backup to verify")
+	}
+
+	meta.VerifyState = config.BackupVerifyRunning
+	meta.VerifyTime = time.Now()
+	meta.VerifyResult = ""
+
+	var restored config.TableRowCounts
+	var err error
+	if cluster.Conf.BackupVerifyServer != "" {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Verifying %s backup %d from %s on %s", meta.BackupTool, meta.Id, meta.Source, cluster.Conf.BackupVerifyServer)
+		restored, err = cluster.verifyBackupOnServer(meta)
+	} else {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Verifying %s backup %d from %s on a local instance", meta.BackupTool, meta.Id, meta.Source)
+		restored, err = cluster.verifyBackupOnLocalhost(meta)
+	}
+	if err == nil {
+		if len(meta.TableRows) > 0 && len(restored) == 0 {
+			err = errors.New("no table restored")
+		} else if errs := meta.TableRows.CompareRows(restored, cluster.Conf.BackupVerifyRowTolerance); len(errs) > 0 {
+			err = errors.New(strings.Join(errs, ", "))
+		}
+	}
+
+	meta.VerifyTime = time.Now()
+	if err != nil {
+		meta.VerifyState = config.BackupVerifyFailed
+		meta.VerifyResult = err.Error()
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Restore verification of %s backup %d failed: %s", meta.BackupTool, meta.Id, err)
+	} else {
+		meta.VerifyState = config.BackupVerifyVerified
+		meta.VerifyResult = fmt.Sprintf("%d tables restored", len(restored))
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Restore verification of %s backup %d succeeded: %s", meta.BackupTool, meta.Id, meta.VerifyResult)
+	}
+
+	if source := cluster.GetServerFromURL(meta.Source); source != nil {
+		if err := source.SaveBackupMetadata(meta); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to write verification of backup %d: %s", meta.Id, err)
+		}
+	}
+	cluster.CheckBackupVerify()
+
+	return meta, err
+}
+
+// CheckBackupVerify raises ERR00097 while the last verified backup failed its
+// restore verification
+func (cluster *Cluster) CheckBackupVerify() {
+	var last *config.BackupMetadata
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		if backup.VerifyState == config.BackupVerifyVerified || backup.VerifyState == config.BackupVerifyFailed {
+			if last == nil || last.VerifyTime.Before(backup.VerifyTime) {
+				last = backup
+			}
+		}
+		return true
+	})
+	if last != nil && last.VerifyState == config.BackupVerifyFailed {
+		cluster.SetState("ERR00097", state.State{ErrType: config.LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00097"], last.BackupTool, last.Id, last.Source, last.VerifyResult), ErrFrom: "BACKUP", ServerUrl: last.Source})
+	} else {
+		cluster.StateMachine.DeleteState("ERR00097")
+	}
+}
+
+// verifyBackupOnServer reseeds the verification replica without replication
+// and counts the rows of the restored tables
+func (cluster *Cluster) verifyBackupOnServer(meta *config.BackupMetadata) (config.TableRowCounts, error) {
+	server := cluster.GetServerFromURL(cluster.Conf.BackupVerifyServer)
+	if server == nil {
+		return nil, fmt.Errorf("Verification server %s not found in cluster", cluster.Conf.BackupVerifyServer)
+	}
+	if master := cluster.GetMaster(); master != nil && master.URL == server.URL {
+		return nil, fmt.Errorf("Verification server %s is the master", server.URL)
+	}
+
+	err := server.ReseedPointInTime(config.PointInTimeMeta{IsInPITR: true, UseBinlog: false, Backup: meta.Id})
+	if err != nil {
+		return nil, err
+	}
+	if server.Conn == nil {
+		return nil, fmt.Errorf("No connection to verification server %s", server.URL)
+	}
+
+	rows, logs, err := dbhelper.GetTableRowCounts(server.Conn)
+	cluster.LogSQL(logs, err, server.URL, "BackupVerify", config.LvlErr, "Could not count restored rows on %s: %s", server.URL, err)
+	return rows, err
+}
+
+// verifyBackupOnLocalhost restores the backup in a throwaway instance of the
+// mysqld of prov-db-binary-basedir listening on a socket only
+func (cluster *Cluster) verifyBackupOnLocalhost(meta *config.BackupMetadata) (config.TableRowCounts, error) {
+	dir := cluster.WorkingDir + "/backup-verify"
+	datadir := dir + "/data"
+	socket := dir + "/mysqld.sock"
+
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(datadir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var err error
+	if meta.BackupMethod == config.BackupMethodPhysical {
+		err = cluster.verifyPreparePhysical(meta, dir, datadir)
+	} else {
+		err = cluster.verifyInitDatadir(datadir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, db, err := cluster.verifyStartInstance(dir, datadir, socket)
+	if err != nil {
+		return nil, err
+	}
+	defer cluster.verifyStopInstance(cmd, db)
+
+	if meta.BackupMethod == config.BackupMethodLogical {
+		if err := cluster.verifyLoadLogical(meta, socket); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, logs, err := dbhelper.GetTableRowCounts(db)
+	cluster.LogSQL(logs, err, socket, "BackupVerify", config.LvlErr, "Could not count restored rows: %s", err)
+	return rows, err
+}
+
+func (cluster *Cluster) verifyRun(cmd *exec.Cmd) error {
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Command: %s", cmd.String())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "%s", out)
+		return fmt.Errorf("%s failed: %s", cmd.Path, err)
+	}
+	return nil
+}
+
+func (cluster *Cluster) verifyInitDatadir(datadir string) error {
+	version := cluster.LocalhostProvisionGetVersionFromMysqld(nil)
+	if version == "" {
+		return errors.New("mysqld --version not found")
+	}
+	if strings.Contains(version, "mariadb") {
+		return cluster.verifyRun(exec.Command(cluster.Conf.ProvDBClientBasedir+"/mysql_install_db", "--no-defaults", "--datadir="+datadir, "--basedir="+cluster.Conf.ProvDBBinaryBasedir+"/../", "--force"))
+	}
+	return cluster.verifyRun(exec.Command(cluster.Conf.ProvDBBinaryBasedir+"/mysqld", "--no-defaults", "--datadir="+datadir, "--basedir="+cluster.Conf.ProvDBBinaryBasedir+"/../", "--initialize-insecure"))
+}
+
+// verifyPreparePhysical extracts the backup chain and applies it on the full
+// backup extracted in the datadir
+func (cluster *Cluster) verifyPreparePhysical(meta *config.BackupMetadata, dir string, datadir string) error {
+	chain, err := cluster.BackupMetaMap.GetBackupChain(meta.Id)
+	if err != nil {
+		return err
+	}
+	tool := cluster.Conf.ProvDBClientBasedir + "/" + meta.BackupTool
+	stream := cluster.Conf.ProvDBClientBasedir + "/mbstream"
+	if meta.BackupTool == config.ConstBackupPhysicalTypeXtrabackup {
+		stream = cluster.Conf.ProvDBClientBasedir + "/xbstream"
+	}
+
+	for i, backup := range chain {
+		target := datadir
+		if i > 0 {
+			target = dir + "/inc/" + strconv.Itoa(i)
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		}
+		file, err := cluster.OpenBackupFile(backup.Dest)
+		if err != nil {
+			return err
+		}
+		var in io.Reader = file
+		if strings.HasSuffix(backup.Dest, ".gz") {
+			fz, err := gzip.NewReader(file)
+			if err != nil {
+				file.Close()
+				return err
+			}
+			in = fz
+		}
+		cmd := exec.Command(stream, "-x", "-C", target)
+		cmd.Stdin = in
+		err = cluster.verifyRun(cmd)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(chain) > 1 {
+		if err := cluster.verifyRun(exec.Command(tool, "--prepare", "--apply-log-only", "--target-dir="+datadir)); err != nil {
+			return err
+		}
+		for i := 1; i < len(chain); i++ {
+			if err := cluster.verifyRun(exec.Command(tool, "--prepare", "--apply-log-only", "--target-dir="+datadir, "--incremental-dir="+dir+"/inc/"+strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return cluster.verifyRun(exec.Command(tool, "--prepare", "--target-dir="+datadir))
+}
+
+// verifyStartInstance starts mysqld without grants nor networking and waits
+// until it accepts connections on its socket
+func (cluster *Cluster) verifyStartInstance(dir string, datadir string, socket string) (*exec.Cmd, *sqlx.DB, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := exec.Command(cluster.Conf.ProvDBBinaryBasedir+"/mysqld", "--no-defaults", "--datadir="+datadir, "--socket="+socket, "--pid-file="+dir+"/mysqld.pid", "--log-error="+dir+"/error.log", "--user="+usr.Username, "--skip-networking", "--skip-grant-tables", "--skip-slave-start", "--skip-log-bin")
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Command: %s", cmd.String())
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	for i := 0; i < 120; i++ {
+		time.Sleep(time.Second)
+		db, err := dbhelper.MySQLConnect("root", "", "unix("+socket+")", "timeout=5s")
+		if err == nil {
+			return cmd, db, nil
+		}
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	content, _ := os.ReadFile(dir + "/error.log")
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "%s", content)
+	return nil, nil, errors.New("Restored instance did not start")
+}
+
+func (cluster *Cluster) verifyStopInstance(cmd *exec.Cmd, db *sqlx.DB) {
+	db.Exec("SHUTDOWN")
+	db.Close()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+func (cluster *Cluster) verifyLoadLogical(meta *config.BackupMetadata, socket string) error {
+	switch meta.BackupTool {
+	case config.ConstBackupLogicalTypeMydumper:
+		backupdir, cleanup, err := cluster.GetClearBackupDir(meta.Dest)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		myargs := strings.Split(strings.ReplaceAll(cluster.Conf.BackupMyLoaderOptions, "  ", " "), " ")
+		myargs = append(myargs, "--directory="+backupdir, "--threads="+strconv.Itoa(cluster.Conf.BackupLogicalLoadThreads), "--socket="+socket, "--user=root")
+		return cluster.verifyRun(exec.Command(cluster.GetMyLoaderPath(), myargs...))
+	case config.ConstBackupLogicalTypeMysqldump:
+		file, err := cluster.OpenBackupFile(meta.Dest)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer fz.Close()
+		cmd := exec.Command(cluster.GetMysqlclientPath(), "--no-defaults", "--socket="+socket, "--user=root", "--force", "--batch")
+		cmd.Stdin = fz
+		// Accounts statements fail without grant tables, the row counts tell if the load failed
+		if err := cluster.verifyRun(cmd); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Restore verification load of %s: %s", meta.Dest, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("Restore verification of %s backups is not supported", meta.BackupTool)
+}
@@ -21,11 +21,14 @@ import (
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/router/maxscale"
 	"github.com/signal18/replication-manager/utils/alert"
+	"github.com/signal18/replication-manager/utils/crypto"
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/state"
 )
 
 func (cluster *Cluster) CheckFailed() {
+	_, span := cluster.StartSpan("Cluster.CheckFailed")
+	defer span.End()
 	// Don't trigger a failover if a switchover is happening
 	if cluster.StateMachine.IsInFailover() {
 		cluster.SetState("ERR00001", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00001"]), ErrFrom: "CHECK"})
@@ -44,6 +47,8 @@ func (cluster *Cluster) CheckFailed() {
 		cluster.isMaxClusterFailoverCountNotReached() &&
 		cluster.isAutomaticFailover() &&
 		cluster.isMasterFailed() &&
+		cluster.isZoneQuorumReached() &&
+		cluster.isCrossRegionFailoverAllowed() &&
 		cluster.isNotFirstSlave() &&
 		cluster.isArbitratorAlive() {
 
@@ -60,75 +65,80 @@ func (cluster *Cluster) CheckFailed() {
 }
 
 func (cluster *Cluster) isSlaveElectableForSwitchover(sl *ServerMonitor, forcingLog bool) bool {
+	return cluster.getSlaveElectableForSwitchoverIssue(sl, forcingLog) == ""
+}
+
+// getSlaveElectableForSwitchoverIssue returns why the slave can not be elected in switchover, empty when electable
+func (cluster *Cluster) getSlaveElectableForSwitchoverIssue(sl *ServerMonitor, forcingLog bool) string {
 	//Ignore if child cluster
 	if sl.GetSourceClusterName() != cluster.Name {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s is in child cluster. Skipping", sl.URL)
-		return false
+		return fmt.Sprintf("Slave %s is in child cluster", sl.URL)
 	}
 
 	ss, err := sl.GetSlaveStatus(sl.ReplicationSourceName)
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlDbg, "Error in getting slave status in testing slave electable for switchover %s: %s  ", sl.URL, err)
-		return false
+		return fmt.Sprintf("Error in getting slave status in testing slave electable for switchover %s: %s", sl.URL, err)
 	}
 	hasBinLogs, err := cluster.IsEqualBinlogFilters(cluster.master, sl)
 	if err != nil {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Could not check binlog filters on %s", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Could not check binlog filters on %s", sl.URL)
 	}
 	if (!cluster.Conf.SwitchLowerRelease) && (sl.DBVersion.Major < cluster.master.DBVersion.Major || (sl.DBVersion.Major == cluster.master.DBVersion.Major && sl.DBVersion.Minor < cluster.master.DBVersion.Minor)) {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Could not elect a minor version as leader without enabing switchover-lower-release %s", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Could not elect a minor version as leader without enabing switchover-lower-release %s", sl.URL)
 	}
 	if hasBinLogs == false && cluster.Conf.CheckBinFilter == true {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Binlog filters differ on master and slave %s. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Binlog filters differ on master and slave %s", sl.URL)
 	}
 	if cluster.IsEqualReplicationFilters(cluster.master, sl) == false && cluster.Conf.CheckReplFilter == true {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Replication filters differ on master and slave %s. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Replication filters differ on master and slave %s", sl.URL)
 	}
 	if cluster.Conf.SwitchGtidCheck && cluster.IsCurrentGTIDSync(sl, cluster.master) == false && cluster.Conf.RplChecks == true {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Equal-GTID option is enabled and GTID position on slave %s differs from master. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Equal-GTID option is enabled and GTID position on slave %s differs from master", sl.URL)
 	}
 	if sl.HaveSemiSync && sl.SemiSyncSlaveStatus == false && cluster.Conf.SwitchSync && cluster.Conf.RplChecks {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Semi-sync slave %s is out of sync. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Semi-sync slave %s is out of sync", sl.URL)
 	}
 
 	if ss.SecondsBehindMaster.Valid == false && cluster.Conf.RplChecks == true {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s is stopped. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Slave %s is stopped", sl.URL)
 	}
 
 	if sl.IsMaxscale || sl.IsRelay {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s is a relay slave. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Slave %s is a relay slave", sl.URL)
 	}
 
 	if sl.HasErrantTransactions() {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s has errent transactions. Skipping", sl.URL)
-		return false
+		return fmt.Sprintf("Slave %s has errent transactions", sl.URL)
 	}
 
-	return true
+	return ""
 }
 
 func (cluster *Cluster) isAutomaticFailover() bool {
@@ -510,15 +520,21 @@ func (cluster *Cluster) CheckAlert(state state.State, resolved bool) {
 	}
 
 	// exit even earlier
-	if cluster.Conf.MailTo == "" && cluster.Conf.AlertScript == "" {
+	if cluster.getNotifiers() == nil && cluster.Conf.AlertScript == "" {
 		return
 	}
 
 	if strings.Contains(cluster.Conf.MonitoringAlertTrigger, state.ErrKey) {
 		a := alert.Alert{
-			State:    state.ErrDesc,
-			Cluster:  cluster.Name,
-			Resolved: resolved,
+			Type:      alert.TypeState,
+			State:     state.ErrDesc,
+			Cluster:   cluster.Name,
+			Monitor:   cluster.Conf.MonitorAddress,
+			Host:      state.ServerUrl,
+			ErrKey:    state.ErrKey,
+			ErrType:   state.ErrType,
+			Resolved:  resolved,
+			Timestamp: time.Now(),
 		}
 
 		err := cluster.SendAlert(a)
@@ -529,159 +545,63 @@ func (cluster *Cluster) CheckAlert(state state.State, resolved bool) {
 	}
 }
 
-func (cluster *Cluster) SendAlert(alert alert.Alert) error {
+func (cluster *Cluster) SendAlert(a alert.Alert) error {
 	if cluster.IsAlertDisable {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Cancel alert caused by alert disabled from scheduler")
 		return nil
 	}
-	if cluster.Conf.MailTo != "" {
-		go alert.EmailMessage("", "", cluster.Conf)
+	if cluster.AlertSilences != nil && a.Type == alert.TypeState {
+		if s, ok := cluster.AlertSilences.Matching(a); ok {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Cancel alert %s on %s silenced by %s until %s", a.ErrKey, a.Host, s.Id, s.End.Format(time.RFC3339))
+			return nil
+		}
+	}
+	if cluster.AlertGrouper != nil && a.Type == alert.TypeState && !cluster.AlertGrouper.Add(a, time.Now()) {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlDbg, "Grouping flapping alert %s on %s", a.ErrKey, a.Host)
+		return nil
 	}
-	cluster.BashScriptAlert(alert)
+	cluster.routeAlert(a)
 
 	return nil
 }
 
-func (cluster *Cluster) CheckAllTableChecksum() {
-	for _, t := range cluster.master.Tables {
-		cluster.CheckTableChecksum(t.TableSchema, t.TableName)
+// routeAlert sends the alert to the channels of the matching routes, or to every channel without match
+func (cluster *Cluster) routeAlert(a alert.Alert) {
+	if notifiers := cluster.getNotifiers(); notifiers != nil {
+		channels, ok := alert.RouteChannels(cluster.AlertRoutes, a, cluster.Configurator.GetDBTags())
+		if !ok {
+			channels = notifiers.Names()
+		}
+		cluster.dispatchAlertChannels(notifiers, a, channels)
 	}
+	cluster.BashScriptAlert(a)
 }
 
-func (cluster *Cluster) CheckTableChecksum(schema string, table string) {
-
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum master table %s.%s %s", schema, table, cluster.master.URL)
-
-	Conn, err := cluster.master.GetNewDBConn()
-	if err != nil {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Error connection in exec query no log %s", err)
+// FlushGroupedAlerts sends the final state of the states that flapped during the group window
+func (cluster *Cluster) FlushGroupedAlerts() {
+	if cluster.AlertGrouper == nil {
 		return
 	}
-	defer Conn.Close()
-	Conn.SetConnMaxLifetime(3595 * time.Second)
-	pk, _ := cluster.master.GetTablePK(schema, table)
-	if pk == "" {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum, no primary key for table %s.%s", schema, table)
-		t := cluster.master.DictTables.Get(schema + "." + table)
-		t.TableSync = "NA"
-		cluster.master.DictTables.Set(schema+"."+table, t)
-		return
-	}
-	if strings.Contains(pk, ",") {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum, composit primary key for table %s.%s", schema, table)
+	for _, a := range cluster.AlertGrouper.Flush(time.Now()) {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Sending alert %s on %s after %d grouped transitions", a.ErrKey, a.Host, a.Flaps)
+		cluster.routeAlert(a)
 	}
-	Conn.Exec("CREATE DATABASE IF NOT EXISTS replication_manager_schema")
-	Conn.Exec("USE replication_manager_schema")
-	Conn.Exec("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ")
-	Conn.Exec("SET SESSION group_concat_max_len = 1000000")
+}
 
-	Conn.Exec("CREATE OR REPLACE TABLE replication_manager_schema.table_checksum(chunkId BIGINT,chunkMinKey VARCHAR(254),chunkMaxKey VARCHAR(254),chunkCheckSum BIGINT UNSIGNED ) ENGINE=MYISAM")
-	query := "CREATE TEMPORARY TABLE replication_manager_schema.table_chunk ENGINE=MYISAM SELECT FLOOR((@rows:=@rows+1/2000)) as chunkId, MIN(CONCAT_WS('/*;*/'," + pk + ")) as chunkMinKey, MAX(CONCAT_WS('/*;*/'," + pk + ")) as chunkMaxKey from " + schema + "." + table + " , (SELECT @rows:=0 FROM DUAL) A group by chunkId"
-	_, err = Conn.Exec(query)
-	Conn.Exec("SET SESSION binlog_format = 'STATEMENT'")
-	if err != nil {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "ERROR: Could not process chunck %s %s", query, err)
+// JournalState appends the state transition to the state journal
+func (cluster *Cluster) JournalState(s state.State, resolved bool) {
+	if !cluster.Conf.MonitoringStateJournal || cluster.StateJournal == nil {
 		return
 	}
-	var md5Sum string
-	err = Conn.QueryRowx("SELECT CONCAT( \"SUM(CRC32(CONCAT(\" , GROUP_CONCAT( CONCAT( \"IFNULL(\" , COLUMN_NAME, \",'N')\")),\")))\") as fields FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA ='" + schema + "' AND TABLE_NAME='" + table + "'").Scan(&md5Sum)
-	if err != nil {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "ERROR: Could not get SQL md5Sum", err)
-		return
-	}
-
-	// build predicate iterating over each pk columns
-	predicate := " 1=1"
-	pks := strings.Split(pk, ",")
-	//	var ftype string
-	for i, p := range pks {
-		/*	query := "SELECT (select COLUMN_TYPE from information_schema.columns C where C.TABLE_NAME=TABLE_NAME AND C.COLUMN_NAME=COLUMN_NAME AND C.TABLE_SCHEMA=TABLE_SCHEMA LIMIT 1) as TYPE   from information_schema.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME='PRIMARY' AND CONSTRAINT_SCHEMA='" + schema + "' AND TABLE_NAME='" + table + "' AND  ORDINAL_POSITION=" + strconv.Itoa(i+1)
-			err := Conn.QueryRowx(query).Scan(&ftype)
-			if err != nil {
-				cluster.LogModulePrintf(cluster.Conf.Verbose,config.ConstLogModGeneral,config.LvlErr, "ERROR: Could not fetch  datatype %s %s", query, err)
-				return
-			}
-			separator := ""
-			if strings.Contains(strings.ToLower(ftype), "char") || strings.Contains(strings.ToLower(ftype), "date") || strings.Contains(strings.ToLower(ftype), "enum") || strings.Contains(strings.ToLower(ftype), "timestamp") {
-				separator = "'"
-			}*/
-		predicate = predicate + " AND A." + p + " >= SUBSTRING_INDEX(SUBSTRING_INDEX(B.chunkMinKey,'/*;*/'," + strconv.Itoa(i+1) + "),'/*;*/',-1) and A." + p + "<= SUBSTRING_INDEX(SUBSTRING_INDEX(B.chunkMaxKey,'/*;*/'," + strconv.Itoa(i+1) + "),'/*;*/',-1)"
-	}
-
-	for true {
-		query := "INSERT INTO replication_manager_schema.table_checksum SELECT chunkId, chunkMinKey , chunkMaxKey," + md5Sum + " as chunkCheckSum FROM " + schema + "." + table + " A inner join (select * from replication_manager_schema.table_chunk limit 1) B on " + predicate
-		_, err := Conn.Exec(query)
-		if err != nil {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "ERROR: Could not process chunck %s %s", query, err)
-			return
-		}
-
-		_, err2 := Conn.Exec("DELETE FROM replication_manager_schema.table_chunk limit 1")
-		if err2 != nil {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum error deleting chunck %s", err)
-			return
-		}
-
-		var count int
-		err3 := Conn.QueryRowx("SELECT count(*) FROM replication_manager_schema.table_chunk").Scan(&count)
-		if err3 != nil {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum can't fetch rows remaining", err)
-			return
-		}
-		if count == 0 {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Finished checksum table %s.%s", schema, table)
-			break
-		}
-		/*	slave := cluster.GetFirstWorkingSlave()
-			if slave != nil {
-				if slave.GetReplicationDelay() > 5 {
-					time.Sleep(time.Duration(slave.GetReplicationDelay()) * time.Second)
-				}
-			}*/
-	}
-	cluster.master.Refresh()
-	masterSeq := cluster.master.CurrentGtid.GetSeqServerIdNos(uint64(cluster.master.ServerID))
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Wait sync: Master sequence %d", masterSeq)
-
-	for _, s := range cluster.slaves {
-		if !s.IsFailed() && !s.IsReplicationBroken() {
-			for true {
-				slaveSeq := s.SlaveGtid.GetSeqServerIdNos(uint64(cluster.master.ServerID))
-				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Wait sync on slave %s sequence %d", s.URL, slaveSeq)
-				if slaveSeq >= masterSeq {
-					break
-				} else {
-					cluster.SetState("WARN0086", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["WARN0086"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
-				}
-				time.Sleep(1 * time.Second)
-			}
-
-		}
+	cluster.StateJournal.SetKeep(time.Duration(cluster.Conf.MonitoringStateJournalKeep) * 24 * time.Hour)
+	var err error
+	if resolved {
+		err = cluster.StateJournal.Resolve(s, time.Now())
+	} else {
+		err = cluster.StateJournal.Open(s, time.Now())
 	}
-	// check slave result
-	masterChecksums, logs, err := dbhelper.GetTableChecksumResult(cluster.master.Conn)
-	cluster.LogSQL(logs, err, cluster.master.URL, "CheckTableChecksum", config.LvlDbg, "GetTableChecksumResult")
-	for _, s := range cluster.slaves {
-		slaveChecksums, logs, err := dbhelper.GetTableChecksumResult(s.Conn)
-		cluster.LogSQL(logs, err, s.URL, "CheckTableChecksum", config.LvlDbg, "GetTableChecksumResult")
-		checkok := true
-
-		for _, chunk := range masterChecksums {
-			if chunk.ChunkCheckSum != slaveChecksums[chunk.ChunkId].ChunkCheckSum {
-				checkok = false
-				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table failed chunk(%s,%s) %s.%s %s", chunk.ChunkMinKey, chunk.ChunkMaxKey, schema, table, s.URL)
-				t := cluster.master.DictTables.Get(schema + "." + table)
-				t.TableSync = "ER"
-				cluster.master.DictTables.Set(schema+"."+table, t)
-			}
-
-		}
-		if checkok {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table succeed %s.%s %s", schema, table, s.URL)
-			t := cluster.master.DictTables.Get(schema + "." + table)
-			t.TableSync = "OK"
-			cluster.master.DictTables.Set(schema+"."+table, t)
-		}
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not journal state %s: %s", s.ErrKey, err)
 	}
 }
 
@@ -704,6 +624,12 @@ func (cluster *Cluster) CheckSameServerID() {
 }
 
 func (cluster *Cluster) IsSameWsrepUUID() bool {
+	return cluster.isSameWsrepUUID(false)
+}
+
+// isSameWsrepUUID checks the wsrep state UUID of the nodes, a simulation does
+// not raise ERR00083
+func (cluster *Cluster) isSameWsrepUUID(simulate bool) bool {
 	if cluster.GetTopology() != topoMultiMasterWsrep {
 		return true
 	}
@@ -716,7 +642,7 @@ func (cluster *Cluster) IsSameWsrepUUID() bool {
 				continue
 			}
 			if s.Status.Get("WSREP_CLUSTER_STATE_UUID") != sothers.Status.Get("WSREP_CLUSTER_STATE_UUID") {
-				cluster.SetState("ERR00083", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00083"], s.URL, s.Status.Get("WSREP_CLUSTER_STATE_UUID"), sothers.URL, sothers.Status.Get("WSREP_CLUSTER_STATE_UUID")), ErrFrom: "MON", ServerUrl: s.URL})
+				cluster.setElectionState(simulate, "ERR00083", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00083"], s.URL, s.Status.Get("WSREP_CLUSTER_STATE_UUID"), sothers.URL, sothers.Status.Get("WSREP_CLUSTER_STATE_UUID")), ErrFrom: "MON", ServerUrl: s.URL})
 				return false
 			}
 		}
@@ -725,6 +651,12 @@ func (cluster *Cluster) IsSameWsrepUUID() bool {
 }
 
 func (cluster *Cluster) IsNotHavingMySQLErrantTransaction() bool {
+	return cluster.isNotHavingMySQLErrantTransaction(false)
+}
+
+// isNotHavingMySQLErrantTransaction checks the replicas for errant
+// transactions, a simulation does not raise WARN0091
+func (cluster *Cluster) isNotHavingMySQLErrantTransaction(simulate bool) bool {
 	if cluster.GetMaster() == nil {
 		// disable check if master is crashed as the slave can get more GTID events and so slave GTID is not ubset of masetr GTID
 		return true
@@ -742,7 +674,7 @@ func (cluster *Cluster) IsNotHavingMySQLErrantTransaction() bool {
 
 		hasErrantTrx, _, _ := dbhelper.HaveErrantTransactions(s.Conn, cluster.master.Variables.Get("GTID_EXECUTED"), s.Variables.Get("GTID_EXECUTED"))
 		if hasErrantTrx {
-			cluster.SetState("WARN0091", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0091"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
+			cluster.setElectionState(simulate, "WARN0091", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0091"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
 			return false
 		}
 	}
@@ -862,11 +794,10 @@ func (cluster *Cluster) PushConfigs() {
 
 func (cluster *Cluster) CheckDefaultUser(i bool) {
 	creds := make([]string, 0)
-	if cluster.APIUsers["admin"].Password == "repman" {
-		creds = append(creds, "admin")
-	}
-	if cluster.APIUsers["dba"].Password == "repman" {
-		creds = append(creds, "dba")
+	for _, user := range []string{"admin", "dba"} {
+		if u, ok := cluster.APIUsers[user]; ok && crypto.CheckPassword(u.Password, "repman") {
+			creds = append(creds, user)
+		}
 	}
 	out := strings.Join(creds, ",")
 	if out != "" {
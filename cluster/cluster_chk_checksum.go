@@ -0,0 +1,698 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// tableChecksum is the table checksum job of the cluster, one job runs at a
+// time
+type tableChecksum struct {
+	sync.Mutex
+	status  config.ChecksumStatus
+	running bool
+	resumed bool
+}
+
+const checksumResultTable = "replication_manager_schema.checksums"
+
+// The chunk checksums are computed by each server replaying the statement,
+// the master values are replicated in master_cnt and master_crc
+const checksumResultTableDDL = "CREATE TABLE IF NOT EXISTS " + checksumResultTable + " (db VARCHAR(64) NOT NULL, tbl VARCHAR(64) NOT NULL, chunk INT NOT NULL, lower_boundary TEXT, upper_boundary TEXT, this_cnt BIGINT UNSIGNED NOT NULL DEFAULT 0, this_crc BIGINT UNSIGNED NOT NULL DEFAULT 0, master_cnt BIGINT UNSIGNED, master_crc BIGINT UNSIGNED, ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, PRIMARY KEY (db, tbl, chunk)) ENGINE=InnoDB"
+
+// GetChecksumStatus returns the progress and the differences found by the
+// last table checksum job
+func (cluster *Cluster) GetChecksumStatus() config.ChecksumStatus {
+	c := &cluster.tableChecksum
+	c.Lock()
+	defer c.Unlock()
+	status := c.status
+	status.Tables = make([]*config.ChecksumTable, len(c.status.Tables))
+	for i, t := range c.status.Tables {
+		table := *t
+		status.Tables[i] = &table
+	}
+	return status
+}
+
+// CheckAllTableChecksum checksums the tables of the master on the replicas
+func (cluster *Cluster) CheckAllTableChecksum() {
+	master := cluster.GetMaster()
+	if master == nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum, no master")
+		return
+	}
+	tables := make([]*config.ChecksumTable, 0, len(master.Tables))
+	for i := range master.Tables {
+		tables = append(tables, &config.ChecksumTable{Schema: master.Tables[i].TableSchema, Table: master.Tables[i].TableName})
+	}
+	cluster.StartTableChecksum(tables, cluster.Conf.ChecksumRepair)
+}
+
+// CheckTableChecksum checksums a table of the master on the replicas
+func (cluster *Cluster) CheckTableChecksum(schema string, table string) {
+	cluster.StartTableChecksum([]*config.ChecksumTable{{Schema: schema, Table: table}}, cluster.Conf.ChecksumRepair)
+}
+
+// StartTableChecksum runs a table checksum job repairing the differences
+// following repair, refused while another job runs
+func (cluster *Cluster) StartTableChecksum(tables []*config.ChecksumTable, repair string) error {
+	if err := cluster.newTableChecksum(tables, repair); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlWarn, "%s, cancel checksum of %d tables", err, len(tables))
+		return err
+	}
+	cluster.runTableChecksum()
+	return nil
+}
+
+var errChecksumRunning = errors.New("Checksum already running")
+
+// newTableChecksum reserves the table checksum job for tables
+func (cluster *Cluster) newTableChecksum(tables []*config.ChecksumTable, repair string) error {
+	c := &cluster.tableChecksum
+	c.Lock()
+	defer c.Unlock()
+	if c.running {
+		return errChecksumRunning
+	}
+	c.running = true
+	c.resumed = true
+	c.status = config.ChecksumStatus{Running: true, Start: time.Now(), Tables: tables, Repair: repair}
+	return nil
+}
+
+// getChecksumRepair returns the repair mode of the running checksum job, jobs
+// saved without one follow checksum-repair
+func (cluster *Cluster) getChecksumRepair() string {
+	c := &cluster.tableChecksum
+	c.Lock()
+	defer c.Unlock()
+	if c.status.Repair == "" {
+		return cluster.Conf.ChecksumRepair
+	}
+	return c.status.Repair
+}
+
+// ResumeTableChecksum resumes the table checksum job interrupted by a stop of
+// replication-manager, from the last chunk saved
+func (cluster *Cluster) ResumeTableChecksum() {
+	if cluster.GetMaster() == nil {
+		return
+	}
+	c := &cluster.tableChecksum
+	c.Lock()
+	defer c.Unlock()
+	if c.resumed {
+		return
+	}
+	c.resumed = true
+	content, err := os.ReadFile(cluster.getChecksumStateFile())
+	if err != nil {
+		return
+	}
+	var status config.ChecksumStatus
+	if err := json.Unmarshal(content, &status); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlWarn, "Failed to read checksum state: %s", err)
+		return
+	}
+	c.status = status
+	if !status.Running || c.running {
+		return
+	}
+	c.running = true
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Resuming checksum of %d tables started at %s", len(status.Tables), status.Start.Format(time.RFC3339))
+	go cluster.runTableChecksum()
+}
+
+func (cluster *Cluster) getChecksumStateFile() string {
+	return cluster.WorkingDir + "/checksum.json"
+}
+
+// saveChecksumTable records the progress of the i-th table and saves the job
+// state, the table is copied so the job keeps working on its own
+func (cluster *Cluster) saveChecksumTable(i int, t *config.ChecksumTable) {
+	c := &cluster.tableChecksum
+	c.Lock()
+	if t != nil {
+		table := *t
+		c.status.Tables[i] = &table
+	}
+	content, err := json.MarshalIndent(c.status, "", "\t")
+	c.Unlock()
+	if err == nil {
+		fname := cluster.getChecksumStateFile()
+		if err = os.WriteFile(fname+".tmp", content, 0600); err == nil {
+			err = os.Rename(fname+".tmp", fname)
+		}
+	}
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Failed to save checksum state: %s", err)
+	}
+}
+
+func (cluster *Cluster) runTableChecksum() {
+	c := &cluster.tableChecksum
+	c.Lock()
+	tables := make([]config.ChecksumTable, len(c.status.Tables))
+	for i, t := range c.status.Tables {
+		tables[i] = *t
+	}
+	c.Unlock()
+
+	if err := cluster.checkChecksumPrivileges(); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum of %d tables cancelled: %s", len(tables), err)
+		cluster.endTableChecksum(err)
+		return
+	}
+
+	var jobErr error
+	for i := range tables {
+		t := &tables[i]
+		if t.Sync != "" {
+			continue
+		}
+		err := cluster.checksumTable(i, t)
+		if err != nil {
+			t.Error = err.Error()
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum table %s failed: %s", t.GetName(), err)
+			cluster.saveChecksumTable(i, t)
+			if errors.Is(err, errChecksumMaster) {
+				jobErr = err
+				break
+			}
+		}
+	}
+
+	cluster.endTableChecksum(jobErr)
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Finished checksum of %d tables", len(tables))
+}
+
+// endTableChecksum ends the table checksum job with its error
+func (cluster *Cluster) endTableChecksum(jobErr error) {
+	c := &cluster.tableChecksum
+	c.Lock()
+	c.status.Running = false
+	c.status.End = time.Now()
+	if jobErr != nil {
+		c.status.Error = jobErr.Error()
+	}
+	c.running = false
+	c.Unlock()
+	cluster.saveChecksumTable(0, nil)
+}
+
+var errChecksumMaster = errors.New("Master changed during checksum")
+
+// checkChecksumPrivileges tests that the master user can set the session
+// binlog_format of the chunks and of the repairs, it needs SUPER or
+// SYSTEM_VARIABLES_ADMIN
+func (cluster *Cluster) checkChecksumPrivileges() error {
+	master := cluster.GetMaster()
+	if master == nil {
+		return errChecksumMaster
+	}
+	db, err := master.GetNewDBConn()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec("SET SESSION binlog_format='STATEMENT'"); err != nil {
+		cluster.SetState("ERR00100", state.State{ErrType: "ERROR", ErrDesc: fmt.Sprintf(clusterError["ERR00100"], master.URL, err), ErrFrom: "CHECK", ServerUrl: master.URL})
+		return fmt.Errorf("SUPER or SYSTEM_VARIABLES_ADMIN privilege needed to set binlog_format on %s: %w", master.URL, err)
+	}
+	return nil
+}
+
+// getChecksumColumns returns the primary key and the columns of a table in
+// their order
+func getChecksumColumns(conn *sqlx.Conn, schema string, table string) ([]string, []string, error) {
+	ctx := context.Background()
+	var keys, columns []string
+	err := conn.SelectContext(ctx, &keys, "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME='PRIMARY' AND TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION", schema, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = conn.SelectContext(ctx, &columns, "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION", schema, table)
+	return keys, columns, err
+}
+
+func getChecksumBoundary(values []string) string {
+	if values == nil {
+		return "NULL"
+	}
+	content, _ := json.Marshal(values)
+	s := string(content)
+	return config.QuoteValue(&s)
+}
+
+func parseChecksumBoundary(value sql.NullString) []string {
+	var values []string
+	if value.Valid {
+		json.Unmarshal([]byte(value.String), &values)
+	}
+	return values
+}
+
+func (cluster *Cluster) setTableSync(t *config.ChecksumTable, sync string) {
+	t.Sync = sync
+	if master := cluster.GetMaster(); master != nil {
+		if dt := master.DictTables.Get(t.GetName()); dt != nil {
+			dt.TableSync = sync
+			master.DictTables.Set(t.GetName(), dt)
+		}
+	}
+}
+
+// waitChecksumThrottle pauses the checksum while a replica is delayed or the
+// master is loaded over the checksum thresholds
+func (cluster *Cluster) waitChecksumThrottle(t *config.ChecksumTable) {
+	paused := false
+	for {
+		reason := cluster.Conf.GetChecksumThrottleReason(cluster.getReplicationLoad())
+		if reason == "" {
+			if paused {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s resumed", t.GetName())
+			}
+			return
+		}
+		if !paused {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s paused: %s", t.GetName(), reason)
+			paused = true
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// checksumTable checksums the chunks of a table following the last chunk
+// saved, the chunks are replicated in statement format so that each replica
+// computes its own checksum, then compares the replicas
+func (cluster *Cluster) checksumTable(i int, t *config.ChecksumTable) error {
+	master := cluster.GetMaster()
+	if master == nil {
+		return errChecksumMaster
+	}
+	db, err := master.GetNewDBConn()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if t.Keys == nil {
+		keys, columns, err := getChecksumColumns(conn, t.Schema, t.Table)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum, no primary key for table %s", t.GetName())
+			t.Done = true
+			cluster.setTableSync(t, "NA")
+			cluster.saveChecksumTable(i, t)
+			return nil
+		}
+		t.Keys, t.Columns = keys, columns
+	}
+	for _, query := range []string{
+		"SET SESSION binlog_format='STATEMENT'",
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"CREATE DATABASE IF NOT EXISTS replication_manager_schema",
+		checksumResultTableDDL,
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+
+	schema, table := config.QuoteValue(&t.Schema), config.QuoteValue(&t.Table)
+	where := fmt.Sprintf("db=%s AND tbl=%s", schema, table)
+	if t.Chunks == 0 && !t.Done {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum master table %s %s", t.GetName(), master.URL)
+		if _, err := conn.ExecContext(ctx, "DELETE FROM "+checksumResultTable+" WHERE "+where); err != nil {
+			return err
+		}
+		t.ChunkSize = cluster.Conf.ChecksumChunkSize
+	} else if !t.Done {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum master table %s %s resumed at chunk %d", t.GetName(), master.URL, t.Chunks)
+	}
+
+	name := config.QuoteIdentifier(t.Schema) + "." + config.QuoteIdentifier(t.Table)
+	keys := make([]string, len(t.Keys))
+	for k, key := range t.Keys {
+		keys[k] = config.QuoteIdentifier(key)
+	}
+	target := time.Duration(cluster.Conf.ChecksumChunkTime) * time.Millisecond
+	for !t.Done {
+		if cluster.GetMaster() != master {
+			return errChecksumMaster
+		}
+		cluster.waitChecksumThrottle(t)
+
+		// The chunk ends at the ChunkSize-th row after the last one, the last
+		// chunk has no upper boundary
+		var upper []string
+		row := make([]sql.NullString, len(keys))
+		dest := make([]interface{}, len(keys))
+		for k := range row {
+			dest[k] = &row[k]
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s FORCE INDEX (`PRIMARY`) WHERE %s ORDER BY %s LIMIT 1 OFFSET %d", strings.Join(keys, ","), name, config.GetChunkPredicate(t.Keys, t.LastKey, nil), strings.Join(keys, ","), t.ChunkSize-1)
+		err := conn.QueryRowxContext(ctx, query).Scan(dest...)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			upper = make([]string, len(row))
+			for k := range row {
+				upper[k] = row[k].String
+			}
+		}
+
+		start := time.Now()
+		cnt, err := checksumChunk(ctx, conn, t, t.Chunks, t.LastKey, upper)
+		if err != nil {
+			return fmt.Errorf("Could not process chunk %d: %w", t.Chunks, err)
+		}
+		elapsed := time.Since(start)
+
+		t.Chunks++
+		t.Rows += int64(cnt)
+		t.LastKey = upper
+		t.Done = upper == nil
+		t.ChunkSize = config.GetChecksumChunkSize(t.ChunkSize, elapsed, target, cluster.Conf.ChecksumChunkSizeMax)
+		cluster.saveChecksumTable(i, t)
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Finished checksum table %s, %d rows in %d chunks", t.GetName(), t.Rows, t.Chunks)
+
+	cluster.compareTableChecksum(ctx, conn, master, t)
+	cluster.saveChecksumTable(i, t)
+	return nil
+}
+
+// checksumChunk checksums the rows of a chunk on the master and saves the
+// master values replicated with it, it returns the rows of the chunk
+func checksumChunk(ctx context.Context, conn *sqlx.Conn, t *config.ChecksumTable, chunk int, lower []string, upper []string) (uint64, error) {
+	schema, table := config.QuoteValue(&t.Schema), config.QuoteValue(&t.Table)
+	name := config.QuoteIdentifier(t.Schema) + "." + config.QuoteIdentifier(t.Table)
+	query := fmt.Sprintf("REPLACE INTO %s (db, tbl, chunk, lower_boundary, upper_boundary, this_cnt, this_crc) SELECT %s, %s, %d, %s, %s, COUNT(*), %s FROM %s FORCE INDEX (`PRIMARY`) WHERE %s",
+		checksumResultTable, schema, table, chunk, getChecksumBoundary(lower), getChecksumBoundary(upper), config.GetChecksumExpression(t.Columns), name, config.GetChunkPredicate(t.Keys, lower, upper))
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return 0, err
+	}
+	where := fmt.Sprintf("db=%s AND tbl=%s AND chunk=%d", schema, table, chunk)
+	var cnt, crc uint64
+	if err := conn.QueryRowxContext(ctx, "SELECT this_cnt, this_crc FROM "+checksumResultTable+" WHERE "+where).Scan(&cnt, &crc); err != nil {
+		return 0, err
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET master_cnt=%d, master_crc=%d WHERE %s", checksumResultTable, cnt, crc, where)); err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
+
+// waitChecksumSync waits for a replica to replay a chunk, until the query
+// returns a count above 0 or checksum-sync-timeout
+func (cluster *Cluster) waitChecksumSync(s *ServerMonitor, query string) error {
+	timeout := time.Now().Add(time.Duration(cluster.Conf.ChecksumSyncTimeout) * time.Second)
+	for {
+		if s.IsFailed() || s.IsReplicationBroken() {
+			return errors.New("Replication stopped")
+		}
+		var count int
+		if err := s.Conn.QueryRowx(query).Scan(&count); err == nil && count > 0 {
+			return nil
+		}
+		if time.Now().After(timeout) {
+			return fmt.Errorf("Replica not synced after %d seconds", cluster.Conf.ChecksumSyncTimeout)
+		}
+		cluster.SetState("WARN0086", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["WARN0086"], s.URL), ErrFrom: "MON", ServerUrl: s.URL})
+		time.Sleep(time.Second)
+	}
+}
+
+// compareTableChecksum compares the chunk checksums of the replicas to the
+// master ones and diffs the rows of the chunks still differing on a recheck
+func (cluster *Cluster) compareTableChecksum(ctx context.Context, conn *sqlx.Conn, master *ServerMonitor, t *config.ChecksumTable) {
+	t.Diffs = nil
+	t.NotCompared = nil
+	sync := "OK"
+	last := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE db=%s AND tbl=%s AND chunk=%d AND master_cnt IS NOT NULL", checksumResultTable, config.QuoteValue(&t.Schema), config.QuoteValue(&t.Table), t.Chunks-1)
+	for _, s := range cluster.slaves {
+		if s.IsFailed() || s.IsReplicationBroken() {
+			continue
+		}
+		if err := cluster.waitChecksumSync(s, last); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlWarn, "Checksum table %s not compared on %s: %s", t.GetName(), s.URL, err)
+			t.NotCompared = append(t.NotCompared, s.URL)
+			continue
+		}
+		rows, err := s.Conn.Queryx(fmt.Sprintf("SELECT chunk, lower_boundary, upper_boundary FROM %s WHERE db=%s AND tbl=%s AND chunk>=0 AND (master_cnt IS NULL OR this_cnt<>master_cnt OR this_crc<>master_crc) ORDER BY chunk", checksumResultTable, config.QuoteValue(&t.Schema), config.QuoteValue(&t.Table)))
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum table %s can't fetch result on %s: %s", t.GetName(), s.URL, err)
+			continue
+		}
+		var diffs []config.ChecksumDiff
+		for rows.Next() {
+			var diff config.ChecksumDiff
+			var lower, upper sql.NullString
+			if err := rows.Scan(&diff.Chunk, &lower, &upper); err != nil {
+				break
+			}
+			diff.Server = s.URL
+			diff.Lower, diff.Upper = parseChecksumBoundary(lower), parseChecksumBoundary(upper)
+			diffs = append(diffs, diff)
+		}
+		rows.Close()
+		var repair []string
+		var differing []config.ChecksumDiff
+		for d := range diffs {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table failed chunk %d (%s,%s) %s %s", diffs[d].Chunk, strings.Join(diffs[d].Lower, ","), strings.Join(diffs[d].Upper, ","), t.GetName(), s.URL)
+			synced, err := cluster.recheckChecksumChunk(ctx, conn, s, t, diffs[d])
+			if err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum table %s can't recheck chunk %d on %s: %s", t.GetName(), diffs[d].Chunk, s.URL, err)
+				differing = append(differing, diffs[d])
+				continue
+			}
+			if synced {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s chunk %d in sync on %s at recheck", t.GetName(), diffs[d].Chunk, s.URL)
+				continue
+			}
+			statements, err := cluster.diffChecksumChunk(master, s, t, &diffs[d])
+			if err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum table %s can't diff chunk %d on %s: %s", t.GetName(), diffs[d].Chunk, s.URL, err)
+			}
+			repair = append(repair, statements...)
+			differing = append(differing, diffs[d])
+		}
+		if len(differing) == 0 {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table succeed %s %s", t.GetName(), s.URL)
+			continue
+		}
+		sync = "ER"
+		diffs = differing
+		if cluster.getChecksumRepair() == config.ChecksumRepairGenerate && len(repair) > 0 {
+			fname := fmt.Sprintf("%s/checksum-repair-%s-%s.sql", cluster.WorkingDir, t.GetName(), s.Id)
+			content := "SET SESSION binlog_format='STATEMENT';\n" + strings.Join(repair, ";\n") + ";\n"
+			if err := os.WriteFile(fname, []byte(content), 0600); err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Checksum table %s can't write repair: %s", t.GetName(), err)
+			} else {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s repair of %s written to %s, to run on the master", t.GetName(), s.URL, fname)
+				for d := range diffs {
+					diffs[d].Repair = fname
+				}
+			}
+		}
+		t.Diffs = append(t.Diffs, diffs...)
+	}
+	if sync == "OK" && len(t.NotCompared) > 0 {
+		sync = "NC"
+	}
+	cluster.setTableSync(t, sync)
+}
+
+// recheckChecksumChunk checksums again on the master a chunk differing on a
+// replica, as chunk -chunk-1, and compares it once the replica has replayed
+// it. The rows diffed are not read in a consistent snapshot, a chunk written
+// since its checksum is only reported when it still differs. It returns true
+// when the chunk is in sync.
+func (cluster *Cluster) recheckChecksumChunk(ctx context.Context, conn *sqlx.Conn, s *ServerMonitor, t *config.ChecksumTable, diff config.ChecksumDiff) (bool, error) {
+	table := fmt.Sprintf("%s WHERE db=%s AND tbl=%s AND chunk=%d", checksumResultTable, config.QuoteValue(&t.Schema), config.QuoteValue(&t.Table), -diff.Chunk-1)
+	// The recheck of another replica is removed first so that the replica
+	// compares the new one
+	if _, err := conn.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+		return false, err
+	}
+	if err := cluster.waitChecksumSync(s, "SELECT COUNT(*)=0 FROM "+table); err != nil {
+		return false, err
+	}
+	if _, err := checksumChunk(ctx, conn, t, -diff.Chunk-1, diff.Lower, diff.Upper); err != nil {
+		return false, err
+	}
+	if err := cluster.waitChecksumSync(s, "SELECT COUNT(*) FROM "+table+" AND master_cnt IS NOT NULL"); err != nil {
+		return false, err
+	}
+	var synced int
+	err := s.Conn.QueryRowx("SELECT COUNT(*) FROM " + table + " AND this_cnt=master_cnt AND this_crc=master_crc").Scan(&synced)
+	return synced > 0, err
+}
+
+// getChecksumRows returns the rows of a chunk
+func getChecksumRows(db *sqlx.DB, query string) ([]config.ChecksumRow, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []config.ChecksumRow
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, newChecksumRow(values))
+	}
+	return result, rows.Err()
+}
+
+func newChecksumRow(values []sql.NullString) config.ChecksumRow {
+	row := make(config.ChecksumRow, len(values))
+	for i, v := range values {
+		if v.Valid {
+			s := v.String
+			row[i] = &s
+		}
+	}
+	return row
+}
+
+// diffChecksumChunk compares the rows of a chunk of the master and of a
+// replica, and returns the statements repairing the replica or applies them
+// following checksum-repair
+func (cluster *Cluster) diffChecksumChunk(master *ServerMonitor, s *ServerMonitor, t *config.ChecksumTable, diff *config.ChecksumDiff) ([]string, error) {
+	name := config.QuoteIdentifier(t.Schema) + "." + config.QuoteIdentifier(t.Table)
+	cols := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = config.QuoteIdentifier(c)
+	}
+	keys := make([]string, len(t.Keys))
+	for i, k := range t.Keys {
+		keys[i] = config.QuoteIdentifier(k)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s", strings.Join(cols, ","), name, config.GetChunkPredicate(t.Keys, diff.Lower, diff.Upper), strings.Join(keys, ","))
+	masterRows, err := getChecksumRows(master.Conn, query)
+	if err != nil {
+		return nil, err
+	}
+	replicaRows, err := getChecksumRows(s.Conn, query)
+	if err != nil {
+		return nil, err
+	}
+	missing, extra, different := config.DiffChecksumRows(t.GetKeyIndexes(), masterRows, replicaRows)
+	diff.Missing, diff.Extra, diff.Different = len(missing), len(extra), len(different)
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s chunk %d on %s: %d rows missing, %d extra, %d different", t.GetName(), diff.Chunk, s.URL, diff.Missing, diff.Extra, diff.Different)
+
+	switch cluster.getChecksumRepair() {
+	case config.ChecksumRepairGenerate:
+		statements := make([]string, 0, len(missing)+len(extra)+len(different))
+		for _, row := range append(missing, different...) {
+			statements = append(statements, t.GetChecksumRepair(row, row))
+		}
+		for _, row := range extra {
+			statements = append(statements, t.GetChecksumRepair(nil, row))
+		}
+		return statements, nil
+	case config.ChecksumRepairApply:
+		if err := cluster.applyChecksumRepair(master, t, append(append(missing, different...), extra...)); err != nil {
+			return nil, err
+		}
+		diff.Repair = "applied"
+	}
+	return nil, nil
+}
+
+// applyChecksumRepair writes again on the master the rows of the keys, or
+// deletes them when the master does not have them, in statement format so
+// that the replicas get the master rows. Each row is locked while read so
+// that a concurrent change is not overwritten.
+func (cluster *Cluster) applyChecksumRepair(master *ServerMonitor, t *config.ChecksumTable, keyRows []config.ChecksumRow) error {
+	db, err := master.GetNewDBConn()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET SESSION binlog_format='STATEMENT'"); err != nil {
+		return err
+	}
+	name := config.QuoteIdentifier(t.Schema) + "." + config.QuoteIdentifier(t.Table)
+	cols := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = config.QuoteIdentifier(c)
+	}
+	for _, key := range keyRows {
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		var row config.ChecksumRow
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s FOR UPDATE", strings.Join(cols, ","), name, config.GetKeyPredicate(t.Keys, "=", t.GetKeyValues(key)))).Scan(dest...)
+		if err != nil && err != sql.ErrNoRows {
+			tx.Rollback()
+			return err
+		}
+		if err == nil {
+			row = newChecksumRow(values)
+		}
+		if _, err := tx.ExecContext(ctx, t.GetChecksumRepair(row, key)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checksum table %s repaired %d rows through replication", t.GetName(), len(keyRows))
+	return nil
+}
@@ -0,0 +1,365 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/gtid"
+)
+
+// Errant transactions listed or injected at once
+const maxErrantTransactions = 10000
+
+// Events listed by errant transaction
+const maxErrantTransactionEvents = 100
+
+// Events read from a binary log looking for the errant transactions
+const maxErrantScannedEvents = 100000
+
+// GetErrantTransactions returns the errant transactions of the MySQL GTID
+// replicas, the transactions they executed and the master did not. The binary
+// logs are only read for the events of the replica named by server, empty
+// lists the errant sets of all replicas.
+func (cluster *Cluster) GetErrantTransactions(server string) ([]config.ErrantTransactions, error) {
+	master := cluster.GetMaster()
+	if master == nil {
+		return nil, errors.New("No master")
+	}
+	if !master.HasMySQLGTID() {
+		return nil, errors.New("Errant transactions need MySQL GTID")
+	}
+	var replica *ServerMonitor
+	if server != "" {
+		if replica = cluster.GetServerFromName(server); replica == nil {
+			replica = cluster.GetServerFromURL(server)
+		}
+		if replica == nil {
+			return nil, fmt.Errorf("Server %s not found", server)
+		}
+	}
+	list := make([]config.ErrantTransactions, 0)
+	for _, s := range cluster.slaves {
+		if s.IsFailed() || (replica != nil && s != replica) {
+			continue
+		}
+		errant, err := s.GetErrantTransactions(master, s == replica)
+		if err != nil {
+			errant.Error = err.Error()
+		}
+		if errant.Set != "" || errant.Error != "" {
+			list = append(list, errant)
+		}
+	}
+	return list, nil
+}
+
+// GetErrantTransactions returns the GTID set executed by the server and not
+// by the master, with the binary log events of each transaction with events
+func (server *ServerMonitor) GetErrantTransactions(master *ServerMonitor, events bool) (config.ErrantTransactions, error) {
+	errant := config.ErrantTransactions{Server: server.URL}
+	var masterSet string
+	if err := master.Conn.QueryRowx("SELECT @@GLOBAL.gtid_executed").Scan(&masterSet); err != nil {
+		return errant, err
+	}
+	if err := server.Conn.QueryRowx("SELECT GTID_SUBTRACT(@@GLOBAL.gtid_executed, ?)", masterSet).Scan(&errant.Set); err != nil {
+		return errant, err
+	}
+	errant.Set = strings.Join(strings.Fields(errant.Set), "")
+	if errant.Set == "" || !events {
+		return errant, nil
+	}
+	gtids, err := gtid.ExpandMySQLSet(errant.Set, maxErrantTransactions)
+	if err != nil {
+		return errant, err
+	}
+	errant.Transactions, err = server.getErrantTransactionEvents(gtids)
+	return errant, err
+}
+
+// getErrantTransactionEvents finds the binary log of each transaction from
+// the previous GTIDs of the binary logs and lists its events
+func (server *ServerMonitor) getErrantTransactionEvents(gtids []string) ([]config.ErrantTransaction, error) {
+	trxs := make([]config.ErrantTransaction, len(gtids))
+	for i, g := range gtids {
+		trxs[i].Gtid = g
+	}
+	var purgedSet string
+	if err := server.Conn.QueryRowx("SELECT @@GLOBAL.gtid_purged").Scan(&purgedSet); err != nil {
+		return trxs, err
+	}
+	purged, _ := mysql.ParseMysqlGTIDSet(purgedSet)
+
+	files, err := server.getBinaryLogs()
+	if err != nil {
+		return trxs, err
+	}
+	// The previous GTIDs of the next binary log tell the GTIDs of a binary log
+	previous := make([]mysql.GTIDSet, len(files))
+	for i, file := range files {
+		events, err := server.getBinlogEvents(file, 0, 3)
+		if err != nil {
+			return trxs, err
+		}
+		for _, e := range events {
+			if e.EventType == "Previous_gtids" {
+				previous[i], _ = mysql.ParseMysqlGTIDSet(e.Info)
+			}
+		}
+	}
+
+	byFile := make(map[string]map[string]int)
+	for i := range trxs {
+		set, err := mysql.ParseMysqlGTIDSet(trxs[i].Gtid)
+		if err != nil {
+			continue
+		}
+		if purged != nil && purged.Contain(set) {
+			trxs[i].Purged = true
+			continue
+		}
+		for f := range files {
+			if f+1 < len(files) && (previous[f+1] == nil || !previous[f+1].Contain(set)) {
+				continue
+			}
+			if previous[f] != nil && previous[f].Contain(set) {
+				break
+			}
+			trxs[i].File = files[f]
+			if byFile[files[f]] == nil {
+				byFile[files[f]] = make(map[string]int)
+			}
+			byFile[files[f]][strings.ToLower(trxs[i].Gtid)] = i
+			break
+		}
+	}
+
+	for file, targets := range byFile {
+		if err := server.scanErrantTransactionEvents(file, targets, trxs); err != nil {
+			return trxs, err
+		}
+	}
+	return trxs, nil
+}
+
+// scanErrantTransactionEvents reads a binary log until the events of the
+// transactions of targets are found, at most maxErrantScannedEvents events
+func (server *ServerMonitor) scanErrantTransactionEvents(file string, targets map[string]int, trxs []config.ErrantTransaction) error {
+	current := -1
+	found := 0
+	var pos uint64
+	for scanned := 0; ; scanned += 1000 {
+		if scanned >= maxErrantScannedEvents {
+			return fmt.Errorf("Stopped reading %s after %d events", file, scanned)
+		}
+		events, err := server.getBinlogEvents(file, pos, 1000)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if e.EventType == "Gtid" {
+				current = -1
+				if _, g, ok := strings.Cut(e.Info, "GTID_NEXT="); ok {
+					if i, ok := targets[strings.ToLower(strings.Trim(strings.TrimSpace(g), "'"))]; ok {
+						current = i
+						found++
+					}
+				}
+				if current < 0 && found == len(targets) {
+					return nil
+				}
+			}
+			if current >= 0 && len(trxs[current].Events) < maxErrantTransactionEvents {
+				trxs[current].Events = append(trxs[current].Events, e)
+			}
+		}
+		if len(events) < 1000 {
+			return nil
+		}
+		pos = events[len(events)-1].EndLogPos
+	}
+}
+
+func (server *ServerMonitor) getBinaryLogs() ([]string, error) {
+	rows, err := server.Conn.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	values := make([]interface{}, len(columns))
+	var name string
+	values[0] = &name
+	for i := 1; i < len(columns); i++ {
+		values[i] = new(interface{})
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, rows.Err()
+}
+
+func (server *ServerMonitor) getBinlogEvents(file string, pos uint64, limit int) ([]config.BinlogEvent, error) {
+	query := fmt.Sprintf("SHOW BINLOG EVENTS IN %s", config.QuoteValue(&file))
+	if pos > 0 {
+		query += fmt.Sprintf(" FROM %d", pos)
+	}
+	rows, err := server.Conn.Query(fmt.Sprintf("%s LIMIT %d", query, limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []config.BinlogEvent
+	for rows.Next() {
+		var e config.BinlogEvent
+		if err := rows.Scan(&e.LogName, &e.Pos, &e.EventType, &e.ServerId, &e.EndLogPos, &e.Info); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// InjectErrantTransactions commits an empty transaction for each errant
+// transaction of a replica on the master and the other replicas missing it,
+// so that the replica no longer has errant transactions. A dry run returns
+// the statements without running them.
+func (cluster *Cluster) InjectErrantTransactions(server *ServerMonitor, dryRun bool) (config.ErrantReconcilePlan, error) {
+	plan := config.ErrantReconcilePlan{Server: server.URL, Method: config.ErrantReconcileInject, DryRun: dryRun}
+	master, err := cluster.getErrantReconcileMaster(server)
+	if err != nil {
+		return plan, err
+	}
+	errant, err := server.GetErrantTransactions(master, false)
+	if err != nil {
+		return plan, err
+	}
+	if errant.Set == "" {
+		return plan, fmt.Errorf("No errant transaction on %s", server.URL)
+	}
+	plan.Set = errant.Set
+
+	targets := []*ServerMonitor{master}
+	for _, s := range cluster.slaves {
+		if s != server && !s.IsFailed() {
+			targets = append(targets, s)
+		}
+	}
+	for _, s := range targets {
+		var missing string
+		if err := s.Conn.QueryRowx("SELECT GTID_SUBTRACT(?, @@GLOBAL.gtid_executed)", errant.Set).Scan(&missing); err != nil {
+			return plan, err
+		}
+		gtids, err := gtid.ExpandMySQLSet(missing, maxErrantTransactions)
+		if err != nil {
+			return plan, err
+		}
+		if statements := config.GetEmptyTransactionStatements(gtids); statements != nil {
+			plan.Steps = append(plan.Steps, config.ErrantReconcileStep{Server: s.URL, Statements: statements})
+		}
+	}
+	if dryRun {
+		return plan, nil
+	}
+
+	for _, step := range plan.Steps {
+		s := cluster.GetServerFromURL(step.Server)
+		if s == nil {
+			return plan, fmt.Errorf("Server %s not found", step.Server)
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Injecting %d empty transactions of errant transactions of %s on %s", (len(step.Statements)-1)/3, server.URL, s.URL)
+		if err := s.execErrantReconcileStep(step.Statements); err != nil {
+			return plan, fmt.Errorf("Inject on %s failed: %w", s.URL, err)
+		}
+	}
+	return plan, nil
+}
+
+// execErrantReconcileStep runs the statements in one session, GTID_NEXT is
+// reset when a statement fails
+func (server *ServerMonitor) execErrantReconcileStep(statements []string) error {
+	ctx := context.Background()
+	conn, err := server.Conn.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, query := range statements {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			conn.ExecContext(ctx, "SET GTID_NEXT='AUTOMATIC'")
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
+
+// ReseedErrantTransactions reseeds a replica having errant transactions with
+// the backup method of the reseed action
+func (cluster *Cluster) ReseedErrantTransactions(server *ServerMonitor, method string, dryRun bool) (config.ErrantReconcilePlan, error) {
+	plan := config.ErrantReconcilePlan{Server: server.URL, Method: config.ErrantReconcileReseed, DryRun: dryRun, Reseed: method}
+	master, err := cluster.getErrantReconcileMaster(server)
+	if err != nil {
+		return plan, err
+	}
+	errant, err := server.GetErrantTransactions(master, false)
+	if err != nil {
+		return plan, err
+	}
+	if errant.Set == "" {
+		return plan, fmt.Errorf("No errant transaction on %s", server.URL)
+	}
+	plan.Set = errant.Set
+	if method != "logicalbackup" && method != "physicalbackup" && method != "logicalmaster" {
+		return plan, fmt.Errorf("Unknown reseed method %s", method)
+	}
+	if dryRun {
+		return plan, nil
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Reseeding %s with %s to drop errant transactions %s", server.URL, method, errant.Set)
+	switch method {
+	case "logicalbackup":
+		err = server.JobReseedLogicalBackup("default")
+	case "physicalbackup":
+		err = server.JobReseedPhysicalBackup("default")
+	case "logicalmaster":
+		err = server.RejoinDirectDump()
+	}
+	return plan, err
+}
+
+func (cluster *Cluster) getErrantReconcileMaster(server *ServerMonitor) (*ServerMonitor, error) {
+	master := cluster.GetMaster()
+	if master == nil {
+		return nil, errors.New("No master")
+	}
+	if !master.HasMySQLGTID() {
+		return nil, errors.New("Errant transactions need MySQL GTID")
+	}
+	if server == master {
+		return nil, errors.New("Server is the master")
+	}
+	if server.IsFailed() {
+		return nil, fmt.Errorf("Server %s is failed", server.URL)
+	}
+	return master, nil
+}
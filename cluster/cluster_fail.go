@@ -21,6 +21,7 @@ import (
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/gtid"
+	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/state"
 )
 
@@ -37,6 +38,13 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 
 	cluster.StateMachine.SetFailoverState()
 	defer cluster.StateMachine.RemoveFailoverState()
+	tl := NewFailoverTimeline(!fail)
+	cluster.failoverTimeline = tl
+	defer func() { cluster.failoverTimeline = nil }()
+	defer cluster.startFailoverSpan(tl)()
+	// The timeline is finished on every return, failure tells why it stopped
+	failure := "aborted"
+	defer func() { tl.Finish(failure) }()
 	// Phase 1: Cleanup and election
 	var err error
 	if fail == false {
@@ -46,21 +54,29 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Checking long running updates on master %d", cluster.Conf.SwitchWaitWrite)
 		if cluster.master == nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Cannot switchover without a master")
+			failure = "no master"
 			return false
 		}
 		if cluster.master.Conn == nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Cannot switchover without a master connection")
+			failure = "no master connection"
 			return false
 		}
+		step := tl.Step("check-long-running-writes", cluster.master.URL)
 		qt, logs, err := dbhelper.CheckLongRunningWrites(cluster.master.Conn, cluster.Conf.SwitchWaitWrite)
 		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlDbg, "CheckLongRunningWrites")
+		if err == nil && qt > 0 {
+			err = fmt.Errorf("%d long updates running", qt)
+		}
+		step.Done(logs, err)
 		if qt > 0 {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Long updates running on master. Cannot switchover")
-
+			failure = fmt.Sprintf("%d long updates running on master", qt)
 			return false
 		}
 
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Flushing tables on master %s", cluster.master.URL)
+		step = tl.Step("flush-tables", cluster.master.URL)
 		workerFlushTable := make(chan error, 1)
 		if cluster.master.DBVersion.IsMariaDB() && cluster.master.DBVersion.Major > 10 && cluster.master.DBVersion.Minor >= 1 {
 
@@ -82,17 +98,21 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 
 		select {
 		case err = <-workerFlushTable:
+			step.Done(logs, err)
 			if err != nil {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlWarn, "Could not flush tables on master", err)
 			}
 		case <-time.After(time.Second * time.Duration(cluster.Conf.SwitchWaitTrx)):
+			step.Done("FLUSH TABLES", fmt.Errorf("Long running trx on master at least %d", cluster.Conf.SwitchWaitTrx))
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Long running trx on master at least %d, can not switchover ", cluster.Conf.SwitchWaitTrx)
+			failure = fmt.Sprintf("long running trx on master at least %d", cluster.Conf.SwitchWaitTrx)
 			return false
 		}
 
 	} else {
 		if cluster.Conf.MultiMasterGrouprep {
 			// group replication auto elect a new master in case of failure do nothing
+			failure = ""
 			return true
 		}
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "------------------------")
@@ -103,6 +123,7 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	for _, s := range cluster.slaves {
 		s.Refresh()
 	}
+	step := tl.Step("elect-candidate", "")
 	key := -1
 	if fail {
 		key = cluster.electFailoverCandidate(cluster.slaves, true)
@@ -110,14 +131,19 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		key = cluster.electSwitchoverCandidate(cluster.slaves, true)
 	}
 	if key == -1 {
+		step.Done("", errors.New("No candidates found"))
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "No candidates found")
+		failure = "no candidates found"
 		return false
 	}
+	step.Server = cluster.slaves[key].URL
+	step.Done("elected "+cluster.slaves[key].URL, nil)
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Slave %s has been elected as a new master", cluster.slaves[key].URL)
 
 	if fail && !cluster.isSlaveElectable(cluster.slaves[key], true) {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Elected slave have issue cancelling failover", cluster.slaves[key].URL)
+		failure = "elected slave " + cluster.slaves[key].URL + " not electable"
 		return false
 	}
 	// Shuffle the server list
@@ -138,7 +164,12 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 
 	// Phase 2: Reject updates and sync slaves on switchover
 	if fail == false {
-		cluster.oldMaster.freeze()
+		step = tl.Step("freeze", cluster.oldMaster.URL)
+		if cluster.oldMaster.freeze() {
+			step.Done("freeze writes on old master", nil)
+		} else {
+			step.Done("freeze writes on old master", errors.New("Freeze failed"))
+		}
 	}
 	// Sync candidate depending on the master status.
 	// If it's a switchover, use MASTER_POS_WAIT to sync.
@@ -146,7 +177,9 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	// If maxsclale we should wait for relay catch via old style
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Waiting for candidate master %s to apply relay log", cluster.master.URL)
+	step = tl.Step("read-relay-logs", cluster.master.URL)
 	err = cluster.master.ReadAllRelayLogs()
+	step.Done("wait for SQL thread to apply relay logs", err)
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Error while reading relay logs on candidate %s: %s", cluster.master.URL, err)
 	}
@@ -199,8 +232,10 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 
 			binlogfiletoreach, _ := strconv.Atoi(strings.Split(rs.MasterLogFile.String, ".")[1])
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Relay server log pos reached %d", binlogfiletoreach)
+			step := tl.Step("reset-master", cluster.master.URL)
 			logs, err := dbhelper.ResetMaster(cluster.master.Conn, cluster.Conf.MasterConn, cluster.master.DBVersion)
 			cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlInfo, "Reset Master on candidate Master")
+			step.Done(logs, err)
 			ctbinlog := 0
 			for ctbinlog < binlogfiletoreach {
 				ctbinlog++
@@ -224,19 +259,26 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	if !cluster.Conf.MultiMaster && !cluster.Conf.MultiMasterGrouprep {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Stopping slave threads on new master")
 		if cluster.master.DBVersion.IsMariaDB() || (cluster.master.DBVersion.IsMariaDB() == false && cluster.master.DBVersion.Minor < 7) {
+			step := tl.Step("stop-slave", cluster.master.URL)
 			logs, err := cluster.master.StopSlave()
 			cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Failed stopping slave on new master %s %s", cluster.master.URL, err)
+			step.Done(logs, err)
 		}
 		if cluster.master.ClusterGroup.Conf.FailoverSemiSyncState {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Enable semisync leader and disable semisync replica on %s", cluster.master.URL)
+			step := tl.Step("semisync-leader", cluster.master.URL)
 			logs, err := cluster.master.SetSemiSyncLeader()
 			cluster.LogSQL(logs, err, cluster.master.URL, "Rejoin", config.LvlErr, "Failed enable semisync leader and disable semisync replica on %s %s", cluster.master.URL, err)
+			step.Done(logs, err)
 		}
 	}
+	crash.Id = misc.GetUUID()
+	crash.Timeline = tl
 	cluster.Crashes = append(cluster.Crashes, crash)
 	cluster.FailoverHistory.StoreLastN(crash, cluster.Conf.FailoverLogFileKeep)
 	t := time.Now()
-	crash.Save(cluster.WorkingDir + "/failover." + t.Format("20060102150405") + ".json")
+	crashFile := cluster.WorkingDir + "/failover." + t.Format("20060102150405") + ".json"
+	crash.Save(crashFile)
 	crash.Purge(cluster.WorkingDir, cluster.Conf.FailoverLogFileKeep)
 	cluster.Save()
 
@@ -244,18 +286,24 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Resetting slave on new master and set read/write mode on")
 		if cluster.master.DBVersion.IsMySQLOrPercona() {
 			// Need to stop all threads to reset on MySQL
+			step := tl.Step("stop-slave", cluster.master.URL)
 			logs, err := cluster.master.StopSlave()
 			cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Failed stop slave on new master %s %s", cluster.master.URL, err)
+			step.Done(logs, err)
 		}
 
+		step := tl.Step("reset-slave", cluster.master.URL)
 		logs, err := cluster.master.ResetSlave()
 		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Failed reset slave on new master %s %s", cluster.master.URL, err)
+		step.Done(logs, err)
 	}
 	if fail == false {
 		// Get Fresh GTID pos before open traffic
 		cluster.master.Refresh()
 	}
+	step = tl.Step("set-read-write", cluster.master.URL)
 	err = cluster.master.SetReadWrite()
+	step.Done("set read_only off", err)
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not set new master as read-write")
 	}
@@ -266,8 +314,10 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 	cluster.failoverEnableEventScheduler()
 	// Insert a bogus transaction in order to have a new GTID pos on master
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Inject fake transaction on new master %s ", cluster.master.URL)
+	step = tl.Step("inject-trx", cluster.master.URL)
 	logs, err := dbhelper.FlushTables(cluster.master.Conn)
 	cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Could not flush tables on new master for fake trx %s", err)
+	step.Done(logs, err)
 
 	if fail == false {
 		// Get latest GTID pos
@@ -278,17 +328,22 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		// Phase 4: Demote old master to slave
 		// ********
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Killing new connections on old master showing before update route")
-		dbhelper.KillThreads(cluster.oldMaster.Conn, cluster.oldMaster.DBVersion)
+		step := tl.Step("kill-threads", cluster.oldMaster.URL)
+		step.Done(dbhelper.KillThreads(cluster.oldMaster.Conn, cluster.oldMaster.DBVersion))
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Switching old leader to slave")
+		step = tl.Step("unlock-tables", cluster.oldMaster.URL)
 		logs, err := dbhelper.UnlockTables(cluster.oldMaster.Conn)
 		cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not unlock tables on old master %s", err)
+		step.Done(logs, err)
 
 		// Moved in freeze
 		//cluster.oldMaster.StopSlave() // This is helpful in some cases the old master can have an old replication running
 		one_shoot_slave_pos := false
 		if cluster.oldMaster.DBVersion.IsMariaDB() && cluster.oldMaster.HaveMariaDBGTID == false && cluster.oldMaster.DBVersion.Major >= 10 && cluster.Conf.SwitchoverCopyOldLeaderGtid {
+			step := tl.Step("set-gtid-slave-pos", cluster.oldMaster.URL)
 			logs, err := dbhelper.SetGTIDSlavePos(cluster.oldMaster.Conn, cluster.master.GTIDBinlogPos.Sprint())
 			cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not set old master gtid_slave_pos , reason: %s", err)
+			step.Done(logs, err)
 			one_shoot_slave_pos = true
 		}
 
@@ -342,16 +397,22 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 				changemasteropt.Logpos = crash.FailoverMasterLogPos
 			}
 		}
+		step = tl.Step("change-master", cluster.oldMaster.URL)
 		logs, changeMasterErr = dbhelper.ChangeMaster(cluster.oldMaster.Conn, changemasteropt, cluster.oldMaster.DBVersion)
 		cluster.LogSQL(logs, changeMasterErr, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Change master failed on old master, reason:%s ", changeMasterErr)
+		step.Done(logs, changeMasterErr)
 		if oldmasterneedslavestart {
+			step = tl.Step("start-slave", cluster.oldMaster.URL)
 			logs, err = cluster.oldMaster.StartSlave()
 			cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Start slave failed on old master,%s reason:  %s ", cluster.oldMaster.URL, err)
+			step.Done(logs, err)
 		}
 
 		if cluster.Conf.ReadOnly {
+			step = tl.Step("set-read-only", cluster.oldMaster.URL)
 			logs, err = cluster.oldMaster.SetReadOnly()
 			cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not set old master as read-only, %s", err)
+			step.Done(logs, err)
 			/*	} else {
 				logs, err = cluster.oldMaster.SetReadWrite()
 				cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not set old master as read-write, %s", err)
@@ -359,8 +420,10 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		}
 		if cluster.Conf.SwitchDecreaseMaxConn {
 
+			step := tl.Step("set-max-connections", cluster.oldMaster.URL)
 			logs, err := dbhelper.SetMaxConnections(cluster.oldMaster.Conn, cluster.oldMaster.maxConn, cluster.oldMaster.DBVersion)
 			cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not set max connection, %s", err)
+			step.Done(logs, err)
 
 		}
 		// Add the old master to the slaves list
@@ -388,19 +451,26 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		// maxscale is in the list of slave
 
 		if fail == false && cluster.Conf.MxsBinlogOn == false && cluster.Conf.SwitchSlaveWaitCatch {
+			step := tl.Step("wait-sync", sl.URL)
 			sl.WaitSyncToMaster(cluster.oldMaster)
+			step.Done("wait replica sync to old master", nil)
 		}
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Change master on slave %s", sl.URL)
+		step := tl.Step("stop-slave", sl.URL)
 		logs, err = sl.StopSlave()
 		cluster.LogSQL(logs, err, cluster.oldMaster.URL, "MasterFailover", config.LvlErr, "Could not stop slave on server %s, %s", sl.URL, err)
+		step.Done(logs, err)
 		if fail == false && cluster.Conf.MxsBinlogOn == false && cluster.Conf.SwitchSlaveWaitCatch {
 			if cluster.Conf.SwitchoverCopyOldLeaderGtid && sl.DBVersion.IsMariaDB() {
+				step := tl.Step("set-gtid-slave-pos", sl.URL)
 				logs, err := dbhelper.SetGTIDSlavePos(sl.Conn, cluster.oldMaster.GTIDBinlogPos.Sprint())
 				cluster.LogSQL(logs, err, sl.URL, "MasterFailover", config.LvlErr, "Could not set gtid_slave_pos on slave %s, %s", sl.URL, err)
+				step.Done(logs, err)
 			}
 		}
 
 		var changeMasterErr error
+		step = tl.Step("change-master", sl.URL)
 
 		var changemasteropt dbhelper.ChangeMasterOpt
 		changemasteropt.Host = cluster.master.Host
@@ -511,19 +581,26 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 			}
 		}
 		cluster.LogSQL(logs, changeMasterErr, sl.URL, "MasterFailover", config.LvlErr, "Change master failed on slave %s, %s", sl.URL, changeMasterErr)
+		step.Done(logs, changeMasterErr)
+		step = tl.Step("start-slave", sl.URL)
 		logs, err = sl.StartSlave()
 		cluster.LogSQL(logs, err, sl.URL, "MasterFailover", config.LvlErr, "Could not start slave on server %s, %s", sl.URL, err)
+		step.Done(logs, err)
 		// now start the old master as relay is ready
 		if cluster.Conf.MxsBinlogOn && fail == false {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Restarting old master replication relay server ready")
 			cluster.oldMaster.StartSlave()
 		}
 		if cluster.Conf.ReadOnly && cluster.Conf.MxsBinlogOn == false && !sl.IsIgnoredReadonly() {
+			step = tl.Step("set-read-only", sl.URL)
 			logs, err = sl.SetReadOnly()
 			cluster.LogSQL(logs, err, sl.URL, "MasterFailover", config.LvlErr, "Could not set slave %s as read-only, %s", sl.URL, err)
+			step.Done(logs, err)
 		} else {
 			if cluster.Conf.MxsBinlogOn == false {
+				step = tl.Step("set-read-write", sl.URL)
 				err = sl.SetReadWrite()
+				step.Done("set read_only off", err)
 				if err != nil {
 					cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not remove slave %s as read-only, %s", sl.URL, err)
 				}
@@ -531,7 +608,9 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		}
 	}
 	// if consul or internal proxy need to adapt read only route to new slaves
+	step = tl.Step("proxies-backend-state", "")
 	cluster.backendStateChangeProxies()
+	step.Done("update proxies read routes", nil)
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Master switch on %s complete", cluster.master.URL)
 	cluster.master.FailCount = 0
@@ -539,6 +618,11 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 		cluster.FailoverCtr++
 		cluster.FailoverTs = time.Now().Unix()
 	}
+	failure = ""
+	tl.Finish(failure)
+	cluster.observeFailoverDuration(fail, tl.End.Sub(tl.Start))
+	crash.Save(crashFile)
+	cluster.Save()
 
 	// Not a prefered master this code is not default
 	// such code is to dangerous documentation is needed
@@ -555,22 +639,28 @@ func (cluster *Cluster) MasterFailover(fail bool) bool {
 
 func (cluster *Cluster) failoverProxiesWaitMonitor() {
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Waiting %ds for unmanaged proxy to monitor route change", cluster.Conf.SwitchSlaveWaitRouteChange)
+	step := cluster.failoverTimeline.Step("wait-proxy-monitor", "")
 	time.Sleep(time.Duration(cluster.Conf.SwitchSlaveWaitRouteChange) * time.Second)
+	step.Done(fmt.Sprintf("wait %ds for route change", cluster.Conf.SwitchSlaveWaitRouteChange), nil)
 }
 
 func (cluster *Cluster) failoverEnableEventScheduler() {
 
 	if cluster.Conf.FailEventScheduler {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Enable Event Scheduler on the new master")
+		step := cluster.failoverTimeline.Step("enable-event-scheduler", cluster.master.URL)
 		logs, err := cluster.master.SetEventScheduler(true)
 		cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Could not enable event scheduler on the new master")
+		step.Done(logs, err)
 	}
 	if cluster.Conf.FailEventStatus {
 		for _, v := range cluster.master.EventStatus {
 			if v.Status == 3 {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Set ENABLE for event %s %s on new master", v.Db, v.Name)
+				step := cluster.failoverTimeline.Step("enable-event", cluster.master.URL)
 				logs, err := dbhelper.SetEventStatus(cluster.master.Conn, v, 1)
 				cluster.LogSQL(logs, err, cluster.master.URL, "MasterFailover", config.LvlErr, "Could not Set ENABLE for event %s %s on new master", v.Db, v.Name)
+				step.Done(logs, err)
 			}
 		}
 	}
@@ -672,7 +762,15 @@ func (cluster *Cluster) electSwitchoverGroupReplicationCandidate(l []*ServerMoni
 
 // Returns a candidate from a list of slaves. If there's only one slave it will be the de facto candidate.
 func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog bool) int {
+	key, _ := cluster.electSwitchoverCandidateList(l, forcingLog, false)
+	return key
+}
+
+// electSwitchoverCandidateList returns the elected key and the election matrice in slaves order,
+// a simulation only collects the rejections without raising their states
+func (cluster *Cluster) electSwitchoverCandidateList(l []*ServerMonitor, forcingLog bool, simulate bool) (int, []ElectionCandidate) {
 	ll := len(l)
+	candidates := make([]ElectionCandidate, ll)
 	seqList := make([]uint64, ll)
 	posList := make([]uint64, ll)
 	hipos := 0
@@ -681,40 +779,60 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 	var maxpos uint64
 
 	for i, sl := range l {
+		candidates[i].URL = sl.URL
+		candidates[i].Indice = i
+		candidates[i].Prefered = cluster.IsInPreferedHosts(sl)
+		candidates[i].Ignoredconf = sl.IsIgnored()
+		candidates[i].Ignoredrelay = sl.IsRelay
+		candidates[i].DelayStat = sl.DelayStat.Total
+		candidates[i].IgnoredErrantTrx = sl.HasErrantTransactions()
+		candidates[i].IgnoredBlocker = sl.HasBlockerIssue()
+		cluster.setElectionZone(&candidates[i], sl)
 		/* If server is in child cluster, do not elect it in switchover */
 		if sl.SourceClusterName != cluster.Name {
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf("Slave %s is in child cluster", sl.URL))
 			continue
 		}
 		/* If server is in the ignore list, do not elect it in switchover */
 		if sl.IsIgnored() {
-			cluster.SetState("ERR00037", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00037"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00037", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00037"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00037"], sl.URL))
 			continue
 		}
 		if sl.IsFull {
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf("Slave %s disk is full", sl.URL))
 			continue
 		}
 		//Need comment//
 		if sl.IsRelay {
-			cluster.SetState("ERR00036", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00036"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00036", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00036"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00036"], sl.URL))
 			continue
 		}
 		if !sl.HasBinlog() && !sl.IsIgnored() {
-			cluster.SetState("ERR00013", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00013"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			cluster.setElectionState(simulate, "ERR00013", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00013"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00013"], sl.URL))
 			continue
 		}
 		if cluster.Conf.MultiMaster == true && sl.State == stateMaster {
-			cluster.SetState("ERR00035", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00035"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00035", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00035"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Ignoredmultimaster = true
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00035"], sl.URL))
 			continue
 		}
 
 		// The tests below should run only in case of a switchover as they require the master to be up.
-		if cluster.isSlaveElectableForSwitchover(sl, forcingLog) == false {
-			cluster.SetState("ERR00034", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00034"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+		if issue := cluster.getSlaveElectableForSwitchoverIssue(sl, forcingLog); issue != "" {
+			cluster.setElectionState(simulate, "ERR00034", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00034"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Ignoredreplication = true
+			candidates[i].Rejections = append(candidates[i].Rejections, issue)
 			continue
 		}
 		/* binlog + ping  */
-		if cluster.isSlaveElectable(sl, forcingLog) == false {
-			cluster.SetState("ERR00039", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00039"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+		if issue := cluster.getSlaveElectableIssue(sl, forcingLog, simulate); issue != "" {
+			cluster.setElectionState(simulate, "ERR00039", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00039"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Ignoredreplication = true
+			candidates[i].Rejections = append(candidates[i].Rejections, issue)
 			continue
 		}
 
@@ -724,17 +842,19 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 			if cluster.IsInFailover() {
 				cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlInfo, "Election rig: %s elected as preferred master", sl.URL)
 			}
-			return i
+			return i, candidates
 		}
 		if sl.HaveNoMasterOnStart == true && cluster.Conf.FailRestartUnsafe == false {
-			cluster.SetState("ERR00084", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00084"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00084", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00084"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00084"], sl.URL))
 			continue
 		}
 		ss, errss := sl.GetSlaveStatus(sl.ReplicationSourceName)
 		// not a slave
 		if errss != nil && cluster.Conf.FailRestartUnsafe == false {
 			//Skip slave in election %s have no master log file, slave might have failed
-			cluster.SetState("ERR00033", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00033"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00033", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00033"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf(clusterError["ERR00033"], sl.URL))
 			continue
 		}
 		// Fake position if none as new slave
@@ -745,6 +865,7 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 			logfile = ss.MasterLogFile.String
 		}
 		if strings.Contains(logfile, ".") == false {
+			candidates[i].Rejections = append(candidates[i].Rejections, fmt.Sprintf("Slave %s has no valid master log file %s", sl.URL, logfile))
 			continue
 		}
 		for len(filepos) < 12 {
@@ -755,6 +876,7 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 		binlogposreach, _ := strconv.ParseUint(pos, 10, 64)
 
 		posList[i] = binlogposreach
+		candidates[i].Pos = binlogposreach
 
 		seqnos := gtid.NewList("1-1-1").GetSeqNos()
 
@@ -770,11 +892,13 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 		for _, v := range seqnos {
 			seqList[i] += v
 		}
-		if seqList[i] > max {
+		candidates[i].Seq = seqList[i]
+		// On equal sequence prefer the zone then the region of the master
+		if seqList[i] > max || (max > 0 && seqList[i] == max && cluster.getElectionZoneRank(candidates[i]) > cluster.getElectionZoneRank(candidates[hiseq])) {
 			max = seqList[i]
 			hiseq = i
 		}
-		if posList[i] > maxpos {
+		if posList[i] > maxpos || (maxpos > 0 && posList[i] == maxpos && cluster.getElectionZoneRank(candidates[i]) > cluster.getElectionZoneRank(candidates[hipos])) {
 			maxpos = posList[i]
 			hipos = i
 		}
@@ -782,17 +906,48 @@ func (cluster *Cluster) electSwitchoverCandidate(l []*ServerMonitor, forcingLog
 	} //end loop all slaves
 	if max > 0 {
 		/* Return key of slave with the highest seqno. */
-		return hiseq
+		return hiseq, candidates
 	}
 	if maxpos > 0 {
 		/* Return key of slave with the highest pos. */
-		return hipos
+		return hipos, candidates
 	}
-	return -1
+	return -1, candidates
+}
+
+// ElectionCandidate is a line of the election matrice, Rejections tells why
+// the slave was skipped by the election
+type ElectionCandidate struct {
+	URL                 string    `json:"url"`
+	Indice              int       `json:"indice"`
+	Pos                 uint64    `json:"pos"`
+	Seq                 uint64    `json:"seq"`
+	Prefered            bool      `json:"prefered"`
+	Ignoredconf         bool      `json:"ignoredConf"`
+	Ignoredrelay        bool      `json:"ignoredRelay"`
+	Ignoredmultimaster  bool      `json:"ignoredMultiMaster"`
+	Ignoredreplication  bool      `json:"ignoredReplication"`
+	IgnoredMinorVersion bool      `json:"ignoredMinorVersion"`
+	IgnoredErrantTrx    bool      `json:"ignoredErrantTrx"`
+	IgnoredBlocker      bool      `json:"ignoredBlocker"`
+	Weight              uint      `json:"weight"`
+	DelayStat           DelayStat `json:"delayStat"`
+	Region              string    `json:"region"`
+	Zone                string    `json:"zone"`
+	SameZone            bool      `json:"sameZone"`
+	SameRegion          bool      `json:"sameRegion"`
+	Rejections          []string  `json:"rejections"`
 }
 
 // electFailoverCandidate found the most up to date and look after a possibility to failover on it
 func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bool) int {
+	key, _ := cluster.electFailoverCandidateList(l, forcingLog, false)
+	return key
+}
+
+// electFailoverCandidateList returns the elected key and the sorted election matrice,
+// a simulation only collects the rejections without raising their states
+func (cluster *Cluster) electFailoverCandidateList(l []*ServerMonitor, forcingLog bool, simulate bool) (int, []ElectionCandidate) {
 
 	ll := len(l)
 	seqList := make([]uint64, ll)
@@ -800,29 +955,13 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 
 	var maxseq uint64
 	var maxpos uint64
-	type Trackpos struct {
-		URL                 string
-		Indice              int
-		Pos                 uint64
-		Seq                 uint64
-		Prefered            bool
-		Ignoredconf         bool
-		Ignoredrelay        bool
-		Ignoredmultimaster  bool
-		Ignoredreplication  bool
-		IgnoredMinorVersion bool
-		IgnoredErrantTrx    bool
-		IgnoredBlocker      bool
-		Weight              uint
-		DelayStat           DelayStat
-	}
 
 	// HaveOneValidReader is used to state that at least one replicat is available for reading via proxies
 	// In such case it is needed to add the leader in the reader server list
 	// To avoid oveloading the leader with to many read we ignore replication delay and IO thread status
 	HaveOneValidReader := false
 
-	trackposList := make([]Trackpos, ll)
+	trackposList := make([]ElectionCandidate, ll)
 	for i, sl := range l {
 		trackposList[i].URL = sl.URL
 		trackposList[i].Indice = i
@@ -832,50 +971,63 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 		trackposList[i].DelayStat = sl.DelayStat.Total
 		trackposList[i].IgnoredErrantTrx = sl.HasErrantTransactions()
 		trackposList[i].IgnoredBlocker = sl.HasBlockerIssue()
+		cluster.setElectionZone(&trackposList[i], sl)
 
 		//Need comment//
 		if sl.IsRelay {
-			cluster.SetState("ERR00036", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00036"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			cluster.setElectionState(simulate, "ERR00036", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00036"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf(clusterError["ERR00036"], sl.URL))
 			continue
 		}
 		if sl.IsFull {
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf("Slave %s disk is full", sl.URL))
 			continue
 		}
 		if cluster.Conf.MultiMaster == true && sl.State == stateMaster {
-			cluster.SetState("ERR00035", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00035"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			cluster.setElectionState(simulate, "ERR00035", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00035"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 			trackposList[i].Ignoredmultimaster = true
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf(clusterError["ERR00035"], sl.URL))
 			continue
 		}
 		if sl.HaveNoMasterOnStart == true && cluster.Conf.FailRestartUnsafe == false {
-			cluster.SetState("ERR00084", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00084"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			cluster.setElectionState(simulate, "ERR00084", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00084"], sl.URL), ServerUrl: sl.URL, ErrFrom: "CHECK"})
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf(clusterError["ERR00084"], sl.URL))
 			continue
 		}
 		if !sl.HasBinlog() && !sl.IsIgnored() {
-			cluster.SetState("ERR00013", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00013"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			cluster.setElectionState(simulate, "ERR00013", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00013"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf(clusterError["ERR00013"], sl.URL))
 			continue
 		}
 		if cluster.GetTopology() == topoMultiMasterWsrep && cluster.vmaster != nil {
 			if cluster.vmaster.URL == sl.URL {
+				trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf("Server %s is the virtual master", sl.URL))
 				continue
 			} else if sl.State == stateWsrep {
-				return i
+				return i, trackposList
 			} else {
+				trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf("Server %s is not in wsrep state", sl.URL))
 				continue
 			}
 		}
 		if cluster.master == nil {
+			trackposList[i].Rejections = append(trackposList[i].Rejections, "Master not discovered")
 			continue
 		}
 
 		ss, errss := sl.GetSlaveStatus(sl.ReplicationSourceName)
 		// not a slave
 		if errss != nil && cluster.Conf.FailRestartUnsafe == false {
-			cluster.SetState("ERR00033", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00033"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+			cluster.setElectionState(simulate, "ERR00033", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00033"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 			trackposList[i].Ignoredreplication = true
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf(clusterError["ERR00033"], sl.URL))
 			continue
 		}
-		trackposList[i].Ignoredreplication = !cluster.isSlaveElectable(sl, false)
-		if !HaveOneValidReader {
+		if issue := cluster.getSlaveElectableIssue(sl, false, simulate); issue != "" {
+			trackposList[i].Ignoredreplication = true
+			trackposList[i].Rejections = append(trackposList[i].Rejections, issue)
+		}
+		if !HaveOneValidReader && !simulate {
 			HaveOneValidReader = cluster.isSlaveValidReader(sl, false)
 		}
 		// Fake position if none as new slave
@@ -886,6 +1038,7 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 			logfile = ss.MasterLogFile.String
 		}
 		if strings.Contains(logfile, ".") == false {
+			trackposList[i].Rejections = append(trackposList[i].Rejections, fmt.Sprintf("Slave %s has no valid master log file %s", sl.URL, logfile))
 			continue
 		}
 		for len(filepos) < 12 {
@@ -924,7 +1077,7 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 
 	} //end loop all slaves
 
-	if !HaveOneValidReader {
+	if !HaveOneValidReader && !simulate {
 		cluster.SetState("ERR00085", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["ERR00085"]), ErrFrom: "CHECK"})
 	}
 
@@ -960,13 +1113,21 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 		//send the prefered if equal max
 		for _, p := range trackposList {
 			if p.Seq == maxseq && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false && p.Prefered == true {
-				return p.Indice
+				return p.Indice, trackposList
+			}
+		}
+		//send one in the zone then in the region of the master with maxseq
+		for rank := 2; rank > 0; rank-- {
+			for _, p := range trackposList {
+				if p.Seq == maxseq && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false && cluster.getElectionZoneRank(p) == rank {
+					return p.Indice, trackposList
+				}
 			}
 		}
 		//send one with maxseq
 		for _, p := range trackposList {
 			if p.Seq == maxseq && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false {
-				return p.Indice
+				return p.Indice, trackposList
 			}
 		}
 		//send one with maxseq but also ignored
@@ -975,14 +1136,14 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 				if forcingLog {
 					cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlInfo, "Ignored server is the most up to date ")
 				}
-				return p.Indice
+				return p.Indice, trackposList
 			}
 
 		}
 
 		data, _ := json.MarshalIndent(trackposList, "", "\t")
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModWriterElection, config.LvlDbg, "Election matrice maxseq >0: %s ", data)
-		return -1
+		return -1, trackposList
 	}
 
 	if !cluster.Conf.FailoverCheckDelayStat {
@@ -1011,13 +1172,21 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 		/* Return key of slave with the highest pos. */
 		for _, p := range trackposList {
 			if p.Pos == maxpos && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false && p.Prefered == true {
-				return p.Indice
+				return p.Indice, trackposList
+			}
+		}
+		//send one in the zone then in the region of the master with maxpos
+		for rank := 2; rank > 0; rank-- {
+			for _, p := range trackposList {
+				if p.Pos == maxpos && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false && cluster.getElectionZoneRank(p) == rank {
+					return p.Indice, trackposList
+				}
 			}
 		}
 		//send one with maxpos
 		for _, p := range trackposList {
 			if p.Pos == maxpos && p.Ignoredrelay == false && p.Ignoredmultimaster == false && p.Ignoredreplication == false && p.Ignoredconf == false {
-				return p.Indice
+				return p.Indice, trackposList
 			}
 		}
 		//send one with maxpos and ignored
@@ -1026,103 +1195,119 @@ func (cluster *Cluster) electFailoverCandidate(l []*ServerMonitor, forcingLog bo
 				if forcingLog {
 					cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlInfo, "Ignored server is the most up to date ")
 				}
-				return p.Indice
+				return p.Indice, trackposList
 			}
 		}
 
 		data, _ := json.MarshalIndent(trackposList, "", "\t")
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModWriterElection, config.LvlDbg, "Election matrice maxpos>0: %s ", data)
-		return -1
+		return -1, trackposList
 	}
 
 	data, _ := json.MarshalIndent(trackposList, "", "\t")
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModWriterElection, config.LvlDbg, "Election matrice: %s ", data)
-	return -1
+	return -1, trackposList
+}
+
+// setElectionState raises the state of an election rejection, a simulation
+// of the election leaves the states of the cluster untouched
+func (cluster *Cluster) setElectionState(simulate bool, key string, s state.State) {
+	if simulate {
+		return
+	}
+	cluster.SetState(key, s)
 }
 
 func (cluster *Cluster) isSlaveElectable(sl *ServerMonitor, forcingLog bool) bool {
+	return cluster.getSlaveElectableIssue(sl, forcingLog, false) == ""
+}
+
+// getSlaveElectableIssue returns why the slave can not be elected, empty when electable
+func (cluster *Cluster) getSlaveElectableIssue(sl *ServerMonitor, forcingLog bool, simulate bool) string {
 	//Ignore if child cluster
 	if sl.SourceClusterName != cluster.Name {
-		return false
+		return fmt.Sprintf("Slave %s is in child cluster", sl.URL)
 	}
 	ss, err := sl.GetSlaveStatus(sl.ReplicationSourceName)
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModWriterElection, config.LvlWarn, "Error in getting slave status in testing slave electable %s: %s  ", sl.URL, err)
-		return false
+		return fmt.Sprintf("Error in getting slave status %s: %s", sl.URL, err)
 	}
 	//if master is alived and IO Thread stops then not a good candidate and not forced
 	if ss.SlaveIORunning.String == "No" && cluster.Conf.RplChecks && !cluster.IsMasterFailed() {
-		cluster.SetState("ERR00087", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00087"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00087", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00087"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Unsafe failover condition. Slave %s IO Thread is stopped %s. Skipping", sl.URL, ss.LastIOError.String)
 		// }
-		return false
+		return fmt.Sprintf("Unsafe failover condition. Slave %s IO Thread is stopped %s", sl.URL, ss.LastIOError.String)
 	}
 
 	/* binlog + ping  */
 	if dbhelper.CheckSlavePrerequisites(sl.Conn, sl.Host, sl.DBVersion) == false {
-		cluster.SetState("ERR00040", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00040"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00040", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00040"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s does not ping or has no binlogs. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Slave %s does not ping or has no binlogs", sl.URL)
 	}
 	if sl.IsMaintenance {
-		cluster.SetState("ERR00047", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00047"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00047", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00047"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave %s is in maintenance. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Slave %s is in maintenance", sl.URL)
 	}
 
 	if ss.SecondsBehindMaster.Int64 > cluster.Conf.FailMaxDelay && cluster.Conf.FailMaxDelay != -1 && cluster.Conf.RplChecks == true {
-		cluster.SetState("ERR00041", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00041"]+" Sql: "+sl.GetProcessListReplicationLongQuery(), sl.URL, cluster.Conf.FailMaxDelay, ss.SecondsBehindMaster.Int64), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00041", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00041"]+" Sql: "+sl.GetProcessListReplicationLongQuery(), sl.URL, cluster.Conf.FailMaxDelay, ss.SecondsBehindMaster.Int64), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Unsafe failover condition. Slave %s has more than failover-max-delay %d seconds with replication delay %d. Skipping", sl.URL, cluster.Conf.FailMaxDelay, ss.SecondsBehindMaster.Int64)
 		// }
 
-		return false
+		return fmt.Sprintf("Unsafe failover condition. Slave %s has more than failover-max-delay %d seconds with replication delay %d", sl.URL, cluster.Conf.FailMaxDelay, ss.SecondsBehindMaster.Int64)
 	}
 
 	if ss.SlaveSQLRunning.String == "No" && cluster.Conf.RplChecks {
-		cluster.SetState("ERR00042", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00042"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00042", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00042"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Unsafe failover condition. Slave %s SQL Thread is stopped. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Unsafe failover condition. Slave %s SQL Thread is stopped", sl.URL)
 	}
 
 	//if master is alived and connection issues, we have to refetch password from vault
 	if ss.SlaveIORunning.String == "Connecting" && !cluster.IsMasterFailed() {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlDbg, "isSlaveElect lastIOErrno: %s", ss.LastIOErrno.String)
 		if ss.LastIOErrno.String == "1045" {
-			cluster.SetState("ERR00088", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00088"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
-			sl.SetReplicationCredentialsRotation(ss)
+			if !simulate {
+				cluster.SetState("ERR00088", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00088"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+				sl.SetReplicationCredentialsRotation(ss)
+			}
 		}
 	}
 
 	if sl.HaveSemiSync && sl.SemiSyncSlaveStatus == false && cluster.Conf.FailSync && cluster.Conf.RplChecks {
-		cluster.SetState("ERR00043", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00043"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
+		cluster.setElectionState(simulate, "ERR00043", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(clusterError["ERR00043"], sl.URL), ErrFrom: "CHECK", ServerUrl: sl.URL})
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Semi-sync slave %s is out of sync. Skipping", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Semi-sync slave %s is out of sync", sl.URL)
 	}
 
 	if cluster.Conf.FailoverMdevCheck && sl.HasMdevIssue() {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "MDEV check are enabled. Slave %s has MDEV issue with higher priority than '%s'. Skipping", sl.URL, cluster.Conf.FailoverMdevLevel)
 		// }
-		return false
+		return fmt.Sprintf("MDEV check are enabled. Slave %s has MDEV issue with higher priority than '%s'", sl.URL, cluster.Conf.FailoverMdevLevel)
 	}
 
 	if sl.IsIgnored() {
 		// if cluster.Conf.LogLevel > 1 || forcingLog {
 		cluster.LogModulePrintf(forcingLog, config.ConstLogModWriterElection, config.LvlWarn, "Slave is in ignored list %s", sl.URL)
 		// }
-		return false
+		return fmt.Sprintf("Slave is in ignored list %s", sl.URL)
 	}
-	return true
+	return ""
 }
 
 func (cluster *Cluster) isSlaveValidReader(sl *ServerMonitor, forcingLog bool) bool {
@@ -1309,6 +1494,7 @@ func (cluster *Cluster) VMasterFailover(fail bool) bool {
 		crash.UnixTimestamp = time.Now().Unix()
 		crash.URL = cluster.oldMaster.URL
 		crash.ElectedMasterURL = cluster.master.URL
+		crash.Id = misc.GetUUID()
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Save replication status before electing")
 		ms, err := cluster.master.GetSlaveStatus(cluster.master.ReplicationSourceName)
 		if err != nil {
@@ -0,0 +1,127 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// getZone returns the location of a host:port in topology-zones parsed when
+// the configuration is loaded, else the labels of the orchestrator agent
+// hosting it
+func (cluster *Cluster) getZone(url string, agent string) config.Zone {
+	if z, ok := cluster.topologyZones[url]; ok {
+		return z
+	}
+	if agent == "" {
+		return config.Zone{}
+	}
+	for _, a := range cluster.Agents {
+		if a.HostName == agent {
+			return config.Zone{Region: a.Region, Zone: a.Zone}
+		}
+	}
+	return config.Zone{}
+}
+
+func (server *ServerMonitor) GetZone() config.Zone {
+	return server.ClusterGroup.getZone(server.URL, server.Agent)
+}
+
+func (proxy *Proxy) GetZone() config.Zone {
+	return proxy.ClusterGroup.getZone(proxy.Host+":"+proxy.Port, proxy.Agent)
+}
+
+// setElectionZone sets the location of a candidate compared to the master
+func (cluster *Cluster) setElectionZone(candidate *ElectionCandidate, sl *ServerMonitor) {
+	z := sl.GetZone()
+	candidate.Region = z.Region
+	candidate.Zone = z.Zone
+	if cluster.master == nil {
+		return
+	}
+	mz := cluster.master.GetZone()
+	candidate.SameZone = z.IsSameZone(mz)
+	candidate.SameRegion = z.Region != "" && z.Region == mz.Region
+}
+
+// getElectionZoneRank ranks a candidate in the zone of the master over one in
+// its region, over others when failover-zone-prefer-local is enabled
+func (cluster *Cluster) getElectionZoneRank(candidate ElectionCandidate) int {
+	if !cluster.Conf.FailZonePreferLocal {
+		return 0
+	}
+	if candidate.SameZone {
+		return 2
+	}
+	if candidate.SameRegion {
+		return 1
+	}
+	return 0
+}
+
+// getZoneCount returns the number of zones having a server up
+func (cluster *Cluster) getZoneCount() int {
+	zones := make(map[string]bool)
+	for _, s := range cluster.Servers {
+		if z := s.GetZone(); z.Zone != "" && !s.IsFailed() {
+			zones[z.String()] = true
+		}
+	}
+	return len(zones)
+}
+
+// isZoneQuorumReached tests that servers are up in failover-zone-min zones,
+// a failover is not started from a partition holding fewer zones
+func (cluster *Cluster) isZoneQuorumReached() bool {
+	if cluster.Conf.FailZoneMin <= 0 {
+		return true
+	}
+	if zones := cluster.getZoneCount(); zones < cluster.Conf.FailZoneMin {
+		cluster.SetState("ERR00098", state.State{ErrType: config.LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00098"], zones, cluster.Conf.FailZoneMin), ErrFrom: "CHECK"})
+		return false
+	}
+	return true
+}
+
+// isCrossRegionFailoverAllowed tests that the elected candidate is in the
+// region of the master unless failover-zone-cross-region, a manual failover
+// promoting it anyway
+func (cluster *Cluster) isCrossRegionFailoverAllowed() bool {
+	if cluster.Conf.FailZoneCrossRegion || cluster.master == nil {
+		return true
+	}
+	key := cluster.electFailoverCandidate(cluster.slaves, false)
+	if key == -1 {
+		return true
+	}
+	candidate := cluster.slaves[key]
+	cz := candidate.GetZone()
+	mz := cluster.master.GetZone()
+	if cz.IsCrossRegion(mz) {
+		cluster.SetState("ERR00099", state.State{ErrType: config.LvlErr, ErrDesc: fmt.Sprintf(clusterError["ERR00099"], candidate.URL, cz.Region, mz.Region), ErrFrom: "CHECK", ServerUrl: candidate.URL})
+		return false
+	}
+	return true
+}
+
+// isProxyRoutable tests that a proxy may route to the master given
+// failover-zone-proxy-cross-region
+func (cluster *Cluster) isProxyRoutable(pr DatabaseProxy) bool {
+	if !pr.IsIgnored() {
+		return true
+	}
+	master := cluster.GetMaster()
+	cluster.SetState("WARN0133", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0133"], pr.GetURL(), pr.GetZone().Region, master.URL, master.GetZone().Region), ErrFrom: "PROXY"})
+	return false
+}
@@ -28,6 +28,13 @@ func (cluster *Cluster) HasServer(srv *ServerMonitor) bool {
 	return false
 }
 
+// IsBackupKeptUntilValid tells if the last backup of a tool is renamed .old
+// until the next backup is valid, with backup-keep-until-valid or with
+// backup-retention that archives it then
+func (cluster *Cluster) IsBackupKeptUntilValid() bool {
+	return cluster.Conf.BackupKeepUntilValid || cluster.Conf.BackupRetention
+}
+
 func (cluster *Cluster) HasValidBackup() bool {
 	//	if cluster.Conf.MonitorScheduler && (cluster.Conf.SchedulerBackupLogical || cluster.Conf.SchedulerBackupPhysical) {
 	sv := cluster.GetBackupServer()
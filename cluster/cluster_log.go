@@ -14,16 +14,13 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	teams "github.com/atc0005/go-teams-notify/v2"
-	"github.com/atc0005/go-teams-notify/v2/messagecard"
-
 	"github.com/nsf/termbox-go"
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/alert"
 	"github.com/signal18/replication-manager/utils/s18log"
 	"github.com/signal18/replication-manager/utils/state"
 	log "github.com/sirupsen/logrus"
@@ -170,53 +167,24 @@ func (cluster *Cluster) LogPrintf(level string, format string, args ...interface
 
 	if cluster.Conf.Daemon {
 		// wrap logrus levels
+		errkey := ""
 		switch level {
 		case "ERROR":
 			cluster.Logrus.WithField("cluster", cluster.Name).Errorf(cliformat, args...)
-			if cluster.Conf.SlackURL != "" {
-				cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack"}).Errorf(cliformat, args...)
-			}
-			if cluster.Conf.TeamsUrl != "" {
-				go cluster.sendMsTeams(level, format, args...)
-			}
 		case "INFO":
 			cluster.Logrus.WithField("cluster", cluster.Name).Infof(cliformat, args...)
 		case "DEBUG":
 			cluster.Logrus.WithField("cluster", cluster.Name).Debugf(cliformat, args...)
 		case "WARN":
 			cluster.Logrus.WithField("cluster", cluster.Name).Warnf(cliformat, args...)
-			if cluster.Conf.SlackURL != "" {
-				cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack"}).Warnf(cliformat, args...)
-			}
-			if cluster.Conf.TeamsUrl != "" {
-				go cluster.sendMsTeams(level, format, args...)
-			}
 		case "TEST":
 			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "test", "channel": "StdOut"}).Infof(cliformat, args...)
 		case "BENCH":
 			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "benchmark", "channel": "StdOut"}).Infof(cliformat, args...)
 		case "ALERT":
 			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "StdOut"}).Errorf(cliformat, args...)
-			if cluster.Conf.SlackURL != "" {
-				cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack"}).Errorf(cliformat, args...)
-			}
-			if cluster.Conf.PushoverAppToken != "" && cluster.Conf.PushoverUserToken != "" {
-				cluster.LogPushover.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Pushover"}).Errorf(cliformat, args...)
-			}
-			if cluster.Conf.TeamsUrl != "" {
-				go cluster.sendMsTeams(level, format, args...)
-			}
 		case "START":
 			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "StdOut"}).Warnf(cliformat, args...)
-			if cluster.Conf.SlackURL != "" {
-				cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "start", "channel": "Slack"}).Warnf(cliformat, args...)
-			}
-			if cluster.Conf.PushoverAppToken != "" && cluster.Conf.PushoverUserToken != "" {
-				cluster.LogPushover.WithFields(log.Fields{"cluster": cluster.Name, "type": "start", "channel": "Pushover"}).Warnf(cliformat, args...)
-			}
-			if cluster.Conf.TeamsUrl != "" {
-				go cluster.sendMsTeams(level, format, args...)
-			}
 		case "STATE":
 			status := cliformat[0:6]
 			code := cliformat[7:15]
@@ -226,74 +194,146 @@ func (cluster *Cluster) LogPrintf(level string, format string, args ...interface
 			} else {
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "state", "status": status, "code": code, "channel": "StdOut"}).Warnf(err, args...)
 			}
-
-			if cluster.Conf.TeamsUrl != "" && cluster.Conf.TeamsAlertState != "" {
-				stateList := strings.Split(cluster.Conf.TeamsAlertState, ",")
-				for _, alertcode := range stateList {
-					if strings.Contains(code, alertcode) {
-						go cluster.sendMsTeams(level, format, args...)
-						break
-					}
-				}
-			}
-
+			errkey = code
 		default:
 			cluster.Logrus.Printf(cliformat, args...)
 		}
+		cluster.notifyLog(level, "", errkey, "", cliformat, args...)
 
 	}
 
 	return line
 }
 
-func (cluster *Cluster) sendMsTeams(level string, format string, args ...interface{}) error {
-	// init the client
-	mstClient := teams.NewTeamsClient()
+// Alerts waiting for the dispatch worker of a cluster, alerts are dropped
+// while the queue is full so a slow channel can not pile up goroutines
+const alertQueueSize = 1000
+
+// alertNotifiers holds the alert channels, swapped when a setting changes
+// while alerts are dispatched, and the queue of their dispatch worker
+type alertNotifiers struct {
+	atomic.Pointer[alert.Registry]
+	queue   chan alertDispatch
+	start   sync.Once
+	dropped atomic.Int64
+}
+
+// alertDispatch is an alert queued for some channels
+type alertDispatch struct {
+	notifiers *alert.Registry
+	alert     alert.Alert
+	channels  []string
+}
 
-	// setup webhook url
-	webhookUrl := cluster.Conf.TeamsUrl
-	webhookProxyUrl := cluster.Conf.TeamsProxyUrl
+// initNotifiers builds the alert channels from the cluster configuration
+func (cluster *Cluster) initNotifiers() {
+	notifiers, err := alert.NewRegistryFromConf(&cluster.Conf)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not init alert notifiers: %s", err)
+	}
+	cluster.notifiers.Store(notifiers)
+}
 
-	proxyUrl, err := url.Parse(cluster.Conf.TeamsProxyUrl)
+// getNotifiers returns the alert channels, nil when none is registered
+func (cluster *Cluster) getNotifiers() *alert.Registry {
+	notifiers := cluster.notifiers.Load()
+	if notifiers == nil || notifiers.Len() == 0 {
+		return nil
+	}
+	return notifiers
+}
 
-	// Create a copy of the default mstClient.HTTPClient
-	httpClient := mstClient.HTTPClient()
+// initAlertRouting loads the routes, the flapping window and the persisted silences
+func (cluster *Cluster) initAlertRouting() {
+	routes, err := alert.ParseRoutes(cluster.Conf.AlertRoutes)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not parse alert routes: %s", err)
+	}
+	cluster.AlertRoutes = routes
+	cluster.AlertGrouper = alert.NewGrouper(time.Duration(cluster.Conf.AlertGroupWindow) * time.Second)
+	cluster.AlertSilences = alert.NewSilences(cluster.WorkingDir + "/alertsilences.json")
+	if err := cluster.AlertSilences.Load(); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Could not load alert silences: %s", err)
+	}
+}
 
-	if webhookProxyUrl != "" {
-		if err == nil {
-			httpClient.Transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
-		} else {
-			cluster.Logrus.Printf(
-				"Failed to parse proxy URL %q: %v. Using the default HTTP client without a proxy.",
-				webhookProxyUrl,
-				err,
-			)
+// notifyLog forwards a log line of a level relayed by the channels to the
+// notifiers, each channel deciding on the levels it relays, unless the state
+// of the line is silenced
+func (cluster *Cluster) notifyLog(level string, module string, errkey string, host string, format string, args ...interface{}) {
+	if !alert.IsRelayedLogLevel(level) {
+		return
+	}
+	notifiers := cluster.getNotifiers()
+	if notifiers == nil {
+		return
+	}
+	a := alert.Alert{
+		Type:      alert.TypeLog,
+		Cluster:   cluster.Name,
+		Monitor:   cluster.Conf.MonitorAddress,
+		Host:      host,
+		Level:     level,
+		Module:    module,
+		ErrKey:    errkey,
+		Text:      fmt.Sprintf(format, args...),
+		Timestamp: time.Now(),
+	}
+	if cluster.AlertSilences != nil {
+		if _, ok := cluster.AlertSilences.Matching(a); ok {
+			return
 		}
-	} else {
-		cluster.Logrus.Printf("Proxy URL is empty. Using the default HTTP client without a proxy.")
-	}
-
-	// setup message card
-	msgCard := messagecard.NewMessageCard()
-	msgCard.Title = "Replication-Manager alert. Monitor: " + cluster.Conf.MonitorAddress
-	switch level {
-	case "ERROR":
-		msgCard.ThemeColor = "#4169e1"
-	case "ALERT":
-		msgCard.ThemeColor = "#b22222"
-	case "WARN":
-		msgCard.ThemeColor = "#112233"
-	}
-
-	msgCard.Text = fmt.Sprintf(format, args...)
-	// send
-	if err := mstClient.Send(webhookUrl, msgCard); err != nil {
-		cluster.Logrus.Printf(
-			"failed to send MSTeams alert message: %s",
-			err,
-		)
-	}
-	return nil
+	}
+	cluster.dispatchAlert(notifiers, a)
+}
+
+// SendMessage sends an explicit message to the channels accepting messages
+func (cluster *Cluster) SendMessage(subject string, msg string) {
+	notifiers := cluster.getNotifiers()
+	if notifiers == nil {
+		return
+	}
+	a := alert.Alert{
+		Type:      alert.TypeMessage,
+		Cluster:   cluster.Name,
+		Monitor:   cluster.Conf.MonitorAddress,
+		Subject:   subject,
+		Text:      msg,
+		Timestamp: time.Now(),
+	}
+	cluster.dispatchAlert(notifiers, a)
+}
+
+// dispatchAlert queues an alert for every channel
+func (cluster *Cluster) dispatchAlert(notifiers *alert.Registry, a alert.Alert) {
+	cluster.dispatchAlertChannels(notifiers, a, notifiers.Names())
+}
+
+// dispatchAlertChannels queues an alert for the dispatch worker of the
+// cluster, started on the first alert. Errors are only written to logrus,
+// using LogPrintf would loop back to the failing channel.
+func (cluster *Cluster) dispatchAlertChannels(notifiers *alert.Registry, a alert.Alert, channels []string) {
+	q := &cluster.notifiers
+	q.start.Do(func() {
+		q.queue = make(chan alertDispatch, alertQueueSize)
+		go cluster.runAlertDispatch(q.queue)
+	})
+	select {
+	case q.queue <- alertDispatch{notifiers: notifiers, alert: a, channels: channels}:
+	default:
+		if dropped := q.dropped.Add(1); dropped%100 == 1 && cluster.Logrus != nil {
+			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert"}).Errorf("Alert queue full, %d alerts dropped", dropped)
+		}
+	}
+}
+
+// runAlertDispatch sends the queued alerts one at a time
+func (cluster *Cluster) runAlertDispatch(queue chan alertDispatch) {
+	for d := range queue {
+		if err := d.notifiers.NotifyChannels(d.alert, d.channels); err != nil && cluster.Logrus != nil {
+			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert"}).Errorf("Could not send alert: %s", err)
+		}
+	}
 }
 
 /*
@@ -343,53 +383,24 @@ func (cluster *Cluster) LogModulePrintf(forcingLog bool, module int, level strin
 
 		if cluster.Conf.Daemon {
 			// wrap logrus levels
+			errkey := ""
 			switch level {
 			case "ERROR":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "log", "module": tag}).Errorf(cliformat, args...)
-				if cluster.Conf.SlackURL != "" {
-					cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack", "module": tag}).Errorf(cliformat, args...)
-				}
-				if cluster.Conf.TeamsUrl != "" {
-					go cluster.sendMsTeams(level, format, args...)
-				}
 			case "INFO":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "log", "module": tag}).Infof(cliformat, args...)
 			case "DEBUG":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "log", "module": tag}).Debugf(cliformat, args...)
 			case "WARN":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "log", "module": tag}).Warnf(cliformat, args...)
-				if cluster.Conf.SlackURL != "" {
-					cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack", "module": tag}).Warnf(cliformat, args...)
-				}
-				if cluster.Conf.TeamsUrl != "" {
-					go cluster.sendMsTeams(level, format, args...)
-				}
 			case "TEST":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "test", "channel": "StdOut", "module": tag}).Infof(cliformat, args...)
 			case "BENCH":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "benchmark", "channel": "StdOut", "module": tag}).Infof(cliformat, args...)
 			case "ALERT":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "StdOut", "module": tag}).Errorf(cliformat, args...)
-				if cluster.Conf.SlackURL != "" {
-					cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Slack", "module": tag}).Errorf(cliformat, args...)
-				}
-				if cluster.Conf.PushoverAppToken != "" && cluster.Conf.PushoverUserToken != "" {
-					cluster.LogPushover.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "Pushover", "module": tag}).Errorf(cliformat, args...)
-				}
-				if cluster.Conf.TeamsUrl != "" {
-					go cluster.sendMsTeams(level, format, args...)
-				}
 			case "START":
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "alert", "channel": "StdOut", "module": tag}).Warnf(cliformat, args...)
-				if cluster.Conf.SlackURL != "" {
-					cluster.LogSlack.WithFields(log.Fields{"cluster": cluster.Name, "type": "start", "channel": "Slack", "module": tag}).Warnf(cliformat, args...)
-				}
-				if cluster.Conf.PushoverAppToken != "" && cluster.Conf.PushoverUserToken != "" {
-					cluster.LogPushover.WithFields(log.Fields{"cluster": cluster.Name, "type": "start", "channel": "Pushover", "module": tag}).Warnf(cliformat, args...)
-				}
-				if cluster.Conf.TeamsUrl != "" {
-					go cluster.sendMsTeams(level, format, args...)
-				}
 			case "STATE":
 				status := cliformat[0:6]
 				code := cliformat[7:15]
@@ -399,20 +410,15 @@ func (cluster *Cluster) LogModulePrintf(forcingLog bool, module int, level strin
 				} else {
 					cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "state", "status": status, "code": code, "channel": "StdOut"}).Warnf(err, args...)
 				}
-
-				if cluster.Conf.TeamsUrl != "" && cluster.Conf.TeamsAlertState != "" {
-					stateList := strings.Split(cluster.Conf.TeamsAlertState, ",")
-					for _, alertcode := range stateList {
-						if strings.Contains(code, alertcode) {
-							go cluster.sendMsTeams(level, format, args...)
-							break
-						}
-					}
-				}
-
+				errkey = code
 			default:
 				cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "log", "module": tag}).Printf(cliformat, args...)
 			}
+			// Lines printed only because of forcingLog are not relayed, the
+			// log level of their module filters them out
+			if eligible {
+				cluster.notifyLog(level, tag, errkey, "", cliformat, args...)
+			}
 		}
 	}
 
@@ -546,16 +552,7 @@ func (cluster *Cluster) LogPrintState(st state.State, resolved bool) int {
 		} else {
 			cluster.Logrus.WithFields(log.Fields{"cluster": cluster.Name, "type": "state", "status": "OPENED", "code": st.ErrKey, "channel": "StdOut"}).Warnf(st.ErrDesc)
 		}
-
-		if cluster.Conf.TeamsUrl != "" && cluster.Conf.TeamsAlertState != "" {
-			stateList := strings.Split(cluster.Conf.TeamsAlertState, ",")
-			for _, alertcode := range stateList {
-				if strings.Contains(st.ErrKey, alertcode) {
-					go cluster.sendMsTeams(level, format)
-					break
-				}
-			}
-		}
+		cluster.notifyLog(level, tag, st.ErrKey, st.ServerUrl, "%s", cliformat)
 	}
 
 	return line
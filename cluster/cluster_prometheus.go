@@ -0,0 +1,254 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/openmetrics"
+)
+
+var (
+	failoverDurationBuckets = []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+	replicationDelayBuckets = []float64{0, 1, 2, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+// histogramMap holds the failover and switchover duration histograms
+type histogramMap map[string]*openmetrics.Histogram
+
+func newFailoverHistograms() histogramMap {
+	return histogramMap{
+		"failover":   openmetrics.NewHistogram(failoverDurationBuckets...),
+		"switchover": openmetrics.NewHistogram(failoverDurationBuckets...),
+	}
+}
+
+// observeFailoverDuration feeds the failover duration histogram at the end of a MasterFailover
+func (cluster *Cluster) observeFailoverDuration(fail bool, d time.Duration) {
+	h := cluster.failoverHistograms["switchover"]
+	if fail {
+		h = cluster.failoverHistograms["failover"]
+	}
+	if h != nil {
+		h.Observe(d.Seconds())
+	}
+}
+
+// GetPrometheusRole returns the role label of a server
+func (server *ServerMonitor) GetPrometheusRole() string {
+	switch {
+	case server.IsMaster():
+		return "master"
+	case server.IsRelay:
+		return "relay"
+	case server.IsSlave:
+		return "slave"
+	}
+	return "standalone"
+}
+
+// prometheusMetricName converts a graphite metric name like the ones of
+// GetDatabaseMetrics into the family name used for the filter lists
+func prometheusMetricName(name string) string {
+	v := strings.Split(name, ".")
+	if len(v) < 3 {
+		return name
+	}
+	if v[2] == "pfs" {
+		return "mysql_pfs"
+	}
+	return v[2]
+}
+
+// legacyPrometheusMetric returns the former family name of a graphite metric
+// name and its graphite hostname, the only label of the legacy series
+func legacyPrometheusMetric(name string) (string, string) {
+	v := strings.Split(name, ".")
+	if len(v) < 3 {
+		return name, ""
+	}
+	if v[2] == "pfs" && len(v) > 3 {
+		return v[2] + "_" + v[3], v[1]
+	}
+	return v[2], v[1]
+}
+
+// matchPrometheus applies the graphite blacklist to the replication-manager families,
+// the whitelist only selects the raw database status and variables
+func (cluster *Cluster) matchPrometheus(family string) bool {
+	cg := cluster.ClusterGraphite
+	return cg == nil || !cg.UseBlacklist || !cg.MatchBlacklist(family)
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// GetOpenMetrics adds the cluster, server, job, backup and proxy families of the cluster
+func (cluster *Cluster) GetOpenMetrics(e *openmetrics.Exposition) {
+	lc := openmetrics.L("cluster", cluster.Name)
+	add := func(name string, typ string, help string, value float64, labels ...openmetrics.Label) {
+		if cluster.matchPrometheus(name) {
+			e.Family(name, typ, help).Add(value, append([]openmetrics.Label{lc}, labels...)...)
+		}
+	}
+
+	add("replication_manager_cluster_info", openmetrics.TypeGauge, "Cluster topology", 1, openmetrics.L("topology", cluster.GetTopology()))
+	add("replication_manager_cluster_failovers", openmetrics.TypeCounter, "Failovers since the last reset of the failover control", float64(cluster.FailoverCtr))
+	add("replication_manager_cluster_failable", openmetrics.TypeGauge, "Cluster can failover", boolMetric(cluster.IsFailable))
+	sla := cluster.StateMachine.GetSla()
+	if sla.Lasttime > sla.Firsttime {
+		add("replication_manager_cluster_sla_uptime_percent", openmetrics.TypeGauge, "SLA uptime of the master", sla.GetUptime(), openmetrics.L("sla", "uptime"))
+		add("replication_manager_cluster_sla_uptime_percent", openmetrics.TypeGauge, "SLA uptime of the master", sla.GetUptimeFailable(), openmetrics.L("sla", "failable"))
+		add("replication_manager_cluster_sla_uptime_percent", openmetrics.TypeGauge, "SLA uptime of the master", sla.GetUptimeSemiSync(), openmetrics.L("sla", "semisync"))
+	}
+	if cluster.matchPrometheus("replication_manager_failover_duration_seconds") {
+		f := e.Family("replication_manager_failover_duration_seconds", openmetrics.TypeHistogram, "Duration of the failovers and switchovers since start")
+		for _, typ := range []string{"failover", "switchover"} {
+			if h, ok := cluster.failoverHistograms[typ]; ok {
+				f.AddHistogram(h, lc, openmetrics.L("type", typ))
+			}
+		}
+	}
+
+	add("replication_manager_backup_throttled", openmetrics.TypeGauge, "Backups are paused or deferred on replication delay or master load", boolMetric(cluster.IsBackupPaused()))
+
+	now := time.Now()
+	if cluster.Conf.BackupBinlogsArchiver {
+		status := cluster.GetBinlogArchiverStatus()
+		la := openmetrics.L("server", status.Source)
+		add("replication_manager_binlog_archiver_running", openmetrics.TypeGauge, "Binary logs archiver is streaming without error", boolMetric(status.Running && status.Error == ""), la)
+		add("replication_manager_binlog_archiver_lag_seconds", openmetrics.TypeGauge, "Seconds since the last event archived, or the last heartbeat of an idle master", status.GetLag(now), la)
+		add("replication_manager_binlog_archiver_bytes", openmetrics.TypeCounter, "Bytes of binary logs archived", float64(status.Bytes), la)
+	}
+	for _, server := range cluster.Servers {
+		if server == nil {
+			continue
+		}
+		server.getOpenMetrics(e, now)
+	}
+
+	for _, pr := range cluster.Proxies {
+		if pr == nil {
+			continue
+		}
+		lp := []openmetrics.Label{openmetrics.L("proxy", pr.GetURL()), openmetrics.L("proxy_type", pr.GetType())}
+		add("replication_manager_proxy_up", openmetrics.TypeGauge, "Proxy is up", boolMetric(!pr.IsDown()), lp...)
+		add("replication_manager_proxy_fail_count", openmetrics.TypeGauge, "Failed checks of the proxy", float64(pr.GetFailCount()), lp...)
+		for _, b := range []struct {
+			role     string
+			backends []Backend
+		}{{"write", pr.GetBackendsWrite()}, {"read", pr.GetBackendsRead()}} {
+			for _, bk := range b.backends {
+				lb := append(append([]openmetrics.Label{}, lp...), openmetrics.L("backend", bk.PrxName), openmetrics.L("hostgroup", bk.PrxHostgroup), openmetrics.L("route", b.role))
+				if v, err := strconv.ParseFloat(bk.PrxConnections, 64); err == nil {
+					add("replication_manager_proxy_backend_connections", openmetrics.TypeGauge, "Connections used by the proxy on the backend", v, lb...)
+				}
+				if v, err := strconv.ParseFloat(bk.PrxByteOut, 64); err == nil {
+					add("replication_manager_proxy_backend_sent_bytes", openmetrics.TypeCounter, "Bytes sent by the proxy to the backend", v, lb...)
+				}
+				if v, err := strconv.ParseFloat(bk.PrxByteIn, 64); err == nil {
+					add("replication_manager_proxy_backend_received_bytes", openmetrics.TypeCounter, "Bytes received by the proxy from the backend", v, lb...)
+				}
+				if v, err := strconv.ParseFloat(bk.PrxLatency, 64); err == nil {
+					add("replication_manager_proxy_backend_latency_seconds", openmetrics.TypeGauge, "Latency of the backend seen by the proxy", v/1e6, lb...)
+				}
+				add("replication_manager_proxy_backend_online", openmetrics.TypeGauge, "Backend is online in the proxy", boolMetric(bk.PrxStatus == "ONLINE"), lb...)
+			}
+		}
+	}
+}
+
+func (server *ServerMonitor) getOpenMetrics(e *openmetrics.Exposition, now time.Time) {
+	cluster := server.ClusterGroup
+	ls := []openmetrics.Label{openmetrics.L("cluster", cluster.Name), openmetrics.L("server", server.URL), openmetrics.L("role", server.GetPrometheusRole())}
+	add := func(name string, typ string, help string, value float64, labels ...openmetrics.Label) {
+		if cluster.matchPrometheus(name) {
+			e.Family(name, typ, help).Add(value, append(append([]openmetrics.Label{}, ls...), labels...)...)
+		}
+	}
+
+	add("replication_manager_server_up", openmetrics.TypeGauge, "Server is up", boolMetric(!server.IsDown()))
+	add("replication_manager_server_state", openmetrics.TypeGauge, "Monitored state of the server", 1, openmetrics.L("state", server.State))
+	add("replication_manager_server_maintenance", openmetrics.TypeGauge, "Server is in maintenance", boolMetric(server.IsMaintenance))
+	add("replication_manager_server_ignored", openmetrics.TypeGauge, "Server is ignored for election", boolMetric(server.IsIgnored()))
+	add("replication_manager_server_fail_count", openmetrics.TypeGauge, "Failed checks of the server", float64(server.FailCount))
+	add("replication_manager_server_queries_per_second", openmetrics.TypeGauge, "Queries per second between the last two monitoring loops", float64(server.QPS))
+	if server.IsSlave && server.SlaveStatus != nil {
+		add("replication_manager_server_replication_lag_seconds", openmetrics.TypeGauge, "Seconds behind master of the replica", float64(server.SlaveStatus.SecondsBehindMaster.Int64))
+		add("replication_manager_server_replication_sql_running", openmetrics.TypeGauge, "Replica SQL thread is running", boolMetric(server.SlaveStatus.SlaveSQLRunning.String == "Yes"))
+		add("replication_manager_server_replication_io_running", openmetrics.TypeGauge, "Replica IO thread is running", boolMetric(server.SlaveStatus.SlaveIORunning.String == "Yes"))
+	}
+	if server.DelayHistogram != nil && cluster.matchPrometheus("replication_manager_server_replication_delay_seconds") {
+		e.Family("replication_manager_server_replication_delay_seconds", openmetrics.TypeHistogram, "Replication delay observed at each monitoring loop").AddHistogram(server.DelayHistogram, ls...)
+	}
+	if server.DelayStat != nil {
+		for _, p := range []struct {
+			period string
+			stat   DelayStat
+		}{{"total", server.DelayStat.Total}, {"rotated", server.DelayStat.Rotated}, {"current", server.DelayStat.Current}} {
+			add("replication_manager_server_delay_average_seconds", openmetrics.TypeGauge, "Average replication delay of the delay statistics period", p.stat.DelayAvg, openmetrics.L("period", p.period))
+			add("replication_manager_server_delay_events", openmetrics.TypeGauge, "Delayed checks of the delay statistics period", float64(p.stat.DelayCount), openmetrics.L("period", p.period))
+			add("replication_manager_server_replication_errors", openmetrics.TypeGauge, "Replication errors of the delay statistics period", float64(p.stat.SlaveErrCount), openmetrics.L("period", p.period))
+		}
+	}
+
+	if server.JobResults != nil {
+		server.JobResults.Callback(func(job string, t *config.Task) bool {
+			add("replication_manager_job_state", openmetrics.TypeGauge, "Last state of the job, 0 available 1 running 2 halted 3 finished 4 success 5 exec error 6 after error", float64(t.State), openmetrics.L("job", job))
+			if t.Start > 0 {
+				add("replication_manager_job_start_timestamp_seconds", openmetrics.TypeGauge, "Start time of the last job run", float64(t.Start), openmetrics.L("job", job))
+			}
+			if t.End > 0 {
+				add("replication_manager_job_end_timestamp_seconds", openmetrics.TypeGauge, "End time of the last job run", float64(t.End), openmetrics.L("job", job))
+			}
+			return true
+		})
+	}
+
+	for _, b := range []struct {
+		method string
+		meta   *config.BackupMetadata
+		cookie bool
+	}{{"logical", server.LastBackupMeta.Logical, server.HasBackupLogicalCookie()}, {"physical", server.LastBackupMeta.Physical, server.HasBackupPhysicalCookie()}} {
+		add("replication_manager_backup_valid", openmetrics.TypeGauge, "Server has a valid backup cookie", boolMetric(b.cookie), openmetrics.L("method", b.method))
+		if b.meta == nil || b.meta.EndTime.IsZero() {
+			continue
+		}
+		add("replication_manager_backup_age_seconds", openmetrics.TypeGauge, "Age of the last backup", now.Sub(b.meta.EndTime).Seconds(), openmetrics.L("method", b.method))
+		add("replication_manager_backup_size_bytes", openmetrics.TypeGauge, "Size of the last backup", float64(b.meta.Size), openmetrics.L("method", b.method))
+	}
+
+	// raw database families already filtered by the graphite lists, the legacy
+	// series keep the former names and labels while prometheus-legacy-metrics is on
+	instance := openmetrics.L("instance", server.Variables.Get("HOSTNAME"))
+	for _, m := range server.GetDatabaseMetrics() {
+		v, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil {
+			continue
+		}
+		name := prometheusMetricName(m.Name)
+		labels := append(append([]openmetrics.Label{}, ls...), instance)
+		if name == "mysql_pfs" {
+			labels = append(labels, openmetrics.L("digest", m.Name[strings.Index(m.Name, ".pfs.")+5:]))
+		}
+		e.Family(name, openmetrics.TypeGauge, "Database metric "+name).Add(v, labels...)
+		if cluster.Conf.PrometheusLegacyMetrics {
+			legacy, host := legacyPrometheusMetric(m.Name)
+			e.Family(legacy, openmetrics.TypeGauge, "Database metric "+legacy).Add(v, openmetrics.L("instance", host))
+		}
+	}
+}
@@ -15,7 +15,6 @@ import (
 	vault "github.com/hashicorp/vault/api"
 	"github.com/jordan-wright/email"
 	"github.com/signal18/replication-manager/config"
-	"github.com/signal18/replication-manager/utils/alert"
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/sirupsen/logrus"
@@ -195,13 +194,8 @@ func (cluster *Cluster) RotatePasswords() error {
 				msg := "A password rotation has been made on Replication-Manager " + cluster.Name + " cluster. Check the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModVault, "ALERT", msg)
 			}
-			if cluster.Conf.MailTo != "" {
-				msg := "A password rotation has been made\nCheck the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
-				subj := "Password Rotation Replication-Manager"
-				alert := alert.Alert{}
-				alert.Cluster = cluster.Name
-				go alert.EmailMessage(msg, subj, cluster.Conf)
-			}
+			msg := "A password rotation has been made\nCheck the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
+			cluster.SendMessage("Password Rotation Replication-Manager", msg)
 
 		}
 	} else {
@@ -308,13 +302,8 @@ func (cluster *Cluster) RotatePasswords() error {
 				msg := "A password rotation has been made on Replication-Manager " + cluster.Name + " cluster. Check the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModVault, "ALERT", msg)
 			}
-			if cluster.Conf.MailTo != "" {
-				msg := "A password rotation has been made\nCheck the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
-				subj := "Password Rotation Replication-Manager"
-				alert := alert.Alert{}
-				alert.Cluster = cluster.Name
-				go alert.EmailMessage(msg, subj, cluster.Conf)
-			}
+			msg := "A password rotation has been made\nCheck the new password on " + cluster.Conf.VaultServerAddr + " website on path " + cluster.Conf.VaultMount + cluster.Conf.User + " and " + cluster.Conf.VaultMount + cluster.Conf.RplUser + "."
+			cluster.SendMessage("Password Rotation Replication-Manager", msg)
 
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModVault, config.LvlInfo, "Password rotation is done.")
 			cluster.Save()
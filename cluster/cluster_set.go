@@ -21,6 +21,7 @@ import (
 
 	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/opensvc"
+	"github.com/signal18/replication-manager/utils/alert"
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/state"
@@ -108,12 +109,14 @@ func (cluster *Cluster) SetSchedulerBackupLogical() {
 		var err error
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Schedule logical backup time at: %s", cluster.Conf.BackupLogicalCron)
 		cluster.idSchedulerLogicalBackup, err = cluster.scheduler.AddFunc(cluster.Conf.BackupLogicalCron, func() {
-			mysrv := cluster.GetBackupServer()
-			if mysrv != nil {
-				mysrv.JobBackupLogical()
-			} else {
-				cluster.master.JobBackupLogical()
-			}
+			cluster.RunScheduledBackup("logical", func() {
+				mysrv := cluster.GetBackupServer()
+				if mysrv != nil {
+					mysrv.JobBackupLogical()
+				} else {
+					cluster.master.JobBackupLogical()
+				}
+			})
 		})
 		if err == nil {
 			cluster.Schedule["backuplogical"] = cluster.scheduler.Entry(cluster.idSchedulerPhysicalBackup)
@@ -135,7 +138,9 @@ func (cluster *Cluster) SetSchedulerBackupPhysical() {
 		var err error
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Schedule Physical backup time at: %s", cluster.Conf.BackupPhysicalCron)
 		cluster.idSchedulerPhysicalBackup, err = cluster.scheduler.AddFunc(cluster.Conf.BackupPhysicalCron, func() {
-			cluster.master.JobBackupPhysical()
+			cluster.RunScheduledBackup("physical", func() {
+				cluster.master.JobBackupPhysical()
+			})
 		})
 		if err == nil {
 			cluster.Schedule["backupphysical"] = cluster.scheduler.Entry(cluster.idSchedulerPhysicalBackup)
@@ -143,6 +148,78 @@ func (cluster *Cluster) SetSchedulerBackupPhysical() {
 	}
 }
 
+func (cluster *Cluster) SetSchedulerBackupPhysicalIncremental() {
+	if cluster.scheduler == nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Scheduler is disable cancel")
+		return
+	}
+	if cluster.HasSchedulerEntry("backupphysicalincremental") {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Disable database incremental physical backup")
+		cluster.scheduler.Remove(cluster.idSchedulerIncrBackup)
+		delete(cluster.Schedule, "backupphysicalincremental")
+	}
+	if cluster.Conf.SchedulerBackupPhysicalIncremental {
+		var err error
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Schedule incremental physical backup time at: %s", cluster.Conf.BackupPhysicalIncrementalCron)
+		cluster.idSchedulerIncrBackup, err = cluster.scheduler.AddFunc(cluster.Conf.BackupPhysicalIncrementalCron, func() {
+			cluster.RunScheduledBackup("incremental physical", func() {
+				cluster.master.JobBackupPhysicalIncremental(config.BackupStrategyIncremental)
+			})
+		})
+		if err == nil {
+			cluster.Schedule["backupphysicalincremental"] = cluster.scheduler.Entry(cluster.idSchedulerIncrBackup)
+		}
+	}
+}
+
+func (cluster *Cluster) SetSchedulerBackupPhysicalDifferential() {
+	if cluster.scheduler == nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Scheduler is disable cancel")
+		return
+	}
+	if cluster.HasSchedulerEntry("backupphysicaldifferential") {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Disable database differential physical backup")
+		cluster.scheduler.Remove(cluster.idSchedulerDiffBackup)
+		delete(cluster.Schedule, "backupphysicaldifferential")
+	}
+	if cluster.Conf.SchedulerBackupPhysicalDifferential {
+		var err error
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Schedule differential physical backup time at: %s", cluster.Conf.BackupPhysicalDifferentialCron)
+		cluster.idSchedulerDiffBackup, err = cluster.scheduler.AddFunc(cluster.Conf.BackupPhysicalDifferentialCron, func() {
+			cluster.RunScheduledBackup("differential physical", func() {
+				cluster.master.JobBackupPhysicalIncremental(config.BackupStrategyDifferential)
+			})
+		})
+		if err == nil {
+			cluster.Schedule["backupphysicaldifferential"] = cluster.scheduler.Entry(cluster.idSchedulerDiffBackup)
+		}
+	}
+}
+
+func (cluster *Cluster) SetSchedulerBackupVerify() {
+	if cluster.scheduler == nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Scheduler is disable cancel")
+		return
+	}
+	if cluster.HasSchedulerEntry("backupverify") {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Disable backup restore verification")
+		cluster.scheduler.Remove(cluster.idSchedulerBackupVerify)
+		delete(cluster.Schedule, "backupverify")
+	}
+	if cluster.Conf.SchedulerBackupVerify {
+		var err error
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Schedule backup restore verification time at: %s", cluster.Conf.BackupVerifyCron)
+		cluster.idSchedulerBackupVerify, err = cluster.scheduler.AddFunc(cluster.Conf.BackupVerifyCron, func() {
+			if _, err := cluster.JobBackupVerify(); err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Backup restore verification: %s", err)
+			}
+		})
+		if err == nil {
+			cluster.Schedule["backupverify"] = cluster.scheduler.Entry(cluster.idSchedulerBackupVerify)
+		}
+	}
+}
+
 func (cluster *Cluster) SetSchedulerLogsTableRotate() {
 	if cluster.scheduler == nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Scheduler is disable cancel")
@@ -879,6 +956,28 @@ func (cluster *Cluster) SetBackupKeepYearly(keep string) error {
 	return nil
 }
 
+func (cluster *Cluster) SetBackupVerifyMethod(method string) error {
+	switch method {
+	case "", "logical", "physical":
+		cluster.Conf.BackupVerifyMethod = method
+		return nil
+	}
+	return fmt.Errorf("Unknown backup verification method %s", method)
+}
+
+func (cluster *Cluster) SetBackupVerifyServer(url string) {
+	cluster.Conf.BackupVerifyServer = url
+}
+
+func (cluster *Cluster) SetBackupVerifyRowTolerance(tolerance string) error {
+	numtol, err := strconv.Atoi(tolerance)
+	if err != nil {
+		return err
+	}
+	cluster.Conf.BackupVerifyRowTolerance = numtol
+	return nil
+}
+
 func (cluster *Cluster) SetBackupKeepHourly(keep string) error {
 	numkeep, err := strconv.Atoi(keep)
 	if err != nil {
@@ -1366,6 +1465,24 @@ func (cluster *Cluster) SetSchedulerDbServersPhysicalBackupCron(value string) er
 	return nil
 }
 
+func (cluster *Cluster) SetSchedulerDbServersPhysicalBackupIncrementalCron(value string) error {
+	cluster.Conf.BackupPhysicalIncrementalCron = value
+	cluster.SetSchedulerBackupPhysicalIncremental()
+	return nil
+}
+
+func (cluster *Cluster) SetSchedulerDbServersPhysicalBackupDifferentialCron(value string) error {
+	cluster.Conf.BackupPhysicalDifferentialCron = value
+	cluster.SetSchedulerBackupPhysicalDifferential()
+	return nil
+}
+
+func (cluster *Cluster) SetSchedulerDbServersBackupVerifyCron(value string) error {
+	cluster.Conf.BackupVerifyCron = value
+	cluster.SetSchedulerBackupVerify()
+	return nil
+}
+
 func (cluster *Cluster) SetSchedulerDbServersOptimizeCron(value string) error {
 	cluster.Conf.BackupDatabaseOptimizeCron = value
 	cluster.SetSchedulerOptimize()
@@ -1835,9 +1952,23 @@ func (cluster *Cluster) SetInLogicalBackupState(value bool) {
 }
 
 func (cluster *Cluster) SetInBinlogBackupState(value bool) {
+	cluster.binlogBackupLock.Lock()
+	defer cluster.binlogBackupLock.Unlock()
 	cluster.InBinlogBackup = value
 }
 
+// TryInBinlogBackupState takes the binary logs backup slot of the cluster,
+// false while a binary logs copy or purge holds it
+func (cluster *Cluster) TryInBinlogBackupState() bool {
+	cluster.binlogBackupLock.Lock()
+	defer cluster.binlogBackupLock.Unlock()
+	if cluster.InBinlogBackup {
+		return false
+	}
+	cluster.InBinlogBackup = true
+	return true
+}
+
 func (cluster *Cluster) SetInResticBackupState(value bool) {
 	cluster.InResticBackup = value
 }
@@ -1877,11 +2008,21 @@ func (cluster *Cluster) SetSSTBufferSize(value int) {
 }
 
 func (cluster *Cluster) SetAlertPushoverAppToken(value string) {
+	var new_secret config.Secret
+	new_secret.Value = value
+	new_secret.OldValue = cluster.Conf.GetDecryptedValue("alert-pushover-app-token")
+	cluster.Conf.Secrets["alert-pushover-app-token"] = new_secret
 	cluster.Conf.PushoverAppToken = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertPushoverUserToken(value string) {
+	var new_secret config.Secret
+	new_secret.Value = value
+	new_secret.OldValue = cluster.Conf.GetDecryptedValue("alert-pushover-user-token")
+	cluster.Conf.Secrets["alert-pushover-user-token"] = new_secret
 	cluster.Conf.PushoverUserToken = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertScript(value string) {
@@ -1890,26 +2031,85 @@ func (cluster *Cluster) SetAlertScript(value string) {
 
 func (cluster *Cluster) SetAlertSlackChannel(value string) {
 	cluster.Conf.SlackChannel = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertSlackUrl(value string) {
 	cluster.Conf.SlackURL = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertSlackUser(value string) {
 	cluster.Conf.SlackUser = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertTeamsProxyUrl(value string) {
 	cluster.Conf.TeamsProxyUrl = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertTeamsState(value string) {
 	cluster.Conf.TeamsAlertState = value
+	cluster.initNotifiers()
 }
 
 func (cluster *Cluster) SetAlertTeamsUrl(value string) {
 	cluster.Conf.TeamsUrl = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertWebhookUrl(value string) {
+	cluster.Conf.AlertWebhookURL = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertWebhookTemplate(value string) {
+	cluster.Conf.AlertWebhookTemplate = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertPagerDutyUrl(value string) {
+	cluster.Conf.AlertPagerDutyURL = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertPagerDutyRoutingKey(value string) {
+	var new_secret config.Secret
+	new_secret.Value = value
+	new_secret.OldValue = cluster.Conf.GetDecryptedValue("alert-pagerduty-routing-key")
+	cluster.Conf.Secrets["alert-pagerduty-routing-key"] = new_secret
+	cluster.Conf.AlertPagerDutyRoutingKey = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertOpsgenieUrl(value string) {
+	cluster.Conf.AlertOpsgenieURL = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertOpsgenieApiKey(value string) {
+	var new_secret config.Secret
+	new_secret.Value = value
+	new_secret.OldValue = cluster.Conf.GetDecryptedValue("alert-opsgenie-api-key")
+	cluster.Conf.Secrets["alert-opsgenie-api-key"] = new_secret
+	cluster.Conf.AlertOpsgenieApiKey = value
+	cluster.initNotifiers()
+}
+
+func (cluster *Cluster) SetAlertRoutes(value string) error {
+	routes, err := alert.ParseRoutes(value)
+	if err != nil {
+		return err
+	}
+	cluster.Conf.AlertRoutes = value
+	cluster.AlertRoutes = routes
+	return nil
+}
+
+func (cluster *Cluster) SetAlertGroupWindow(value int) {
+	cluster.Conf.AlertGroupWindow = value
+	cluster.AlertGrouper = alert.NewGrouper(time.Duration(value) * time.Second)
 }
 
 func (cluster *Cluster) SetMonitoringAlertTriggerl(value string) {
@@ -1921,6 +2121,10 @@ func (cluster *Cluster) SetMailSmtpAddr(value string) {
 }
 
 func (cluster *Cluster) SetMailSmtpPassword(value string) {
+	var new_secret config.Secret
+	new_secret.Value = value
+	new_secret.OldValue = cluster.Conf.GetDecryptedValue("mail-smtp-password")
+	cluster.Conf.Secrets["mail-smtp-password"] = new_secret
 	cluster.Conf.MailSMTPPassword = value
 }
 
@@ -0,0 +1,129 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/dbhelper"
+)
+
+// ElectionCheck is a pre-check of a leader change, a failed blocking check cancels it
+type ElectionCheck struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Blocking bool   `json:"blocking"`
+	Detail   string `json:"detail"`
+}
+
+// ElectionSimulation is the outcome of a failover or switchover election run
+// against the current topology without changing it. Candidates are ranked,
+// the elected one first and the rejected ones last.
+type ElectionSimulation struct {
+	Type       string              `json:"type"`
+	Time       time.Time           `json:"time"`
+	Master     string              `json:"master"`
+	Elected    string              `json:"elected"`
+	Blocked    bool                `json:"blocked"`
+	Checks     []ElectionCheck     `json:"checks"`
+	Candidates []ElectionCandidate `json:"candidates"`
+}
+
+// SimulateFailover runs the failover election and the CheckFailed constraints
+// without raising the states of the rejections
+func (cluster *Cluster) SimulateFailover() ElectionSimulation {
+	sim := ElectionSimulation{Type: "failover", Time: time.Now()}
+	if cluster.GetMaster() != nil {
+		sim.Master = cluster.GetMaster().URL
+	}
+	key, candidates := cluster.electFailoverCandidateList(cluster.slaves, false, true)
+	sim.setCandidates(cluster.slaves, key, candidates)
+
+	sim.addCheck("in-failover", !cluster.StateMachine.IsInFailover(), true, "No failover or switchover in progress")
+	sim.addCheck("candidate-master", key != -1 || cluster.GetTopology() == topoActivePassive, true, fmt.Sprintf("Elected candidate: %s", sim.Elected))
+	rem := (cluster.FailoverTs + cluster.Conf.FailTime) - time.Now().Unix()
+	sim.addCheck("failover-time-limit", cluster.Conf.FailTime == 0 || rem <= 0, true, fmt.Sprintf("failover-time-limit %ds, %ds remaining since last failover", cluster.Conf.FailTime, max(rem, 0)))
+	sim.addCheck("errant-transactions", cluster.isNotHavingMySQLErrantTransaction(true), true, "No errant transaction on replicas when replication-check-errant-trx is enabled")
+	sim.addCheck("wsrep-uuid", cluster.isSameWsrepUUID(true), true, "Same wsrep cluster state UUID on all nodes")
+	sim.addCheck("failover-limit", cluster.Conf.FailLimit == 0 || cluster.FailoverCtr != cluster.Conf.FailLimit, true, fmt.Sprintf("%d failovers done of failover-limit %d", cluster.FailoverCtr, cluster.Conf.FailLimit))
+	if cluster.Conf.Arbitration {
+		sim.addCheck("arbitration", !cluster.IsFailedArbitrator, true, fmt.Sprintf("Arbitrator %s is asked for the winner at failover time", cluster.Conf.ArbitrationSasHosts))
+	} else {
+		sim.addCheck("arbitration", true, true, "Arbitration disabled")
+	}
+	sim.addCheck("failover-mode", !cluster.Conf.Interactive, true, fmt.Sprintf("failover-mode is %s, a manual failover is still possible", cluster.Conf.FailMode))
+	sim.addCheck("first-slave", cluster.Conf.Interactive || cluster.Conf.FailRestartUnsafe || cluster.master != nil, true, "Master known since replication-manager start or failover-restart-unsafe enabled")
+	zones := cluster.getZoneCount()
+	sim.addCheck("failover-zone-min", cluster.Conf.FailZoneMin <= 0 || zones >= cluster.Conf.FailZoneMin, true, fmt.Sprintf("Servers up in %d zones, failover-zone-min %d", zones, cluster.Conf.FailZoneMin))
+	crossRegion := key != -1 && cluster.master != nil && cluster.slaves[key].GetZone().IsCrossRegion(cluster.master.GetZone())
+	sim.addCheck("failover-zone-cross-region", cluster.Conf.FailZoneCrossRegion || !crossRegion, true, "Elected candidate in the region of the master or failover-zone-cross-region enabled, a manual failover is still possible")
+
+	failCount := 0
+	masterFailed := false
+	if cluster.master != nil {
+		failCount = cluster.master.FailCount
+		masterFailed = cluster.master.State == stateFailed
+	}
+	sim.addCheck("master-failed", masterFailed, false, fmt.Sprintf("Master failure is confirmed after failcount %d checks, current count %d", cluster.Conf.MaxFail, failCount))
+	sim.addCheck("false-positive-heartbeat", true, false, fmt.Sprintf("check-false-positive-heartbeat %t, waits %ds for increasing replica heartbeats", cluster.Conf.CheckFalsePositiveHeartbeat, cluster.Conf.CheckFalsePositiveHeartbeatTimeout))
+	sim.addCheck("false-positive-external", true, false, fmt.Sprintf("check-false-positive-external %t on port %d", cluster.Conf.CheckFalsePositiveExternal, cluster.Conf.CheckFalsePositiveExternalPort))
+	sim.addCheck("false-positive-maxscale", true, false, fmt.Sprintf("check-false-positive-maxscale %t", cluster.Conf.CheckFalsePositiveMaxscale && cluster.Conf.MxsOn))
+	return sim
+}
+
+// SimulateSwitchover runs the switchover election and the checks done before demoting the master
+func (cluster *Cluster) SimulateSwitchover() ElectionSimulation {
+	sim := ElectionSimulation{Type: "switchover", Time: time.Now()}
+	sim.addCheck("in-failover", !cluster.StateMachine.IsInFailover(), true, "No failover or switchover in progress")
+	if cluster.master == nil || cluster.master.Conn == nil {
+		sim.addCheck("master", false, true, "Cannot switchover without a master connection")
+		return sim
+	}
+	sim.Master = cluster.master.URL
+	key, candidates := cluster.electSwitchoverCandidateList(cluster.slaves, false, true)
+	sim.setCandidates(cluster.slaves, key, candidates)
+	sim.addCheck("master", true, true, "Master connection available")
+	sim.addCheck("candidate-master", key != -1, true, fmt.Sprintf("Elected candidate: %s", sim.Elected))
+	qt, logs, err := dbhelper.CheckLongRunningWrites(cluster.master.Conn, cluster.Conf.SwitchWaitWrite)
+	cluster.LogSQL(logs, err, cluster.master.URL, "SimulateSwitchover", "DEBUG", "CheckLongRunningWrites")
+	sim.addCheck("long-running-writes", err == nil && qt == 0, true, fmt.Sprintf("%d writes running for more than switchover-wait-write-query %ds", qt, cluster.Conf.SwitchWaitWrite))
+	return sim
+}
+
+func (sim *ElectionSimulation) addCheck(name string, passed bool, blocking bool, detail string) {
+	sim.Checks = append(sim.Checks, ElectionCheck{Name: name, Passed: passed, Blocking: blocking, Detail: detail})
+	if blocking && !passed {
+		sim.Blocked = true
+	}
+}
+
+// setCandidates ranks the election matrice, candidates are indexed by slave position
+func (sim *ElectionSimulation) setCandidates(l []*ServerMonitor, key int, candidates []ElectionCandidate) {
+	if key >= 0 && key < len(l) {
+		sim.Elected = l[key].URL
+	}
+	ranked := make([]ElectionCandidate, len(candidates))
+	copy(ranked, candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if (ranked[i].Indice == key) != (ranked[j].Indice == key) {
+			return ranked[i].Indice == key
+		}
+		if (len(ranked[i].Rejections) == 0) != (len(ranked[j].Rejections) == 0) {
+			return len(ranked[i].Rejections) == 0
+		}
+		if ranked[i].Seq != ranked[j].Seq {
+			return ranked[i].Seq > ranked[j].Seq
+		}
+		return ranked[i].Pos > ranked[j].Pos
+	})
+	sim.Candidates = ranked
+}
@@ -27,6 +27,8 @@ import (
 
 // Heartbeat call from main cluster loop
 func (cluster *Cluster) Heartbeat(wg *sync.WaitGroup) {
+	_, span := cluster.StartSpan("Cluster.Heartbeat")
+	defer span.End()
 
 	defer wg.Done()
 	if cluster.Conf.Arbitration {
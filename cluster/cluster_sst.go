@@ -21,6 +21,7 @@ import (
 
 	gzip "github.com/klauspost/pgzip"
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/crypto"
 )
 
 type SST struct {
@@ -31,8 +32,11 @@ type SST struct {
 	outfilewriter     io.Writer
 	outresticreader   io.WriteCloser
 	outfilegzipwriter *gzip.Writer
+	outfileencwriter  io.WriteCloser
+	storageclose      func()
 	cluster           *Cluster
 	port              int
+	backup            bool
 }
 
 type ProtectedSSTconnections struct {
@@ -42,6 +46,15 @@ type ProtectedSSTconnections struct {
 
 var SSTs = ProtectedSSTconnections{SSTconnections: make(map[int]*SST)}
 
+// reader returns the stream of the connection, throttled for backups while log
+// copies appended to existing files are not
+func (sst *SST) reader() io.Reader {
+	if sst.backup {
+		return sst.cluster.NewBackupThrottleReader(sst.in)
+	}
+	return sst.in
+}
+
 func (cluster *Cluster) SSTCloseReceiver(destinationPort int) {
 	SSTs.SSTconnections[destinationPort].in.(net.Conn).Close()
 }
@@ -69,6 +82,7 @@ func (cluster *Cluster) SSTWatchRestic(r io.Reader) error {
 func (cluster *Cluster) SSTRunReceiverToRestic(filename string) (string, error) {
 	sst := new(SST)
 	sst.cluster = cluster
+	sst.backup = true
 
 	var err error
 
@@ -115,9 +129,12 @@ func (cluster *Cluster) SSTRunReceiverToRestic(filename string) (string, error)
 	return strconv.Itoa(destinationPort), nil
 }
 
-func (cluster *Cluster) SSTRunReceiverToFile(server *ServerMonitor, filename string, openfile string, task string) (string, error) {
+// SSTRunReceiverToFile writes the received stream to filename, encrypted when
+// a data key is given
+func (cluster *Cluster) SSTRunReceiverToFile(server *ServerMonitor, filename string, openfile string, task string, key *crypto.DataKey) (string, error) {
 	sst := new(SST)
 	sst.cluster = cluster
+	sst.backup = openfile == ConstJobCreateFile
 	var writers []io.Writer
 
 	var err error
@@ -131,7 +148,18 @@ func (cluster *Cluster) SSTRunReceiverToFile(server *ServerMonitor, filename str
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "Open file failed for job %s %s", filename, err)
 		return "", err
 	}
-	writers = append(writers, sst.file)
+	fw := sst.openBackupStorage(filename, openfile)
+	if key != nil {
+		sst.outfileencwriter, err = crypto.NewEncryptWriter(fw, key)
+		if err != nil {
+			sst.file.Close()
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "Encryption failed for job %s %s", filename, err)
+			return "", err
+		}
+		writers = append(writers, sst.outfileencwriter)
+	} else {
+		writers = append(writers, fw)
+	}
 
 	sst.outfilewriter = io.MultiWriter(writers...)
 
@@ -154,9 +182,25 @@ func (cluster *Cluster) SSTRunReceiverToFile(server *ServerMonitor, filename str
 	return strconv.Itoa(destinationPort), nil
 }
 
-func (cluster *Cluster) SSTRunReceiverToGZip(server *ServerMonitor, filename string, openfile string, task string) (string, error) {
+// openBackupStorage returns the writer of the received file, a new file is
+// streamed to the backup storage while it is written and a file opened for
+// append is uploaded when the transfer ends
+func (sst *SST) openBackupStorage(filename string, openfile string) io.Writer {
+	if openfile == ConstJobCreateFile {
+		fw, storageclose := sst.cluster.StreamBackupStorage(filename, sst.file)
+		sst.storageclose = storageclose
+		return fw
+	}
+	sst.storageclose = func() { sst.cluster.UploadBackupFile(filename) }
+	return sst.file
+}
+
+// SSTRunReceiverToGZip compresses the received stream to filename, encrypted
+// after compression when a data key is given
+func (cluster *Cluster) SSTRunReceiverToGZip(server *ServerMonitor, filename string, openfile string, task string, key *crypto.DataKey) (string, error) {
 	sst := new(SST)
 	sst.cluster = cluster
+	sst.backup = openfile == ConstJobCreateFile
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlInfo, "Compressing mariadb backup")
 
@@ -167,14 +211,26 @@ func (cluster *Cluster) SSTRunReceiverToGZip(server *ServerMonitor, filename str
 		sst.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 	}
 
-	gw := gzip.NewWriter(sst.file)
-
-	sst.outfilegzipwriter = gw
-
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "Open file failed for job %s %s", filename, err)
 		return "", err
 	}
+	fw := sst.openBackupStorage(filename, openfile)
+
+	var out io.Writer = fw
+	if key != nil {
+		sst.outfileencwriter, err = crypto.NewEncryptWriter(fw, key)
+		if err != nil {
+			sst.file.Close()
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "Encryption failed for job %s %s", filename, err)
+			return "", err
+		}
+		out = sst.outfileencwriter
+	}
+
+	gw := gzip.NewWriter(out)
+
+	sst.outfilegzipwriter = gw
 
 	sst.listener, err = net.Listen("tcp", cluster.Conf.BindAddr+":"+cluster.SSTGetSenderPort())
 	if err != nil {
@@ -206,7 +262,11 @@ func (sst *SST) tcp_con_handle_to_gzip(server *ServerMonitor, task string) {
 		port := sst.listener.Addr().(*net.TCPAddr).Port
 		sst.tcplistener.Close()
 		sst.outfilegzipwriter.Close()
+		if sst.outfileencwriter != nil {
+			sst.outfileencwriter.Close()
+		}
 		sst.file.Close()
+		sst.storageclose()
 		sst.listener.Close()
 		SSTs.Lock()
 		delete(SSTs.SSTconnections, port)
@@ -244,7 +304,11 @@ func (sst *SST) tcp_con_handle_to_file(server *ServerMonitor, task string) {
 		}
 		port := sst.listener.Addr().(*net.TCPAddr).Port
 		sst.tcplistener.Close()
+		if sst.outfileencwriter != nil {
+			sst.outfileencwriter.Close()
+		}
 		sst.file.Close()
+		sst.storageclose()
 		sst.listener.Close()
 		SSTs.Lock()
 		delete(SSTs.SSTconnections, port)
@@ -327,11 +391,12 @@ func (sst *SST) stream_copy_to_file() <-chan int {
 			}
 			sync_channel <- 0 // Notify that processing is finished
 		}()
+		in := sst.reader()
 		for {
 			var nBytes int
 			var err error
 
-			nBytes, err = sst.in.Read(buf)
+			nBytes, err = in.Read(buf)
 
 			if err != nil {
 				if err != io.EOF {
@@ -366,11 +431,12 @@ func (sst *SST) stream_copy_to_gzip() <-chan int {
 			sync_channel <- 0 // Notify that processing is finished
 
 		}()
+		in := sst.reader()
 		for {
 			var nBytes int
 			var err error
 
-			nBytes, err = sst.in.Read(buf)
+			nBytes, err = in.Read(buf)
 
 			if err != nil {
 				if err != io.EOF {
@@ -404,11 +470,12 @@ func (sst *SST) stream_copy_to_restic() <-chan int {
 			}
 			sync_channel <- 0 // Notify that processing is finished
 		}()
+		in := sst.reader()
 		for {
 			var nBytes int
 			var err error
 
-			nBytes, err = sst.in.Read(buf)
+			nBytes, err = in.Read(buf)
 
 			if err != nil {
 				if err != io.EOF {
@@ -458,7 +525,7 @@ func (cluster *Cluster) SSTRunSender(backupfile string, sv *ServerMonitor) error
 
 func (cluster *Cluster) SSTRunSendGzip(client net.Conn, backupfile string, sv *ServerMonitor) error {
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlInfo, "SST sending file: %s to node: %s port: %s", backupfile, sv.Host, sv.SSTPort)
-	file, err := os.Open(backupfile)
+	file, err := cluster.OpenBackupFile(backupfile)
 	if err != nil {
 		return errors.New(fmt.Sprintf("SST to server %s failed to open backup file, err: %s ", sv.URL, err))
 	}
@@ -499,7 +566,7 @@ func (cluster *Cluster) SSTRunSendGzip(client net.Conn, backupfile string, sv *S
 }
 
 func (cluster *Cluster) SSTRunSendFile(client net.Conn, backupfile string, sv *ServerMonitor) error {
-	file, err := os.Open(backupfile)
+	file, err := cluster.OpenBackupFile(backupfile)
 	if os.IsNotExist(err) && cluster.Conf.CompressBackups {
 		backupfile = strings.Replace(backupfile, "xbtream", "gz", 1)
 		return cluster.SSTRunSendGzip(client, backupfile, sv)
@@ -515,16 +582,20 @@ func (cluster *Cluster) SSTRunSendFile(client net.Conn, backupfile string, sv *S
 	defer file.Close()
 
 	for {
-		_, err = file.Read(sendBuffer)
+		n, err := file.Read(sendBuffer)
+		if n > 0 {
+			bts, err := client.Write(sendBuffer[:n])
+			if err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "SST failed to write chunk %s at position %d", err, total)
+			}
+			total = total + uint64(bts)
+		}
 		if err == io.EOF {
 			break
 		}
-
-		bts, err := client.Write(sendBuffer)
 		if err != nil {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, "SST failed to write chunk %s at position %d", err, total)
+			return fmt.Errorf("SST to server %s failed to read backup file, err: %s ", sv.URL, err)
 		}
-		total = total + uint64(bts)
 	}
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlInfo, "Backup has been sent, closing connection!")
 
@@ -558,6 +629,49 @@ func (cluster *Cluster) SSTRunSenderSSL(backupfile string, sv *ServerMonitor) er
 	return nil
 }
 
+// SSTRunSenderChain sends a backup chain to a reseeding node, one connection
+// per file. The node opens its receiver again after extracting each stream, so
+// the connection of the next file is retried until the receiver is listening.
+func (cluster *Cluster) SSTRunSenderChain(backupfiles []string, sv *ServerMonitor) error {
+	for i, backupfile := range backupfiles {
+		if i == 0 {
+			if err := cluster.SSTRunSender(backupfile, sv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var client net.Conn
+		var err error
+		for retry := 0; retry < 60; retry++ {
+			if cluster.Conf.SchedulerReceiverUseSSL {
+				client, err = tls.Dial("tcp", net.JoinHostPort(sv.Host, sv.SSTPort), &tls.Config{InsecureSkipVerify: true})
+			} else {
+				client, err = net.Dial("tcp", net.JoinHostPort(sv.Host, sv.SSTPort))
+			}
+			if err == nil {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+		if err != nil {
+			return fmt.Errorf("SST Reseed failed connection to port %s server %s for backup %d of the chain: %s", sv.SSTPort, sv.Host, i, err)
+		}
+
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlInfo, "SST sending backup %d/%d of the chain to node: %s", i, len(backupfiles)-1, sv.Host)
+		if strings.HasSuffix(backupfile, "gz") {
+			err = cluster.SSTRunSendGzip(client, backupfile, sv)
+		} else {
+			err = cluster.SSTRunSendFile(client, backupfile, sv)
+		}
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("Error sending SST chain to server %s: %s ", sv.Host, err)
+		}
+	}
+	return nil
+}
+
 func (cluster *Cluster) SSTGetSenderPort() string {
 	port := "0"
 	if cluster.Conf.SchedulerSenderPorts != "" {
@@ -166,6 +166,10 @@ func (cluster *Cluster) SwitchCompressBackups() {
 	cluster.Conf.CompressBackups = !cluster.Conf.CompressBackups
 }
 
+func (cluster *Cluster) SwitchBackupEncrypt() {
+	cluster.Conf.BackupEncrypt = !cluster.Conf.BackupEncrypt
+}
+
 func (cluster *Cluster) SwitchInteractive() {
 	if cluster.Conf.Interactive == true {
 		cluster.Conf.Interactive = false
@@ -278,6 +282,21 @@ func (cluster *Cluster) SwitchSchedulerBackupPhysical() {
 	cluster.SetSchedulerBackupPhysical()
 }
 
+func (cluster *Cluster) SwitchSchedulerBackupPhysicalIncremental() {
+	cluster.Conf.SchedulerBackupPhysicalIncremental = !cluster.Conf.SchedulerBackupPhysicalIncremental
+	cluster.SetSchedulerBackupPhysicalIncremental()
+}
+
+func (cluster *Cluster) SwitchSchedulerBackupPhysicalDifferential() {
+	cluster.Conf.SchedulerBackupPhysicalDifferential = !cluster.Conf.SchedulerBackupPhysicalDifferential
+	cluster.SetSchedulerBackupPhysicalDifferential()
+}
+
+func (cluster *Cluster) SwitchSchedulerBackupVerify() {
+	cluster.Conf.SchedulerBackupVerify = !cluster.Conf.SchedulerBackupVerify
+	cluster.SetSchedulerBackupVerify()
+}
+
 func (cluster *Cluster) SwitchSchedulerDbJobsSsh() {
 	cluster.Conf.SchedulerJobsSSH = !cluster.Conf.SchedulerJobsSSH
 	cluster.SetSchedulerDbJobsSsh()
@@ -415,6 +434,10 @@ func (cluster *Cluster) SwitchMonitoringCapture() {
 	// delete cluster config
 }
 
+func (cluster *Cluster) SwitchMonitoringStateJournal() {
+	cluster.Conf.MonitoringStateJournal = !cluster.Conf.MonitoringStateJournal
+}
+
 func (cluster *Cluster) SwitchMonitoringInnoDBStatus() {
 	cluster.Conf.MonitorInnoDBStatus = !cluster.Conf.MonitorInnoDBStatus
 }
@@ -648,3 +671,7 @@ func (cluster *Cluster) SwitchForceWriteConfig() {
 func (cluster *Cluster) SwitchBackupKeepUntilValid() {
 	cluster.Conf.BackupKeepUntilValid = !cluster.Conf.BackupKeepUntilValid
 }
+
+func (cluster *Cluster) SwitchBackupRetention() {
+	cluster.Conf.BackupRetention = !cluster.Conf.BackupRetention
+}
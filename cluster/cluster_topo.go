@@ -75,6 +75,8 @@ func (cluster *Cluster) newServerList() error {
 
 // AddChildServers Add child clusters nodes  if they get same  source name
 func (cluster *Cluster) AddChildServers() error {
+	_, span := cluster.StartSpan("Cluster.AddChildServers")
+	defer span.End()
 
 	mychilds := cluster.GetChildClusters()
 	for _, c := range mychilds {
@@ -129,6 +131,8 @@ func (cluster *Cluster) AddChildServers() error {
 // Start of topology detection
 // Create a connection to each host and build list of slaves.
 func (cluster *Cluster) TopologyDiscover(wcg *sync.WaitGroup) error {
+	_, span := cluster.StartSpan("Cluster.TopologyDiscover")
+	defer span.End()
 	cluster.Lock()
 	defer cluster.Unlock()
 
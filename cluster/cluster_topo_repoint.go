@@ -0,0 +1,234 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/gtid"
+)
+
+// MoveReplicaUp repoints a replica of a relay under the master
+func (cluster *Cluster) MoveReplicaUp(server *ServerMonitor) error {
+	master := cluster.GetMaster()
+	if master == nil {
+		return errors.New("No master")
+	}
+	return cluster.RepointReplica(server, master)
+}
+
+// SwapRelay makes a replica of a relay the source of the relay, the replica
+// is first repointed under the source of the relay. The other replicas of the
+// relay stay under it.
+func (cluster *Cluster) SwapRelay(relay *ServerMonitor, child *ServerMonitor) error {
+	parent := cluster.getReplicationSource(relay)
+	if parent == nil {
+		return fmt.Errorf("No source found for relay %s", relay.URL)
+	}
+	if cluster.getReplicationSource(child) != relay {
+		return fmt.Errorf("%s is not a replica of %s", child.URL, relay.URL)
+	}
+	if !child.HasBinlog() || !child.HasBinlogSlaveUpdates() {
+		return fmt.Errorf("%s needs binary logs and log_slave_updates to become a relay", child.URL)
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTopology, config.LvlInfo, "Swapping relay %s with its replica %s", relay.URL, child.URL)
+	if err := cluster.RepointReplica(child, parent); err != nil {
+		return err
+	}
+	return cluster.RepointReplica(relay, child)
+}
+
+// RepointReplica changes the source of a replica without rebuilding it. The
+// replication is stopped, the new source must reach the position of the
+// replica within replication-repoint-wait-timeout using GTID, or pseudo GTID
+// for positional replication, before the replica is pointed to it.
+func (cluster *Cluster) RepointReplica(server *ServerMonitor, source *ServerMonitor) error {
+	if err := cluster.checkRepointReplica(server, source); err != nil {
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTopology, config.LvlInfo, "Repointing replica %s to %s", server.URL, source.URL)
+
+	logs, err := server.StopSlave()
+	cluster.LogSQL(logs, err, server.URL, "Repoint", config.LvlErr, "Could not stop slave on server %s, %s", server.URL, err)
+	if err != nil {
+		return err
+	}
+	changemasteropt, err := cluster.waitRepointSource(server, source)
+	if err != nil {
+		// Resume replication from the current source
+		logs, serr := server.StartSlave()
+		cluster.LogSQL(logs, serr, server.URL, "Repoint", config.LvlErr, "Could not start slave on server %s, %s", server.URL, serr)
+		return err
+	}
+	changemasteropt.Host = source.Host
+	changemasteropt.Port = source.Port
+	changemasteropt.User = cluster.GetRplUser()
+	changemasteropt.Password = cluster.GetRplPass()
+	changemasteropt.Retry = strconv.Itoa(cluster.Conf.ForceSlaveHeartbeatRetry)
+	changemasteropt.Heartbeat = strconv.Itoa(cluster.Conf.ForceSlaveHeartbeatTime)
+	changemasteropt.SSL = cluster.Conf.ReplicationSSL
+	changemasteropt.Channel = cluster.Conf.MasterConn
+	changemasteropt.IsDelayed = server.IsDelayed
+	changemasteropt.Delay = strconv.Itoa(cluster.Conf.HostsDelayedTime)
+	changemasteropt.PostgressDB = source.PostgressDB
+
+	logs, err = dbhelper.ChangeMaster(server.Conn, changemasteropt, server.DBVersion)
+	cluster.LogSQL(logs, err, server.URL, "Repoint", config.LvlErr, "Change master failed on replica %s, %s", server.URL, err)
+	logs, serr := server.StartSlave()
+	cluster.LogSQL(logs, serr, server.URL, "Repoint", config.LvlErr, "Could not start slave on server %s, %s", server.URL, serr)
+	if err != nil {
+		return err
+	}
+	if serr != nil {
+		return serr
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTopology, config.LvlInfo, "Replica %s repointed to %s with %s replication", server.URL, source.URL, changemasteropt.Mode)
+	return nil
+}
+
+func (cluster *Cluster) checkRepointReplica(server *ServerMonitor, source *ServerMonitor) error {
+	master := cluster.GetMaster()
+	if master == nil {
+		return errors.New("No master")
+	}
+	if cluster.IsInFailover() {
+		return errors.New("Failover in progress")
+	}
+	if server == master {
+		return errors.New("Can't repoint the master")
+	}
+	if server == source {
+		return errors.New("Can't repoint a replica to itself")
+	}
+	if server.IsFailed() {
+		return fmt.Errorf("Replica %s is failed", server.URL)
+	}
+	if source.IsFailed() {
+		return fmt.Errorf("Source %s is failed", source.URL)
+	}
+	if source != master {
+		if cluster.Conf.ReplicationNoRelay && !cluster.Conf.MultiTierSlave {
+			return errors.New("Relays are not allowed with replication-master-slave-never-relay")
+		}
+		if !source.HasBinlog() || !source.HasBinlogSlaveUpdates() {
+			return fmt.Errorf("Source %s needs binary logs and log_slave_updates", source.URL)
+		}
+		// The new source must not replicate from the replica
+		s := source
+		for i := 0; s != nil && i < len(cluster.Servers); i++ {
+			if s == server {
+				return fmt.Errorf("Source %s replicates from %s", source.URL, server.URL)
+			}
+			s = cluster.getReplicationSource(s)
+		}
+	}
+	if !server.HasMariaDBGTID() && !(server.HasMySQLGTID() && source.HasMySQLGTID()) && !cluster.Conf.AutorejoinSlavePositionalHeartbeat {
+		return errors.New("Positional replication needs pseudo GTID with autorejoin-slave-positional-heartbeat")
+	}
+	return nil
+}
+
+// getReplicationSource returns the server a replica is replicating from,
+// reading the replication status instead of the last monitored one
+func (cluster *Cluster) getReplicationSource(server *ServerMonitor) *ServerMonitor {
+	if server.Conn == nil {
+		return nil
+	}
+	ss, _, err := dbhelper.GetSlaveStatus(server.Conn, cluster.Conf.MasterConn, server.DBVersion)
+	if err != nil || ss.MasterHost.String == "" {
+		return nil
+	}
+	for _, s := range cluster.Servers {
+		if s != server && s.Host == ss.MasterHost.String && s.Port == ss.MasterPort.String {
+			return s
+		}
+	}
+	return nil
+}
+
+// waitRepointSource waits for the new source to be ahead of the stopped
+// replica and returns the replication mode and coordinates to use
+func (cluster *Cluster) waitRepointSource(server *ServerMonitor, source *ServerMonitor) (dbhelper.ChangeMasterOpt, error) {
+	timeout := time.Duration(cluster.Conf.ReplicationRepointWaitTimeout) * time.Second
+	start := time.Now()
+	for {
+		changemasteropt, ahead, err := cluster.isRepointSourceAhead(server, source)
+		if err != nil || ahead {
+			return changemasteropt, err
+		}
+		if time.Since(start) > timeout {
+			return changemasteropt, fmt.Errorf("Source %s is not ahead of replica %s after %s", source.URL, server.URL, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (cluster *Cluster) isRepointSourceAhead(server *ServerMonitor, source *ServerMonitor) (dbhelper.ChangeMasterOpt, bool, error) {
+	var changemasteropt dbhelper.ChangeMasterOpt
+	if server.HasMySQLGTID() && source.HasMySQLGTID() {
+		changemasteropt.Mode = "MASTER_AUTO_POSITION"
+		var executed string
+		if err := server.Conn.QueryRowx("SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+			return changemasteropt, false, err
+		}
+		var ahead bool
+		if err := source.Conn.QueryRowx("SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)", executed).Scan(&ahead); err != nil {
+			return changemasteropt, false, err
+		}
+		return changemasteropt, ahead, nil
+	}
+	if server.HasMariaDBGTID() {
+		changemasteropt.Mode = "SLAVE_POS"
+		var slavePos, binlogPos string
+		if err := server.Conn.QueryRowx("SELECT @@GLOBAL.gtid_slave_pos").Scan(&slavePos); err != nil {
+			return changemasteropt, false, err
+		}
+		if err := source.Conn.QueryRowx("SELECT @@GLOBAL.gtid_binlog_pos").Scan(&binlogPos); err != nil {
+			return changemasteropt, false, err
+		}
+		return changemasteropt, gtid.NewList(binlogPos).Contains(gtid.NewList(slavePos)), nil
+	}
+
+	// Positional, the last pseudo GTID of the replica and the events after it
+	// must be found in the binary logs of the source
+	changemasteropt.Mode = "POSITIONAL"
+	pseudoGTID, logs, err := server.GetLastPseudoGTID()
+	cluster.LogSQL(logs, err, server.URL, "Repoint", config.LvlErr, "Could not get pseudoGTID on slave %s, %s", server.URL, err)
+	if err != nil {
+		return changemasteropt, false, err
+	}
+	slFile, slPos, logs, err := server.GetBinlogPosFromPseudoGTID(pseudoGTID)
+	cluster.LogSQL(logs, err, server.URL, "Repoint", config.LvlErr, "Could not find pseudoGTID in slave %s, %s", server.URL, err)
+	if err != nil {
+		return changemasteropt, false, err
+	}
+	slSkip, logs, err := server.GetNumberOfEventsAfterPos(slFile, slPos)
+	cluster.LogSQL(logs, err, server.URL, "Repoint", config.LvlErr, "Could not find number of events after pseudoGTID in slave %s, %s", server.URL, err)
+	if err != nil {
+		return changemasteropt, false, err
+	}
+	mFile, mPos, _, err := source.GetBinlogPosFromPseudoGTID(pseudoGTID)
+	if err != nil || mFile == "" {
+		return changemasteropt, false, nil
+	}
+	mFile, mPos, _, err = source.GetBinlogPosAfterSkipNumberOfEvents(mFile, mPos, slSkip)
+	if err != nil || mFile == "" {
+		return changemasteropt, false, nil
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTopology, config.LvlInfo, "Found coordinates on source %s after pseudoGTID %s, %s:%s", source.URL, pseudoGTID, mFile, mPos)
+	changemasteropt.Logfile = mFile
+	changemasteropt.Logpos = mPos
+	return changemasteropt, true, nil
+}
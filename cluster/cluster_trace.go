@@ -0,0 +1,82 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/signal18/replication-manager/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSpan opens a span child of the running monitoring loop or failover
+func (cluster *Cluster) StartSpan(name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(cluster.traceCtx.Get(), name, append(attrs, attribute.String("cluster", cluster.Name))...)
+}
+
+// startMonitorSpan opens the root span of a monitoring loop, the phases and
+// the server refreshes run during the loop are its children
+func (cluster *Cluster) startMonitorSpan() trace.Span {
+	ctx, span := tracing.Start(context.Background(), "Cluster.Monitor",
+		attribute.String("cluster", cluster.Name),
+		attribute.String("topology", cluster.Topology),
+		attribute.Int("servers", len(cluster.Servers)),
+		attribute.Int("proxies", len(cluster.Proxies)),
+	)
+	cluster.traceCtx.Set(ctx)
+	return span
+}
+
+// endMonitorSpan closes the loop span, a switchover requested between two
+// loops starts its own trace
+func (cluster *Cluster) endMonitorSpan(span trace.Span) {
+	cluster.traceCtx.Set(context.Background())
+	span.End()
+}
+
+// startFailoverSpan opens the span of a MasterFailover, the steps of the
+// timeline are its children. The returned function restores the parent.
+func (cluster *Cluster) startFailoverSpan(tl *FailoverTimeline) func() {
+	ctx, span := cluster.StartSpan("Cluster.MasterFailover", attribute.Bool("switchover", tl.Switchover))
+	tl.Lock()
+	tl.traceCtx = ctx
+	tl.Unlock()
+	prev := cluster.traceCtx.Set(ctx)
+	return func() {
+		cluster.traceCtx.Set(prev)
+		tl.Lock()
+		failed := tl.Failed
+		tl.Unlock()
+		if failed {
+			tracing.End(span, errors.New("failover step failed"))
+			return
+		}
+		span.End()
+	}
+}
+
+// StartSpan opens a span of the cluster with the server attributes
+func (server *ServerMonitor) StartSpan(name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return server.ClusterGroup.StartSpan(name, append(attrs,
+		attribute.String("server", server.URL),
+		attribute.String("server.id", server.Id),
+		attribute.String("server.state", server.State),
+	)...)
+}
+
+func (cluster *Cluster) startProxySpan(pr DatabaseProxy, name string) (context.Context, trace.Span) {
+	return cluster.StartSpan(name,
+		attribute.String("proxy", pr.GetURL()),
+		attribute.String("proxy.id", pr.GetId()),
+		attribute.String("proxy.type", pr.GetType()),
+	)
+}
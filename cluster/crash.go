@@ -32,6 +32,8 @@ type Crash struct {
 	ElectedMasterURL            string
 	UnixTimestamp               int64
 	Switchover                  bool
+	Id                          string
+	Timeline                    *FailoverTimeline
 }
 
 // Collection of Crash reports
@@ -86,6 +88,24 @@ func (cluster *Cluster) getCrashFromMaster(URL string) *Crash {
 	return nil
 }
 
+// GetCrashById return a crash of the current crashes or of the failover history
+func (cluster *Cluster) GetCrashById(id string) *Crash {
+	if id == "" {
+		return nil
+	}
+	for _, cr := range cluster.Crashes {
+		if cr.Id == id {
+			return cr
+		}
+	}
+	for _, cr := range cluster.FailoverHistory {
+		if cr.Id == id {
+			return cr
+		}
+	}
+	return nil
+}
+
 // GetCrashes return crashes
 func (cluster *Cluster) GetCrashes() crashList {
 	return cluster.Crashes
@@ -0,0 +1,133 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//
+//	Stephane Varoqui  <svaroqui@gmail.com>
+//
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	StepOutcomeRunning = "running"
+	StepOutcomeOk      = "ok"
+	StepOutcomeError   = "error"
+)
+
+// FailoverStep is a step of a failover or switchover, Operation is the SQL
+// sent or the proxy or script action
+type FailoverStep struct {
+	Name      string    `json:"name"`
+	Server    string    `json:"server"`
+	Operation string    `json:"operation"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Duration  int64     `json:"durationMs"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error"`
+	timeline  *FailoverTimeline
+	span      trace.Span
+}
+
+// FailoverTimeline is the structured report of a MasterFailover run stored
+// with its crash
+type FailoverTimeline struct {
+	Switchover bool            `json:"switchover"`
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Failed     bool            `json:"failed"`
+	Reason     string          `json:"reason"`
+	Steps      []*FailoverStep `json:"steps"`
+	traceCtx   context.Context
+	sync.Mutex
+}
+
+func NewFailoverTimeline(switchover bool) *FailoverTimeline {
+	return &FailoverTimeline{Switchover: switchover, Start: time.Now(), Steps: make([]*FailoverStep, 0)}
+}
+
+// Step starts a step, a nil timeline records nothing outside of a failover
+func (tl *FailoverTimeline) Step(name string, server string) *FailoverStep {
+	if tl == nil {
+		return nil
+	}
+	s := &FailoverStep{Name: name, Server: server, Start: time.Now(), Outcome: StepOutcomeRunning, timeline: tl}
+	tl.Lock()
+	tl.Steps = append(tl.Steps, s)
+	ctx := tl.traceCtx
+	tl.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, s.span = tracing.Start(ctx, "FailoverStep "+name, attribute.String("step", name), attribute.String("server", server))
+	return s
+}
+
+// Done ends the step with the operation done and its error if any
+func (s *FailoverStep) Done(operation string, err error) {
+	if s == nil {
+		return
+	}
+	s.timeline.Lock()
+	defer s.timeline.Unlock()
+	s.End = time.Now()
+	s.Duration = s.End.Sub(s.Start).Milliseconds()
+	s.Operation = strings.TrimSpace(operation)
+	s.Outcome = StepOutcomeOk
+	if err != nil {
+		s.Outcome = StepOutcomeError
+		s.Error = err.Error()
+	}
+	s.span.SetAttributes(attribute.String("operation", s.Operation))
+	tracing.End(s.span, err)
+}
+
+// Finish closes the timeline once, Failed tells that at least one step failed
+// or that the run stopped for the reason given
+func (tl *FailoverTimeline) Finish(reason string) {
+	if tl == nil {
+		return
+	}
+	tl.Lock()
+	defer tl.Unlock()
+	if !tl.End.IsZero() {
+		return
+	}
+	tl.End = time.Now()
+	if reason != "" {
+		tl.Failed = true
+		tl.Reason = reason
+	}
+	for _, s := range tl.Steps {
+		if s.Outcome == StepOutcomeError {
+			tl.Failed = true
+		}
+	}
+}
+
+func (tl *FailoverTimeline) MarshalJSON() ([]byte, error) {
+	tl.Lock()
+	defer tl.Unlock()
+	return json.Marshal(&struct {
+		Switchover bool            `json:"switchover"`
+		Start      time.Time       `json:"start"`
+		End        time.Time       `json:"end"`
+		Failed     bool            `json:"failed"`
+		Reason     string          `json:"reason"`
+		Steps      []*FailoverStep `json:"steps"`
+	}{tl.Switchover, tl.Start, tl.End, tl.Failed, tl.Reason, tl.Steps})
+}
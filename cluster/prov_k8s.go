@@ -53,6 +53,8 @@ func (cluster *Cluster) K8SGetNodes() ([]Agent, error) {
 		agent.MemFreeBytes = n.Status.Allocatable.Memory().Value()
 
 		agent.HostName = n.Name
+		agent.Region = n.Labels["topology.kubernetes.io/region"]
+		agent.Zone = n.Labels["topology.kubernetes.io/zone"]
 		agents = append(agents, agent)
 	}
 	return agents, err
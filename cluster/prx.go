@@ -26,6 +26,7 @@ import (
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/state"
+	"github.com/signal18/replication-manager/utils/tracing"
 	"github.com/spf13/pflag"
 )
 
@@ -101,8 +102,11 @@ type DatabaseProxy interface {
 	GetPass() string
 	GetServiceName() string
 	GetOrchestrator() string
+	GetZone() config.Zone
 
 	GetPrevState() string
+	GetBackendsWrite() []Backend
+	GetBackendsRead() []Backend
 
 	SetPrevState(state string)
 	GetCluster() *Cluster
@@ -335,6 +339,8 @@ func (cluster *Cluster) backendStateChangeProxies() {
 
 // Used to monitor proxies call by main monitor loop
 func (cluster *Cluster) refreshProxies(wcg *sync.WaitGroup) {
+	_, span := cluster.StartSpan("Cluster.RefreshProxies")
+	defer span.End()
 	defer wcg.Done()
 	// if cluster.Conf.LogLevel > 2 {
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModProxy, config.LvlDbg, "Refresh proxy start")
@@ -344,7 +350,12 @@ func (cluster *Cluster) refreshProxies(wcg *sync.WaitGroup) {
 			var err error
 			//	pr.SetLock()
 
+			// A proxy in another region than the master is still monitored, only its routing to the master is left unchanged
+			cluster.isProxyRoutable(pr)
+
+			_, prspan := cluster.startProxySpan(pr, "Proxy.Refresh")
 			err = pr.Refresh()
+			tracing.End(prspan, err)
 			if err == nil {
 				pr.SetFailCount(0)
 				pr.SetState(stateProxyRunning)
@@ -386,8 +397,14 @@ func (cluster *Cluster) refreshProxies(wcg *sync.WaitGroup) {
 
 func (cluster *Cluster) failoverProxies() {
 	for _, pr := range cluster.Proxies {
+		if !cluster.isProxyRoutable(pr) {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModProxy, config.LvlInfo, "Skip failover of proxy %s in another region than the master", pr.GetURL())
+			continue
+		}
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModProxy, config.LvlInfo, "Failover Proxy Type: %s Host: %s Port: %s", pr.GetType(), pr.GetHost(), pr.GetPort())
+		step := cluster.failoverTimeline.Step("proxy-failover", pr.GetHost()+":"+pr.GetPort())
 		pr.Failover()
+		step.Done(pr.GetType()+" failover", nil)
 	}
 
 }
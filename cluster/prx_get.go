@@ -162,6 +162,14 @@ func (proxy *Proxy) GetDatadir() string {
 	return proxy.Datadir
 }
 
+func (proxy *Proxy) GetBackendsWrite() []Backend {
+	return proxy.BackendsWrite
+}
+
+func (proxy *Proxy) GetBackendsRead() []Backend {
+	return proxy.BackendsRead
+}
+
 func (proxy *Proxy) GetName() string {
 	return proxy.Name
 }
@@ -327,7 +327,7 @@ func (proxy *HaproxyProxy) Refresh() error {
 					PrxByteOut:     line[9],
 					PrxLatency:     line[61], //ttime: average session time in ms over the 1024 last requests
 				})
-				if !srv.IsMaster() {
+				if !srv.IsMaster() && !proxy.IsIgnored() {
 					master := cluster.GetMaster()
 					if master != nil {
 						cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModHAProxy, config.LvlInfo, "Detecting wrong master server in haproxy %s fixing it to master %s %s", proxy.Host+":"+proxy.Port, master.Host, master.Port)
@@ -415,7 +415,7 @@ func (proxy *HaproxyProxy) Refresh() error {
 			}
 		}
 	}
-	if !foundMasterInStat {
+	if !foundMasterInStat && !proxy.IsIgnored() {
 		master := cluster.GetMaster()
 		if master != nil && master.IsLeader() {
 			res, err := haRuntime.SetMaster(master.Host, master.Port)
@@ -56,9 +56,18 @@ func (proxy *Proxy) IsRunning() bool {
 	return !proxy.IsDown()
 }
 
+// IsIgnored tells if the proxy is not routed to a master in another region
+// while failover-zone-proxy-cross-region is disabled
 func (proxy *Proxy) IsIgnored() bool {
-	// implement logic for zone and disable route janitor to proxy
-	return false
+	cluster := proxy.ClusterGroup
+	if cluster.Conf.FailZoneProxyCrossRegion {
+		return false
+	}
+	master := cluster.GetMaster()
+	if master == nil {
+		return false
+	}
+	return proxy.GetZone().IsCrossRegion(master.GetZone())
 }
 
 func (proxy *Proxy) IsDown() bool {
@@ -273,6 +273,7 @@ func (proxy *ProxySQLProxy) Refresh() error {
 	var updated bool
 	bkWriters := make([]Backend, 0)
 	bkReaders := make([]Backend, 0)
+	ignored := proxy.IsIgnored()
 
 	for _, s := range cluster.Servers {
 		isBackendWriter := true
@@ -364,6 +365,9 @@ func (proxy *ProxySQLProxy) Refresh() error {
 				}
 				updated = true
 
+			} else if s.IsLeader() && ignored {
+				// a proxy in another region than the master does not route to it
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModProxySQL, config.LvlDbg, "Monitor ProxySQL skip routing to leader %s in another region than %s", s.URL, proxy.GetURL())
 			} else if s.IsLeader() && (s.PrevState == stateUnconn || s.PrevState == stateFailed || (len(proxy.BackendsWrite) == 0 || !isBackendWriter)) {
 				// if the master comes back from a previously failed or standalone state, reintroduce it in
 				// the appropriate HostGroup
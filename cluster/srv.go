@@ -34,6 +34,7 @@ import (
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/gtid"
 	"github.com/signal18/replication-manager/utils/misc"
+	"github.com/signal18/replication-manager/utils/openmetrics"
 	"github.com/signal18/replication-manager/utils/s18log"
 	"github.com/signal18/replication-manager/utils/state"
 	"github.com/signal18/replication-manager/utils/version"
@@ -193,10 +194,12 @@ type ServerMonitor struct {
 	MaxSlowQueryTimestamp       int64                      `json:"maxSlowQueryTimestamp"`
 	WorkLoad                    *config.WorkLoadsMap       `json:"workLoad"`
 	DelayStat                   *ServerDelayStat           `json:"delayStat"`
+	DelayHistogram              *openmetrics.Histogram     `json:"-"`
 	SlaveVariables              SlaveVariables             `json:"slaveVariables"`
 	IsReseeding                 string                     `json:"isReseeding"`
 	ReplicationTags             string                     `json:"replicationTags"`
 	JobResults                  *config.TasksMap           `json:"jobResults"`
+	remediatedEvent             string                     `json:"-"`
 	IsInSlowQueryCapture        bool
 	IsInPFSQueryCapture         bool
 	InPurgingBinaryLog          bool
@@ -398,6 +401,7 @@ func (cluster *Cluster) newServerMonitor(url string, user string, pass string, c
 	server.ReloadSaveInfosVariables()
 	server.DelayStat = new(ServerDelayStat)
 	server.DelayStat.ResetDelayStat()
+	server.DelayHistogram = openmetrics.NewHistogram(replicationDelayBuckets...)
 
 	server.CurrentWorkLoad()
 	server.WorkLoad.Set("max", server.WorkLoad.Get("current"))
@@ -414,6 +418,8 @@ func (cluster *Cluster) newServerMonitor(url string, user string, pass string, c
 }
 
 func (server *ServerMonitor) Ping(wg *sync.WaitGroup) {
+	_, span := server.StartSpan("ServerMonitor.Ping")
+	defer span.End()
 	cluster := server.ClusterGroup
 	defer wg.Done()
 
@@ -515,7 +521,6 @@ func (server *ServerMonitor) Ping(wg *sync.WaitGroup) {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "ALERT", "Server %s state changed from %s to %s", server.URL, server.PrevState, server.State)
 				cluster.backendStateChangeProxies()
 				server.SendAlert()
-				server.ProcessFailedSlave()
 			}
 		}
 		if server.PrevState != server.State {
@@ -654,31 +659,53 @@ func (server *ServerMonitor) Ping(wg *sync.WaitGroup) {
 		server.rejoinSlave(ss)
 	}
 
+	// A replica in error is processed on each loop, the alert of an error
+	// remediated is held back until the next loop finds it still in error
+	if server.State == stateSlaveErr && cluster.IsActive() && server.ProcessFailedSlave() && server.PrevState != server.State {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Server %s replication error remediated, state change alert held back", server.URL)
+		return
+	}
+
 	if server.PrevState != server.State {
+		prevState := server.PrevState
 		server.SetPrevState(server.State)
 		if server.PrevState != stateSuspect {
 			cluster.backendStateChangeProxies()
 			server.SendAlert()
+			server.runReplicationErrorScript(prevState)
 		}
 	}
 }
 
-func (server *ServerMonitor) ProcessFailedSlave() {
+// runReplicationErrorScript calls replication-error-script when the replica
+// gets in error
+func (server *ServerMonitor) runReplicationErrorScript(prevState string) {
+	cluster := server.ClusterGroup
+	if server.State != stateSlaveErr || cluster.Conf.ReplicationErrorScript == "" {
+		return
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Calling replication error script")
+	var out []byte
+	out, err := exec.Command(cluster.Conf.ReplicationErrorScript, server.URL, prevState, server.State).CombinedOutput()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "ERROR", "%s", err)
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Replication error script complete:", string(out))
+}
+
+// ProcessFailedSlave remediates the SQL error of a replica following
+// replication-error-policies, or replication-restart-on-sqlerror-match when
+// no policy handles it. It returns true when a policy remediated the error.
+func (server *ServerMonitor) ProcessFailedSlave() bool {
 	cluster := server.ClusterGroup
 	if server.State == stateSlaveErr {
-		if cluster.Conf.ReplicationErrorScript != "" {
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Calling replication error script")
-			var out []byte
-			out, err := exec.Command(cluster.Conf.ReplicationErrorScript, server.URL, server.PrevState, server.State).CombinedOutput()
-			if err != nil {
-				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "ERROR", "%s", err)
-			}
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Replication error script complete:", string(out))
+		if handled, remediated := server.RemediateReplicationError(); handled {
+			return remediated
 		}
 		if server.HasReplicationSQLThreadRunning() && cluster.Conf.ReplicationRestartOnSQLErrorMatch != "" {
 			ss, err := server.GetSlaveStatus(server.ReplicationSourceName)
 			if err != nil {
-				return
+				return false
 			}
 			matched, err := regexp.Match(cluster.Conf.ReplicationRestartOnSQLErrorMatch, []byte(ss.LastSQLError.String))
 			if err != nil {
@@ -691,12 +718,15 @@ func (server *ServerMonitor) ProcessFailedSlave() {
 			}
 		}
 	}
+	return false
 }
 
 var start_time time.Time
 
 // Refresh a server object
 func (server *ServerMonitor) Refresh() error {
+	_, span := server.StartSpan("ServerMonitor.Refresh")
+	defer span.End()
 	cluster := server.ClusterGroup
 	var err error
 
@@ -13,9 +13,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
 	"github.com/signal18/replication-manager/config"
 )
 
@@ -50,6 +56,8 @@ func (server *ServerMonitor) FetchLastBackupMetadata() {
 		// if server.HasBackupMariabackupCookie() {
 		server.AppendLastMetadata(config.ConstBackupPhysicalTypeMariaBackup, &physical)
 		// }
+		server.AppendBackupChainMetadata(config.ConstBackupPhysicalTypeXtrabackup, &physical)
+		server.AppendBackupChainMetadata(config.ConstBackupPhysicalTypeMariaBackup, &physical)
 
 		if physical > 0 {
 			server.LastBackupMeta.Physical = cluster.BackupMetaMap.Get(physical)
@@ -57,6 +65,8 @@ func (server *ServerMonitor) FetchLastBackupMetadata() {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "No physical backup metadata, but cookie found on %s", server.URL)
 		}
 	}
+
+	server.AppendArchivedMetadata()
 }
 
 func (server *ServerMonitor) AppendLastMetadata(method string, latest *int64) {
@@ -98,6 +108,325 @@ func (server *ServerMonitor) ReadLastMetadata(method string) (*config.BackupMeta
 	return meta, nil
 }
 
+// GetBackupMetaFile returns the metadata file of a backup of the server
+func (server *ServerMonitor) GetBackupMetaFile(meta *config.BackupMetadata) string {
+	if meta.Archived {
+		return filepath.Dir(meta.Dest) + "/" + meta.BackupTool + ".meta.json"
+	}
+	if meta.IsIncremental() {
+		return server.GetBackupChainDirectory(meta.BackupTool) + strconv.FormatInt(meta.Id, 10) + ".meta.json"
+	}
+	return server.GetMyBackupDirectory() + meta.BackupTool + ".meta.json"
+}
+
+// SaveBackupMetadata rewrites the metadata file of a backup of the server
+func (server *ServerMonitor) SaveBackupMetadata(meta *config.BackupMetadata) error {
+	bjson, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(server.GetBackupMetaFile(meta), bjson, 0644)
+}
+
+// GetBackupPinnedDirectory returns the directory a pinned backup is moved to,
+// out of the reach of the next backups of its tool
+func (server *ServerMonitor) GetBackupPinnedDirectory(id int64) string {
+	return server.GetMyBackupDirectory() + "pinned/" + strconv.FormatInt(id, 10) + "/"
+}
+
+// GetBackupArchiveDirectory returns the directory the last backup of a tool is
+// moved to before the next backup when backup-retention is enabled
+func (server *ServerMonitor) GetBackupArchiveDirectory(id int64) string {
+	return server.GetMyBackupDirectory() + "archive/" + strconv.FormatInt(id, 10) + "/"
+}
+
+// AppendArchivedMetadata loads the metadata of the pinned and archived backups
+// of the server
+func (server *ServerMonitor) AppendArchivedMetadata() {
+	cluster := server.ClusterGroup
+	pinned, _ := filepath.Glob(server.GetMyBackupDirectory() + "pinned/*/*.meta.json")
+	archived, _ := filepath.Glob(server.GetMyBackupDirectory() + "archive/*/*.meta.json")
+	for _, filename := range append(pinned, archived...) {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "Error reading %s meta: %s", filename, err.Error())
+			continue
+		}
+		meta := new(config.BackupMetadata)
+		if err := json.Unmarshal(content, meta); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "Error reading %s meta: %s", filename, err.Error())
+			continue
+		}
+		cluster.BackupMetaMap.Set(meta.Id, meta)
+	}
+}
+
+// GetMyDumperTableRows counts the rows of the data files of a mydumper backup
+// for its restore verification, the tables whose schema is dumped without
+// data file have no row
+func (server *ServerMonitor) GetMyDumperTableRows(dir string) (config.TableRowCounts, error) {
+	cluster := server.ClusterGroup
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(config.TableRowCounts)
+	var views []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), backupEncryptExtension)
+		compression := filepath.Ext(name)
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+		if !file.Type().IsRegular() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".sql")
+		switch {
+		case strings.HasSuffix(name, "-schema-view"):
+			views = append(views, strings.TrimSuffix(name, "-schema-view"))
+			continue
+		case strings.HasSuffix(name, "-schema"):
+			table := strings.TrimSuffix(name, "-schema")
+			if schema, _, ok := strings.Cut(table, "."); ok && config.IsRowCountedSchema(schema) {
+				if _, ok := rows[table]; !ok {
+					rows[table] = 0
+				}
+			}
+			continue
+		case strings.Contains(name, "-schema"):
+			continue
+		}
+		schema, _, ok := strings.Cut(name, ".")
+		if !ok || !config.IsRowCountedSchema(schema) {
+			continue
+		}
+		counter := config.NewRowCounter(schema)
+		if err := cluster.readBackupDump(filepath.Join(dir, file.Name()), compression, counter); err != nil {
+			return nil, err
+		}
+		for table, n := range counter.Rows {
+			rows[table] += n
+		}
+	}
+	for _, view := range views {
+		delete(rows, view)
+	}
+	return rows, nil
+}
+
+// readBackupDump writes the clear content of a dump file of a backup to w
+func (cluster *Cluster) readBackupDump(path string, compression string, w io.Writer) error {
+	file, err := cluster.OpenBackupFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	switch compression {
+	case ".gz":
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case ".zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// GetBackupChainDirectory returns the directory of the incremental and
+// differential backups chained to the physical backup of a tool
+func (server *ServerMonitor) GetBackupChainDirectory(tool string) string {
+	cluster := server.ClusterGroup
+	dir := server.GetMyBackupDirectory() + tool + ".incr"
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Create backup chain path %s failed: %s", dir, err)
+		}
+	}
+	return dir + "/"
+}
+
+// AppendBackupChainMetadata loads the metadata of the backups chained to the
+// physical backup of a tool
+func (server *ServerMonitor) AppendBackupChainMetadata(tool string, latest *int64) {
+	cluster := server.ClusterGroup
+	files, _ := filepath.Glob(server.GetMyBackupDirectory() + tool + ".incr/*.meta.json")
+	for _, filename := range files {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "Error reading %s meta: %s", filename, err.Error())
+			continue
+		}
+		meta := new(config.BackupMetadata)
+		if err := json.Unmarshal(content, meta); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlDbg, "Error reading %s meta: %s", filename, err.Error())
+			continue
+		}
+		cluster.BackupMetaMap.Set(meta.Id, meta)
+		if *latest < meta.Id {
+			*latest = meta.Id
+		}
+	}
+}
+
+// PurgeBackupChain removes the incremental and differential backups of a tool,
+// they can't be restored once the full backup they chain to is replaced
+func (server *ServerMonitor) PurgeBackupChain(tool string) {
+	cluster := server.ClusterGroup
+	cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+		if backup.IsIncremental() && backup.BackupTool == tool && backup.Source == server.URL && !backup.Archived {
+			cluster.BackupMetaMap.Delete(key)
+		}
+		return true
+	})
+	if err := os.RemoveAll(server.GetMyBackupDirectory() + tool + ".incr"); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to purge backup chain of %s on %s: %s", tool, server.URL, err)
+		return
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Purged %s backup chain on %s", tool, server.URL)
+}
+
+// MoveBackup moves the files of a backup to a directory out of the rotation of
+// the backups of its tool and saves its metadata there
+func (server *ServerMonitor) MoveBackup(meta *config.BackupMetadata, dir string) error {
+	return server.moveBackup(meta, meta.Dest, dir, true)
+}
+
+// moveBackup moves the files of a backup from src, its metadata file is kept
+// when a new backup of the tool owns it
+func (server *ServerMonitor) moveBackup(meta *config.BackupMetadata, src string, dir string, removeMeta bool) error {
+	cluster := server.ClusterGroup
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	oldmeta := server.GetBackupMetaFile(meta)
+	archived := meta.Archived
+	dest := dir + filepath.Base(meta.Dest)
+	if err := os.Rename(src, dest); err != nil {
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Moved backup %d from %s to %s", meta.Id, src, dest)
+	meta.Dest = dest
+	meta.Archived = true
+	if err := server.SaveBackupMetadata(meta); err != nil {
+		return err
+	}
+	if removeMeta && oldmeta != server.GetBackupMetaFile(meta) {
+		os.Remove(oldmeta)
+	}
+	// Remove the previous directory of an archived backup once empty
+	if archived {
+		os.Remove(filepath.Dir(oldmeta))
+	}
+	return nil
+}
+
+// ArchiveLastBackup moves the backup a new valid backup replaces and the
+// backups chained to it to the archive directory, the backup retention purges
+// them. The replaced backup is kept renamed .old until the new one completes,
+// a failed one is dropped. It returns true when the replaced backup is
+// archived.
+func (server *ServerMonitor) ArchiveLastBackup(meta *config.BackupMetadata) bool {
+	cluster := server.ClusterGroup
+	if !cluster.Conf.BackupRetention || meta.Previous == 0 || !meta.HasBackupFile() {
+		return false
+	}
+	last := cluster.BackupMetaMap.Get(meta.Previous)
+	if last == nil || last.Archived {
+		return false
+	}
+	if _, err := os.Stat(last.Dest + ".old"); err != nil || !last.Completed {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Dropping incomplete backup %d of %s", last.Id, server.URL)
+		cluster.BackupMetaMap.Delete(last.Id)
+		return false
+	}
+	// The dependent backups are read before the move changes the chain
+	backups := []*config.BackupMetadata{last}
+	for i := 0; i < len(backups); i++ {
+		backups = append(backups, cluster.BackupMetaMap.GetDependentBackups(backups[i].Id)...)
+	}
+	if err := server.moveBackup(last, last.Dest+".old", server.GetBackupArchiveDirectory(last.Id), false); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to archive backup %d of %s: %s", last.Id, server.URL, err)
+		return false
+	}
+	for _, backup := range backups[1:] {
+		if backup.Archived {
+			continue
+		}
+		if err := server.MoveBackup(backup, server.GetBackupArchiveDirectory(backup.Id)); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to archive backup %d of %s: %s", backup.Id, server.URL, err)
+		}
+	}
+	return true
+}
+
+// GetReseedBackupChain returns the incremental backups to apply after the full
+// backup file sent to reseed the server: the chain of the backup of the point
+// in time recovery, or the whole chain of the full backup otherwise
+func (server *ServerMonitor) GetReseedBackupChain(backupfile string) ([]string, error) {
+	cluster := server.ClusterGroup
+	files := make([]string, 0)
+
+	var id int64
+	if server.PointInTimeMeta.IsInPITR {
+		id = server.PointInTimeMeta.Backup
+	} else {
+		var full *config.BackupMetadata
+		cluster.BackupMetaMap.Callback(func(key int64, backup *config.BackupMetadata) bool {
+			if !backup.IsIncremental() && backup.Completed && backup.Dest == backupfile {
+				full = backup
+				return false
+			}
+			return true
+		})
+		if full == nil {
+			return files, nil
+		}
+		id = cluster.BackupMetaMap.GetLastChainBackup(full).Id
+	}
+
+	chain, err := cluster.BackupMetaMap.GetBackupChain(id)
+	if err != nil {
+		return files, err
+	}
+	if filepath.Base(chain[0].Dest) != filepath.Base(backupfile) {
+		return files, fmt.Errorf("Backup %d chains to %s and not to %s", id, chain[0].Dest, backupfile)
+	}
+	for _, backup := range chain[1:] {
+		if _, err := os.Stat(backup.Dest); err != nil {
+			return files, fmt.Errorf("Backup %d of the chain: %s", backup.Id, err)
+		}
+		files = append(files, backup.Dest)
+	}
+	return files, nil
+}
+
+// GetPointInTimeBackupFile returns the file or directory of the backup restored
+// by the point in time recovery, the full backup of its chain for an
+// incremental backup, and an empty string outside of a recovery
+func (server *ServerMonitor) GetPointInTimeBackupFile() (string, error) {
+	cluster := server.ClusterGroup
+	if !server.PointInTimeMeta.IsInPITR || server.PointInTimeMeta.Backup == 0 {
+		return "", nil
+	}
+	chain, err := cluster.BackupMetaMap.GetBackupChain(server.PointInTimeMeta.Backup)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(chain[0].Dest); err != nil {
+		return "", fmt.Errorf("Backup %d: %s", chain[0].Id, err)
+	}
+	return chain[0].Dest, nil
+}
+
 func (server *ServerMonitor) GetLatestMeta(method string) (int64, *config.BackupMetadata) {
 	cluster := server.ClusterGroup
 	var latest int64 = 0
@@ -218,8 +547,22 @@ func (server *ServerMonitor) ReseedPointInTime(meta config.PointInTimeMeta) erro
 	return nil
 }
 
+// InjectViaBinlogs replays on the server the binary logs of the backup source
+// archived in the backup directory until the restore time
 func (server *ServerMonitor) InjectViaBinlogs(meta config.PointInTimeMeta) error {
-	return nil
+	cluster := server.ClusterGroup
+	backup := cluster.BackupMetaMap.Get(meta.Backup)
+	if backup == nil {
+		return fmt.Errorf("Backup with id %d not found in BackupMetaMap", meta.Backup)
+	}
+	plan, err := cluster.GetPointInTimePlan(config.PointInTimeRequest{Target: config.PointInTimeTargetTime, Time: meta.RestoreTime, Source: backup.Source, Backup: backup.Id, Server: server.URL})
+	if err != nil {
+		return err
+	}
+	source := cluster.GetServerFromURL(plan.Source)
+	return source.ApplyArchivedBinaryLogs(plan, func(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary) error {
+		return source.ReadAndApplyBinaryLogsWithinRange(start, end, server)
+	})
 }
 
 func (server *ServerMonitor) InjectViaReplication(meta config.PointInTimeMeta) error {
@@ -270,7 +270,10 @@ func (server *ServerMonitor) CheckBinaryLogs(force bool) error {
 		//Don't do anything while failover
 		if cluster.Conf.BackupBinlogs && !cluster.IsInFailover() {
 			//Set second parameter to false, not part of backupbinlogpurge
-			server.InitiateJobBackupBinlog(server.BinaryLogFilePrevious, false)
+			//The binary logs streamed by the archiver are already copied
+			if !cluster.IsBinlogArchived(server) {
+				server.InitiateJobBackupBinlog(server.BinaryLogFilePrevious, false)
+			}
 			//Initiate purging backup binlog
 			go server.JobBackupBinlogPurge(server.BinaryLogFilePrevious)
 		}
@@ -630,14 +633,16 @@ func (server *ServerMonitor) ReadBinlogBackupDirGoMySQL(meta *dbhelper.BinaryLog
 	var err error
 	parser := replication.NewBinlogParser()
 
-	file, err := os.Open(server.GetMyBackupDirectory() + "/" + meta.Filename)
+	file, err := server.ClusterGroup.OpenBackupFile(server.GetMyBackupDirectory() + "/" + meta.Filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	//Binary logs start at 4
-	file.Seek(4, io.SeekStart)
+	if _, err := io.CopyN(io.Discard, file, 4); err != nil {
+		return err
+	}
 
 	_, err = parser.ParseSingleEvent(file, func(e *replication.BinlogEvent) error {
 		// Check if the event is FORMAT_DESCRIPTION_EVENT
@@ -731,6 +736,7 @@ func (server *ServerMonitor) WriteBackupBinlogMetadata() {
 	} else {
 		server.BinaryLogMetaToWrite = make([]string, 0)
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, info, server.URL)
+		cluster.UploadBackupFile(fname)
 	}
 }
 
@@ -929,6 +935,22 @@ func (server *ServerMonitor) GetBinlogPositionFromTimestamp(start uint32, end *c
 func (server *ServerMonitor) ReadAndApplyBinaryLogsWithinRange(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary, dest *ServerMonitor) error {
 	cluster := server.ClusterGroup
 
+	file, err := cluster.CreateTmpClientConfFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	cliParams := make([]string, 0)
+	cliParams = append(cliParams, `--defaults-file=`+file, `--host=`+misc.Unbracket(dest.Host), `--port=`+dest.Port, `--user=`+cluster.GetDbUser(), `--force`, `--batch`, `--verbose`, server.GetSSLClientParam("client"))
+	return server.applyBinaryLogsWithinRange(start, end, cliParams, dest)
+}
+
+// applyBinaryLogsWithinRange pipes mysqlbinlog to the mysql client started
+// with cliParams, the binary log is read from the server or from start.Path
+func (server *ServerMonitor) applyBinaryLogsWithinRange(start config.ReadBinaryLogsBoundary, end config.ReadBinaryLogsBoundary, cliParams []string, dest *ServerMonitor) error {
+	cluster := server.ClusterGroup
+
 	if _, err := os.Stat(cluster.GetMysqlBinlogPath()); os.IsNotExist(err) {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, "ERROR", "File does not exist %s", cluster.GetMysqlBinlogPath())
 		return err
@@ -939,16 +961,12 @@ func (server *ServerMonitor) ReadAndApplyBinaryLogsWithinRange(start config.Read
 		return err
 	}
 
-	file, err := cluster.CreateTmpClientConfFile()
-	if err != nil {
-		return err
-	}
-	defer os.Remove(file)
-
 	// Base parameters
 	// Important! Server ID should use binlog owner to apply binary logs correctly
 	params := make([]string, 0)
-	params = append(params, "--read-from-remote-server", fmt.Sprintf("--server-id=%d", server.ServerID), "--user="+cluster.GetRplUser(), "--password="+cluster.GetRplPass(), "--host="+misc.Unbracket(server.Host), "--port="+server.Port)
+	if start.Path == "" {
+		params = append(params, "--read-from-remote-server", fmt.Sprintf("--server-id=%d", server.ServerID), "--user="+cluster.GetRplUser(), "--password="+cluster.GetRplPass(), "--host="+misc.Unbracket(server.Host), "--port="+server.Port)
+	}
 
 	if start.Position > 0 {
 		params = append(params, "--start-position="+strconv.FormatInt(start.Position, 10))
@@ -959,14 +977,16 @@ func (server *ServerMonitor) ReadAndApplyBinaryLogsWithinRange(start config.Read
 	}
 
 	// Binlog filename parameter
-	params = append(params, server.GetSSLClientParam("client-binlog"), start.Filename)
+	if start.Path != "" {
+		params = append(params, start.Path)
+	} else {
+		params = append(params, server.GetSSLClientParam("client-binlog"), start.Filename)
+	}
 
 	binlogCmd := exec.Command(cluster.GetMysqlBinlogPath(), misc.RemoveEmptyString(params)...)
 	iodumpreader, _ := binlogCmd.StdoutPipe()
 	stderrIn, _ := binlogCmd.StderrPipe()
 
-	cliParams := make([]string, 0)
-	cliParams = append(cliParams, `--defaults-file=`+file, `--host=`+misc.Unbracket(dest.Host), `--port=`+dest.Port, `--user=`+cluster.GetDbUser(), `--force`, `--batch`, `--verbose`, server.GetSSLClientParam("client"))
 	clientCmd := exec.Command(cluster.GetMysqlclientPath(), misc.RemoveEmptyString(cliParams)...)
 	cliErrPipe, _ := clientCmd.StderrPipe()
 	cliOutPipe, _ := clientCmd.StdoutPipe()
@@ -159,6 +159,7 @@ func (server *ServerMonitor) CheckReplication() string {
 		if cluster.Conf.DelayStatCapture {
 			server.DelayStat.UpdateDelayStat(ss.SecondsBehindMaster.Int64, cluster.Conf.DelayStatRotate) // Capture Delay Stat
 		}
+		server.DelayHistogram.Observe(float64(ss.SecondsBehindMaster.Int64))
 		return "Behind master"
 	}
 	if server.IsRelay == false && server.IsMaxscale == false {
@@ -170,6 +171,7 @@ func (server *ServerMonitor) CheckReplication() string {
 	if cluster.Conf.DelayStatCapture {
 		server.DelayStat.UpdateDelayStat(ss.SecondsBehindMaster.Int64, cluster.Conf.DelayStatRotate) // Capture Delay Stat with 0 seconds behind master
 	}
+	server.DelayHistogram.Observe(0)
 	return "Running OK"
 }
 
@@ -34,6 +34,7 @@ import (
 	gzip "github.com/klauspost/pgzip"
 	dumplingext "github.com/pingcap/dumpling/v4/export"
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/crypto"
 	"github.com/signal18/replication-manager/utils/dbhelper"
 	"github.com/signal18/replication-manager/utils/misc"
 	river "github.com/signal18/replication-manager/utils/river"
@@ -127,6 +128,15 @@ func (server *ServerMonitor) JobsCreateTable() error {
 		}
 	}
 
+	server.ConnGetQueryWithTimeout(Conn, JobTimeout, &exist, "SELECT COUNT(*) col_exists FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = 'replication_manager_schema' AND TABLE_NAME = 'jobs' AND COLUMN_NAME = 'params'")
+	if exist == 0 {
+		//Job parameters read by the dbjobs script, e.g. incremental-lsn of an incremental backup
+		_, err := server.ConnExecQueryWithTimeout(Conn, JobTimeout, "ALTER TABLE replication_manager_schema.jobs ADD COLUMN params VARCHAR(255) not null default '' AFTER `server`")
+		if err != nil {
+			return fmt.Errorf("Failed to add column on jobs table: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -173,6 +183,12 @@ func (server *ServerMonitor) JobsUpdateEntries(Conn *sqlx.Conn) error {
 }
 
 func (server *ServerMonitor) JobInsertTask(task string, port string, repmanhost string) (int64, error) {
+	return server.JobInsertTaskWithParams(task, port, repmanhost, "")
+}
+
+// JobInsertTaskWithParams inserts a job with space separated key=value
+// parameters that the dbjobs script reads from the params column
+func (server *ServerMonitor) JobInsertTaskWithParams(task string, port string, repmanhost string, params string) (int64, error) {
 	cluster := server.ClusterGroup
 	if cluster.InRollingRestart {
 		return 0, errors.New("In rolling restart")
@@ -236,7 +252,11 @@ func (server *ServerMonitor) JobInsertTask(task string, port string, repmanhost
 	}
 
 	//Reuse the same id
-	res, err := server.ConnExecQueryWithTimeout(conn, JobTimeout, fmt.Sprintf("INSERT INTO replication_manager_schema.jobs(id, task, port,server,start) VALUES(%d,'%s',%s,'%s', NOW())", t.Id, task, port, repmanhost))
+	query = fmt.Sprintf("INSERT INTO replication_manager_schema.jobs(id, task, port,server,start) VALUES(%d,'%s',%s,'%s', NOW())", t.Id, task, port, repmanhost)
+	if params != "" {
+		query = fmt.Sprintf("INSERT INTO replication_manager_schema.jobs(id, task, port,server,params,start) VALUES(%d,'%s',%s,'%s','%s', NOW())", t.Id, task, port, repmanhost, params)
+	}
+	res, err := server.ConnExecQueryWithTimeout(conn, JobTimeout, query)
 	if err != nil {
 		return 0, fmt.Errorf("Failed to insert row on jobs table for %s: %v", t.Task, err)
 	}
@@ -274,25 +294,31 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Receive physical backup %s request for server: %s", cluster.Conf.BackupPhysicalType, server.URL)
 
+	key, err := cluster.NewBackupDataKey()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Cancel physical backup of %s: %s", server.URL, err)
+		cluster.SetInPhysicalBackupState(false)
+		return 0, err
+	}
+
 	var port string
-	var err error
 	var backupext string = ".xbtream"
 	var dest string = server.GetMyBackupDirectory() + cluster.Conf.BackupPhysicalType
 	if cluster.Conf.CompressBackups {
 		backupext = backupext + ".gz"
 		dest = dest + backupext
-		if cluster.Conf.BackupKeepUntilValid {
+		if cluster.IsBackupKeptUntilValid() {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Rename previous backup to .old")
 			exec.Command("mv", dest, dest+".old").Run()
 		}
-		port, err = cluster.SSTRunReceiverToGZip(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType)
+		port, err = cluster.SSTRunReceiverToGZip(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType, key)
 	} else {
 		dest = dest + backupext
-		if cluster.Conf.BackupKeepUntilValid {
+		if cluster.IsBackupKeptUntilValid() {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Rename previous backup to .old")
 			exec.Command("mv", dest, dest+".old").Run()
 		}
-		port, err = cluster.SSTRunReceiverToFile(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType)
+		port, err = cluster.SSTRunReceiverToFile(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType, key)
 	}
 
 	if err != nil {
@@ -309,8 +335,9 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 	}
 
 	// Remove from backup list, since the file will be replaced
-	if !cluster.Conf.BackupKeepUntilValid {
+	if !cluster.IsBackupKeptUntilValid() {
 		cluster.BackupMetaMap.Delete(prevId)
+		server.PurgeBackupChain(cluster.Conf.BackupPhysicalType)
 	}
 
 	server.LastBackupMeta.Physical = &config.BackupMetadata{
@@ -325,6 +352,7 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 		Previous:       prevId,
 	}
 
+	cluster.SetBackupEncryption(server.LastBackupMeta.Physical, key)
 	cluster.BackupMetaMap.Set(server.LastBackupMeta.Physical.Id, server.LastBackupMeta.Physical)
 
 	jobid, err := server.JobInsertTask(cluster.Conf.BackupPhysicalType, port, cluster.Conf.MonitorAddress)
@@ -335,6 +363,99 @@ func (server *ServerMonitor) JobBackupPhysical() (int64, error) {
 	return jobid, err
 }
 
+// JobBackupPhysicalIncremental streams the pages changed since the last backup
+// of the chain (incremental) or since the last full backup (differential). It
+// falls back to a full backup when no full backup can be chained to.
+func (server *ServerMonitor) JobBackupPhysicalIncremental(strategy config.BackupStrategy) (int64, error) {
+	//server can be nil as no dicovered master
+	if server == nil {
+		return 0, nil
+	}
+
+	if server.IsDown() {
+		return 0, nil
+	}
+
+	cluster := server.ClusterGroup
+
+	if cluster.IsInBackup() {
+		cluster.SetState("WARN0110", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(cluster.GetErrorList()["WARN0110"], "Physical", cluster.Conf.BackupPhysicalType, server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
+		time.Sleep(1 * time.Second)
+
+		return server.JobBackupPhysicalIncremental(strategy)
+	}
+
+	// Prevent backing up with incompatible tools
+	if server.IsMariaDB() && server.DBVersion.GreaterEqual("10.1") && cluster.Conf.BackupPhysicalType == "xtrabackup" {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Master %s MariaDB version is greater than 10.1. Changing from xtrabackup to mariabackup as physical backup tools", server.URL)
+		cluster.Conf.BackupPhysicalType = config.ConstBackupPhysicalTypeMariaBackup
+	}
+
+	full := cluster.BackupMetaMap.GetLastFullBackup(cluster.Conf.BackupPhysicalType, server.URL)
+	if full == nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "No full %s backup with checkpoint found for server %s, running a full backup instead of %s", cluster.Conf.BackupPhysicalType, server.URL, strategy)
+		return server.JobBackupPhysical()
+	}
+
+	parent := full
+	if strategy == config.BackupStrategyIncremental {
+		parent = cluster.BackupMetaMap.GetLastChainBackup(full)
+	}
+	if parent.ToLSN == 0 {
+		return 0, fmt.Errorf("Backup %d has no checkpoint to chain a %s backup", parent.Id, strategy)
+	}
+
+	cluster.SetInPhysicalBackupState(true)
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Receive physical backup %s %s request for server: %s from LSN %d of backup %d", cluster.Conf.BackupPhysicalType, strategy, server.URL, parent.ToLSN, parent.Id)
+
+	key, err := cluster.NewBackupDataKey()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Cancel physical backup of %s: %s", server.URL, err)
+		cluster.SetInPhysicalBackupState(false)
+		return 0, err
+	}
+
+	now := time.Now()
+	var port string
+	var dest string = server.GetBackupChainDirectory(cluster.Conf.BackupPhysicalType) + strconv.FormatInt(now.Unix(), 10) + ".xbtream"
+	if cluster.Conf.CompressBackups {
+		dest = dest + ".gz"
+		port, err = cluster.SSTRunReceiverToGZip(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType, key)
+	} else {
+		port, err = cluster.SSTRunReceiverToFile(server, dest, ConstJobCreateFile, cluster.Conf.BackupPhysicalType, key)
+	}
+
+	if err != nil {
+		cluster.SetInPhysicalBackupState(false)
+		return 0, nil
+	}
+
+	server.LastBackupMeta.Physical = &config.BackupMetadata{
+		Id:             now.Unix(),
+		StartTime:      now,
+		BackupMethod:   config.BackupMethodPhysical,
+		BackupStrategy: strategy,
+		BackupTool:     cluster.Conf.BackupPhysicalType,
+		Source:         server.URL,
+		Dest:           dest,
+		Compressed:     cluster.Conf.CompressBackups,
+		Previous:       parent.Id,
+		FromLSN:        parent.ToLSN,
+	}
+
+	cluster.SetBackupEncryption(server.LastBackupMeta.Physical, key)
+	cluster.BackupMetaMap.Set(server.LastBackupMeta.Physical.Id, server.LastBackupMeta.Physical)
+
+	jobid, err := server.JobInsertTaskWithParams(cluster.Conf.BackupPhysicalType, port, cluster.Conf.MonitorAddress, "incremental-lsn="+strconv.FormatUint(parent.ToLSN, 10))
+	if err != nil {
+		cluster.BackupMetaMap.Delete(server.LastBackupMeta.Physical.Id)
+		cluster.SetInPhysicalBackupState(false)
+	}
+
+	return jobid, err
+}
+
 func (server *ServerMonitor) JobReseedPhysicalBackup(backtype string) error {
 	cluster := server.ClusterGroup
 	if backtype == "default" {
@@ -356,7 +477,12 @@ func (server *ServerMonitor) JobReseedPhysicalBackup(backtype string) error {
 		return errors.New("No master found. Cancel reseed physical backup")
 	}
 
-	useMaster := true
+	pitrfile, err := server.GetPointInTimeBackupFile()
+	if err != nil {
+		return fmt.Errorf("Cancelling reseed. %s", err)
+	}
+
+	useMaster := pitrfile == ""
 	backupext := ".xbtream"
 	if cluster.Conf.CompressBackups {
 		backupext = backupext + ".gz"
@@ -364,9 +490,12 @@ func (server *ServerMonitor) JobReseedPhysicalBackup(backtype string) error {
 
 	file := backtype + backupext
 	backupfile := master.GetMyBackupDirectory() + file
+	if pitrfile != "" {
+		backupfile = pitrfile
+	}
 
 	bckserver := cluster.GetBackupServer()
-	if bckserver != nil && bckserver.HasBackupTypeCookie(backtype) {
+	if useMaster && bckserver != nil && bckserver.HasBackupTypeCookie(backtype) {
 		if _, err := os.Stat(bckserver.GetMyBackupDirectory() + file); err == nil {
 			backupfile = bckserver.GetMyBackupDirectory() + file
 			useMaster = false
@@ -384,6 +513,14 @@ func (server *ServerMonitor) JobReseedPhysicalBackup(backtype string) error {
 		}
 	}
 
+	increments, err := server.GetReseedBackupChain(backupfile)
+	if err != nil {
+		if server.PointInTimeMeta.IsInPITR {
+			return fmt.Errorf("Cancelling reseed. %s", err)
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Reseed %s without incremental backups: %s", server.URL, err)
+	}
+
 	//Delete wait physical backup cookie
 	server.DelWaitPhysicalBackupCookie()
 
@@ -413,7 +550,12 @@ func (server *ServerMonitor) JobReseedPhysicalBackup(backtype string) error {
 		cluster.Conf.BackupPhysicalType = backtype
 	}
 
-	_, err := server.JobInsertTask(task, server.SSTPort, cluster.Conf.MonitorAddress)
+	var params string
+	if len(increments) > 0 {
+		params = "chain=" + strconv.Itoa(len(increments))
+	}
+
+	_, err = server.JobInsertTaskWithParams(task, server.SSTPort, cluster.Conf.MonitorAddress, params)
 	if err != nil {
 		if server.HasReseedingState(task) {
 			server.SetInReseedBackup("")
@@ -562,7 +704,12 @@ func (server *ServerMonitor) JobReseedLogicalBackup(backtype string) error {
 		return errors.New("No mydumper version found")
 	}
 
-	useMaster := true
+	pitrfile, err := server.GetPointInTimeBackupFile()
+	if err != nil {
+		return err
+	}
+
+	useMaster := pitrfile == ""
 	var dest string
 	switch backtype {
 	case config.ConstBackupLogicalTypeMysqldump:
@@ -574,7 +721,7 @@ func (server *ServerMonitor) JobReseedLogicalBackup(backtype string) error {
 	}
 
 	// Can't handle script validation, unknown logic
-	if backtype != "script" {
+	if backtype != "script" && pitrfile == "" {
 		backupfile := master.GetMyBackupDirectory() + dest
 
 		bckserver := cluster.GetBackupServer()
@@ -621,7 +768,7 @@ func (server *ServerMonitor) JobReseedLogicalBackup(backtype string) error {
 		server.SetState(stateUnconn)
 	}
 
-	_, err := server.JobInsertTask(task, "0", cluster.Conf.MonitorAddress)
+	_, err = server.JobInsertTask(task, "0", cluster.Conf.MonitorAddress)
 	if err != nil {
 		if server.HasReseedingState(task) {
 			server.SetInReseedBackup("")
@@ -662,9 +809,13 @@ func (server *ServerMonitor) JobReseedLogicalBackup(backtype string) error {
 			useMaster := true
 			file := "mysqldump.sql.gz"
 			backupfile := cluster.master.GetMyBackupDirectory() + file
+			if pitrfile != "" {
+				backupfile = pitrfile
+				useMaster = false
+			}
 
 			bckserver := cluster.GetBackupServer()
-			if bckserver != nil && bckserver.HasBackupTypeCookie(config.ConstBackupLogicalTypeMysqldump) {
+			if pitrfile == "" && bckserver != nil && bckserver.HasBackupTypeCookie(config.ConstBackupLogicalTypeMysqldump) {
 				if _, err := os.Stat(bckserver.GetMyBackupDirectory() + file); err == nil {
 					backupfile = bckserver.GetMasterBackupDirectory() + file
 					useMaster = false
@@ -698,9 +849,13 @@ func (server *ServerMonitor) JobReseedLogicalBackup(backtype string) error {
 			useMaster := true
 			dir := "mydumper"
 			backupdir := cluster.master.GetMyBackupDirectory() + dir
+			if pitrfile != "" {
+				backupdir = pitrfile
+				useMaster = false
+			}
 
 			bckserver := cluster.GetBackupServer()
-			if bckserver != nil && bckserver.HasBackupTypeCookie(config.ConstBackupLogicalTypeMydumper) {
+			if pitrfile == "" && bckserver != nil && bckserver.HasBackupTypeCookie(config.ConstBackupLogicalTypeMydumper) {
 				if _, err := os.Stat(bckserver.GetMyBackupDirectory() + dir); err == nil {
 					backupdir = bckserver.GetMasterBackupDirectory() + dir
 					useMaster = false
@@ -926,7 +1081,7 @@ func (server *ServerMonitor) JobBackupErrorLog() (int64, error) {
 	if server.IsDown() {
 		return 0, nil
 	}
-	port, err := cluster.SSTRunReceiverToFile(server, server.Datadir+"/log/log_error.log", ConstJobAppendFile, task)
+	port, err := cluster.SSTRunReceiverToFile(server, server.Datadir+"/log/log_error.log", ConstJobAppendFile, task, nil)
 	if err != nil {
 		return 0, nil
 	}
@@ -999,7 +1154,7 @@ func (server *ServerMonitor) JobBackupSlowQueryLog() (int64, error) {
 		return 0, nil
 	}
 
-	port, err := cluster.SSTRunReceiverToFile(server, server.Datadir+"/log/log_slow_query.log", ConstJobAppendFile, task)
+	port, err := cluster.SSTRunReceiverToFile(server, server.Datadir+"/log/log_slow_query.log", ConstJobAppendFile, task, nil)
 	if err != nil {
 		return 0, nil
 	}
@@ -1024,7 +1179,6 @@ func (server *ServerMonitor) JobZFSSnapBack() (int64, error) {
 
 func (server *ServerMonitor) JobReseedMyLoader(backupdir string) error {
 	cluster := server.ClusterGroup
-	threads := strconv.Itoa(cluster.Conf.BackupLogicalLoadThreads)
 
 	defer server.SetInReseedBackup("")
 
@@ -1033,31 +1187,17 @@ func (server *ServerMonitor) JobReseedMyLoader(backupdir string) error {
 		return fmt.Errorf("No master. Cancel backup reseeding %s", server.URL)
 	}
 
-	myargs := strings.Split(strings.ReplaceAll(cluster.Conf.BackupMyLoaderOptions, "  ", " "), " ")
+	backupdir, cleanup, err := cluster.GetClearBackupDir(backupdir)
+	if err != nil {
+		return fmt.Errorf("[%s] Failed decrypting backup directory for reseed: %s", server.URL, err)
+	}
+	defer cleanup()
+
+	var myargs []string
 	if server.URL == cluster.GetMaster().URL {
 		myargs = append(myargs, "--enable-binlog")
 	}
-
-	myargs = append(myargs, "--directory="+backupdir, "--threads="+threads, "--host="+misc.Unbracket(server.Host), "--port="+server.Port, "--user="+cluster.GetDbUser(), "--password="+cluster.GetDbPass())
-	dumpCmd := exec.Command(cluster.GetMyLoaderPath(), myargs...)
-
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Command: %s", strings.ReplaceAll(dumpCmd.String(), cluster.GetDbPass(), "XXXX"))
-
-	stdoutIn, _ := dumpCmd.StdoutPipe()
-	stderrIn, _ := dumpCmd.StderrPipe()
-	dumpCmd.Start()
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		server.copyLogs(stdoutIn, config.ConstLogModBackupStream, config.LvlDbg)
-	}()
-	go func() {
-		defer wg.Done()
-		server.copyLogs(stderrIn, config.ConstLogModBackupStream, config.LvlDbg)
-	}()
-	wg.Wait()
-	if err := dumpCmd.Wait(); err != nil {
+	if err := server.runMyLoader(backupdir, myargs...); err != nil {
 		return err
 	}
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Finish logical restaure %s for: %s", cluster.Conf.BackupLogicalType, server.URL)
@@ -1093,16 +1233,11 @@ func (server *ServerMonitor) JobReseedMysqldump(backupfile string) error {
 
 	server.StopSlave()
 
-	file, err := cluster.CreateTmpClientConfFile()
-	if err != nil {
-		return fmt.Errorf("[%s] Failed creating tmp connection file:  %s ", server.URL, err)
-	}
-	defer os.Remove(file)
-
-	gzfile, err := os.Open(backupfile)
+	gzfile, err := cluster.OpenBackupFile(backupfile)
 	if err != nil {
 		return fmt.Errorf("[%s] Failed opening backup file in backup server for reseed:  %s ", server.URL, err)
 	}
+	defer gzfile.Close()
 
 	fz, err := gzip.NewReader(gzfile)
 	if err != nil {
@@ -1116,10 +1251,69 @@ func (server *ServerMonitor) JobReseedMysqldump(backupfile string) error {
 		return fmt.Errorf("[%s] Error happened when unzipping backup file in backup server for reseed:  %s ", server.URL, err)
 	}
 
+	err = server.runMysqlClient(io.MultiReader(bytes.NewBufferString("reset master;set sql_log_bin=0;set long_query_time=10;"), &buf), true)
+	if err != nil {
+		return err
+	}
+
+	if server.IsSlave && !server.PointInTimeMeta.IsInPITR {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Start slave after dump on %s", server.URL)
+		server.StartSlave()
+	}
+
+	return nil
+}
+
+// runMyLoader loads a clear mydumper directory into the server with the
+// backup-myloader-options and extra arguments
+func (server *ServerMonitor) runMyLoader(backupdir string, args ...string) error {
+	cluster := server.ClusterGroup
+	threads := strconv.Itoa(cluster.Conf.GetBackupThreads(cluster.Conf.BackupLogicalLoadThreads))
+
+	myargs := strings.Split(strings.ReplaceAll(cluster.Conf.BackupMyLoaderOptions, "  ", " "), " ")
+	myargs = append(myargs, args...)
+	myargs = append(myargs, "--directory="+backupdir, "--threads="+threads, "--host="+misc.Unbracket(server.Host), "--port="+server.Port, "--user="+cluster.GetDbUser(), "--password="+cluster.GetDbPass())
+	dumpCmd := exec.Command(cluster.GetMyLoaderPath(), misc.RemoveEmptyString(myargs)...)
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Command: %s", strings.ReplaceAll(dumpCmd.String(), cluster.GetDbPass(), "XXXX"))
+
+	stdoutIn, _ := dumpCmd.StdoutPipe()
+	stderrIn, _ := dumpCmd.StderrPipe()
+	if err := dumpCmd.Start(); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		server.copyLogs(stdoutIn, config.ConstLogModBackupStream, config.LvlDbg)
+	}()
+	go func() {
+		defer wg.Done()
+		server.copyLogs(stderrIn, config.ConstLogModBackupStream, config.LvlDbg)
+	}()
+	wg.Wait()
+	return dumpCmd.Wait()
+}
+
+// runMysqlClient sends the SQL statements of a reader to the server with the
+// mysql client, errors of the statements do not stop the client with force
+func (server *ServerMonitor) runMysqlClient(r io.Reader, force bool) error {
+	cluster := server.ClusterGroup
+
+	file, err := cluster.CreateTmpClientConfFile()
+	if err != nil {
+		return fmt.Errorf("[%s] Failed creating tmp connection file:  %s ", server.URL, err)
+	}
+	defer os.Remove(file)
+
 	cliParams := make([]string, 0)
-	cliParams = append(cliParams, `--defaults-file=`+file, `--host=`+misc.Unbracket(server.Host), `--port=`+server.Port, `--user=`+cluster.GetDbUser(), `--force`, `--batch`, `--verbose`, server.GetSSLClientParam("client"))
+	cliParams = append(cliParams, `--defaults-file=`+file, `--host=`+misc.Unbracket(server.Host), `--port=`+server.Port, `--user=`+cluster.GetDbUser(), `--batch`, `--verbose`, server.GetSSLClientParam("client"))
+	if force {
+		cliParams = append(cliParams, `--force`)
+	}
 	clientCmd := exec.Command(cluster.GetMysqlclientPath(), misc.RemoveEmptyString(cliParams)...)
-	clientCmd.Stdin = io.MultiReader(bytes.NewBufferString("reset master;set sql_log_bin=0;set long_query_time=10;"), &buf)
+	clientCmd.Stdin = r
 
 	stderr, _ := clientCmd.StdoutPipe()
 	clientCmd.Stderr = clientCmd.Stdout
@@ -1136,12 +1330,6 @@ func (server *ServerMonitor) JobReseedMysqldump(backupfile string) error {
 	if err != nil {
 		return fmt.Errorf("Error waiting reseed %s at %s", server.URL, err)
 	}
-
-	if server.IsSlave && !server.PointInTimeMeta.IsInPITR {
-		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Start slave after dump on %s", server.URL)
-		server.StartSlave()
-	}
-
 	return nil
 }
 
@@ -1608,7 +1796,7 @@ func (server *ServerMonitor) JobBackupScript() error {
 	return err
 }
 
-func (server *ServerMonitor) JobBackupMysqldump(filename string) error {
+func (server *ServerMonitor) JobBackupMysqldump(filename string, key *crypto.DataKey) error {
 	cluster := server.ClusterGroup
 	var err error
 	var bckConn *sqlx.DB
@@ -1667,7 +1855,25 @@ func (server *ServerMonitor) JobBackupMysqldump(filename string) error {
 	}
 	defer f.Close()
 
-	gw := gzip.NewWriter(f)
+	fw, storageclose := cluster.StreamBackupStorage(filename, f)
+	defer storageclose()
+
+	var out io.Writer = fw
+	if key != nil {
+		ew, err := crypto.NewEncryptWriter(fw, key)
+		if err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error mysqldump backup encryption: %s", err.Error())
+			return err
+		}
+		defer func() {
+			if err := ew.Close(); err != nil {
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error closing encryption: %s", err.Error())
+			}
+		}()
+		out = ew
+	}
+
+	gw := gzip.NewWriter(out)
 	defer func() {
 		if err := gw.Flush(); err != nil {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error flushing gzip: %s", err.Error())
@@ -1677,7 +1883,14 @@ func (server *ServerMonitor) JobBackupMysqldump(filename string) error {
 		}
 	}()
 
-	teeReader := io.TeeReader(stdout, gw)
+	// Rows are counted from the dump only for the restore verification
+	var dump io.Writer = gw
+	var counter *config.RowCounter
+	if cluster.Conf.SchedulerBackupVerify {
+		counter = config.NewRowCounter("")
+		dump = io.MultiWriter(gw, counter)
+	}
+	teeReader := io.TeeReader(cluster.NewBackupThrottleReader(stdout), dump)
 
 	err = dumpCmd.Start()
 	if err != nil {
@@ -1775,11 +1988,14 @@ func (server *ServerMonitor) JobBackupMysqldump(filename string) error {
 	server.LastBackupMeta.Logical.BinLogGtid = bgtid
 	server.LastBackupMeta.Logical.BinLogFilePos = bpos
 	server.LastBackupMeta.Logical.BinLogFileName = bfile
+	if counter != nil {
+		server.LastBackupMeta.Logical.TableRows = counter.Rows
+	}
 
 	return err
 }
 
-func (server *ServerMonitor) JobBackupMyDumper(outputdir string) error {
+func (server *ServerMonitor) JobBackupMyDumper(outputdir string, key *crypto.DataKey) error {
 	cluster := server.ClusterGroup
 	var err error
 	var bckConn *sqlx.DB
@@ -1797,6 +2013,12 @@ func (server *ServerMonitor) JobBackupMyDumper(outputdir string) error {
 		}
 	}
 
+	encryptArgs, encryptEnv, err := cluster.GetMyDumperEncryption(dumper, key)
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error mydumper backup encryption: %s", err)
+		return err
+	}
+
 	//Block DDL For Backup
 	if server.IsMariaDB() && server.DBVersion.GreaterEqual("10.4") && dumper.Lower("0.12.3") && cluster.Conf.BackupLockDDL {
 		bckConn, err = server.GetNewDBConn()
@@ -1816,13 +2038,15 @@ func (server *ServerMonitor) JobBackupMyDumper(outputdir string) error {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Blocking DDL via BACKUP STAGE")
 	}
 
-	threads := strconv.Itoa(cluster.Conf.BackupLogicalDumpThreads)
+	threads := strconv.Itoa(cluster.Conf.GetBackupThreads(cluster.Conf.BackupLogicalDumpThreads))
 	myargs := strings.Split(strings.ReplaceAll(cluster.Conf.BackupMyDumperOptions, "  ", " "), " ")
 	if dumper.GreaterEqual("0.15.3") {
 		myargs = append(myargs, "--clear")
 	}
 	myargs = append(myargs, "--outputdir", outputdir, "--threads", threads, "--host", misc.Unbracket(server.Host), "--port", server.Port, "--user", cluster.GetDbUser(), "--password", cluster.GetDbPass(), "--regex", "^(?!(replication_manager_schema\\.jobs$)).*")
+	myargs = append(myargs, encryptArgs...)
 	dumpCmd := exec.Command(cluster.GetMyDumperPath(), myargs...)
+	dumpCmd.Env = encryptEnv
 
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "%s", strings.Replace(dumpCmd.String(), cluster.GetDbPass(), "XXXX", 1))
 	stdoutIn, _ := dumpCmd.StdoutPipe()
@@ -1850,6 +2074,20 @@ func (server *ServerMonitor) JobBackupMyDumper(outputdir string) error {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error parsing mydumper metadata: %s", err.Error())
 	}
 
+	if cluster.Conf.SchedulerBackupVerify {
+		if rows, err := server.GetMyDumperTableRows(outputdir); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Error counting mydumper backup rows: %s", err)
+		} else {
+			server.LastBackupMeta.Logical.TableRows = rows
+		}
+	}
+
+	// The metadata file is not written by the mydumper threads
+	if err = cluster.EncryptBackupDir(outputdir, key); err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Error mydumper backup encryption: %s", err)
+		return err
+	}
+
 	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Success backup data via mydumper. Setting logical cookie")
 	server.SetBackupLogicalCookie(config.ConstBackupLogicalTypeMydumper)
 
@@ -1869,7 +2107,7 @@ func (server *ServerMonitor) JobBackupDumpling(outputdir string) error {
 	conf.Port, _ = strconv.Atoi(server.Port)
 	conf.Password = cluster.GetDbPass()
 
-	conf.Threads = cluster.Conf.BackupLogicalDumpThreads
+	conf.Threads = cluster.Conf.GetBackupThreads(cluster.Conf.BackupLogicalDumpThreads)
 	conf.FileSize = 1000
 	conf.StatementSize = dumplingext.UnspecifiedSize
 	conf.OutputDirPath = outputdir
@@ -1963,6 +2201,12 @@ func (server *ServerMonitor) JobBackupLogical() error {
 		return server.JobBackupLogical()
 	}
 
+	key, err := cluster.NewBackupDataKey()
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Cancel logical backup of %s: %s", server.URL, err)
+		return err
+	}
+
 	cluster.SetInLogicalBackupState(true)
 	start := time.Now()
 	var prevId int64
@@ -1972,7 +2216,7 @@ func (server *ServerMonitor) JobBackupLogical() error {
 	}
 
 	// Remove from backup list, since the file will be replaced
-	if !cluster.Conf.BackupKeepUntilValid {
+	if !cluster.IsBackupKeptUntilValid() {
 		cluster.BackupMetaMap.Delete(prevId)
 	}
 
@@ -2013,12 +2257,13 @@ func (server *ServerMonitor) JobBackupLogical() error {
 			filename := server.GetMyBackupDirectory() + "mysqldump.sql.gz"
 			server.LastBackupMeta.Logical.Dest = filename
 			server.LastBackupMeta.Logical.Compressed = true
-			if cluster.Conf.BackupKeepUntilValid {
+			cluster.SetBackupEncryption(server.LastBackupMeta.Logical, key)
+			if cluster.IsBackupKeptUntilValid() {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Rename previous backup to .old")
 				exec.Command("mv", filename, filename+".old").Run()
 			}
 
-			err = server.JobBackupMysqldump(filename)
+			err = server.JobBackupMysqldump(filename, key)
 			if err != nil {
 				if e2 := server.JobsUpdateState(task, err.Error(), 5, 1); e2 != nil {
 					cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Task only updated in runtime. Error while writing to jobs table: %s", e2.Error())
@@ -2038,7 +2283,7 @@ func (server *ServerMonitor) JobBackupLogical() error {
 		case config.ConstBackupLogicalTypeDumpling:
 			outputdir := server.GetMyBackupDirectory() + "dumpling"
 			server.LastBackupMeta.Logical.Dest = outputdir
-			if cluster.Conf.BackupKeepUntilValid {
+			if cluster.IsBackupKeptUntilValid() {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Rename previous backup to .old")
 				exec.Command("mv", outputdir, outputdir+".old").Run()
 			}
@@ -2064,11 +2309,12 @@ func (server *ServerMonitor) JobBackupLogical() error {
 			outputdir := server.GetMyBackupDirectory() + "mydumper"
 			server.LastBackupMeta.Logical.Dest = outputdir
 			server.LastBackupMeta.Logical.Compressed = true
-			if cluster.Conf.BackupKeepUntilValid {
+			cluster.SetBackupEncryption(server.LastBackupMeta.Logical, key)
+			if cluster.IsBackupKeptUntilValid() {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Rename previous backup to .old")
 				exec.Command("mv", outputdir, outputdir+".old").Run()
 			}
-			err = server.JobBackupMyDumper(outputdir + "/")
+			err = server.JobBackupMyDumper(outputdir+"/", key)
 			if err != nil {
 				if e2 := server.JobsUpdateState(task, err.Error(), 5, 1); e2 != nil {
 					cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Task only updated in runtime. Error while writing to jobs table: %s", e2.Error())
@@ -2344,14 +2590,13 @@ func (server *ServerMonitor) JobBackupBinlog(binlogfile string, isPurge bool) er
 
 	//Skip setting in backup state due to batch purging
 	if !isPurge {
-		if cluster.IsInBackup() {
+		if cluster.IsInBackup() || !cluster.TryInBinlogBackupState() {
 			cluster.SetState("WARN0110", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(cluster.GetErrorList()["WARN0110"], "Binary Log", cluster.Conf.BinlogCopyMode, server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
 			time.Sleep(1 * time.Second)
 
 			return server.JobBackupBinlog(binlogfile, isPurge)
 		}
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Initiating backup binlog for %s", binlogfile)
-		cluster.SetInBinlogBackupState(true)
 		defer cluster.SetInBinlogBackupState(false)
 	}
 
@@ -2392,6 +2637,11 @@ func (server *ServerMonitor) JobBackupBinlog(binlogfile string, isPurge bool) er
 		return err
 	}
 
+	if err := server.EncryptBinlogBackup(binlogfile); err != nil {
+		return err
+	}
+	cluster.UploadBackupFile(server.GetMyBackupDirectory() + binlogfile)
+
 	//Skip copying to resting when purge due to batching
 	if !isPurge {
 		if idx := slices.Index(server.BinaryLogMetaToWrite, binlogfile); idx == -1 {
@@ -2415,14 +2665,13 @@ func (server *ServerMonitor) JobBackupBinlogPurge(binlogfile string) error {
 		return errors.New("Copy binlog not enable")
 	}
 
-	if cluster.IsInBackup() {
+	if cluster.IsInBackup() || !cluster.TryInBinlogBackupState() {
 		cluster.SetState("WARN0110", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(cluster.GetErrorList()["WARN0110"], "Binary Log", cluster.Conf.BinlogCopyMode, server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
 		time.Sleep(1 * time.Second)
 
 		return server.JobBackupBinlogPurge(binlogfile)
 	}
 
-	cluster.SetInBinlogBackupState(true)
 	defer cluster.SetInBinlogBackupState(false)
 
 	binlogfilestart, _ := strconv.Atoi(strings.Split(binlogfile, ".")[1])
@@ -2448,9 +2697,16 @@ func (server *ServerMonitor) JobBackupBinlogPurge(binlogfile string) error {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlErr, "Failed to read backup directory of %s,%s", server.URL, err.Error())
 	}
 
+	// Binary logs needed to roll forward from the oldest full backup are kept
+	start := cluster.GetRollForwardBinlogs()[server.URL]
+	// The binary log being written by the archiver is not complete
+	archiving := ""
+	if cluster.IsBinlogArchived(server) {
+		archiving = cluster.GetBinlogArchiverStatus().File
+	}
 	for _, file := range files {
 		_, ok := keeping[file.Name()]
-		if strings.HasPrefix(file.Name(), prefix) && !ok {
+		if strings.HasPrefix(file.Name(), prefix) && !ok && !isRollForwardBinlog(start, file.Name()) && file.Name() != archiving {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Purging binlog file from backup dir %s", file.Name())
 			if err := os.Remove(server.GetMyBackupDirectory() + "/" + file.Name()); err == nil {
 				server.BinaryLogMetaToRemove = append(server.BinaryLogMetaToRemove, file.Name())
@@ -2599,7 +2855,7 @@ func (server *ServerMonitor) JobBackupBinlogSSH(binlogfile string, isPurge bool)
 
 	//Skip setting in backup state due to batch purging
 	if !isPurge {
-		if cluster.IsInBackup() {
+		if cluster.IsInBackup() || !cluster.TryInBinlogBackupState() {
 			cluster.SetState("WARN0110", state.State{ErrType: "WARNING", ErrDesc: fmt.Sprintf(cluster.GetErrorList()["WARN0110"], "Binary Log", cluster.Conf.BinlogCopyMode, server.URL), ErrFrom: "JOB", ServerUrl: server.URL})
 			time.Sleep(1 * time.Second)
 
@@ -2607,7 +2863,6 @@ func (server *ServerMonitor) JobBackupBinlogSSH(binlogfile string, isPurge bool)
 		}
 
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Initiating backup binlog for %s", binlogfile)
-		cluster.SetInBinlogBackupState(true)
 		defer cluster.SetInBinlogBackupState(false)
 	}
 
@@ -2648,6 +2903,11 @@ func (server *ServerMonitor) JobBackupBinlogSSH(binlogfile string, isPurge bool)
 		return err
 	}
 
+	if err := server.EncryptBinlogBackup(binlogfile); err != nil {
+		return err
+	}
+	cluster.UploadBackupFile(server.GetMyBackupDirectory() + binlogfile)
+
 	//Skip copying to resting when purge due to batching
 	if !isPurge {
 		if idx := slices.Index(server.BinaryLogMetaToWrite, binlogfile); idx == -1 {
@@ -2692,6 +2952,12 @@ func (server *ServerMonitor) InitiateJobBackupBinlog(binlogfile string, isPurge
 }
 
 func (server *ServerMonitor) WaitAndSendSST(task string, filename string, loop int) error {
+	return server.WaitAndSendSSTChain(task, []string{filename}, loop)
+}
+
+// WaitAndSendSSTChain sends a full backup followed by the incremental backups
+// to apply on it once the reseed job is running
+func (server *ServerMonitor) WaitAndSendSSTChain(task string, filenames []string, loop int) error {
 	cluster := server.ClusterGroup
 	var err error
 
@@ -2719,7 +2985,7 @@ func (server *ServerMonitor) WaitAndSendSST(task string, filename string, loop i
 	if count > 0 {
 		server.JobsUpdateState(task, "processing", 1, 0)
 		go func() {
-			err := cluster.SSTRunSender(filename, server)
+			err := cluster.SSTRunSenderChain(filenames, server)
 			if err != nil {
 				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModSST, config.LvlErr, err.Error())
 				server.JobsUpdateState(task, err.Error(), 5, 0)
@@ -2729,7 +2995,7 @@ func (server *ServerMonitor) WaitAndSendSST(task string, filename string, loop i
 	} else {
 		if loop < 10 {
 			loop++
-			return server.WaitAndSendSST(task, filename, loop)
+			return server.WaitAndSendSSTChain(task, filenames, loop)
 		}
 	}
 
@@ -2756,7 +3022,12 @@ func (server *ServerMonitor) ProcessReseedPhysical(task string) error {
 		return errors.New("Slave is in super read-only")
 	}
 
-	useMaster := true
+	pitrfile, err := server.GetPointInTimeBackupFile()
+	if err != nil {
+		return fmt.Errorf("Cancelling reseed. %s", err)
+	}
+
+	useMaster := pitrfile == ""
 	backupext := ".xbtream"
 	if cluster.Conf.CompressBackups {
 		backupext = backupext + ".gz"
@@ -2764,9 +3035,12 @@ func (server *ServerMonitor) ProcessReseedPhysical(task string) error {
 
 	file := cluster.Conf.BackupPhysicalType + backupext
 	backupfile := master.GetMyBackupDirectory() + file
+	if pitrfile != "" {
+		backupfile = pitrfile
+	}
 
 	bckserver := cluster.GetBackupServer()
-	if bckserver != nil && bckserver.HasBackupTypeCookie(cluster.Conf.BackupPhysicalType) {
+	if useMaster && bckserver != nil && bckserver.HasBackupTypeCookie(cluster.Conf.BackupPhysicalType) {
 		if _, err := os.Stat(bckserver.GetMyBackupDirectory() + file); err == nil {
 			backupfile = bckserver.GetMyBackupDirectory() + file
 			useMaster = false
@@ -2784,10 +3058,18 @@ func (server *ServerMonitor) ProcessReseedPhysical(task string) error {
 		}
 	}
 
-	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Sending master physical backup to reseed %s", server.URL)
+	increments, err := server.GetReseedBackupChain(backupfile)
+	if err != nil {
+		if server.PointInTimeMeta.IsInPITR {
+			return fmt.Errorf("Cancelling reseed. %s", err)
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Reseed %s without incremental backups: %s", server.URL, err)
+	}
+
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Sending master physical backup and %d incremental backups to reseed %s", len(increments), server.URL)
 
 	go func() {
-		err := server.WaitAndSendSST(task, backupfile, 0)
+		err := server.WaitAndSendSSTChain(task, append([]string{backupfile}, increments...), 0)
 		if err != nil {
 			if server.HasReseedingState(task) {
 				server.SetInReseedBackup("")
@@ -2935,6 +3217,7 @@ func (server *ServerMonitor) ParseLogEntries(entry config.LogEntry, mod int, tas
 	}
 
 	binRegex := regexp.MustCompile(`filename '([^']+)', position '([^']+)', GTID of the last change '([^']+)'`)
+	lsnRegex := regexp.MustCompile(`The latest check point \(for incremental\): '(\d+)'`)
 	startRegex := regexp.MustCompile(`Job [^']+ initiated`)
 	endRegex := regexp.MustCompile(`Job [^']+ ended with state`)
 
@@ -2957,6 +3240,9 @@ func (server *ServerMonitor) ParseLogEntries(entry config.LogEntry, mod int, tas
 						server.LastBackupMeta.Physical.BinLogFilePos, _ = strconv.ParseUint(matches[2], 10, 64)
 						server.LastBackupMeta.Physical.BinLogFileName = matches[1]
 					}
+					if matches := lsnRegex.FindStringSubmatch(line); matches != nil {
+						server.LastBackupMeta.Physical.ToLSN, _ = strconv.ParseUint(matches[1], 10, 64)
+					}
 				}
 				cluster.LogModulePrintf(cluster.Conf.Verbose, mod, config.LvlDbg, "[%s] %s", server.URL, line)
 			}
@@ -3000,6 +3286,7 @@ func (server *ServerMonitor) WriteBackupMetadata(backtype config.BackupMethod) {
 			time.Sleep(time.Second)
 		}
 		lastmeta.Completed = true
+		cluster.SetBackupChecksum(lastmeta)
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Metadata completed: %v", lastmeta)
 	} else {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Error occured in backup, writing incomplete metadata for backup in %s", server.URL)
@@ -3010,20 +3297,45 @@ func (server *ServerMonitor) WriteBackupMetadata(backtype config.BackupMethod) {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to marshall metadata for backup in %s: %s", server.URL, err.Error())
 	}
 
-	err = os.WriteFile(server.GetMyBackupDirectory()+lastmeta.BackupTool+".meta.json", bjson, 0644)
+	metafile := server.GetBackupMetaFile(lastmeta)
+	err = os.WriteFile(metafile, bjson, 0644)
 	if err != nil {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlWarn, "Failed to write metadata for backup in %s: %s", server.URL, err.Error())
 	} else {
 		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Created metadata for backup in %s", server.URL)
 	}
+	if lastmeta.Completed {
+		server.UploadBackup(lastmeta, metafile)
+	}
 
-	//Don't change river
-	if cluster.Conf.BackupKeepUntilValid && lastmeta.BackupTool != config.ConstBackupLogicalTypeRiver {
+	// Increments don't replace a previous file, an invalid one is dropped from the chain
+	if lastmeta.IsIncremental() {
+		if !lastmeta.Completed {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Error occured in %s backup, removing it from the backup chain.", lastmeta.BackupStrategy)
+			os.Remove(lastmeta.Dest)
+			os.Remove(metafile)
+			cluster.BackupMetaMap.Delete(lastmeta.Id)
+		}
+		return
+	}
+
+	// The replaced backup and its chain are archived with backup-retention
+	archived := lastmeta.Completed && server.ArchiveLastBackup(lastmeta)
+
+	// A new full backup ends the chain of the previous one
+	if lastmeta.Completed && backtype == config.BackupMethodPhysical && cluster.IsBackupKeptUntilValid() {
+		server.PurgeBackupChain(lastmeta.BackupTool)
+	}
+
+	//Don't change river and script
+	if cluster.IsBackupKeptUntilValid() && lastmeta.HasBackupFile() {
 		if lastmeta.Completed {
-			// Delete previous meta with same type
-			cluster.BackupMetaMap.Delete(lastmeta.Previous)
-			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Backup valid, removing old backup.")
-			exec.Command("rm", "-r", lastmeta.Dest+".old").Run()
+			if !archived {
+				// Delete previous meta with same type
+				cluster.BackupMetaMap.Delete(lastmeta.Previous)
+				cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Backup valid, removing old backup.")
+				exec.Command("rm", "-r", lastmeta.Dest+".old").Run()
+			}
 		} else {
 			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModTask, config.LvlInfo, "Error occured in backup, rolling back to old backup.")
 			exec.Command("mv", lastmeta.Dest, lastmeta.Dest+".err").Run()
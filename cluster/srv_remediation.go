@@ -0,0 +1,435 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/audit"
+	"github.com/signal18/replication-manager/utils/dbhelper"
+	"github.com/signal18/replication-manager/utils/state"
+)
+
+// Events read from the source to find the failed transaction
+const maxRemediationEvents = 10000
+
+// failedRow is a row image of the transaction a replica failed to apply
+type failedRow struct {
+	schema string
+	table  string
+	row    config.ChecksumRow
+}
+
+// failedTransaction are the tables and the row images of the transaction a
+// replica failed to apply, read from the binary logs of its source
+type failedTransaction struct {
+	tables []*config.ChecksumTable
+	rows   []failedRow
+}
+
+func (trx *failedTransaction) addTable(schema string, table string) {
+	for _, t := range trx.tables {
+		if t.Schema == schema && t.Table == table {
+			return
+		}
+	}
+	trx.tables = append(trx.tables, &config.ChecksumTable{Schema: schema, Table: table})
+}
+
+func (trx *failedTransaction) getTableNames() string {
+	names := make([]string, len(trx.tables))
+	for i, t := range trx.tables {
+		names[i] = t.GetName()
+	}
+	return strings.Join(names, ",")
+}
+
+// GetRemediations returns the replication error remediations of the cluster
+func (cluster *Cluster) GetRemediations(filter audit.Filter) ([]audit.Record, error) {
+	if cluster.RemediationLog == nil {
+		return nil, errors.New("No remediation log")
+	}
+	return cluster.RemediationLog.Query(filter, nil)
+}
+
+// RemediateReplicationError runs the action of replication-error-policies
+// matching the SQL error of a replica. A failed event is remediated once and
+// the remediations of a replica are limited to
+// replication-error-policy-max-actions in replication-error-policy-window,
+// each one is recorded in remediation.jsonl. It returns handled when a policy
+// matches the error and is not rate limited, and remediated when its action
+// ran without error on this call.
+func (server *ServerMonitor) RemediateReplicationError() (handled bool, remediated bool) {
+	cluster := server.ClusterGroup
+	if cluster.Conf.ReplicationErrorPolicies == "" || server.Conn == nil {
+		return false, false
+	}
+	ss, _, err := dbhelper.GetSlaveStatus(server.Conn, cluster.Conf.MasterConn, server.DBVersion)
+	if err != nil {
+		return false, false
+	}
+	errno := ss.LastSQLErrno.String
+	action, ok := cluster.Conf.GetReplicationErrorPolicies()[errno]
+	if !ok {
+		return false, false
+	}
+	event := ss.RelayMasterLogFile.String + ":" + ss.ExecMasterLogPos.String
+	if server.remediatedEvent == event {
+		return true, false
+	}
+	if !server.isRemediationAllowed(errno, action) {
+		return false, false
+	}
+	server.remediatedEvent = event
+
+	rec := audit.Record{
+		Time:     time.Now(),
+		User:     "replication-manager",
+		Cluster:  cluster.Name,
+		Protocol: audit.ProtocolRemediation,
+		Method:   action,
+		Endpoint: server.URL,
+		Params:   map[string]string{"errno": errno, "error": ss.LastSQLError.String, "position": event},
+		Result:   audit.ResultOk,
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Remediating replication error %s on %s with %s: %s", errno, server.URL, action, ss.LastSQLError.String)
+	err = server.runRemediation(action, ss, rec.Params)
+	rec.Duration = time.Since(rec.Time).Milliseconds()
+	if err != nil {
+		rec.Result = audit.ResultError
+		rec.Error = err.Error()
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Remediation %s of replication error %s on %s failed: %s", action, errno, server.URL, err)
+	} else {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Remediation %s of replication error %s on %s done", action, errno, server.URL)
+	}
+	if cluster.RemediationLog != nil {
+		if err := cluster.RemediationLog.Append(rec); err != nil {
+			cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Remediation log write failed: %s", err)
+		}
+	}
+	return true, err == nil
+}
+
+// isRemediationAllowed tests that the replica is below
+// replication-error-policy-max-actions remediations in the window
+func (server *ServerMonitor) isRemediationAllowed(errno string, action string) bool {
+	cluster := server.ClusterGroup
+	if cluster.Conf.ReplicationErrorPolicyMaxActions <= 0 || cluster.RemediationLog == nil {
+		return true
+	}
+	filter := audit.Filter{From: time.Now().Add(-time.Duration(cluster.Conf.ReplicationErrorPolicyWindow) * time.Second)}
+	records, err := cluster.RemediationLog.Query(filter, func(rec *audit.Record) bool { return rec.Endpoint == server.URL })
+	if err != nil {
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Remediation log read failed: %s", err)
+		return false
+	}
+	if len(records) >= cluster.Conf.ReplicationErrorPolicyMaxActions {
+		cluster.SetState("WARN0134", state.State{ErrType: config.LvlWarn, ErrDesc: fmt.Sprintf(clusterError["WARN0134"], errno, action, server.URL, len(records), cluster.Conf.ReplicationErrorPolicyWindow), ErrFrom: "MON", ServerUrl: server.URL})
+		return false
+	}
+	return true
+}
+
+func (server *ServerMonitor) runRemediation(action string, ss dbhelper.SlaveStatus, params map[string]string) error {
+	cluster := server.ClusterGroup
+	switch action {
+	case config.RemediationSkip:
+		return server.skipFailedTransaction(params)
+	case config.RemediationReapply:
+		trx, err := server.readFailedTransaction(ss)
+		if err != nil {
+			return err
+		}
+		params["tables"] = trx.getTableNames()
+		if err := server.reapplyFailedTransaction(trx); err != nil {
+			return err
+		}
+		return server.skipFailedTransaction(params)
+	case config.RemediationResync:
+		trx, err := server.readFailedTransaction(ss)
+		if err != nil {
+			return err
+		}
+		params["tables"] = trx.getTableNames()
+		// The replica differences are only repaired in apply mode
+		if err := cluster.newTableChecksum(trx.tables, config.ChecksumRepairApply); err != nil {
+			return err
+		}
+		if err := server.skipFailedTransaction(params); err != nil {
+			cluster.endTableChecksum(err)
+			return err
+		}
+		go cluster.runTableChecksum()
+		return nil
+	case config.RemediationReseedLogicalBackup:
+		return server.JobReseedLogicalBackup("default")
+	case config.RemediationReseedPhysicalBackup:
+		return server.JobReseedPhysicalBackup("default")
+	case config.RemediationReseedLogicalMaster:
+		return server.RejoinDirectDump()
+	}
+	return fmt.Errorf("Unknown remediation %s", action)
+}
+
+// skipFailedTransaction skips the transaction the replica failed to apply,
+// with MySQL GTID an empty transaction is committed in place of the failed
+// GTID as the replication skip counter is not allowed
+func (server *ServerMonitor) skipFailedTransaction(params map[string]string) error {
+	cluster := server.ClusterGroup
+	if server.HasMySQLGTID() {
+		gtid, err := server.getFailedTransactionGtid()
+		if err != nil {
+			return err
+		}
+		params["gtid"] = gtid
+		if err := server.execErrantReconcileStep(config.GetEmptyTransactionStatements([]string{gtid})); err != nil {
+			return err
+		}
+	} else {
+		logs, err := server.StopSlave()
+		cluster.LogSQL(logs, err, server.URL, "Remediation", config.LvlErr, "Could not stop slave on server %s, %s", server.URL, err)
+		if err != nil {
+			return err
+		}
+		logs, err = dbhelper.SkipBinlogEvent(server.Conn, cluster.Conf.MasterConn, server.DBVersion)
+		cluster.LogSQL(logs, err, server.URL, "Remediation", config.LvlErr, "Could not skip event on server %s, %s", server.URL, err)
+		if err != nil {
+			return err
+		}
+	}
+	logs, err := server.StartSlave()
+	cluster.LogSQL(logs, err, server.URL, "Remediation", config.LvlErr, "Could not start slave on server %s, %s", server.URL, err)
+	return err
+}
+
+// getFailedTransactionGtid returns the GTID of the transaction a worker
+// failed to apply, APPLYING_TRANSACTION in MySQL 8.0 and
+// LAST_SEEN_TRANSACTION in MySQL 5.7
+func (server *ServerMonitor) getFailedTransactionGtid() (string, error) {
+	var gtid sql.NullString
+	err := server.Conn.QueryRowx("SELECT APPLYING_TRANSACTION FROM performance_schema.replication_applier_status_by_worker WHERE LAST_ERROR_NUMBER<>0 LIMIT 1").Scan(&gtid)
+	if err != nil && err != sql.ErrNoRows {
+		err = server.Conn.QueryRowx("SELECT LAST_SEEN_TRANSACTION FROM performance_schema.replication_applier_status_by_worker WHERE LAST_ERROR_NUMBER<>0 LIMIT 1").Scan(&gtid)
+	}
+	if err == sql.ErrNoRows {
+		return "", errors.New("No failed replication worker")
+	}
+	if err != nil {
+		return "", err
+	}
+	if gtid.String == "" || gtid.String == "ANONYMOUS" {
+		return "", fmt.Errorf("No GTID for the failed transaction on %s", server.URL)
+	}
+	return gtid.String, nil
+}
+
+// readFailedTransaction reads the failed transaction of the replica in the
+// binary logs of its source. The executed position is the low-water mark of
+// parallel replication, with MySQL GTID the transaction of the failed worker
+// is searched from there, else parallel replication is refused.
+func (server *ServerMonitor) readFailedTransaction(ss dbhelper.SlaveStatus) (*failedTransaction, error) {
+	cluster := server.ClusterGroup
+	source := cluster.getReplicationSource(server)
+	if source == nil {
+		return nil, fmt.Errorf("No source found for replica %s", server.URL)
+	}
+	var failedGtid string
+	if server.HasMySQLGTID() {
+		var err error
+		failedGtid, err = server.getFailedTransactionGtid()
+		if err != nil {
+			return nil, err
+		}
+	} else if workers := server.getParallelWorkers(); workers > 0 {
+		return nil, fmt.Errorf("Failed transaction can't be located with %d parallel replication threads on %s", workers, server.URL)
+	}
+	pos, err := strconv.ParseUint(ss.ExecMasterLogPos.String, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	port, _ := strconv.Atoi(source.Port)
+	cfg := replication.BinlogSyncerConfig{
+		ServerID: uint32(cluster.Conf.ReplicationErrorPolicyBinlogServerId),
+		Flavor:   source.DBVersion.Flavor,
+		Host:     source.Host,
+		Port:     uint16(port),
+		User:     source.User,
+		Password: source.Pass,
+	}
+	if cluster.HaveDBTLSCert {
+		cfg.TLSConfig = cluster.tlsconf
+	}
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(mysql.Position{Name: ss.RelayMasterLogFile.String, Pos: uint32(pos)})
+	if err != nil {
+		return nil, err
+	}
+
+	trx := &failedTransaction{}
+	found := failedGtid == ""
+	for i := 0; i < maxRemediationEvents; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ev, err := streamer.GetEvent(ctx)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := ev.Event.(*replication.GTIDEvent); ok && failedGtid != "" {
+			sid, err := uuid.FromBytes(e.SID)
+			if err != nil {
+				return nil, err
+			}
+			found = strings.EqualFold(sid.String()+":"+strconv.FormatInt(e.GNO, 10), failedGtid)
+			continue
+		}
+		if !found {
+			continue
+		}
+		switch e := ev.Event.(type) {
+		case *replication.RowsEvent:
+			schema, table := string(e.Table.Schema), string(e.Table.Table)
+			trx.addTable(schema, table)
+			for j, values := range e.Rows {
+				if j < len(e.SkippedColumns) && len(e.SkippedColumns[j]) > 0 {
+					return nil, fmt.Errorf("Partial row image of %s.%s, binlog_row_image FULL is needed", schema, table)
+				}
+				trx.rows = append(trx.rows, failedRow{schema: schema, table: table, row: newBinlogRow(values)})
+			}
+		case *replication.XIDEvent:
+			return trx, nil
+		case *replication.QueryEvent:
+			query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+			if query == "COMMIT" {
+				return trx, nil
+			}
+			if query != "BEGIN" {
+				return nil, fmt.Errorf("Statement event in the failed transaction, row based binary logs are needed: %s", string(e.Query))
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Failed transaction %s not found in %d events", failedGtid, maxRemediationEvents)
+	}
+	return nil, fmt.Errorf("End of the failed transaction not found in %d events", maxRemediationEvents)
+}
+
+// getParallelWorkers returns the parallel replication threads of the replica
+func (server *ServerMonitor) getParallelWorkers() int {
+	name := "SLAVE_PARALLEL_WORKERS"
+	if server.IsMariaDB() {
+		name = "SLAVE_PARALLEL_THREADS"
+	} else if v, ok := server.Variables.CheckAndGet("REPLICA_PARALLEL_WORKERS"); ok {
+		workers, _ := strconv.Atoi(v)
+		return workers
+	}
+	workers, _ := strconv.Atoi(server.Variables.Get(name))
+	return workers
+}
+
+// newBinlogRow returns a row image with the text values of the columns
+func newBinlogRow(values []interface{}) config.ChecksumRow {
+	row := make(config.ChecksumRow, len(values))
+	for i, v := range values {
+		var s string
+		switch t := v.(type) {
+		case nil:
+			continue
+		case []byte:
+			s = string(t)
+		default:
+			s = fmt.Sprint(t)
+		}
+		row[i] = &s
+	}
+	return row
+}
+
+// reapplyFailedTransaction copies on the replica the current rows of its
+// source having the keys of the row images of the failed transaction, rows
+// the source no longer has are deleted. The replica writes are not binlogged.
+func (server *ServerMonitor) reapplyFailedTransaction(trx *failedTransaction) error {
+	cluster := server.ClusterGroup
+	source := cluster.getReplicationSource(server)
+	if source == nil {
+		return fmt.Errorf("No source found for replica %s", server.URL)
+	}
+	if len(trx.rows) == 0 {
+		return errors.New("No row image in the failed transaction")
+	}
+	conn, err := server.GetConnNoBinlog(server.Conn)
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		return errors.New("No database connection")
+	}
+	defer conn.Close()
+	if err := reapplyFailedRows(conn, source.Conn, trx); err != nil {
+		return err
+	}
+	cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Reapplied %d rows of %s from %s on %s", len(trx.rows), trx.getTableNames(), source.URL, server.URL)
+	return nil
+}
+
+// reapplyFailedRows writes with conn the rows of source having the keys of the
+// row images of the failed transaction, the rows source no longer has are deleted
+func reapplyFailedRows(conn *sqlx.Conn, source *sqlx.DB, trx *failedTransaction) error {
+	var err error
+	ctx := context.Background()
+	for _, t := range trx.tables {
+		t.Keys, t.Columns, err = getChecksumColumns(conn, t.Schema, t.Table)
+		if err != nil {
+			return err
+		}
+		if len(t.Keys) == 0 {
+			return fmt.Errorf("Table %s has no primary key", t.GetName())
+		}
+	}
+	for _, r := range trx.rows {
+		var t *config.ChecksumTable
+		for _, table := range trx.tables {
+			if table.Schema == r.schema && table.Table == r.table {
+				t = table
+			}
+		}
+		if len(r.row) != len(t.Columns) {
+			return fmt.Errorf("Row image of %s has %d columns, table has %d", t.GetName(), len(r.row), len(t.Columns))
+		}
+		cols := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			cols[i] = config.QuoteIdentifier(c)
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s", strings.Join(cols, ","), config.QuoteIdentifier(t.Schema), config.QuoteIdentifier(t.Table), config.GetKeyPredicate(t.Keys, "=", t.GetKeyValues(r.row)))
+		rows, err := getChecksumRows(source, query)
+		if err != nil {
+			return err
+		}
+		var row config.ChecksumRow
+		if len(rows) > 0 {
+			row = rows[0]
+		}
+		if _, err := conn.ExecContext(ctx, t.GetChecksumRepair(row, r.row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
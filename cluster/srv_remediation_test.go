@@ -0,0 +1,87 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/signal18/replication-manager/config"
+)
+
+func TestReapplyFailedRows(t *testing.T) {
+	replicaDB, replica, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replicaDB.Close()
+	sourceDB, source, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sourceDB.Close()
+
+	// the primary key is not the first column so that keys and columns can not be mixed up
+	replica.ExpectQuery("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME='PRIMARY' AND TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION").
+		WithArgs("app", "t").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	replica.ExpectQuery("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION").
+		WithArgs("app", "t").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("v").AddRow("id"))
+	source.ExpectQuery("SELECT `v`,`id` FROM `app`.`t` WHERE (`id`) = ('1')").
+		WillReturnRows(sqlmock.NewRows([]string{"v", "id"}).AddRow("new", "1"))
+	replica.ExpectExec("REPLACE INTO `app`.`t` (`v`,`id`) VALUES ('new','1')").WillReturnResult(sqlmock.NewResult(0, 1))
+	source.ExpectQuery("SELECT `v`,`id` FROM `app`.`t` WHERE (`id`) = ('2')").
+		WillReturnRows(sqlmock.NewRows([]string{"v", "id"}))
+	replica.ExpectExec("DELETE FROM `app`.`t` WHERE `id`='2' LIMIT 1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	conn, err := sqlx.NewDb(replicaDB, "mysql").Connx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	trx := &failedTransaction{}
+	trx.addTable("app", "t")
+	for _, values := range [][]interface{}{{"old", "1"}, {"gone", "2"}} {
+		trx.rows = append(trx.rows, failedRow{schema: "app", table: "t", row: newBinlogRow(values)})
+	}
+	if err := reapplyFailedRows(conn, sqlx.NewDb(sourceDB, "mysql"), trx); err != nil {
+		t.Fatalf("reapply failed: %s", err)
+	}
+	if tbl := trx.tables[0]; len(tbl.Keys) != 1 || tbl.Keys[0] != "id" || len(tbl.Columns) != 2 {
+		t.Errorf("unexpected keys %v and columns %v", tbl.Keys, tbl.Columns)
+	}
+	for _, mock := range []sqlmock.Sqlmock{replica, source} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReapplyFailedRowsNoPrimaryKey(t *testing.T) {
+	replicaDB, replica, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replicaDB.Close()
+	replica.ExpectQuery("KEY_COLUMN_USAGE").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}))
+	replica.ExpectQuery("INFORMATION_SCHEMA.COLUMNS").WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("v"))
+
+	conn, err := sqlx.NewDb(replicaDB, "mysql").Connx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	trx := &failedTransaction{tables: []*config.ChecksumTable{{Schema: "app", Table: "t"}}}
+	trx.rows = append(trx.rows, failedRow{schema: "app", table: "t", row: newBinlogRow([]interface{}{"v"})})
+	if err := reapplyFailedRows(conn, nil, trx); err == nil {
+		t.Error("expected an error on a table without primary key")
+	}
+}
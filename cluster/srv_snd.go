@@ -115,10 +115,12 @@ func (server *ServerMonitor) SendAlert() error {
 	}
 
 	a := alert.Alert{
+		Type:      alert.TypeState,
 		State:     server.State,
 		PrevState: server.PrevState,
 		Host:      server.URL,
 		Cluster:   server.GetCluster().Name,
+		Timestamp: time.Now(),
 	}
 
 	return server.ClusterGroup.SendAlert(a)
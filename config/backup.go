@@ -1,8 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,6 +28,18 @@ const (
 	BackupStrategyDifferential = 3
 )
 
+func (s BackupStrategy) String() string {
+	switch s {
+	case BackupStrategyIncremental:
+		return "incremental"
+	case BackupStrategyDifferential:
+		return "differential"
+	}
+	return "full"
+}
+
+// BackupMetadata describes a backup, incremental and differential backups
+// chain to the backup they were taken from with Previous and FromLSN
 type BackupMetadata struct {
 	Id             int64          `json:"id"`
 	StartTime      time.Time      `json:"startTime"`
@@ -42,6 +61,441 @@ type BackupMetadata struct {
 	BinLogGtid     string         `json:"binLogUuid"`
 	Completed      bool           `json:"completed"`
 	Previous       int64          `json:"previous"`
+	FromLSN        uint64         `json:"fromLsn"`
+	ToLSN          uint64         `json:"toLsn"`
+	TableRows      TableRowCounts `json:"tableRows,omitempty"`
+	VerifyState    string         `json:"verifyState"`
+	VerifyTime     time.Time      `json:"verifyTime"`
+	VerifyResult   string         `json:"verifyResult"`
+	Pinned         bool           `json:"pinned"`
+	Archived       bool           `json:"archived"`
+	Schemas        []string       `json:"schemas,omitempty"`
+	Tables         []string       `json:"tables,omitempty"`
+	SchemaObjects  []string       `json:"schemaObjects,omitempty"`
+}
+
+// TableRowCounts are the rows of the tables by schema.table counted in the
+// dump of a logical backup, a restore verification compares them to the restore
+type TableRowCounts map[string]int64
+
+// Longest statement start read to find the table of an INSERT
+const rowCounterMaxHeader = 65536
+
+// RowCounter counts by schema.table the rows of the INSERT statements of a
+// SQL dump written to it, the tables it creates start with no row. The schema
+// is followed on the USE statements of the dump. System schemas are skipped
+// as the restore verification does not count them.
+type RowCounter struct {
+	Rows   TableRowCounts
+	schema string
+	table  string
+	line   []byte
+	values bool
+	quote  byte
+	escape bool
+	depth  int
+}
+
+// NewRowCounter returns a counter of the rows of a dump starting in schema
+func NewRowCounter(schema string) *RowCounter {
+	return &RowCounter{Rows: make(TableRowCounts), schema: schema}
+}
+
+// IsRowCountedSchema tells if the rows of a schema are counted
+func IsRowCountedSchema(schema string) bool {
+	switch schema {
+	case "", "information_schema", "mysql", "performance_schema", "sys", "replication_manager_schema":
+		return false
+	}
+	return !strings.HasPrefix(schema, "#")
+}
+
+func (rc *RowCounter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if rc.values {
+			rc.readValue(c)
+			continue
+		}
+		if c == '\n' {
+			rc.line = rc.line[:0]
+			continue
+		}
+		if (c == ' ' || c == '(') && bytes.HasSuffix(rc.line, []byte(" VALUES")) && rc.readInsert() {
+			rc.values = true
+			rc.depth = 0
+			rc.quote = 0
+			rc.escape = false
+			rc.line = rc.line[:0]
+			if c == '(' {
+				rc.readValue(c)
+			}
+			continue
+		}
+		if len(rc.line) < rowCounterMaxHeader {
+			rc.line = append(rc.line, c)
+		}
+		if c == ';' && bytes.HasPrefix(rc.line, []byte("USE ")) {
+			if schema, _, ok := readIdentifier(rc.line[4:]); ok {
+				rc.schema = schema
+			}
+		}
+		if c == ' ' && bytes.HasPrefix(rc.line, []byte("CREATE TABLE ")) {
+			if schema, table, ok := rc.readTable(rc.line[13:]); ok && IsRowCountedSchema(schema) {
+				if _, ok := rc.Rows[schema+"."+table]; !ok {
+					rc.Rows[schema+"."+table] = 0
+				}
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// readValue counts the tuples of the values of an INSERT until its end
+func (rc *RowCounter) readValue(c byte) {
+	switch {
+	case rc.escape:
+		rc.escape = false
+	case rc.quote != 0:
+		if c == '\\' {
+			rc.escape = true
+		} else if c == rc.quote {
+			rc.quote = 0
+		}
+	case c == '\'' || c == '"':
+		rc.quote = c
+	case c == '(':
+		if rc.depth == 0 && rc.table != "" {
+			rc.Rows[rc.table]++
+		}
+		rc.depth++
+	case c == ')':
+		rc.depth--
+	case c == ';' && rc.depth == 0:
+		rc.values = false
+	}
+}
+
+// readInsert reads the table of the INSERT or REPLACE statement of the line
+func (rc *RowCounter) readInsert() bool {
+	if !bytes.HasPrefix(rc.line, []byte("INSERT ")) && !bytes.HasPrefix(rc.line, []byte("REPLACE ")) {
+		return false
+	}
+	i := bytes.Index(rc.line, []byte("INTO "))
+	if i < 0 {
+		return false
+	}
+	rc.table = ""
+	if schema, table, ok := rc.readTable(rc.line[i+5:]); ok && IsRowCountedSchema(schema) {
+		rc.table = schema + "." + table
+		if _, ok := rc.Rows[rc.table]; !ok {
+			rc.Rows[rc.table] = 0
+		}
+	}
+	return true
+}
+
+// readTable reads a table name, qualified or in the current schema
+func (rc *RowCounter) readTable(b []byte) (string, string, bool) {
+	name, rest, ok := readIdentifier(b)
+	if !ok {
+		return "", "", false
+	}
+	if len(rest) > 0 && rest[0] == '.' {
+		table, _, ok := readIdentifier(rest[1:])
+		return name, table, ok
+	}
+	return rc.schema, name, true
+}
+
+// readIdentifier reads a backquoted identifier, a doubled backquote is a
+// backquote of the name
+func readIdentifier(b []byte) (string, []byte, bool) {
+	if len(b) == 0 || b[0] != '`' {
+		return "", b, false
+	}
+	var name []byte
+	for i := 1; i < len(b); i++ {
+		if b[i] != '`' {
+			name = append(name, b[i])
+		} else if i+1 < len(b) && b[i+1] == '`' {
+			name = append(name, '`')
+			i++
+		} else {
+			return string(name), b[i+1:], true
+		}
+	}
+	return "", b, false
+}
+
+const (
+	BackupVerifyRunning  = "running"
+	BackupVerifyVerified = "verified"
+	BackupVerifyFailed   = "failed"
+)
+
+// CompareRows returns the tables missing in the restored counts or whose rows
+// differ from the recorded count by more than tolerance percent, an empty
+// table must be restored empty
+func (rc TableRowCounts) CompareRows(restored TableRowCounts, tolerance int) []string {
+	errs := make([]string, 0)
+	for table, rows := range rc {
+		got, ok := restored[table]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("table %s missing", table))
+			continue
+		}
+		diff := got - rows
+		if diff < 0 {
+			diff = -diff
+		}
+		if (rows > 0 && got == 0) || diff*100 > rows*int64(tolerance) {
+			errs = append(errs, fmt.Sprintf("table %s has %d rows, %d expected", table, got, rows))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// IsIncremental tells if the backup needs the backups it chains to for a restore
+func (bm *BackupMetadata) IsIncremental() bool {
+	return bm.BackupStrategy == BackupStrategyIncremental || bm.BackupStrategy == BackupStrategyDifferential
+}
+
+// HasBackupFile tells if the backup is a file or directory replaced by the
+// next backup of its tool, the river and script backups are not
+func (bm *BackupMetadata) HasBackupFile() bool {
+	return bm.BackupTool != ConstBackupLogicalTypeRiver && bm.BackupTool != "script"
+}
+
+// GetLastFullBackup returns the last completed full backup of a tool and a
+// source having the LSN checkpoint needed to chain increments
+func (b *BackupMetaMap) GetLastFullBackup(backupTool string, source string) *BackupMetadata {
+	var result *BackupMetadata
+	b.Callback(func(key int64, backup *BackupMetadata) bool {
+		if backup.BackupTool == backupTool && backup.Source == source && !backup.IsIncremental() && backup.Completed && backup.ToLSN > 0 {
+			if result == nil || result.Id < backup.Id {
+				result = backup
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// GetBackupChain returns the backups to restore in order to restore a backup,
+// the full backup first and the backup itself last
+func (b *BackupMetaMap) GetBackupChain(id int64) ([]*BackupMetadata, error) {
+	chain := make([]*BackupMetadata, 0)
+	visited := make(map[int64]bool)
+	for {
+		backup := b.Get(id)
+		if backup == nil {
+			return nil, fmt.Errorf("Backup %d of the chain not found", id)
+		}
+		if !backup.Completed {
+			return nil, fmt.Errorf("Backup %d of the chain is not completed", id)
+		}
+		if visited[id] {
+			return nil, fmt.Errorf("Backup %d chains to itself", id)
+		}
+		visited[id] = true
+		chain = append([]*BackupMetadata{backup}, chain...)
+		if !backup.IsIncremental() {
+			return chain, nil
+		}
+		id = backup.Previous
+	}
+}
+
+// GetLastChainBackup returns the last completed backup chained to a full
+// backup, or the full backup itself when nothing is chained to it
+func (b *BackupMetaMap) GetLastChainBackup(full *BackupMetadata) *BackupMetadata {
+	result := full
+	b.Callback(func(key int64, backup *BackupMetadata) bool {
+		if !backup.IsIncremental() || !backup.Completed || backup.Id < result.Id {
+			return true
+		}
+		if chain, err := b.GetBackupChain(backup.Id); err == nil && chain[0].Id == full.Id {
+			result = backup
+		}
+		return true
+	})
+	return result
+}
+
+// Types of the artifacts listed by the backup catalog
+const (
+	BackupCatalogLogical  = "logical"
+	BackupCatalogPhysical = "physical"
+	BackupCatalogBinlog   = "binlog"
+	BackupCatalogRestic   = "restic"
+)
+
+// Retention classes of the backup catalog, telling what removes an artifact
+const (
+	BackupRetentionPinned     = "pinned"
+	BackupRetentionRotate     = "rotate"
+	BackupRetentionChain      = "chain"
+	BackupRetentionBinlogKeep = "binlogs-keep"
+	BackupRetentionRestic     = "restic-forget"
+	BackupRetentionLast       = "last"
+	BackupRetentionHourly     = "hourly"
+	BackupRetentionDaily      = "daily"
+	BackupRetentionWeekly     = "weekly"
+	BackupRetentionMonthly    = "monthly"
+	BackupRetentionYearly     = "yearly"
+	BackupRetentionRollFwd    = "roll-forward"
+	BackupRetentionPurge      = "purge"
+)
+
+// BackupRetentionPolicy is a grandfather-father-son policy keeping the Last
+// newest backups and the newest backup of the Hourly last hours, Daily last
+// days, Weekly last weeks, Monthly last months and Yearly last years having a
+// backup, like restic forget
+type BackupRetentionPolicy struct {
+	Last    int `json:"last"`
+	Hourly  int `json:"hourly"`
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+	Yearly  int `json:"yearly"`
+}
+
+// Select returns the retention class of backups of a series taken at times,
+// sorted from the newest to the oldest, BackupRetentionPurge when no rule
+// keeps the backup. The newest backup is always kept.
+func (p BackupRetentionPolicy) Select(times []time.Time) []string {
+	type rule struct {
+		class  string
+		keep   int
+		bucket func(t time.Time) string
+		last   string
+	}
+	rules := []*rule{
+		{class: BackupRetentionLast, keep: p.Last, bucket: func(t time.Time) string { return t.String() }},
+		{class: BackupRetentionHourly, keep: p.Hourly, bucket: func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{class: BackupRetentionDaily, keep: p.Daily, bucket: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{class: BackupRetentionWeekly, keep: p.Weekly, bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+		{class: BackupRetentionMonthly, keep: p.Monthly, bucket: func(t time.Time) string { return t.Format("2006-01") }},
+		{class: BackupRetentionYearly, keep: p.Yearly, bucket: func(t time.Time) string { return t.Format("2006") }},
+	}
+	classes := make([]string, len(times))
+	for i, t := range times {
+		classes[i] = BackupRetentionPurge
+		for _, r := range rules {
+			bucket := r.bucket(t.Local())
+			if r.keep <= 0 || bucket == r.last {
+				continue
+			}
+			r.keep--
+			r.last = bucket
+			if classes[i] == BackupRetentionPurge {
+				classes[i] = r.class
+			}
+		}
+	}
+	if len(classes) > 0 && classes[0] == BackupRetentionPurge {
+		classes[0] = BackupRetentionLast
+	}
+	return classes
+}
+
+// BackupCatalogEntry is an artifact of the backup catalog of a cluster, the
+// metadata of logical and physical backups, a binary log copied to the backup
+// directory or a restic snapshot
+type BackupCatalogEntry struct {
+	Id             string    `json:"id"`
+	Type           string    `json:"type"`
+	Tool           string    `json:"tool"`
+	Strategy       string    `json:"strategy"`
+	Source         string    `json:"source"`
+	Dest           string    `json:"dest"`
+	StartTime      time.Time `json:"startTime"`
+	EndTime        time.Time `json:"endTime"`
+	Size           int64     `json:"size"`
+	Checksum       string    `json:"checksum"`
+	BinLogFileName string    `json:"binLogFileName"`
+	BinLogFilePos  uint64    `json:"binLogFilePos"`
+	BinLogGtid     string    `json:"binLogGtid"`
+	Compressed     bool      `json:"compressed"`
+	Encrypted      bool      `json:"encrypted"`
+	Completed      bool      `json:"completed"`
+	Pinned         bool      `json:"pinned"`
+	Previous       string    `json:"previous"`
+	VerifyState    string    `json:"verifyState"`
+	RetentionClass string    `json:"retentionClass"`
+	Scope          string    `json:"scope,omitempty"`
+}
+
+// GetRetentionClass returns the retention class of a logical or physical
+// backup: the full backups are replaced by the next backup of their tool and
+// the incremental backups are purged with the full backup they chain to
+func (bm *BackupMetadata) GetRetentionClass() string {
+	if bm.Pinned {
+		return BackupRetentionPinned
+	}
+	if bm.IsIncremental() {
+		return BackupRetentionChain
+	}
+	return BackupRetentionRotate
+}
+
+// GetCatalogEntry returns the backup catalog entry of a logical or physical backup
+func (bm *BackupMetadata) GetCatalogEntry() *BackupCatalogEntry {
+	entry := &BackupCatalogEntry{
+		Id:             strconv.FormatInt(bm.Id, 10),
+		Type:           BackupCatalogLogical,
+		Tool:           bm.BackupTool,
+		Strategy:       bm.BackupStrategy.String(),
+		Source:         bm.Source,
+		Dest:           bm.Dest,
+		StartTime:      bm.StartTime,
+		EndTime:        bm.EndTime,
+		Size:           bm.Size,
+		Checksum:       bm.Checksum,
+		BinLogFileName: bm.BinLogFileName,
+		BinLogFilePos:  bm.BinLogFilePos,
+		BinLogGtid:     bm.BinLogGtid,
+		Compressed:     bm.Compressed,
+		Encrypted:      bm.Encrypted,
+		Completed:      bm.Completed,
+		Pinned:         bm.Pinned,
+		VerifyState:    bm.VerifyState,
+		RetentionClass: bm.GetRetentionClass(),
+		Scope:          bm.GetScope(),
+	}
+	if bm.BackupMethod == BackupMethodPhysical {
+		entry.Type = BackupCatalogPhysical
+	}
+	if bm.IsIncremental() {
+		entry.Previous = strconv.FormatInt(bm.Previous, 10)
+	}
+	return entry
+}
+
+// SortBackupCatalog sorts the catalog entries from the newest to the oldest
+func SortBackupCatalog(entries []*BackupCatalogEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].StartTime.Equal(entries[j].StartTime) {
+			return entries[i].Id > entries[j].Id
+		}
+		return entries[i].StartTime.After(entries[j].StartTime)
+	})
+}
+
+// GetDependentBackups returns the backups chained to a backup
+func (b *BackupMetaMap) GetDependentBackups(id int64) []*BackupMetadata {
+	result := make([]*BackupMetadata, 0)
+	b.Callback(func(key int64, backup *BackupMetadata) bool {
+		if backup.IsIncremental() && backup.Previous == id && backup.Id != id {
+			result = append(result, backup)
+		}
+		return true
+	})
+	return result
 }
 
 type PointInTimeMeta struct {
@@ -51,6 +505,334 @@ type PointInTimeMeta struct {
 	RestoreTime int64
 }
 
+// Point in time recovery targets
+const (
+	PointInTimeTargetTime     = "time"
+	PointInTimeTargetGtid     = "gtid"
+	PointInTimeTargetPosition = "position"
+)
+
+// Point in time recovery job states
+const (
+	PointInTimeJobRunning = "running"
+	PointInTimeJobDone    = "done"
+	PointInTimeJobFailed  = "failed"
+)
+
+// PointInTimeRequest is the target of a point in time recovery. A time target
+// replays the transactions started until Time, a gtid target replays until
+// the transaction Gtid included and a position target stops just before the
+// transaction of the event at Filename and Position, or of the first event
+// logged at Time when no position is given. The base backup is the newest
+// backup of Source before the target unless Backup is set, the restore goes
+// to the member Server or to a standalone local instance.
+type PointInTimeRequest struct {
+	Target     string `json:"target"`
+	Time       int64  `json:"time"`
+	Gtid       string `json:"gtid"`
+	Filename   string `json:"filename"`
+	Position   int64  `json:"position"`
+	Source     string `json:"source"`
+	Backup     int64  `json:"backup"`
+	Server     string `json:"server"`
+	Standalone bool   `json:"standalone"`
+}
+
+// Validate checks the request has what its target needs
+func (r *PointInTimeRequest) Validate() error {
+	switch r.Target {
+	case PointInTimeTargetTime:
+		if r.Time <= 0 {
+			return fmt.Errorf("Missing time for point in time recovery")
+		}
+	case PointInTimeTargetGtid:
+		if r.Gtid == "" {
+			return fmt.Errorf("Missing gtid for point in time recovery")
+		}
+	case PointInTimeTargetPosition:
+		if (r.Filename == "" || r.Position < 4) && r.Time <= 0 {
+			return fmt.Errorf("Missing binary log position or time for point in time recovery")
+		}
+	default:
+		return fmt.Errorf("Unknown point in time recovery target %q", r.Target)
+	}
+	if r.Standalone == (r.Server != "") {
+		return fmt.Errorf("Point in time recovery needs either a server or standalone")
+	}
+	return nil
+}
+
+// PointInTimePlan is a resolved point in time recovery, the base backup and
+// the archived binary logs replayed from Start to Stop. A zero Stop position
+// replays the last binary log up to its end.
+type PointInTimePlan struct {
+	Backup     int64                  `json:"backup"`
+	BackupTool string                 `json:"backupTool"`
+	Source     string                 `json:"source"`
+	Binlogs    []string               `json:"binlogs"`
+	Start      ReadBinaryLogsBoundary `json:"start"`
+	Stop       ReadBinaryLogsBoundary `json:"stop"`
+}
+
+// PointInTimeJob is the state of the last point in time recovery job
+type PointInTimeJob struct {
+	Request   PointInTimeRequest `json:"request"`
+	Plan      *PointInTimePlan   `json:"plan"`
+	State     string             `json:"state"`
+	Target    string             `json:"target"`
+	Error     string             `json:"error"`
+	StartTime time.Time          `json:"startTime"`
+	EndTime   time.Time          `json:"endTime"`
+}
+
+// BinlogArchiverStatus is the position of the continuous binary log archiver
+// in the binary logs of the master it follows, saved to resume after a restart
+type BinlogArchiverStatus struct {
+	Running       bool      `json:"running"`
+	Source        string    `json:"source"`
+	File          string    `json:"file"`
+	FileStart     int64     `json:"fileStart"`
+	Position      uint32    `json:"position"`
+	Gtid          string    `json:"gtid"`
+	LastEventTime time.Time `json:"lastEventTime"`
+	Bytes         int64     `json:"bytes"`
+	Error         string    `json:"error"`
+}
+
+// GetLag returns the seconds between the last event archived and now, the
+// last event is the time of the last heartbeat when the archive is up to date
+func (s BinlogArchiverStatus) GetLag(now time.Time) float64 {
+	if s.LastEventTime.IsZero() {
+		return 0
+	}
+	if lag := now.Sub(s.LastEventTime).Seconds(); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// SchemaBackupRequest is a logical backup limited to whole Schemas and to
+// Tables given as schema.table, taken on Server or on the backup server of the
+// cluster when empty
+type SchemaBackupRequest struct {
+	Schemas []string `json:"schemas"`
+	Tables  []string `json:"tables"`
+	Server  string   `json:"server"`
+}
+
+// SchemaRestoreRequest loads a schema backup into the master of Cluster, the
+// schemas of Rename are created under their new name
+type SchemaRestoreRequest struct {
+	Cluster string            `json:"cluster"`
+	Rename  map[string]string `json:"rename"`
+}
+
+// Schemas a schema backup can not dump nor restore into
+var schemaBackupExcluded = map[string]bool{
+	"mysql":              true,
+	"information_schema": true,
+	"performance_schema": true,
+	"sys":                true,
+}
+
+func validateSchemaName(name string) error {
+	if name == "" || len(name) > 64 || strings.ContainsAny(name, "/\\.\x00") {
+		return fmt.Errorf("Invalid schema name %q", name)
+	}
+	if schemaBackupExcluded[strings.ToLower(name)] {
+		return fmt.Errorf("Schema %s can not be backed up nor restored by a schema backup", name)
+	}
+	return nil
+}
+
+// Validate checks the request names at least one schema or table
+func (r *SchemaBackupRequest) Validate() error {
+	if len(r.Schemas) == 0 && len(r.Tables) == 0 {
+		return fmt.Errorf("Missing schemas or tables for schema backup")
+	}
+	for _, schema := range r.Schemas {
+		if err := validateSchemaName(schema); err != nil {
+			return err
+		}
+	}
+	for _, table := range r.Tables {
+		schema, name, ok := strings.Cut(table, ".")
+		if !ok || name == "" || len(name) > 64 || strings.ContainsAny(name, "/\\\x00") {
+			return fmt.Errorf("Invalid table %q, expecting schema.table", table)
+		}
+		if err := validateSchemaName(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsPartial tells if the backup is a schema backup limited to some schemas
+// and tables instead of the whole instance
+func (bm *BackupMetadata) IsPartial() bool {
+	return len(bm.Schemas) > 0 || len(bm.Tables) > 0
+}
+
+// GetSchemaScope returns the tables of a schema backup by schema, a schema
+// dumped whole has no tables
+func (bm *BackupMetadata) GetSchemaScope() map[string][]string {
+	scope := make(map[string][]string)
+	for _, schema := range bm.Schemas {
+		scope[schema] = nil
+	}
+	for _, table := range bm.Tables {
+		schema, name, _ := strings.Cut(table, ".")
+		if tables, ok := scope[schema]; ok && tables == nil {
+			continue
+		}
+		if !slices.Contains(scope[schema], name) {
+			scope[schema] = append(scope[schema], name)
+		}
+	}
+	return scope
+}
+
+// GetSchemaNames returns the sorted schemas of a schema backup
+func (bm *BackupMetadata) GetSchemaNames() []string {
+	names := make([]string, 0)
+	for schema := range bm.GetSchemaScope() {
+		names = append(names, schema)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetScope returns the schemas and tables of a schema backup for the catalog,
+// empty for a backup of the whole instance
+func (bm *BackupMetadata) GetScope() string {
+	scope := bm.GetSchemaScope()
+	items := make([]string, 0)
+	for _, schema := range bm.GetSchemaNames() {
+		if scope[schema] == nil {
+			items = append(items, schema)
+			continue
+		}
+		for _, table := range scope[schema] {
+			items = append(items, schema+"."+table)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// GetMyDumperRegex returns the mydumper regex matching the schema.table names
+// of the scope of a schema backup
+func (bm *BackupMetadata) GetMyDumperRegex() string {
+	scope := bm.GetSchemaScope()
+	items := make([]string, 0)
+	for _, schema := range bm.GetSchemaNames() {
+		if scope[schema] == nil {
+			items = append(items, regexp.QuoteMeta(schema)+"\\.")
+			continue
+		}
+		for _, table := range scope[schema] {
+			items = append(items, regexp.QuoteMeta(schema+"."+table)+"$")
+		}
+	}
+	return "^(" + strings.Join(items, "|") + ")"
+}
+
+// Validate checks the renamed schemas are schemas of the backup and are not
+// renamed to the same schema. A backup holding views, triggers, routines or
+// events can not be renamed as their bodies keep referencing the source
+// schemas.
+func (r *SchemaRestoreRequest) Validate(bm *BackupMetadata) error {
+	if !bm.IsPartial() {
+		return fmt.Errorf("Backup %d is not a schema backup", bm.Id)
+	}
+	if len(r.Rename) > 0 && len(bm.SchemaObjects) > 0 {
+		return fmt.Errorf("Backup %d can not be restored with renamed schemas, schemas %s hold views, triggers, routines or events referencing the source schemas", bm.Id, strings.Join(bm.SchemaObjects, ","))
+	}
+	scope := bm.GetSchemaScope()
+	targets := make(map[string]string)
+	for src, dst := range r.Rename {
+		if _, ok := scope[src]; !ok {
+			return fmt.Errorf("Schema %s is not in backup %d", src, bm.Id)
+		}
+		if err := validateSchemaName(dst); err != nil {
+			return err
+		}
+		if prev, ok := targets[dst]; ok {
+			return fmt.Errorf("Schemas %s and %s are both renamed to %s", prev, src, dst)
+		}
+		targets[dst] = src
+	}
+	for src := range scope {
+		_, renamed := r.Rename[src]
+		if prev, ok := targets[src]; ok && !renamed {
+			return fmt.Errorf("Schema %s is renamed to schema %s of the backup", prev, src)
+		}
+	}
+	return nil
+}
+
+// GetTargetSchema returns the schema a schema of the backup is restored into
+func (r *SchemaRestoreRequest) GetTargetSchema(schema string) string {
+	if dst, ok := r.Rename[schema]; ok {
+		return dst
+	}
+	return schema
+}
+
+// QuoteIdentifier returns a schema or table name quoted for SQL statements
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// GetSchemaDumpArgs returns the mysqldump arguments dumping a schema backup
+// in one run from the tables of its schemas, the tables out of the scope of
+// a schema are ignored
+func (bm *BackupMetadata) GetSchemaDumpArgs(tables map[string][]string) []string {
+	scope := bm.GetSchemaScope()
+	args := []string{"--databases"}
+	ignored := make([]string, 0)
+	for _, schema := range bm.GetSchemaNames() {
+		args = append(args, schema)
+		if scope[schema] == nil {
+			continue
+		}
+		for _, table := range tables[schema] {
+			if !slices.Contains(scope[schema], table) {
+				ignored = append(ignored, "--ignore-table="+schema+"."+table)
+			}
+		}
+	}
+	sort.Strings(ignored)
+	return append(ignored, args...)
+}
+
+// GetSchemaRestoreHeader returns the statements creating the target schemas
+// of a restore of a schema backup of mysqldump, dumped without them
+func (r *SchemaRestoreRequest) GetSchemaRestoreHeader(bm *BackupMetadata) string {
+	header := ""
+	for _, schema := range bm.GetSchemaNames() {
+		header += "CREATE DATABASE IF NOT EXISTS " + QuoteIdentifier(r.GetTargetSchema(schema)) + ";\n"
+	}
+	return header
+}
+
+// RenameSchemaDumpLine rewrites a line of a schema backup of mysqldump for
+// the restore, the USE statements of the dump and the CREATE DATABASE of the
+// backups dumped by schema go to the renamed schema
+func (r *SchemaRestoreRequest) RenameSchemaDumpLine(line string) string {
+	if len(r.Rename) == 0 || (!strings.HasPrefix(line, "CREATE DATABASE IF NOT EXISTS `") && !strings.HasPrefix(line, "USE `")) {
+		return line
+	}
+	for src, dst := range r.Rename {
+		switch strings.TrimRight(line, "\r\n") {
+		case "CREATE DATABASE IF NOT EXISTS " + QuoteIdentifier(src) + ";":
+			return "CREATE DATABASE IF NOT EXISTS " + QuoteIdentifier(dst) + ";\n"
+		case "USE " + QuoteIdentifier(src) + ";":
+			return "USE " + QuoteIdentifier(dst) + ";\n"
+		}
+	}
+	return line
+}
+
 func (bm *BackupMetadata) GetSize() error {
 	var size int64 = 0
 	err := filepath.Walk(bm.Dest, func(_ string, info os.FileInfo, err error) error {
@@ -68,6 +850,8 @@ type ReadBinaryLogsBoundary struct {
 	Filename     string
 	Position     int64
 	Timestamp    time.Time
+	// Path of a local copy of the binary log read instead of the server
+	Path string `json:"-"`
 }
 
 type ReadBinaryLogsRange struct {
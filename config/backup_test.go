@@ -0,0 +1,296 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupChain(t *testing.T) {
+	m := NewBackupMetaMap()
+	m.Set(1, &BackupMetadata{Id: 1, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyFull, Completed: true, ToLSN: 100})
+	m.Set(2, &BackupMetadata{Id: 2, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyIncremental, Completed: true, Previous: 1, FromLSN: 100, ToLSN: 200})
+	m.Set(3, &BackupMetadata{Id: 3, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyIncremental, Completed: true, Previous: 2, FromLSN: 200, ToLSN: 300})
+	m.Set(4, &BackupMetadata{Id: 4, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyIncremental, Completed: false, Previous: 3, FromLSN: 300})
+	m.Set(5, &BackupMetadata{Id: 5, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyDifferential, Completed: true, Previous: 1, FromLSN: 100, ToLSN: 250})
+
+	full := m.GetLastFullBackup("mariabackup", "db1:3306")
+	if full == nil || full.Id != 1 {
+		t.Fatalf("unexpected full backup %v", full)
+	}
+	if m.GetLastFullBackup("mariabackup", "db2:3306") != nil {
+		t.Error("expected no full backup for another source")
+	}
+	if last := m.GetLastChainBackup(full); last.Id != 5 {
+		t.Errorf("unexpected last chain backup %d", last.Id)
+	}
+
+	chain, err := m.GetBackupChain(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 || chain[0].Id != 1 || chain[2].Id != 3 {
+		t.Errorf("unexpected chain %v", chain)
+	}
+	if chain, _ := m.GetBackupChain(5); len(chain) != 2 || chain[0].Id != 1 {
+		t.Errorf("unexpected differential chain %v", chain)
+	}
+	if _, err := m.GetBackupChain(4); err == nil {
+		t.Error("expected an error on incomplete backup")
+	}
+	m.Delete(int64(2))
+	if _, err := m.GetBackupChain(3); err == nil {
+		t.Error("expected an error on missing backup of the chain")
+	}
+	if BackupStrategy(BackupStrategyDifferential).String() != "differential" {
+		t.Error("unexpected strategy name")
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	backup := TableRowCounts{"app.users": 1000, "app.orders": 50000, "app.empty": 0, "app.lost": 10}
+	restored := TableRowCounts{"app.users": 1100, "app.orders": 20000, "app.empty": 0}
+
+	errs := backup.CompareRows(restored, 20)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 differences, got %v", errs)
+	}
+	if errs[0] != "table app.lost missing" {
+		t.Errorf("missing table not reported: %v", errs)
+	}
+	if errs := backup.CompareRows(restored, 100); len(errs) != 1 {
+		t.Errorf("expected only the missing table with a 100%% tolerance, got %v", errs)
+	}
+	if errs := backup.CompareRows(TableRowCounts{"app.users": 1000, "app.orders": 50000, "app.empty": 3, "app.lost": 10}, 20); len(errs) != 1 {
+		t.Errorf("expected the empty table restored with rows, got %v", errs)
+	}
+}
+
+func TestBackupCatalog(t *testing.T) {
+	now := time.Now()
+	m := NewBackupMetaMap()
+	m.Set(1, &BackupMetadata{Id: 1, StartTime: now.Add(-time.Hour), BackupMethod: BackupMethodPhysical, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyFull, Completed: true, Pinned: true})
+	m.Set(2, &BackupMetadata{Id: 2, StartTime: now, BackupMethod: BackupMethodPhysical, BackupTool: "mariabackup", Source: "db1:3306", BackupStrategy: BackupStrategyIncremental, Completed: true, Previous: 1})
+	m.Set(3, &BackupMetadata{Id: 3, StartTime: now.Add(-2 * time.Hour), BackupMethod: BackupMethodLogical, BackupTool: "mysqldump", Source: "db1:3306", BackupStrategy: BackupStrategyFull, Completed: true})
+
+	entries := make([]*BackupCatalogEntry, 0)
+	m.Callback(func(key int64, backup *BackupMetadata) bool {
+		entries = append(entries, backup.GetCatalogEntry())
+		return true
+	})
+	SortBackupCatalog(entries)
+	if len(entries) != 3 || entries[0].Id != "2" || entries[2].Id != "3" {
+		t.Fatalf("unexpected catalog order %v", entries)
+	}
+	if entries[0].Type != BackupCatalogPhysical || entries[0].Previous != "1" || entries[0].RetentionClass != BackupRetentionChain {
+		t.Errorf("unexpected incremental entry %+v", entries[0])
+	}
+	if entries[1].RetentionClass != BackupRetentionPinned || entries[2].RetentionClass != BackupRetentionRotate || entries[2].Type != BackupCatalogLogical {
+		t.Errorf("unexpected retention classes %s %s", entries[1].RetentionClass, entries[2].RetentionClass)
+	}
+	if deps := m.GetDependentBackups(1); len(deps) != 1 || deps[0].Id != 2 {
+		t.Errorf("unexpected dependent backups %v", deps)
+	}
+	if prev := m.GetPreviousBackup("mariabackup", "db1:3306"); prev != nil {
+		t.Errorf("pinned backup returned as previous backup %d", prev.Id)
+	}
+}
+
+func TestBackupRetentionPolicy(t *testing.T) {
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+	times := make([]time.Time, 0)
+	// A backup every 6 hours over 60 days, newest first
+	for i := 0; i < 240; i++ {
+		times = append(times, now.Add(-time.Duration(i)*6*time.Hour))
+	}
+	classes := BackupRetentionPolicy{Hourly: 2, Daily: 3, Weekly: 2, Monthly: 2}.Select(times)
+
+	kept := 0
+	for _, class := range classes {
+		if class != BackupRetentionPurge {
+			kept++
+		}
+	}
+	if classes[0] != BackupRetentionHourly || classes[1] != BackupRetentionHourly {
+		t.Errorf("unexpected hourly classes %v", classes[:2])
+	}
+	// The newest backup of a day is the last one of the day, 18:00 before today
+	if classes[2] != BackupRetentionPurge || classes[3] != BackupRetentionDaily || classes[7] != BackupRetentionDaily {
+		t.Errorf("unexpected daily classes %v", classes[:8])
+	}
+	if classes[15] != BackupRetentionWeekly || classes[79] != BackupRetentionMonthly {
+		t.Errorf("unexpected weekly or monthly classes %s %s", classes[15], classes[79])
+	}
+	if kept != 6 {
+		t.Errorf("expected 6 backups kept, got %d", kept)
+	}
+	if classes := (BackupRetentionPolicy{}).Select(times[:3]); classes[0] != BackupRetentionLast || classes[1] != BackupRetentionPurge {
+		t.Errorf("newest backup not kept with an empty policy: %v", classes)
+	}
+}
+
+func TestPointInTimeRequestValidate(t *testing.T) {
+	valid := []PointInTimeRequest{
+		{Target: PointInTimeTargetTime, Time: 1718884800, Standalone: true},
+		{Target: PointInTimeTargetGtid, Gtid: "0-1-4512", Server: "db2:3306"},
+		{Target: PointInTimeTargetPosition, Filename: "mariadb-bin.000012", Position: 1024, Standalone: true},
+		{Target: PointInTimeTargetPosition, Time: 1718884800, Server: "db2:3306"},
+	}
+	for _, req := range valid {
+		if err := req.Validate(); err != nil {
+			t.Errorf("unexpected error for %+v: %s", req, err)
+		}
+	}
+	invalid := []PointInTimeRequest{
+		{Target: "lsn", Time: 1718884800, Standalone: true},
+		{Target: PointInTimeTargetTime, Standalone: true},
+		{Target: PointInTimeTargetGtid, Server: "db2:3306"},
+		{Target: PointInTimeTargetPosition, Filename: "mariadb-bin.000012", Standalone: true},
+		{Target: PointInTimeTargetTime, Time: 1718884800},
+		{Target: PointInTimeTargetTime, Time: 1718884800, Server: "db2:3306", Standalone: true},
+	}
+	for _, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+}
+
+func TestBinlogArchiverStatusLag(t *testing.T) {
+	now := time.Unix(1718884800, 0)
+	if lag := (BinlogArchiverStatus{}).GetLag(now); lag != 0 {
+		t.Errorf("lag %f without event", lag)
+	}
+	if lag := (BinlogArchiverStatus{LastEventTime: now.Add(-3 * time.Second)}).GetLag(now); lag != 3 {
+		t.Errorf("lag %f, expected 3", lag)
+	}
+	// Event timestamps of a master with a clock ahead
+	if lag := (BinlogArchiverStatus{LastEventTime: now.Add(time.Second)}).GetLag(now); lag != 0 {
+		t.Errorf("negative lag %f", lag)
+	}
+}
+
+func TestSchemaBackup(t *testing.T) {
+	invalid := []SchemaBackupRequest{
+		{},
+		{Schemas: []string{"mysql"}},
+		{Tables: []string{"shop"}},
+		{Tables: []string{"shop."}},
+		{Schemas: []string{"../shop"}},
+	}
+	for _, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+	req := SchemaBackupRequest{Schemas: []string{"shop"}, Tables: []string{"crm.clients", "shop.orders", "crm.notes"}}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BackupMetadata{Id: 1, Schemas: req.Schemas, Tables: req.Tables}
+	if scope := bm.GetScope(); scope != "crm.clients,crm.notes,shop" {
+		t.Errorf("scope %s", scope)
+	}
+	if regex := bm.GetMyDumperRegex(); regex != `^(crm\.clients$|crm\.notes$|shop\.)` {
+		t.Errorf("regex %s", regex)
+	}
+	args := bm.GetSchemaDumpArgs(map[string][]string{"crm": {"clients", "leads", "notes", "v_leads"}})
+	if got := strings.Join(args, " "); got != "--ignore-table=crm.leads --ignore-table=crm.v_leads --databases crm shop" {
+		t.Errorf("dump args %s", got)
+	}
+
+	restore := SchemaRestoreRequest{Rename: map[string]string{"shop": "shop_staging"}}
+	if err := restore.Validate(bm); err != nil {
+		t.Fatal(err)
+	}
+	if line := restore.RenameSchemaDumpLine("USE `shop`;\n"); line != "USE `shop_staging`;\n" {
+		t.Errorf("renamed line %q", line)
+	}
+	if line := restore.RenameSchemaDumpLine("CREATE DATABASE IF NOT EXISTS `shop`;\n"); line != "CREATE DATABASE IF NOT EXISTS `shop_staging`;\n" {
+		t.Errorf("renamed line %q", line)
+	}
+	if header := restore.GetSchemaRestoreHeader(bm); header != "CREATE DATABASE IF NOT EXISTS `crm`;\nCREATE DATABASE IF NOT EXISTS `shop_staging`;\n" {
+		t.Errorf("restore header %q", header)
+	}
+	if line := restore.RenameSchemaDumpLine("USE `crm`;\n"); line != "USE `crm`;\n" {
+		t.Errorf("line of a schema not renamed changed to %q", line)
+	}
+	for _, rename := range []map[string]string{{"billing": "b2"}, {"shop": "crm"}, {"shop": "x", "crm": "x"}, {"shop": "mysql"}} {
+		if err := (&SchemaRestoreRequest{Rename: rename}).Validate(bm); err == nil {
+			t.Errorf("expected an error for rename %v", rename)
+		}
+	}
+	if err := restore.Validate(&BackupMetadata{Id: 2}); err == nil {
+		t.Error("expected an error for a backup of the whole instance")
+	}
+	bm.SchemaObjects = []string{"crm"}
+	if err := restore.Validate(bm); err == nil {
+		t.Error("expected an error renaming a backup holding views, triggers, routines or events")
+	}
+	if err := (&SchemaRestoreRequest{}).Validate(bm); err != nil {
+		t.Errorf("restore without rename refused: %s", err)
+	}
+}
+
+func TestBackupThrottle(t *testing.T) {
+	conf := &Config{}
+	if threads := conf.GetBackupThreads(8); threads != 8 {
+		t.Errorf("threads %d without budget", threads)
+	}
+	if reason := conf.GetBackupThrottleReason(3600, 500); reason != "" {
+		t.Errorf("throttled without thresholds: %s", reason)
+	}
+	conf.BackupThrottleThreads = 4
+	conf.BackupThrottleMaxDelay = 30
+	conf.BackupThrottleMaxThreadsRunning = 64
+	if threads := conf.GetBackupThreads(8); threads != 4 {
+		t.Errorf("threads %d over the budget", threads)
+	}
+	if threads := conf.GetBackupThreads(2); threads != 2 {
+		t.Errorf("threads %d under the budget changed", threads)
+	}
+	if reason := conf.GetBackupThrottleReason(30, 64); reason != "" {
+		t.Errorf("throttled at the thresholds: %s", reason)
+	}
+	if reason := conf.GetBackupThrottleReason(31, 0); !strings.Contains(reason, "replication delay") {
+		t.Errorf("reason %q for a delayed replica", reason)
+	}
+	if reason := conf.GetBackupThrottleReason(0, 65); !strings.Contains(reason, "Threads_running") {
+		t.Errorf("reason %q for a busy master", reason)
+	}
+}
+
+func TestRowCounter(t *testing.T) {
+	dump := "-- MariaDB dump\n" +
+		"USE `mysql`;\n" +
+		"INSERT INTO `user` VALUES ('root','x');\n" +
+		"USE `app`;\n" +
+		"CREATE TABLE `empty` (\n  `id` int(11) NOT NULL\n);\n" +
+		"CREATE TABLE `t` (\n  `id` int(11) NOT NULL,\n  `v` text\n);\n" +
+		"INSERT INTO `t` VALUES (1,'a),(b'),(2,'it\\'s (x)'),(3,'line\nbreak');\n" +
+		"INSERT INTO `t` (`id`,`v`) VALUES (4,NULL),(5,0x28);\n" +
+		"INSERT INTO `other`.`u` VALUES(1,\"q;\")\n,(2,\"r\");\n"
+	rc := NewRowCounter("")
+	// write in small chunks to cross the statement boundaries
+	for i := 0; i < len(dump); i += 7 {
+		rc.Write([]byte(dump[i:min(i+7, len(dump))]))
+	}
+	want := TableRowCounts{"app.empty": 0, "app.t": 5, "other.u": 2}
+	if len(rc.Rows) != len(want) {
+		t.Fatalf("unexpected rows %v", rc.Rows)
+	}
+	for table, rows := range want {
+		if got, ok := rc.Rows[table]; !ok || got != rows {
+			t.Errorf("table %s has %d rows, %d expected", table, got, rows)
+		}
+	}
+}
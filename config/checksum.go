@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Repair of the rows differing on a replica of checksum-repair
+const (
+	ChecksumRepairNone     = "none"
+	ChecksumRepairGenerate = "generate"
+	ChecksumRepairApply    = "apply"
+)
+
+// ChecksumStatus is the progress of a table checksum job, saved after each
+// chunk so that a restart of replication-manager resumes it
+type ChecksumStatus struct {
+	Running bool             `json:"running"`
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	Tables  []*ChecksumTable `json:"tables"`
+	Error   string           `json:"error"`
+	Repair  string           `json:"repair"`
+}
+
+// ChecksumTable is the progress of the checksum of a table, LastKey is the
+// primary key of the last row of the last chunk checksummed, NotCompared the
+// replicas not synced in checksum-sync-timeout
+type ChecksumTable struct {
+	Schema      string         `json:"schema"`
+	Table       string         `json:"table"`
+	Keys        []string       `json:"keys"`
+	Columns     []string       `json:"columns"`
+	Chunks      int            `json:"chunks"`
+	ChunkSize   int            `json:"chunkSize"`
+	LastKey     []string       `json:"lastKey"`
+	Rows        int64          `json:"rows"`
+	Done        bool           `json:"done"`
+	Sync        string         `json:"sync"`
+	Diffs       []ChecksumDiff `json:"diffs"`
+	NotCompared []string       `json:"notCompared"`
+	Error       string         `json:"error"`
+}
+
+// ChecksumDiff are the rows of a chunk differing on a replica, Repair is the
+// file of the repair statements or applied
+type ChecksumDiff struct {
+	Server    string   `json:"server"`
+	Chunk     int      `json:"chunk"`
+	Lower     []string `json:"lower"`
+	Upper     []string `json:"upper"`
+	Missing   int      `json:"missing"`
+	Extra     int      `json:"extra"`
+	Different int      `json:"different"`
+	Repair    string   `json:"repair"`
+}
+
+// ChecksumRow is a row of a chunk in the order of the columns of the table,
+// a nil value is NULL
+type ChecksumRow []*string
+
+// GetName returns schema.table
+func (t *ChecksumTable) GetName() string {
+	return t.Schema + "." + t.Table
+}
+
+// GetKeyIndexes returns the positions of the primary key in the columns
+func (t *ChecksumTable) GetKeyIndexes() []int {
+	idx := make([]int, 0, len(t.Keys))
+	for _, k := range t.Keys {
+		for i, c := range t.Columns {
+			if c == k {
+				idx = append(idx, i)
+				break
+			}
+		}
+	}
+	return idx
+}
+
+// GetTable returns the progress of a table of the job, nil when the table is
+// not checksummed
+func (s *ChecksumStatus) GetTable(schema string, table string) *ChecksumTable {
+	for _, t := range s.Tables {
+		if t.Schema == schema && t.Table == table {
+			return t
+		}
+	}
+	return nil
+}
+
+// GetChecksumChunkSize returns the rows of the next chunk so that it takes
+// the target time, from the rows of the last chunk and its time. The size is
+// averaged with the last one and grows at most twice to absorb spikes.
+func GetChecksumChunkSize(size int, elapsed time.Duration, target time.Duration, max int) int {
+	next := size * 2
+	if elapsed > 0 {
+		next = int(float64(size) * float64(target) / float64(elapsed))
+	}
+	next = (next + size) / 2
+	if next > size*2 {
+		next = size * 2
+	}
+	if max > 0 && next > max {
+		next = max
+	}
+	if next < 1 {
+		next = 1
+	}
+	return next
+}
+
+// QuoteValue returns the SQL literal of a value, NULL for nil
+func QuoteValue(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i := 0; i < len(*v); i++ {
+		switch c := (*v)[i]; c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		case '\\', '\'', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// GetKeyPredicate compares the primary key to values with a row constructor,
+// (`a`,`b`) > ('1','2')
+func GetKeyPredicate(keys []string, op string, values []string) string {
+	cols := make([]string, len(keys))
+	vals := make([]string, len(values))
+	for i, k := range keys {
+		cols[i] = QuoteIdentifier(k)
+	}
+	for i := range values {
+		vals[i] = QuoteValue(&values[i])
+	}
+	return "(" + strings.Join(cols, ",") + ") " + op + " (" + strings.Join(vals, ",") + ")"
+}
+
+// GetChunkPredicate returns the condition of the rows of a chunk, after lower
+// and up to upper, a nil boundary is the start or the end of the table
+func GetChunkPredicate(keys []string, lower []string, upper []string) string {
+	conds := make([]string, 0, 2)
+	if lower != nil {
+		conds = append(conds, GetKeyPredicate(keys, ">", lower))
+	}
+	if upper != nil {
+		conds = append(conds, GetKeyPredicate(keys, "<=", upper))
+	}
+	if len(conds) == 0 {
+		return "1=1"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// GetChecksumExpression returns the CRC of the rows of a chunk, NULL and
+// empty values are told apart by the ISNULL flags
+func GetChecksumExpression(columns []string) string {
+	cols := make([]string, len(columns))
+	nulls := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = QuoteIdentifier(c)
+		nulls[i] = "ISNULL(" + QuoteIdentifier(c) + ")"
+	}
+	return "COALESCE(SUM(CRC32(CONCAT_WS('#'," + strings.Join(cols, ",") + ",CONCAT(" + strings.Join(nulls, ",") + ")))),0)"
+}
+
+func getRowKey(row ChecksumRow, keys []int) string {
+	var b strings.Builder
+	for _, i := range keys {
+		if row[i] == nil {
+			b.WriteString("\x01")
+		} else {
+			b.WriteString("\x02" + *row[i])
+		}
+		b.WriteString("\x00")
+	}
+	return b.String()
+}
+
+func isSameRow(a ChecksumRow, b ChecksumRow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if (a[i] == nil) != (b[i] == nil) || (a[i] != nil && *a[i] != *b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffChecksumRows compares the rows of a chunk of the master and of a
+// replica by primary key, missing are the master rows absent from the
+// replica, extra the replica rows absent from the master and different the
+// master rows differing on the replica
+func DiffChecksumRows(keys []int, master []ChecksumRow, replica []ChecksumRow) (missing []ChecksumRow, extra []ChecksumRow, different []ChecksumRow) {
+	replicaRows := make(map[string]ChecksumRow, len(replica))
+	for _, row := range replica {
+		replicaRows[getRowKey(row, keys)] = row
+	}
+	for _, row := range master {
+		key := getRowKey(row, keys)
+		r, ok := replicaRows[key]
+		if !ok {
+			missing = append(missing, row)
+		} else if !isSameRow(row, r) {
+			different = append(different, row)
+		}
+		delete(replicaRows, key)
+	}
+	for _, row := range replica {
+		if _, ok := replicaRows[getRowKey(row, keys)]; ok {
+			extra = append(extra, row)
+		}
+	}
+	return missing, extra, different
+}
+
+// GetChecksumRepair returns the statement run on the master to repair a row
+// of a replica through replication, the master row is written again or the
+// row is deleted when the master does not have it
+func (t *ChecksumTable) GetChecksumRepair(master ChecksumRow, key ChecksumRow) string {
+	name := QuoteIdentifier(t.Schema) + "." + QuoteIdentifier(t.Table)
+	if master != nil {
+		cols := make([]string, len(t.Columns))
+		vals := make([]string, len(master))
+		for i, c := range t.Columns {
+			cols[i] = QuoteIdentifier(c)
+		}
+		for i, v := range master {
+			vals[i] = QuoteValue(v)
+		}
+		return fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s)", name, strings.Join(cols, ","), strings.Join(vals, ","))
+	}
+	conds := make([]string, 0, len(t.Keys))
+	for _, i := range t.GetKeyIndexes() {
+		conds = append(conds, QuoteIdentifier(t.Columns[i])+"="+QuoteValue(key[i]))
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1", name, strings.Join(conds, " AND "))
+}
+
+// GetKeyValues returns the primary key of a row
+func (t *ChecksumTable) GetKeyValues(row ChecksumRow) []string {
+	values := make([]string, 0, len(t.Keys))
+	for _, i := range t.GetKeyIndexes() {
+		if row[i] == nil {
+			values = append(values, "")
+		} else {
+			values = append(values, *row[i])
+		}
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+func TestChecksumChunkSize(t *testing.T) {
+	target := 500 * time.Millisecond
+	if size := GetChecksumChunkSize(1000, 500*time.Millisecond, target, 0); size != 1000 {
+		t.Errorf("size %d on target", size)
+	}
+	if size := GetChecksumChunkSize(1000, 2*time.Second, target, 0); size != 625 {
+		t.Errorf("size %d for a slow chunk", size)
+	}
+	if size := GetChecksumChunkSize(1000, time.Millisecond, target, 0); size != 2000 {
+		t.Errorf("size %d for a fast chunk grows over twice", size)
+	}
+	if size := GetChecksumChunkSize(1000, 0, target, 1500); size != 1500 {
+		t.Errorf("size %d over the maximum", size)
+	}
+	if size := GetChecksumChunkSize(1, time.Hour, target, 0); size != 1 {
+		t.Errorf("size %d under one row", size)
+	}
+}
+
+func TestChecksumPredicates(t *testing.T) {
+	if q := QuoteValue(str("it's a \\ test\n")); q != `'it\'s a \\ test\n'` {
+		t.Errorf("QuoteValue returned %s", q)
+	}
+	if q := QuoteValue(nil); q != "NULL" {
+		t.Errorf("QuoteValue of nil returned %s", q)
+	}
+	keys := []string{"id", "seq"}
+	if p := GetChunkPredicate(keys, nil, nil); p != "1=1" {
+		t.Errorf("whole table predicate %s", p)
+	}
+	if p := GetChunkPredicate(keys, []string{"1", "2"}, []string{"5", "1"}); p != "(`id`,`seq`) > ('1','2') AND (`id`,`seq`) <= ('5','1')" {
+		t.Errorf("chunk predicate %s", p)
+	}
+	if e := GetChecksumExpression([]string{"id", "name"}); e != "COALESCE(SUM(CRC32(CONCAT_WS('#',`id`,`name`,CONCAT(ISNULL(`id`),ISNULL(`name`))))),0)" {
+		t.Errorf("checksum expression %s", e)
+	}
+}
+
+func TestDiffChecksumRows(t *testing.T) {
+	table := &ChecksumTable{Schema: "shop", Table: "orders", Keys: []string{"id"}, Columns: []string{"name", "id"}}
+	keys := table.GetKeyIndexes()
+	if len(keys) != 1 || keys[0] != 1 {
+		t.Fatalf("key indexes %v", keys)
+	}
+	master := []ChecksumRow{{str("a"), str("1")}, {str("b"), str("2")}, {nil, str("3")}}
+	replica := []ChecksumRow{{str("a"), str("1")}, {str(""), str("3")}, {str("d"), str("4")}}
+	missing, extra, different := DiffChecksumRows(keys, master, replica)
+	if len(missing) != 1 || *missing[0][1] != "2" {
+		t.Errorf("missing rows %v", missing)
+	}
+	if len(extra) != 1 || *extra[0][1] != "4" {
+		t.Errorf("extra rows %v", extra)
+	}
+	if len(different) != 1 || *different[0][1] != "3" {
+		t.Errorf("different rows %v, NULL and empty are not the same", different)
+	}
+
+	if q := table.GetChecksumRepair(master[2], master[2]); q != "REPLACE INTO `shop`.`orders` (`name`,`id`) VALUES (NULL,'3')" {
+		t.Errorf("repair of a row %s", q)
+	}
+	if q := table.GetChecksumRepair(nil, extra[0]); q != "DELETE FROM `shop`.`orders` WHERE `id`='4' LIMIT 1" {
+		t.Errorf("repair of an extra row %s", q)
+	}
+	if k := table.GetKeyValues(extra[0]); len(k) != 1 || k[0] != "4" {
+		t.Errorf("key values %v", k)
+	}
+}
+
+func TestChecksumThrottle(t *testing.T) {
+	conf := &Config{ChecksumMaxDelay: 10, ChecksumMaxThreadsRunning: 25}
+	if reason := conf.GetChecksumThrottleReason(10, 25); reason != "" {
+		t.Errorf("paused at the thresholds: %s", reason)
+	}
+	if reason := conf.GetChecksumThrottleReason(11, 0); reason == "" {
+		t.Error("not paused on a delayed replica")
+	}
+}
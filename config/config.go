@@ -12,6 +12,7 @@ package config
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -96,6 +97,8 @@ type Config struct {
 	MonitoringQueryTimeout                    int                    `mapstructure:"monitoring-query-timeout" toml:"monitoring-query-timeout" json:"monitoringQueryTimeout"`
 	MonitoringOpenStateScript                 string                 `mapstructure:"monitoring-open-state-script" toml:"monitoring-open-state-script" json:"monitoringOpenStateScript"`
 	MonitoringCloseStateScript                string                 `mapstructure:"monitoring-close-state-script" toml:"monitoring-close-state-script" json:"monitoringCloseStateScript"`
+	MonitoringStateJournal                    bool                   `mapstructure:"monitoring-state-journal" toml:"monitoring-state-journal" json:"monitoringStateJournal"`
+	MonitoringStateJournalKeep                int                    `mapstructure:"monitoring-state-journal-keep" toml:"monitoring-state-journal-keep" json:"monitoringStateJournalKeep"`
 	Interactive                               bool                   `mapstructure:"interactive" toml:"-" json:"interactive"`
 	Verbose                                   bool                   `mapstructure:"verbose" toml:"verbose" json:"verbose"`
 	LogFile                                   string                 `scope:"server" mapstructure:"log-file" toml:"log-file" json:"logFile"`
@@ -176,7 +179,12 @@ type Config struct {
 	MasterSlavePgStream                       bool                   `mapstructure:"replication-master-slave-pg-stream" toml:"replication-master-slave-pg-stream" json:"replicationMasterSlavePgStream"`
 	MasterSlavePgLogical                      bool                   `mapstructure:"replication-master-slave-pg-logical" toml:"replication-master-slave-pg-logical" json:"replicationMasterSlavePgLogical"`
 	ReplicationNoRelay                        bool                   `mapstructure:"replication-master-slave-never-relay" toml:"replication-master-slave-never-relay" json:"replicationMasterSlaveNeverRelay"`
+	ReplicationRepointWaitTimeout             int                    `mapstructure:"replication-repoint-wait-timeout" toml:"replication-repoint-wait-timeout" json:"replicationRepointWaitTimeout"`
 	ReplicationRestartOnSQLErrorMatch         string                 `mapstructure:"replication-restart-on-sqlerror-match" toml:"replication-restart-on-sqlerror-match" json:"eeplicationRestartOnSqlLErrorMatch"`
+	ReplicationErrorPolicies                  string                 `mapstructure:"replication-error-policies" toml:"replication-error-policies" json:"replicationErrorPolicies"`
+	ReplicationErrorPolicyMaxActions          int                    `mapstructure:"replication-error-policy-max-actions" toml:"replication-error-policy-max-actions" json:"replicationErrorPolicyMaxActions"`
+	ReplicationErrorPolicyWindow              int                    `mapstructure:"replication-error-policy-window" toml:"replication-error-policy-window" json:"replicationErrorPolicyWindow"`
+	ReplicationErrorPolicyBinlogServerId      int                    `mapstructure:"replication-error-policy-binlog-server-id" toml:"replication-error-policy-binlog-server-id" json:"replicationErrorPolicyBinlogServerId"`
 	SwitchWaitKill                            int64                  `mapstructure:"switchover-wait-kill" toml:"switchover-wait-kill" json:"switchoverWaitKill"`
 	SwitchWaitTrx                             int64                  `mapstructure:"switchover-wait-trx" toml:"switchover-wait-trx" json:"switchoverWaitTrx"`
 	SwitchWaitWrite                           int                    `mapstructure:"switchover-wait-write-query" toml:"switchover-wait-write-query" json:"switchoverWaitWriteQuery"`
@@ -219,6 +227,11 @@ type Config struct {
 	PrintDelayStatInterval                    int                    `mapstructure:"print-delay-stat-interval" toml:"print-delay-stat-interval" json:"printDelayStatInterval"`
 	DelayStatRotate                           int                    `mapstructure:"delay-stat-rotate" toml:"delay-stat-rotate" json:"delayStatRotate"`
 	FailoverCheckDelayStat                    bool                   `mapstructure:"failover-check-delay-stat" toml:"failover-check-delay-stat" json:"failoverCheckDelayStat"`
+	TopologyZones                             string                 `mapstructure:"topology-zones" toml:"topology-zones" json:"topologyZones"`
+	FailZonePreferLocal                       bool                   `mapstructure:"failover-zone-prefer-local" toml:"failover-zone-prefer-local" json:"failoverZonePreferLocal"`
+	FailZoneCrossRegion                       bool                   `mapstructure:"failover-zone-cross-region" toml:"failover-zone-cross-region" json:"failoverZoneCrossRegion"`
+	FailZoneMin                               int                    `mapstructure:"failover-zone-min" toml:"failover-zone-min" json:"failoverZoneMin"`
+	FailZoneProxyCrossRegion                  bool                   `mapstructure:"failover-zone-proxy-cross-region" toml:"failover-zone-proxy-cross-region" json:"failoverZoneProxyCrossRegion"`
 	Autorejoin                                bool                   `mapstructure:"autorejoin" toml:"autorejoin" json:"autorejoin"`
 	Autoseed                                  bool                   `mapstructure:"autoseed" toml:"autoseed" json:"autoseed"`
 	AutorejoinForceRestore                    bool                   `mapstructure:"autorejoin-force-restore" toml:"autorejoin-force-restore" json:"autorejoinForceRestore"`
@@ -238,6 +251,13 @@ type Config struct {
 	CheckBinServerId                          int                    `mapstructure:"check-binlog-server-id" toml:"check-binlog-server-id" json:"checkBinlogServerId"`
 	CheckGrants                               bool                   `mapstructure:"check-grants" toml:"check-grants" json:"checkGrants"`
 	RplChecks                                 bool                   `mapstructure:"check-replication-state" toml:"check-replication-state" json:"checkReplicationState"`
+	ChecksumChunkTime                         int                    `mapstructure:"checksum-chunk-time" toml:"checksum-chunk-time" json:"checksumChunkTime"`
+	ChecksumChunkSize                         int                    `mapstructure:"checksum-chunk-size" toml:"checksum-chunk-size" json:"checksumChunkSize"`
+	ChecksumChunkSizeMax                      int                    `mapstructure:"checksum-chunk-size-max" toml:"checksum-chunk-size-max" json:"checksumChunkSizeMax"`
+	ChecksumMaxDelay                          int                    `mapstructure:"checksum-max-delay" toml:"checksum-max-delay" json:"checksumMaxDelay"`
+	ChecksumMaxThreadsRunning                 int                    `mapstructure:"checksum-max-threads-running" toml:"checksum-max-threads-running" json:"checksumMaxThreadsRunning"`
+	ChecksumRepair                            string                 `mapstructure:"checksum-repair" toml:"checksum-repair" json:"checksumRepair"`
+	ChecksumSyncTimeout                       int                    `mapstructure:"checksum-sync-timeout" toml:"checksum-sync-timeout" json:"checksumSyncTimeout"`
 	RplCheckErrantTrx                         bool                   `mapstructure:"check-replication-errant-trx" toml:"check-replication-errant-trx" json:"checkReplicationErrantTrx"`
 	ForceSlaveHeartbeat                       bool                   `mapstructure:"force-slave-heartbeat" toml:"force-slave-heartbeat" json:"forceSlaveHeartbeat"`
 	ForceSlaveHeartbeatTime                   int                    `mapstructure:"force-slave-heartbeat-time" toml:"force-slave-heartbeat-time" json:"forceSlaveHeartbeatTime"`
@@ -292,6 +312,14 @@ type Config struct {
 	TeamsUrl                                  string                 `mapstructure:"alert-teams-url" toml:"alert-teams-url" json:"alertTeamsUrl"`
 	TeamsProxyUrl                             string                 `mapstructure:"alert-teams-proxy-url" toml:"alert-teams-proxy-url" json:"alertTeamsProxyUrl"`
 	TeamsAlertState                           string                 `mapstructure:"alert-teams-state" toml:"alert-teams-state" json:"alertTeamsState"`
+	AlertWebhookURL                           string                 `mapstructure:"alert-webhook-url" toml:"alert-webhook-url" json:"alertWebhookUrl"`
+	AlertWebhookTemplate                      string                 `mapstructure:"alert-webhook-template" toml:"alert-webhook-template" json:"alertWebhookTemplate"`
+	AlertPagerDutyURL                         string                 `mapstructure:"alert-pagerduty-url" toml:"alert-pagerduty-url" json:"alertPagerdutyUrl"`
+	AlertPagerDutyRoutingKey                  string                 `mapstructure:"alert-pagerduty-routing-key" toml:"alert-pagerduty-routing-key" json:"alertPagerdutyRoutingKey"`
+	AlertOpsgenieURL                          string                 `mapstructure:"alert-opsgenie-url" toml:"alert-opsgenie-url" json:"alertOpsgenieUrl"`
+	AlertOpsgenieApiKey                       string                 `mapstructure:"alert-opsgenie-api-key" toml:"alert-opsgenie-api-key" json:"alertOpsgenieApiKey"`
+	AlertRoutes                               string                 `mapstructure:"alert-routes" toml:"alert-routes" json:"alertRoutes"`
+	AlertGroupWindow                          int                    `mapstructure:"alert-group-window" toml:"alert-group-window" json:"alertGroupWindow"`
 	Heartbeat                                 bool                   `mapstructure:"heartbeat-table" toml:"heartbeat-table" json:"heartbeatTable"`
 	ExtProxyOn                                bool                   `mapstructure:"extproxy" toml:"extproxy" json:"extproxy"`
 	ExtProxyVIP                               string                 `mapstructure:"extproxy-address" toml:"extproxy-address" json:"extproxyAddress"`
@@ -413,6 +441,7 @@ type Config struct {
 	GraphiteWhitelist                         bool                   `scope:"server" mapstructure:"graphite-whitelist" toml:"graphite-whitelist" json:"graphiteWhitelist"`
 	GraphiteBlacklist                         bool                   `scope:"server" mapstructure:"graphite-blacklist" toml:"graphite-blacklist" json:"graphiteBlacklist"`
 	GraphiteWhitelistTemplate                 string                 `scope:"server" mapstructure:"graphite-whitelist-template" toml:"graphite-whitelist-template" json:"graphiteWhitelistTemplate"`
+	PrometheusLegacyMetrics                   bool                   `scope:"server" mapstructure:"prometheus-legacy-metrics" toml:"prometheus-legacy-metrics" json:"prometheusLegacyMetrics"`
 	GraphiteCarbonHost                        string                 `scope:"server" mapstructure:"graphite-carbon-host" toml:"graphite-carbon-host" json:"graphiteCarbonHost"`
 	GraphiteCarbonPort                        int                    `scope:"server" mapstructure:"graphite-carbon-port" toml:"graphite-carbon-port" json:"graphiteCarbonPort"`
 	GraphiteCarbonApiPort                     int                    `scope:"server" mapstructure:"graphite-carbon-api-port" toml:"graphite-carbon-api-port" json:"graphiteCarbonApiPort"`
@@ -420,6 +449,11 @@ type Config struct {
 	GraphiteCarbonLinkPort                    int                    `scope:"server" mapstructure:"graphite-carbon-link-port" toml:"graphite-carbon-link-port" json:"graphiteCarbonLinkPort"`
 	GraphiteCarbonPicklePort                  int                    `scope:"server" mapstructure:"graphite-carbon-pickle-port" toml:"graphite-carbon-pickle-port" json:"graphiteCarbonPicklePort"`
 	GraphiteCarbonPprofPort                   int                    `scope:"server" mapstructure:"graphite-carbon-pprof-port" toml:"graphite-carbon-pprof-port" json:"graphiteCarbonPprofPort"`
+	TracingExporter                           string                 `scope:"server" mapstructure:"tracing-exporter" toml:"tracing-exporter" json:"tracingExporter"`
+	TracingOTLPEndpoint                       string                 `scope:"server" mapstructure:"tracing-otlp-endpoint" toml:"tracing-otlp-endpoint" json:"tracingOtlpEndpoint"`
+	TracingOTLPInsecure                       bool                   `scope:"server" mapstructure:"tracing-otlp-insecure" toml:"tracing-otlp-insecure" json:"tracingOtlpInsecure"`
+	TracingFile                               string                 `scope:"server" mapstructure:"tracing-file" toml:"tracing-file" json:"tracingFile"`
+	TracingSampleRatio                        float64                `scope:"server" mapstructure:"tracing-sample-ratio" toml:"tracing-sample-ratio" json:"tracingSampleRatio"`
 	SysbenchBinaryPath                        string                 `scope:"server" mapstructure:"sysbench-binary-path" toml:"sysbench-binary-path" json:"sysbenchBinaryPath"`
 	SysbenchTest                              string                 `mapstructure:"sysbench-test" toml:"sysbench-test" json:"sysbenchBinaryTest"`
 	SysbenchV1                                bool                   `scope:"server" mapstructure:"sysbench-v1" toml:"sysbench-v1" json:"sysbenchV1"`
@@ -569,6 +603,11 @@ type Config struct {
 	APIUsersExternal                          string                 `mapstructure:"api-credentials-external" toml:"api-credentials-external" json:"apiCredentialsExternal"`
 	APIUsersACLAllow                          string                 `mapstructure:"api-credentials-acl-allow" toml:"api-credentials-acl-allow" json:"apiCredentialsACLAllow"`
 	APIUsersACLDiscard                        string                 `mapstructure:"api-credentials-acl-discard" toml:"api-credentials-acl-discard" json:"apiCredentialsACLDiscard"`
+	APITokenMaxTTL                            int                    `scope:"server" mapstructure:"api-token-max-ttl" toml:"api-token-max-ttl" json:"apiTokenMaxTtl"`
+	APIAuditLog                               bool                   `scope:"server" mapstructure:"api-audit-log" toml:"api-audit-log" json:"apiAuditLog"`
+	APIRoles                                  string                 `scope:"server" mapstructure:"api-roles" toml:"api-roles" json:"apiRoles"`
+	APIUsersRoles                             string                 `scope:"server" mapstructure:"api-credentials-roles" toml:"api-credentials-roles" json:"apiCredentialsRoles"`
+	OAuthGroupRoles                           string                 `scope:"server" mapstructure:"api-oauth-group-roles" toml:"api-oauth-group-roles" json:"apiOAuthGroupRoles"`
 	APISecureConfig                           bool                   `mapstructure:"api-credentials-secure-config" toml:"api-credentials-secure-config" json:"apiCredentialsSecureConfig"`
 	APIPort                                   string                 `scope:"server" mapstructure:"api-port" toml:"api-port" json:"apiPort"`
 	APIBind                                   string                 `scope:"server" mapstructure:"api-bind" toml:"api-bind" json:"apiBind"`
@@ -582,6 +621,8 @@ type Config struct {
 	SchedulerReceiverUseSSL                   bool                   `mapstructure:"scheduler-db-servers-receiver-use-ssl" toml:"scheduler-db-servers-receiver-use-ssl" json:"schedulerDbServersReceiverUseSSL"`
 	SchedulerBackupLogical                    bool                   `mapstructure:"scheduler-db-servers-logical-backup" toml:"scheduler-db-servers-logical-backup" json:"schedulerDbServersLogicalBackup"`
 	SchedulerBackupPhysical                   bool                   `mapstructure:"scheduler-db-servers-physical-backup" toml:"scheduler-db-servers-physical-backup" json:"schedulerDbServersPhysicalBackup"`
+	SchedulerBackupPhysicalIncremental        bool                   `mapstructure:"scheduler-db-servers-physical-backup-incremental" toml:"scheduler-db-servers-physical-backup-incremental" json:"schedulerDbServersPhysicalBackupIncremental"`
+	SchedulerBackupPhysicalDifferential       bool                   `mapstructure:"scheduler-db-servers-physical-backup-differential" toml:"scheduler-db-servers-physical-backup-differential" json:"schedulerDbServersPhysicalBackupDifferential"`
 	SchedulerDatabaseLogs                     bool                   `mapstructure:"scheduler-db-servers-logs" toml:"scheduler-db-servers-logs" json:"schedulerDbServersLogs"`
 	SchedulerDatabaseOptimize                 bool                   `mapstructure:"scheduler-db-servers-optimize" toml:"scheduler-db-servers-optimize" json:"schedulerDbServersOptimize"`
 	SchedulerDatabaseAnalyze                  bool                   `mapstructure:"scheduler-db-servers-analyze" toml:"scheduler-db-servers-analyze" json:"schedulerDbServersAnalyze"`
@@ -592,12 +633,26 @@ type Config struct {
 	AnalyzeUseSQL                             bool                   `mapstructure:"analyze-use-sql" toml:"analyze-use-sql" json:"analyzeUseSql"`
 	BackupLogicalCron                         string                 `mapstructure:"scheduler-db-servers-logical-backup-cron" toml:"scheduler-db-servers-logical-backup-cron" json:"schedulerDbServersLogicalBackupCron"`
 	BackupPhysicalCron                        string                 `mapstructure:"scheduler-db-servers-physical-backup-cron" toml:"scheduler-db-servers-physical-backup-cron" json:"schedulerDbServersPhysicalBackupCron"`
+	BackupPhysicalIncrementalCron             string                 `mapstructure:"scheduler-db-servers-physical-backup-incremental-cron" toml:"scheduler-db-servers-physical-backup-incremental-cron" json:"schedulerDbServersPhysicalBackupIncrementalCron"`
+	BackupPhysicalDifferentialCron            string                 `mapstructure:"scheduler-db-servers-physical-backup-differential-cron" toml:"scheduler-db-servers-physical-backup-differential-cron" json:"schedulerDbServersPhysicalBackupDifferentialCron"`
+	SchedulerBackupVerify                     bool                   `mapstructure:"scheduler-db-servers-backup-verify" toml:"scheduler-db-servers-backup-verify" json:"schedulerDbServersBackupVerify"`
+	BackupVerifyCron                          string                 `mapstructure:"scheduler-db-servers-backup-verify-cron" toml:"scheduler-db-servers-backup-verify-cron" json:"schedulerDbServersBackupVerifyCron"`
 	BackupDatabaseLogCron                     string                 `mapstructure:"scheduler-db-servers-logs-cron" toml:"scheduler-db-servers-logs-cron" json:"schedulerDbServersLogsCron"`
 	BackupDatabaseOptimizeCron                string                 `mapstructure:"scheduler-db-servers-optimize-cron" toml:"scheduler-db-servers-optimize-cron" json:"schedulerDbServersOptimizeCron"`
 	BackupDatabaseAnalyzeCron                 string                 `mapstructure:"scheduler-db-servers-analyze-cron" toml:"scheduler-db-servers-analyze-cron" json:"schedulerDbServersAnalyzeCron"`
 	BackupSaveScript                          string                 `mapstructure:"backup-save-script" toml:"backup-save-script" json:"backupSaveScript"`
 	BackupLoadScript                          string                 `mapstructure:"backup-load-script" toml:"backup-load-script" json:"backupLoadScript"`
 	CompressBackups                           bool                   `mapstructure:"compress-backups" toml:"compress-backups" json:"compressBackups"`
+	BackupEncrypt                             bool                   `mapstructure:"backup-encrypt" toml:"backup-encrypt" json:"backupEncrypt"`
+	BackupEncryptionMasterKey                 string                 `mapstructure:"backup-encryption-master-key" toml:"backup-encryption-master-key" json:"-"`
+	BackupStorage                             string                 `mapstructure:"backup-storage" toml:"backup-storage" json:"backupStorage"`
+	BackupStoragePath                         string                 `mapstructure:"backup-storage-path" toml:"backup-storage-path" json:"backupStoragePath"`
+	BackupStoragePrefix                       string                 `mapstructure:"backup-storage-prefix" toml:"backup-storage-prefix" json:"backupStoragePrefix"`
+	BackupStoragePartSize                     int                    `mapstructure:"backup-storage-part-size" toml:"backup-storage-part-size" json:"backupStoragePartSize"`
+	BackupStorageAzureAccount                 string                 `mapstructure:"backup-storage-azure-account" toml:"backup-storage-azure-account" json:"backupStorageAzureAccount"`
+	BackupStorageAzureKey                     string                 `mapstructure:"backup-storage-azure-key" toml:"backup-storage-azure-key" json:"-"`
+	BackupStorageAzureContainer               string                 `mapstructure:"backup-storage-azure-container" toml:"backup-storage-azure-container" json:"backupStorageAzureContainer"`
+	BackupStorageAzureEndpoint                string                 `mapstructure:"backup-storage-azure-endpoint" toml:"backup-storage-azure-endpoint" json:"backupStorageAzureEndpoint"`
 	SchedulerDatabaseLogsTableRotate          bool                   `mapstructure:"scheduler-db-servers-logs-table-rotate" toml:"scheduler-db-servers-logs-table-rotate" json:"schedulerDbServersLogsTableRotate"`
 	SchedulerDatabaseLogsTableRotateCron      string                 `mapstructure:"scheduler-db-servers-logs-table-rotate-cron" toml:"scheduler-db-servers-logs-table-rotate-cron" json:"schedulerDbServersLogsTableRotateCron"`
 	SchedulerMaintenanceDatabaseLogsTableKeep int                    `mapstructure:"scheduler-db-servers-logs-table-keep" toml:"scheduler-db-servers-logs-table-keep" json:"schedulerDatabaseLogsTableKeep"`
@@ -613,6 +668,13 @@ type Config struct {
 	BackupLogicalLoadThreads                  int                    `mapstructure:"backup-logical-load-threads" toml:"backup-logical-load-threads" json:"backupLogicalLoadThreads"`
 	BackupLogicalDumpThreads                  int                    `mapstructure:"backup-logical-dump-threads" toml:"backup-logical-dump-threads" json:"backupLogicalDumpThreads"`
 	BackupLogicalDumpSystemTables             bool                   `mapstructure:"backup-logical-dump-system-tables" toml:"backup-logical-dump-system-tables" json:"backupLogicalDumpSystemTables"`
+	BackupThrottleBandwidth                   int                    `mapstructure:"backup-throttle-bandwidth" toml:"backup-throttle-bandwidth" json:"backupThrottleBandwidth"`
+	BackupThrottleThreads                     int                    `mapstructure:"backup-throttle-threads" toml:"backup-throttle-threads" json:"backupThrottleThreads"`
+	BackupThrottleMaxJobs                     int                    `mapstructure:"backup-throttle-max-jobs" toml:"backup-throttle-max-jobs" json:"backupThrottleMaxJobs"`
+	BackupThrottleMaxDelay                    int                    `mapstructure:"backup-throttle-max-delay" toml:"backup-throttle-max-delay" json:"backupThrottleMaxDelay"`
+	BackupThrottleMaxThreadsRunning           int                    `mapstructure:"backup-throttle-max-threads-running" toml:"backup-throttle-max-threads-running" json:"backupThrottleMaxThreadsRunning"`
+	BackupThrottleDeferTimeout                int                    `mapstructure:"backup-throttle-defer-timeout" toml:"backup-throttle-defer-timeout" json:"backupThrottleDeferTimeout"`
+	BackupThrottleMaxPause                    int                    `mapstructure:"backup-throttle-max-pause" toml:"backup-throttle-max-pause" json:"backupThrottleMaxPause"`
 	BackupPhysicalType                        string                 `mapstructure:"backup-physical-type" toml:"backup-physical-type" json:"backupPhysicalType"`
 	BackupKeepUntilValid                      bool                   `mapstructure:"backup-keep-until-valid" toml:"backup-keep-until-valid" json:"backupKeepUntilValid"`
 	BackupKeepHourly                          int                    `mapstructure:"backup-keep-hourly" toml:"backup-keep-hourly" json:"backupKeepHourly"`
@@ -620,6 +682,10 @@ type Config struct {
 	BackupKeepWeekly                          int                    `mapstructure:"backup-keep-weekly" toml:"backup-keep-weekly" json:"backupKeepWeekly"`
 	BackupKeepMonthly                         int                    `mapstructure:"backup-keep-monthly" toml:"backup-keep-monthly" json:"backupKeepMonthly"`
 	BackupKeepYearly                          int                    `mapstructure:"backup-keep-yearly" toml:"backup-keep-yearly" json:"backupKeepYearly"`
+	BackupRetention                           bool                   `mapstructure:"backup-retention" toml:"backup-retention" json:"backupRetention"`
+	BackupVerifyMethod                        string                 `mapstructure:"backup-verify-method" toml:"backup-verify-method" json:"backupVerifyMethod"`
+	BackupVerifyServer                        string                 `mapstructure:"backup-verify-server" toml:"backup-verify-server" json:"backupVerifyServer"`
+	BackupVerifyRowTolerance                  int                    `mapstructure:"backup-verify-row-count-tolerance" toml:"backup-verify-row-count-tolerance" json:"backupVerifyRowCountTolerance"`
 	BackupRestic                              bool                   `mapstructure:"backup-restic" toml:"backup-restic" json:"backupRestic"`
 	BackupResticBinaryPath                    string                 `mapstructure:"backup-restic-binary-path" toml:"backup-restic-binary-path" json:"backupResticBinaryPath"`
 	BackupResticAwsAccessKeyId                string                 `mapstructure:"backup-restic-aws-access-key-id" toml:"backup-restic-aws-access-key-id" json:"backupResticAwsAccessKeyId"`
@@ -644,6 +710,8 @@ type Config struct {
 	BackupMysqlclientPath                     string                 `mapstructure:"backup-mysqlclient-path" toml:"backup-mysqlclient-path" json:"backupMysqlclientgPath"`
 	BackupBinlogs                             bool                   `mapstructure:"backup-binlogs" toml:"backup-binlogs" json:"backupBinlogs"`
 	BackupBinlogsKeep                         int                    `mapstructure:"backup-binlogs-keep" toml:"backup-binlogs-keep" json:"backupBinlogsKeep"`
+	BackupBinlogsArchiver                     bool                   `mapstructure:"backup-binlogs-archiver" toml:"backup-binlogs-archiver" json:"backupBinlogsArchiver"`
+	BackupBinlogsArchiverServerId             int                    `mapstructure:"backup-binlogs-archiver-server-id" toml:"backup-binlogs-archiver-server-id" json:"backupBinlogsArchiverServerId"`
 	BinlogCopyMode                            string                 `mapstructure:"binlog-copy-mode" toml:"binlog-copy-mode" json:"binlogCopyMode"`
 	BinlogCopyScript                          string                 `mapstructure:"binlog-copy-script" toml:"binlog-copy-script" json:"binlogCopyScript"`
 	BinlogRotationScript                      string                 `mapstructure:"binlog-rotation-script" toml:"binlog-rotation-script" json:"binlogRotationScript"`
@@ -929,6 +997,8 @@ const (
 	GrantClusterRotatePasswords    string = "cluster-rotate-passwords"
 	GrantClusterResetSLA           string = "cluster-reset-sla"
 	GrantClusterDebug              string = "cluster-debug"
+	GrantClusterAlerts             string = "cluster-alerts"
+	GrantClusterAudit              string = "cluster-audit"
 
 	GrantProxyConfigCreate      string = "proxy-config-create"
 	GrantProxyConfigGet         string = "proxy-config-get"
@@ -1096,9 +1166,13 @@ func (conf *Config) DecryptSecretsFromConfig() {
 		"backup-restic-aws-access-secret":       {"", ""},
 		"backup-streaming-aws-access-secret":    {"", ""},
 		"backup-restic-password":                {"", ""},
+		"backup-encryption-master-key":          {"", ""},
+		"backup-storage-azure-key":              {"", ""},
 		"arbitration-external-secret":           {"", ""},
 		"alert-pushover-user-token":             {"", ""},
 		"alert-pushover-app-token":              {"", ""},
+		"alert-pagerduty-routing-key":           {"", ""},
+		"alert-opsgenie-api-key":                {"", ""},
 		"git-acces-token":                       {"", ""},
 		"mail-smtp-password":                    {"", ""},
 		"cloud18-gitlab-password":               {"", ""},
@@ -1418,6 +1492,69 @@ func (conf *Config) GetDecryptedValue(key string) string {
 	return conf.Secrets[key].Value
 }
 
+// GetBackupRetentionPolicy returns the grandfather-father-son policy of the
+// backup-keep-* settings
+func (conf *Config) GetBackupRetentionPolicy() BackupRetentionPolicy {
+	return BackupRetentionPolicy{
+		Hourly:  conf.BackupKeepHourly,
+		Daily:   conf.BackupKeepDaily,
+		Weekly:  conf.BackupKeepWeekly,
+		Monthly: conf.BackupKeepMonthly,
+		Yearly:  conf.BackupKeepYearly,
+	}
+}
+
+// GetBackupMasterKey returns the key wrapping the backup data keys, the
+// backup-encryption-master-key secret or a key derived from the key of
+// monitoring-key-path so the secrets key is not reused for the backups
+func (conf *Config) GetBackupMasterKey() ([]byte, error) {
+	if value := conf.GetDecryptedValue("backup-encryption-master-key"); value != "" {
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("backup-encryption-master-key is not hex encoded: %s", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("backup-encryption-master-key must be 32 bytes, got %d", len(key))
+		}
+		return key, nil
+	}
+	if conf.SecretKey == nil {
+		return nil, errors.New("No backup encryption master key, set backup-encryption-master-key or monitoring-key-path")
+	}
+	return crypto.DeriveKey(conf.SecretKey, "replication-manager backup master key")
+}
+
+// GetBackupThreads returns the IO threads of a backup tool within the
+// backup-throttle-threads budget
+func (conf *Config) GetBackupThreads(threads int) int {
+	if conf.BackupThrottleThreads > 0 && (threads <= 0 || threads > conf.BackupThrottleThreads) {
+		return conf.BackupThrottleThreads
+	}
+	return threads
+}
+
+// GetBackupThrottleReason returns why backups are held back given the largest
+// replica delay and the Threads_running of the master, empty when they run
+func (conf *Config) GetBackupThrottleReason(delay int64, threadsRunning int64) string {
+	return getLoadReason(delay, threadsRunning, conf.BackupThrottleMaxDelay, conf.BackupThrottleMaxThreadsRunning)
+}
+
+// GetChecksumThrottleReason returns why the table checksum pauses, empty
+// under checksum-max-delay and checksum-max-threads-running
+func (conf *Config) GetChecksumThrottleReason(delay int64, threadsRunning int64) string {
+	return getLoadReason(delay, threadsRunning, conf.ChecksumMaxDelay, conf.ChecksumMaxThreadsRunning)
+}
+
+func getLoadReason(delay int64, threadsRunning int64, maxDelay int, maxThreadsRunning int) string {
+	if maxDelay > 0 && delay > int64(maxDelay) {
+		return fmt.Sprintf("replication delay %ds over %ds", delay, maxDelay)
+	}
+	if maxThreadsRunning > 0 && threadsRunning > int64(maxThreadsRunning) {
+		return fmt.Sprintf("master Threads_running %d over %d", threadsRunning, maxThreadsRunning)
+	}
+	return ""
+}
+
 func (conf *Config) PrintSecret(value string) string {
 	return masker.String(masker.MAddress, value)
 }
@@ -1870,6 +2007,8 @@ func (conf *Config) GetGrantType() map[string]string {
 		GrantClusterShowCertificates:   GrantClusterShowCertificates,
 		GrantClusterResetSLA:           GrantClusterResetSLA,
 		GrantClusterRotatePasswords:    GrantClusterRotatePasswords,
+		GrantClusterAlerts:             GrantClusterAlerts,
+		GrantClusterAudit:              GrantClusterAudit,
 		GrantProxyConfigCreate:         GrantProxyConfigCreate,
 		GrantProxyConfigGet:            GrantProxyConfigGet,
 		GrantProxyConfigRessource:      GrantProxyConfigRessource,
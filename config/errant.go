@@ -0,0 +1,72 @@
+package config
+
+// Reconciliations of the errant transactions of a replica
+const (
+	ErrantReconcileInject = "inject"
+	ErrantReconcileReseed = "reseed"
+)
+
+// BinlogEvent is an event of SHOW BINLOG EVENTS
+type BinlogEvent struct {
+	LogName   string `json:"logName"`
+	Pos       uint64 `json:"pos"`
+	EventType string `json:"eventType"`
+	ServerId  uint64 `json:"serverId"`
+	EndLogPos uint64 `json:"endLogPos"`
+	Info      string `json:"info"`
+}
+
+// ErrantTransaction is a transaction of a replica not executed by the
+// master, with its events when the binary log holding it is still there
+type ErrantTransaction struct {
+	Gtid   string        `json:"gtid"`
+	File   string        `json:"file"`
+	Purged bool          `json:"purged"`
+	Events []BinlogEvent `json:"events"`
+}
+
+// ErrantTransactions are the GTID set executed by a replica and not by the
+// master
+type ErrantTransactions struct {
+	Server       string              `json:"server"`
+	Set          string              `json:"set"`
+	Transactions []ErrantTransaction `json:"transactions"`
+	Error        string              `json:"error"`
+}
+
+// ErrantReconcileRequest asks for the plan of a reconciliation without
+// running it with DryRun
+type ErrantReconcileRequest struct {
+	DryRun bool `json:"dryRun"`
+}
+
+// ErrantReconcileStep are the statements run on a server
+type ErrantReconcileStep struct {
+	Server     string   `json:"server"`
+	Statements []string `json:"statements"`
+}
+
+// ErrantReconcilePlan is a reconciliation of the errant transactions of a
+// replica, the empty transactions injected on each server or the reseed of
+// the replica with a backup method
+type ErrantReconcilePlan struct {
+	Server string                `json:"server"`
+	Method string                `json:"method"`
+	DryRun bool                  `json:"dryRun"`
+	Set    string                `json:"set"`
+	Steps  []ErrantReconcileStep `json:"steps"`
+	Reseed string                `json:"reseed,omitempty"`
+}
+
+// GetEmptyTransactionStatements returns the statements committing an empty
+// transaction for each GTID
+func GetEmptyTransactionStatements(gtids []string) []string {
+	if len(gtids) == 0 {
+		return nil
+	}
+	statements := make([]string, 0, len(gtids)*3+1)
+	for _, gtid := range gtids {
+		statements = append(statements, "SET GTID_NEXT="+QuoteValue(&gtid), "BEGIN", "COMMIT")
+	}
+	return append(statements, "SET GTID_NEXT='AUTOMATIC'")
+}
@@ -0,0 +1,26 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmptyTransactionStatements(t *testing.T) {
+	if statements := GetEmptyTransactionStatements(nil); statements != nil {
+		t.Errorf("statements %v without GTID", statements)
+	}
+	statements := GetEmptyTransactionStatements([]string{"3e11fa47-71ca-11e1-9e33-c80aa9429562:5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:6"})
+	expected := "SET GTID_NEXT='3e11fa47-71ca-11e1-9e33-c80aa9429562:5';BEGIN;COMMIT;SET GTID_NEXT='3e11fa47-71ca-11e1-9e33-c80aa9429562:6';BEGIN;COMMIT;SET GTID_NEXT='AUTOMATIC'"
+	if got := strings.Join(statements, ";"); got != expected {
+		t.Errorf("statements %s", got)
+	}
+}
@@ -106,6 +106,10 @@ var ClusterError = map[string]string{
 	"ERR00094":  "Proxysql %s can not set %s as OFFLINE_SOFT: %s",
 	"ERR00095":  "ProxySQL %s could not load servers to runtime: %s",
 	"ERR00096":  "Proxysql %s can not save changes to disk: %s",
+	"ERR00097":  "Restore verification of %s backup %d from %s failed: %s",
+	"ERR00098":  "Failover zone quorum not reached, servers up in %d zones of %d",
+	"ERR00099":  "Failover candidate %s is in region %s and master in region %s, manual failover needed",
+	"ERR00100":  "Checksum needs the SUPER or SYSTEM_VARIABLES_ADMIN privilege to set binlog_format on master %s: %s",
 	"WARN0022":  "Rejoining standalone server %s to master %s",
 	"WARN0023":  "Number of failed master ping has been reached",
 	"WARN0045":  "Provision task is in queue",
@@ -193,6 +197,9 @@ var ClusterError = map[string]string{
 	"WARN0129":  "Provision is waiting for node %s running. Err: %s",
 	"WARN0130":  "Error while rotating system logs on %s: %s. Err: %s",
 	"WARN0131":  "Error while reading slow_log on %s. %s. Err: %s",
+	"WARN0132":  "Backups of %s throttled: %s",
+	"WARN0133":  "Proxy %s in region %s does not route to master %s in region %s",
+	"WARN0134":  "Replication error %s remediation %s of %s rate limited, %d remediations in the last %d seconds",
 	"MDEV20821": "MariaDB version has replication issue https://jira.mariadb.org/browse/MDEV-20821",
 	"MDEV28310": "MariaDB version has replication issue for non row format https://jira.mariadb.org/browse/MDEV-28310",
 	"MDEV19577": "MariaDB version has replication issue for non row format https://jira.mariadb.org/browse/MDEV-19577",
@@ -887,7 +887,7 @@ func (b *BackupMetaMap) GetPreviousBackup(backupTool string, source string) *Bac
 	var result *BackupMetadata
 	b.Map.Range(func(key, value interface{}) bool {
 		if backup, ok := value.(*BackupMetadata); ok {
-			if backup.BackupTool == backupTool && backup.Source == source {
+			if backup.BackupTool == backupTool && backup.Source == source && !backup.IsIncremental() && !backup.Pinned && !backup.Archived {
 				result = backup
 				return false
 			}
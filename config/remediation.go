@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Remediations of a replication error of a replica
+const (
+	RemediationSkip                 = "skip"
+	RemediationReapply              = "reapply"
+	RemediationResync               = "resync"
+	RemediationReseedLogicalBackup  = "reseed-logicalbackup"
+	RemediationReseedPhysicalBackup = "reseed-physicalbackup"
+	RemediationReseedLogicalMaster  = "reseed-logicalmaster"
+)
+
+// IsRemediation tells if an action is a known remediation
+func IsRemediation(action string) bool {
+	switch action {
+	case RemediationSkip, RemediationReapply, RemediationResync, RemediationReseedLogicalBackup, RemediationReseedPhysicalBackup, RemediationReseedLogicalMaster:
+		return true
+	}
+	return false
+}
+
+// GetReplicationErrorPolicies returns the remediations of
+// replication-error-policies by SQL error number, an entry errno=action.
+// Entries with an unknown action are ignored.
+func (conf *Config) GetReplicationErrorPolicies() map[string]string {
+	policies := make(map[string]string)
+	for _, entry := range strings.Split(conf.ReplicationErrorPolicies, ",") {
+		errno, action, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		errno, action = strings.TrimSpace(errno), strings.TrimSpace(action)
+		if _, err := strconv.Atoi(errno); err != nil || !IsRemediation(action) {
+			continue
+		}
+		policies[errno] = action
+	}
+	return policies
+}
@@ -0,0 +1,29 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import "testing"
+
+func TestReplicationErrorPolicies(t *testing.T) {
+	conf := Config{ReplicationErrorPolicies: "1062=skip, 1032 = reapply,1146=reseed-logicalbackup,1050=drop,abc=skip,1205"}
+	policies := conf.GetReplicationErrorPolicies()
+	if len(policies) != 3 {
+		t.Fatalf("policies %v", policies)
+	}
+	if policies["1062"] != RemediationSkip || policies["1032"] != RemediationReapply || policies["1146"] != RemediationReseedLogicalBackup {
+		t.Errorf("policies %v", policies)
+	}
+	if _, ok := policies["1050"]; ok {
+		t.Error("unknown action accepted")
+	}
+	if policies := (&Config{}).GetReplicationErrorPolicies(); len(policies) != 0 {
+		t.Errorf("policies %v without configuration", policies)
+	}
+}
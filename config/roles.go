@@ -0,0 +1,109 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/signal18/replication-manager/utils/misc"
+)
+
+const (
+	RoleViewer   string = "viewer"
+	RoleOperator string = "operator"
+	RoleDBA      string = "dba"
+	RoleAdmin    string = "admin"
+)
+
+// RoleBinding gives a role on the clusters matching a name pattern
+type RoleBinding struct {
+	Role    string `json:"role"`
+	Cluster string `json:"cluster"`
+}
+
+// Match tells if the binding applies to a cluster, the pattern follows path.Match
+func (rb *RoleBinding) Match(clusterName string) bool {
+	ok, err := path.Match(rb.Cluster, clusterName)
+	return err == nil && ok
+}
+
+// GetDefaultRoles returns the built-in roles as grant prefixes like api-credentials-acl-allow
+func (conf *Config) GetDefaultRoles() map[string][]string {
+	viewer := []string{"db-show", "db-config-get", "cluster-show", "proxy-config-get"}
+	operator := append([]string{"db-start", "db-stop", "db-maintenance", "db-readonly", "db-kill", "cluster-failover", "cluster-switchover", "cluster-traffic", "cluster-alerts", "cluster-rolling", "cluster-reset-sla", "proxy-start", "proxy-stop"}, viewer...)
+	dba := append([]string{"db", "cluster-replication", "cluster-checksum", "cluster-sharding", "cluster-bench", "cluster-test", "cluster-process", "cluster-settings", "cluster-config-graphs", "cluster-debug"}, operator...)
+	return map[string][]string{
+		RoleViewer:   viewer,
+		RoleOperator: operator,
+		RoleDBA:      dba,
+		RoleAdmin:    {"global", "cluster", "proxy", "db", "prov"},
+	}
+}
+
+// GetRoles returns the built-in roles overloaded by the api-roles definitions
+// role:prefix prefix,role:prefix
+func (conf *Config) GetRoles() (map[string][]string, error) {
+	roles := conf.GetDefaultRoles()
+	for _, def := range strings.Split(conf.APIRoles, ",") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		name, prefixes := misc.SplitPair(strings.TrimSpace(def))
+		if name == "" || prefixes == "" {
+			return roles, fmt.Errorf("invalid role %q: expecting role:grant grant", def)
+		}
+		roles[name] = strings.Fields(prefixes)
+	}
+	return roles, nil
+}
+
+// ParseRoleBindings reads api-credentials-roles or api-oauth-group-roles,
+// subject:role@cluster-pattern role,subject:role the pattern default to all clusters
+func ParseRoleBindings(s string) (map[string][]RoleBinding, error) {
+	bindings := make(map[string][]RoleBinding)
+	for _, def := range strings.Split(s, ",") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		subject, list := misc.SplitPair(strings.TrimSpace(def))
+		if subject == "" || list == "" {
+			return bindings, fmt.Errorf("invalid role binding %q: expecting subject:role@cluster", def)
+		}
+		for _, item := range strings.Fields(list) {
+			role, pattern, _ := strings.Cut(item, "@")
+			if pattern == "" {
+				pattern = "*"
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return bindings, fmt.Errorf("invalid role binding %q: %s", def, err)
+			}
+			bindings[subject] = append(bindings[subject], RoleBinding{Role: role, Cluster: pattern})
+		}
+	}
+	return bindings, nil
+}
+
+// GetRoleGrants returns the grants of a role from the grant list
+func GetRoleGrants(prefixes []string, grants map[string]string) []string {
+	var res []string
+	for key, value := range grants {
+		for _, prefix := range prefixes {
+			if prefix != "" && strings.HasPrefix(key, prefix) {
+				res = append(res, value)
+				break
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,58 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	conf := Config{APIRoles: "backup:db-backup db-restore cluster-show-backups"}
+	roles, err := conf.GetRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := GetRoleGrants(roles["backup"], conf.GetGrantType())
+	if !slices.Equal(grants, []string{GrantClusterShowBackups, GrantDBBackup, GrantDBRestore}) {
+		t.Errorf("unexpected backup role grants %v", grants)
+	}
+	viewer := GetRoleGrants(roles[RoleViewer], conf.GetGrantType())
+	if slices.Contains(viewer, GrantClusterFailover) || !slices.Contains(viewer, GrantDBShowStatus) {
+		t.Errorf("unexpected viewer grants %v", viewer)
+	}
+	operator := GetRoleGrants(roles[RoleOperator], conf.GetGrantType())
+	if !slices.Contains(operator, GrantClusterFailover) || slices.Contains(operator, GrantDBBackup) {
+		t.Errorf("unexpected operator grants %v", operator)
+	}
+	conf.APIRoles = "broken"
+	if _, err := conf.GetRoles(); err == nil {
+		t.Error("expected an error on role without grants")
+	}
+}
+
+func TestRoleBindings(t *testing.T) {
+	bindings, err := ParseRoleBindings("alice:operator@prod-* viewer,dbas:dba@prod-eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings["alice"]) != 2 || bindings["alice"][1].Cluster != "*" {
+		t.Fatalf("unexpected bindings %v", bindings)
+	}
+	if !bindings["alice"][0].Match("prod-eu") || bindings["alice"][0].Match("staging") {
+		t.Error("unexpected cluster pattern match")
+	}
+	if !bindings["dbas"][0].Match("prod-eu") || bindings["dbas"][0].Match("prod-us") {
+		t.Error("unexpected cluster name match")
+	}
+	if _, err := ParseRoleBindings("bob:admin@[prod"); err == nil {
+		t.Error("expected an error on bad pattern")
+	}
+}
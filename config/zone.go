@@ -0,0 +1,56 @@
+package config
+
+import "strings"
+
+// Zone is the location of a database server or a proxy, a zone being a
+// datacenter of a region
+type Zone struct {
+	Region string `json:"region"`
+	Zone   string `json:"zone"`
+}
+
+// IsEmpty tells if the location is unknown
+func (z Zone) IsEmpty() bool {
+	return z.Region == "" && z.Zone == ""
+}
+
+// IsSameZone tells if both locations are the same known zone
+func (z Zone) IsSameZone(other Zone) bool {
+	return z.Zone != "" && z.Zone == other.Zone && z.Region == other.Region
+}
+
+// IsCrossRegion tells if both locations are in known different regions
+func (z Zone) IsCrossRegion(other Zone) bool {
+	return z.Region != "" && other.Region != "" && z.Region != other.Region
+}
+
+// String returns region/zone
+func (z Zone) String() string {
+	if z.Region == "" {
+		return z.Zone
+	}
+	return z.Region + "/" + z.Zone
+}
+
+// Zones are locations by host:port
+type Zones map[string]Zone
+
+// GetTopologyZones returns the locations of topology-zones by host:port, an
+// entry host:port=region/zone or host:port=zone without region
+func (conf *Config) GetTopologyZones() Zones {
+	zones := make(Zones)
+	for _, entry := range strings.Split(conf.TopologyZones, ",") {
+		url, location, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || url == "" {
+			continue
+		}
+		var z Zone
+		if region, zone, ok := strings.Cut(location, "/"); ok {
+			z.Region, z.Zone = region, zone
+		} else {
+			z.Zone = location
+		}
+		zones[url] = z
+	}
+	return zones
+}
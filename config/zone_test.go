@@ -0,0 +1,41 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package config
+
+import "testing"
+
+func TestTopologyZones(t *testing.T) {
+	conf := Config{TopologyZones: "db1:3306=eu-west/paris, db2:3306=eu-west/lyon,db3:3306=us-east/nyc,db4:3306=paris,bad"}
+	zones := conf.GetTopologyZones()
+	if len(zones) != 4 {
+		t.Fatalf("zones %v", zones)
+	}
+	if z := zones["db1:3306"]; z.Region != "eu-west" || z.Zone != "paris" || z.String() != "eu-west/paris" {
+		t.Errorf("db1 zone %v", z)
+	}
+	if z := zones["db4:3306"]; z.Region != "" || z.Zone != "paris" {
+		t.Errorf("db4 zone %v", z)
+	}
+	if zones["db1:3306"].IsSameZone(zones["db4:3306"]) {
+		t.Error("zones of different regions are the same")
+	}
+	if zones["db1:3306"].IsCrossRegion(zones["db2:3306"]) {
+		t.Error("db1 and db2 are in the same region")
+	}
+	if !zones["db1:3306"].IsCrossRegion(zones["db3:3306"]) {
+		t.Error("db1 and db3 are in different regions")
+	}
+	if zones["db1:3306"].IsCrossRegion(zones["db4:3306"]) {
+		t.Error("unknown region is cross region")
+	}
+	if !(Zone{}).IsEmpty() || (Zone{}).IsSameZone(Zone{}) {
+		t.Error("unknown zones are the same")
+	}
+}
@@ -541,17 +541,19 @@ func (collector *Collector) GetNodes() ([]Host, error) {
 		Source string `json:"source"`
 	}
 	type SHost struct {
-		Nodename   Property `json:"nodename"`
-		Fqdn       Property `json:"fqdn"`
-		Version    Property `json:"version"`
-		Osname     Property `json:"os_name"`
-		Osvendor   Property `json:"os_vendor"`
-		Osrelease  Property `json:"os_release"`
-		Oskernel   Property `json:"os_kernel"`
-		Osarch     Property `json:"os_arch"`
-		Membytes   Property `json:"mem_bytes"`
-		Cpufreq    Property `json:"cpu_freq"`
-		Cputhreads Property `json:"cpu_threads"`
+		Nodename    Property `json:"nodename"`
+		Fqdn        Property `json:"fqdn"`
+		Version     Property `json:"version"`
+		Osname      Property `json:"os_name"`
+		Osvendor    Property `json:"os_vendor"`
+		Osrelease   Property `json:"os_release"`
+		Oskernel    Property `json:"os_kernel"`
+		Osarch      Property `json:"os_arch"`
+		Membytes    Property `json:"mem_bytes"`
+		Cpufreq     Property `json:"cpu_freq"`
+		Cputhreads  Property `json:"cpu_threads"`
+		Loccity     Property `json:"loc_city"`
+		Locbuilding Property `json:"loc_building"`
 	}
 
 	type Message struct {
@@ -579,6 +581,8 @@ func (collector *Collector) GetNodes() ([]Host, error) {
 		nhosts[i].Node_name = agent.Nodename.Value
 		nhosts[i].Os_kernel = agent.Oskernel.Value
 		nhosts[i].Os_name = agent.Osname.Value
+		nhosts[i].Loc_city = agent.Loccity.Value
+		nhosts[i].Loc_building = agent.Locbuilding.Value
 		//		r.Data[i].Ips, _ = collector.getNetwork(agent.Node_id)
 		//		r.Data[i].Svc, _ = collector.getNodeServices(agent.Node_id)
 		i++
@@ -61,16 +61,18 @@ type RulesetVariable struct {
 }
 
 type Host struct {
-	Id        int64  `json:"id"`
-	Node_id   string `json:"node_id"`
-	Node_name string `json:"nodename"`
-	Cpu_cores int64  `json:"cpu_cores"`
-	Cpu_freq  int64  `json:"cpu_freq"`
-	Mem_bytes int64  `json:"mem_bytes"`
-	Os_kernel string `json:"os_kernel"`
-	Os_name   string `json:"os_name"`
-	Ips       []Addr
-	Svc       []Service
+	Id           int64  `json:"id"`
+	Node_id      string `json:"node_id"`
+	Node_name    string `json:"nodename"`
+	Cpu_cores    int64  `json:"cpu_cores"`
+	Cpu_freq     int64  `json:"cpu_freq"`
+	Mem_bytes    int64  `json:"mem_bytes"`
+	Os_kernel    string `json:"os_kernel"`
+	Os_name      string `json:"os_name"`
+	Loc_city     string `json:"loc_city"`
+	Loc_building string `json:"loc_building"`
+	Ips          []Addr
+	Svc          []Service
 }
 type Service struct {
 	Id         int    `json:"id"`
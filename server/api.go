@@ -39,9 +39,11 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/signal18/replication-manager/cert"
 	"github.com/signal18/replication-manager/cluster"
+	"github.com/signal18/replication-manager/config"
 	"github.com/signal18/replication-manager/regtest"
 	"github.com/signal18/replication-manager/share"
 	"github.com/signal18/replication-manager/utils/githelper"
+	"github.com/signal18/replication-manager/utils/openmetrics"
 )
 
 //RSA KEYS AND INITIALISATION
@@ -222,6 +224,7 @@ func (repman *ReplicationManager) apiserver() {
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAuthCallback)),
 	))
 	router.Handle("/api/clusters", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusters)),
 	))
 	router.Handle("/api/clusters/peers", negroni.New(
@@ -244,24 +247,46 @@ func (repman *ReplicationManager) apiserver() {
 	))
 	//UNPROTECTED ENDPOINTS FOR SETTINGS
 	router.Handle("/api/monitor", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxReplicationManager)),
 	))
 	//PROTECTED ENDPOINTS FOR SETTINGS
 	router.Handle("/api/monitor", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxReplicationManager)),
 	))
 
 	router.Handle("/api/monitor/actions/adduser/{userName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterGrant)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddUser)),
 	))
+	router.Handle("/api/monitor/tokens", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxTokens)),
+	))
+	router.Handle("/api/monitor/actions/addtoken", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddToken)),
+	))
+	router.Handle("/api/monitor/actions/revoketoken/{tokenId}", negroni.New(
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRevokeToken)),
+	))
+	router.Handle("/api/monitor/audit", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterAudit)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAudit)),
+	))
 
 	repman.apiDatabaseUnprotectedHandler(router)
 	repman.apiDatabaseProtectedHandler(router)
 	repman.apiClusterUnprotectedHandler(router)
 	repman.apiClusterProtectedHandler(router)
 	repman.apiProxyProtectedHandler(router)
+	router.Use(repman.traceMiddleware)
+	router.Use(repman.auditMiddleware)
 
 	tlsConfig := Repmanv3TLS{
 		Enabled: false,
@@ -324,7 +349,9 @@ func (repman *ReplicationManager) apiserver() {
 /////////////////////////////////////////
 
 func (repman *ReplicationManager) isValidRequest(r *http.Request) (bool, error) {
-
+	if _, ok, err := repman.getAPIToken(r); ok {
+		return err == nil, err
+	}
 	_, err := request.ParseFromRequest(r, request.AuthorizationHeaderExtractor, func(token *jwt.Token) (interface{}, error) {
 		vk, _ := jwt.ParseRSAPublicKeyFromPEM(verificationKey)
 		return vk, nil
@@ -336,6 +363,13 @@ func (repman *ReplicationManager) isValidRequest(r *http.Request) (bool, error)
 }
 
 func (repman *ReplicationManager) IsValidClusterACL(r *http.Request, cluster *cluster.Cluster) (bool, string) {
+	if tok, ok, err := repman.getAPIToken(r); ok {
+		if err != nil {
+			return false, ""
+		}
+		grants, declared := getRouteGrants(r)
+		return declared && cluster.IsValidTokenACL(tok, grants), tok.User
+	}
 
 	token, err := request.ParseFromRequest(r, request.AuthorizationHeaderExtractor, func(token *jwt.Token) (interface{}, error) {
 		vk, _ := jwt.ParseRSAPublicKeyFromPEM(verificationKey)
@@ -347,15 +381,24 @@ func (repman *ReplicationManager) IsValidClusterACL(r *http.Request, cluster *cl
 		mycutinfo := userinfo.(map[string]interface{})
 		meuser := mycutinfo["Name"].(string)
 		mepwd := mycutinfo["Password"].(string)
+		authMethod := "password"
 		_, ok := mycutinfo["profile"]
 
 		if ok {
 			if strings.Contains(mycutinfo["profile"].(string), repman.Conf.OAuthProvider) /*&& strings.Contains(mycutinfo["email_verified"]*/ {
 				meuser = mycutinfo["email"].(string)
-				return cluster.IsValidACL(meuser, mepwd, r.URL.Path, "oidc"), meuser
+				authMethod = "oidc"
 			}
 		}
-		return cluster.IsValidACL(meuser, mepwd, r.URL.Path, "password"), meuser
+		if groups := getTokenGroups(mycutinfo); len(groups) > 0 {
+			authMethod = "oidc"
+			cluster.LoadOAuthUser(meuser, groups)
+		}
+		if grants, ok := getRouteGrants(r); ok {
+			return cluster.IsValidRouteACL(meuser, mepwd, grants, authMethod), meuser
+		}
+		cluster.LogModulePrintf(cluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "ACL check failed for user %s, route declares no grants : %s", meuser, r.URL.Path)
+		return false, meuser
 	}
 	return false, ""
 }
@@ -413,9 +456,9 @@ func (repman *ReplicationManager) loginHandler(w http.ResponseWriter, r *http.Re
 
 				u.GitToken = token
 				u.Password = user.Password // Now using git-login has no profile so must use password for check
-				cluster.APIUsers[user.Username] = u
+				cluster.SetAPIUser(u)
 			}
-		} else if !cluster.IsValidACL(user.Username, user.Password, r.URL.Path, "password") {
+		} else if !cluster.IsValidACL(user.Username, user.Password, "password") {
 			continue
 		}
 
@@ -502,14 +545,22 @@ func (repman *ReplicationManager) handlerMuxAuthCallback(w http.ResponseWriter,
 
 	r.Header.Get("Accept")
 
+	var oauthClaims struct {
+		Groups []string `json:"groups"`
+	}
+	if err := userInfo.Claims(&oauthClaims); err != nil {
+		log.Printf("OAuth callback failed to read groups claim: %v\n", err)
+	}
+
 	for _, cluster := range repman.Clusters {
 		//validate user credentials
-		if cluster.IsValidACL(userInfo.Email, cluster.APIUsers[userInfo.Email].Password, r.URL.Path, "oidc") {
+		cluster.LoadOAuthUser(userInfo.Email, oauthClaims.Groups)
+		if cluster.IsValidACL(userInfo.Email, cluster.APIUsers[userInfo.Email].Password, "oidc") {
 			apiuser := cluster.APIUsers[userInfo.Email]
 			apiuser.GitToken = oauth2Token.AccessToken
 			tmp := strings.Split(userInfo.Profile, "/")
 			apiuser.GitUser = tmp[len(tmp)-1]
-			cluster.APIUsers[userInfo.Email] = apiuser
+			cluster.SetAPIUser(apiuser)
 
 			if cluster.Conf.Cloud18 {
 				new_token, user_id := githelper.GetGitLabTokenOAuth(oauth2Token.AccessToken, cluster.Conf.LogGit)
@@ -547,7 +598,8 @@ func (repman *ReplicationManager) handlerMuxAuthCallback(w http.ResponseWriter,
 				Name     string
 				Role     string
 				Password string
-			}{userInfo.Email, "Member", cluster.APIUsers[userInfo.Email].Password}
+				Groups   []string
+			}{userInfo.Email, "Member", cluster.APIUsers[userInfo.Email].Password, oauthClaims.Groups}
 			password := cluster.APIUsers[userInfo.Email].Password
 			signer.Claims = claims
 			sk, _ := jwt.ParseRSAPrivateKeyFromPEM(signingKey)
@@ -715,6 +767,20 @@ func (repman *ReplicationManager) handlerMuxPeerClusters(w http.ResponseWriter,
 
 func (repman *ReplicationManager) validateTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if tok, ok, err := repman.getAPIToken(r); ok {
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "Token is not valid: "+err.Error())
+			return
+		}
+		if !repman.isValidTokenRoute(r, tok) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "Token is not granted on this resource")
+			return
+		}
+		next(w, r)
+		return
+	}
 	//validate token
 	token, err := request.ParseFromRequest(r, request.AuthorizationHeaderExtractor,
 		func(token *jwt.Token) (interface{}, error) {
@@ -784,12 +850,19 @@ func (repman *ReplicationManager) handlerMuxClusterDelete(w http.ResponseWriter,
 func (repman *ReplicationManager) handlerMuxPrometheus(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	e := openmetrics.NewExposition()
+	e.Family("replication_manager_build_info", openmetrics.TypeGauge, "Version of replication-manager").Add(1, openmetrics.L("version", repman.Version))
 	for _, cluster := range repman.Clusters {
-		for _, server := range cluster.Servers {
-			res := server.GetPrometheusMetrics()
-			w.Write([]byte(res))
-		}
+		cluster.GetOpenMetrics(e)
+	}
+	// OpenMetrics when the scraper asks for it, Prometheus text format otherwise
+	om := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if om {
+		w.Header().Set("Content-Type", openmetrics.ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", openmetrics.ContentTypeText)
 	}
+	e.Write(w, om)
 }
 
 func (repman *ReplicationManager) handlerMuxClustersOld(w http.ResponseWriter, r *http.Request) {
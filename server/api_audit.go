@@ -0,0 +1,336 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/request"
+	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/apitoken"
+	"github.com/signal18/replication-manager/utils/audit"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// auditResponseWriter keeps the status and the start of an error response
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (aw *auditResponseWriter) WriteHeader(code int) {
+	aw.status = code
+	aw.ResponseWriter.WriteHeader(code)
+}
+
+func (aw *auditResponseWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	if aw.status >= 400 && aw.body.Len() < 512 {
+		aw.body.Write(b[:min(len(b), 512-aw.body.Len())])
+	}
+	return aw.ResponseWriter.Write(b)
+}
+
+func (aw *auditResponseWriter) Flush() {
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// isAuditedRequest tells if an API call can change something, the actions
+// are mostly GET routes. Logins are not audited as their body is a password.
+func isAuditedRequest(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/api/login") || r.URL.Path == "/api/auth/callback" {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return strings.Contains(r.URL.Path, "/actions/")
+}
+
+// getAuditIdentity returns the user and the auth method of a bearer, refused
+// calls are audited too with what can be read from it
+func (repman *ReplicationManager) getAuditIdentity(bearer string) (string, string) {
+	if bearer == "" {
+		return "", ""
+	}
+	if apitoken.IsToken(bearer) {
+		if repman.APITokens != nil {
+			if tok, err := repman.APITokens.Validate(bearer); err == nil {
+				return tok.User, "token"
+			}
+		}
+		return "", "token"
+	}
+	token, err := jwt.Parse(bearer, func(token *jwt.Token) (interface{}, error) {
+		vk, _ := jwt.ParseRSAPublicKeyFromPEM(verificationKey)
+		return vk, nil
+	})
+	if err != nil {
+		return "", ""
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	userinfo, ok := claims["CustomUserInfo"].(map[string]interface{})
+	if !ok {
+		return "", ""
+	}
+	user, _ := userinfo["Name"].(string)
+	method := "password"
+	if profile, ok := userinfo["profile"].(string); ok && strings.Contains(profile, repman.Conf.OAuthProvider) {
+		user, _ = userinfo["email"].(string)
+		method = "oidc"
+	}
+	if len(getTokenGroups(userinfo)) > 0 {
+		method = "oidc"
+	}
+	return user, method
+}
+
+// auditMiddleware records the mutating calls of the router, the endpoint is
+// the route template so that secrets in the URL only go through the redacted
+// parameters
+func (repman *ReplicationManager) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if repman.AuditLog == nil || !isAuditedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := audit.Record{Time: start, Protocol: audit.ProtocolREST, Method: r.Method, Endpoint: r.URL.Path}
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				rec.Endpoint = tpl
+			}
+		}
+		params := make(map[string]string)
+		for k, v := range mux.Vars(r) {
+			params[k] = v
+		}
+		for k, v := range r.URL.Query() {
+			params[k] = strings.Join(v, ",")
+		}
+		params = audit.RedactParams(params)
+		if r.Body != nil {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if err == nil && len(body) > 0 {
+				params["body"] = audit.RedactBody(body)
+			}
+		}
+		rec.Params = params
+		rec.Cluster = params["clusterName"]
+		bearer, _ := request.AuthorizationHeaderExtractor.ExtractToken(r)
+		rec.User, rec.AuthMethod = repman.getAuditIdentity(bearer)
+		rec.SourceIP = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			rec.SourceIP = host
+		}
+
+		aw := &auditResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+
+		rec.Status = aw.status
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		rec.Result = audit.ResultOk
+		if rec.Status >= 400 {
+			rec.Result = audit.ResultError
+			rec.Error = strings.TrimSpace(aw.body.String())
+		}
+		rec.Duration = time.Since(start).Milliseconds()
+		if err := repman.AuditLog.Append(rec); err != nil {
+			repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Audit log write failed: %s", err)
+		}
+	})
+}
+
+// auditGRPC records a gRPC call with the request message as redacted parameters
+func (repman *ReplicationManager) auditGRPC(ctx context.Context, method string, clusterName string, in proto.Message, start time.Time, err error) {
+	if repman.AuditLog == nil {
+		return
+	}
+	rec := audit.Record{Time: start, Protocol: audit.ProtocolGRPC, Method: method, Endpoint: "/signal18.replication_manager.v3.ClusterService/" + method, Cluster: clusterName}
+	params := make(map[string]string)
+	if content, merr := protojson.Marshal(in); merr == nil {
+		params["request"] = audit.RedactBody(content)
+	}
+	rec.Params = params
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if auth := md.Get("authorization"); len(auth) > 0 && len(auth[0]) > 6 && strings.ToUpper(auth[0][0:7]) == "BEARER " {
+			rec.User, rec.AuthMethod = repman.getAuditIdentity(auth[0][7:])
+		}
+		if fwd := md.Get("x-forwarded-for"); len(fwd) > 0 {
+			rec.SourceIP = strings.TrimSpace(strings.Split(fwd[0], ",")[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && rec.SourceIP == "" {
+		rec.SourceIP = p.Addr.String()
+		if host, _, serr := net.SplitHostPort(rec.SourceIP); serr == nil {
+			rec.SourceIP = host
+		}
+	}
+	st := status.Convert(err)
+	rec.Status = int(st.Code())
+	rec.Result = audit.ResultOk
+	if err != nil {
+		rec.Result = audit.ResultError
+		rec.Error = st.Message()
+	}
+	rec.Duration = time.Since(start).Milliseconds()
+	if aerr := repman.AuditLog.Append(rec); aerr != nil {
+		repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Audit log write failed: %s", aerr)
+	}
+}
+
+// getAuditFilter reads the from and to RFC3339 times, the user, endpoint,
+// result and limit of an audit query
+func getAuditFilter(r *http.Request) (audit.Filter, error) {
+	var filter audit.Filter
+	var err error
+	q := r.URL.Query()
+	if q.Get("from") != "" {
+		if filter.From, err = time.Parse(time.RFC3339, q.Get("from")); err != nil {
+			return filter, err
+		}
+	}
+	if q.Get("to") != "" {
+		if filter.To, err = time.Parse(time.RFC3339, q.Get("to")); err != nil {
+			return filter, err
+		}
+	}
+	if q.Get("limit") != "" {
+		if filter.Limit, err = strconv.Atoi(q.Get("limit")); err != nil {
+			return filter, err
+		}
+	}
+	filter.User = q.Get("user")
+	filter.Endpoint = q.Get("endpoint")
+	filter.Result = q.Get("result")
+	return filter, nil
+}
+
+func (repman *ReplicationManager) encodeAuditRecords(w http.ResponseWriter, r *http.Request, filter audit.Filter, match func(rec *audit.Record) bool) {
+	if repman.AuditLog == nil {
+		http.Error(w, "Audit log is disabled", 500)
+		return
+	}
+	records, err := repman.AuditLog.Query(filter, match)
+	if err != nil {
+		http.Error(w, "Audit log error: "+err.Error(), 500)
+		return
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(records)
+	if err != nil {
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+// handlerMuxClusterAudit returns the audit records of a cluster
+func (repman *ReplicationManager) handlerMuxClusterAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	filter, err := getAuditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), 400)
+		return
+	}
+	filter.Cluster = mycluster.Name
+	repman.encodeAuditRecords(w, r, filter, nil)
+}
+
+// handlerMuxClusterRemediations returns the replication error remediations
+// of a cluster
+func (repman *ReplicationManager) handlerMuxClusterRemediations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	filter, err := getAuditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), 400)
+		return
+	}
+	records, err := mycluster.GetRemediations(filter)
+	if err != nil {
+		http.Error(w, "Remediation log error: "+err.Error(), 500)
+		return
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(records)
+	if err != nil {
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+// handlerMuxAudit returns the audit records of the clusters where the caller
+// has the audit grant, records without cluster need the grant on one of them
+func (repman *ReplicationManager) handlerMuxAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	allowed := make(map[string]bool)
+	var any bool
+	for _, c := range repman.Clusters {
+		if valid, _ := repman.IsValidClusterACL(r, c); valid {
+			allowed[c.Name] = true
+			any = true
+		}
+	}
+	if !any {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	filter, err := getAuditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid filter: "+err.Error(), 400)
+		return
+	}
+	filter.Cluster = r.URL.Query().Get("cluster")
+	repman.encodeAuditRecords(w, r, filter, func(rec *audit.Record) bool {
+		return rec.Cluster == "" || allowed[rec.Cluster]
+	})
+}
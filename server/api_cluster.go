@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/codegangsta/negroni"
@@ -25,7 +26,9 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/signal18/replication-manager/cluster"
 	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/alert"
 	"github.com/signal18/replication-manager/utils/s18log"
+	"github.com/signal18/replication-manager/utils/state"
 )
 
 func (repman *ReplicationManager) apiClusterUnprotectedHandler(router *mux.Router) {
@@ -41,318 +44,525 @@ func (repman *ReplicationManager) apiClusterUnprotectedHandler(router *mux.Route
 func (repman *ReplicationManager) apiClusterProtectedHandler(router *mux.Router) {
 
 	router.Handle("/api/clusters/{clusterName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxCluster)),
 	))
 
 	//PROTECTED ENDPOINTS FOR CLUSTERS ACTIONS
 	router.Handle("/api/clusters/{clusterName}/settings", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSettings)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/tags", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterTags)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/jobs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterGetJobEntries)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/backups", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackups)),
 	))
 
+	router.Handle("/api/clusters/{clusterName}/backups/catalog", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupCatalog)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/retention", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupRetention)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/retention/actions/purge", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupRetention)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/binlog-archiver", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBinlogArchiver)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/pitr", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterPointInTimeJob)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/pitr/actions/plan", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterPointInTimeRecovery)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/pitr/actions/start", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterPointInTimeRecovery)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/pitr/actions/stop-standalone", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterPointInTimeStandaloneStop)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/catalog/{backupId}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowBackups)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupCatalogEntry)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/catalog/{backupId}/actions/{action:pin|unpin|delete}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupCatalogAction)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/catalog/{backupId}/actions/restore/{serverName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupCatalogRestore)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/catalog/{backupId}/actions/restore-schema", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupSchemaRestore)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/backups/actions/schema-backup", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupSchema)),
+	))
+
 	router.Handle("/api/clusters/{clusterName}/certificates", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowCertificates)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterCertificates)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/queryrules", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowRoutes)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterQueryRules)),
 	))
 	router.Handle("/api/clusters/{clusterName}/top", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterTop)),
 	))
 	router.Handle("/api/clusters/{clusterName}/shardclusters", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterShardClusters)),
 	))
 	router.Handle("/api/clusters/{clusterName}/shared", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterShared)),
 	))
 	router.Handle("/api/clusters/{clusterName}/send-vault-token", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSendVaultToken)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/reload", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSettingsReload)),
 	))
 	router.Handle("/api/clusters/settings/actions/switch/{settingName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantGlobalSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchGlobalSettings)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/switch/{settingName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSettings)),
 	))
 	router.Handle("/api/clusters/settings/actions/set/{settingName}/{settingValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantGlobalSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetGlobalSettings)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/set/{settingName}/{settingValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetSettings)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/set-cron/{settingName}/{settingValue:.*}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetCron)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/add-db-tag/{tagValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBConfigFlag)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddTag)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/drop-db-tag/{tagValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBConfigFlag)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxDropTag)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/add-proxy-tag/{tagValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProxyConfigFlag)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddProxyTag)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/drop-proxy-tag/{tagValue}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProxyConfigFlag)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxDropProxyTag)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/reset-failover-control", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterResetFailoverControl)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/discover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetSettingsDiscover)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/apply-dynamic-config", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBConfigFlag)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterApplyDynamicConfig)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/add/{clusterShardingName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterShardingAdd)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/switchover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSwitchover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchover)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/failover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterFailover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxFailover)),
 	))
+	router.Handle("/api/clusters/{clusterName}/actions/simulate-switchover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSwitchover)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSimulateSwitchover)),
+	))
+	router.Handle("/api/clusters/{clusterName}/actions/simulate-failover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterFailover)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSimulateFailover)),
+	))
 	router.Handle("/api/clusters/{clusterName}/actions/certificates-rotate", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterCertificatesRotate)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRotateKeys)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/certificates-reload", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterCertificatesReload)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterReloadCertificates)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/reset-sla", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterResetSLA)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxResetSla)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/replication/bootstrap/{topology}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxBootstrapReplication)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/replication/cleanup", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxBootstrapReplicationCleanup)),
 	))
 	router.Handle("/api/clusters/{clusterName}/services/actions/provision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvCluster)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesProvision)),
 	))
 	router.Handle("/api/clusters/{clusterName}/services/actions/unprovision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvClusterUnprovision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesUnprovision)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-restart", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterRolling)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesCancelRollingRestart)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/cancel-rolling-reprov", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterRolling)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServicesCancelRollingReprov)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/stop-traffic", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterTraffic)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStopTraffic)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/start-traffic", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterTraffic)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStartTraffic)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/optimize", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterRolling)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterOptimize)),
 	))
 
+	router.Handle("/api/clusters/{clusterName}/actions/backup-verify", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterBackupVerify)),
+	))
+
 	router.Handle("/api/clusters/{clusterName}/actions/sysbench", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterBench, config.GrantClusterTest)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSysbench)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/waitdatabases", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterWaitDatabases)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterCreateMonitor)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAdd)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/addserver/{host}/{port}/{type}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterCreateMonitor)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAdd)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/dropserver/{host}/{port}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterDropMonitor)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDrop)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/dropserver/{host}/{port}/{type}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterDropMonitor)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDrop)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/rolling", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterRolling)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRolling)),
 	))
 	router.Handle("/api/clusters/{clusterName}/actions/rotate-passwords", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterRotatePasswords)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerRotatePasswords)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaReshardTable)),
 	))
 	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/reshard-table/{clusterList}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaReshardTable)),
 	))
 	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/move-table/{clusterShard}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaMoveTable)),
 	))
 	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/universal-table", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaUniversalTable)),
 	))
 	router.Handle("/api/clusters/{clusterName}/schema/{schemaName}/{tableName}/actions/checksum-table", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaChecksumTable)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/actions/checksum-all-tables", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterChecksum)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchemaChecksumAllTable)),
 	))
 
+	router.Handle("/api/clusters/{clusterName}/errant-transactions", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterReplication)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterErrantTransactions)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/checksum", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterChecksum)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterChecksum)),
+	))
+
 	router.Handle("/api/clusters/{clusterName}/schema", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSharding)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSchema)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/graphite-filterlist", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterShowGraphs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterGraphiteFilterList)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/settings/actions/set-graphite-filterlist/{filterType}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterConfigGraphs, config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterSetGraphiteFilterList)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/settings/actions/reload-graphite-filterlist", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterConfigGraphs, config.GrantClusterSettings)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterReloadGraphiteFilterList)),
 	))
 	router.Handle("/api/clusters/{clusterName}/settings/actions/reset-graphite-filterlist/{template}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterConfigGraphs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterResetGraphiteFilterList)),
 	))
 	//PROTECTED ENDPOINTS FOR CLUSTERS TOPOLOGY
 
 	router.Handle("/api/clusters/actions/add/{clusterName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterCreate, config.GrantProvCluster)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterAdd)),
 	))
 
 	router.Handle("/api/clusters/actions/delete/{clusterName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterDelete)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterDelete)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/topology/servers", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServers)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/master", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxMaster)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/slaves", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSlaves)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/logs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxLog)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/proxies", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxies)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/alerts", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAlerts)),
 	))
 	router.Handle("/api/clusters/{clusterName}/topology/crashes", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxCrashes)),
 	))
+	router.Handle("/api/clusters/{clusterName}/topology/crashes/{crashId}/timeline", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxCrashTimeline)),
+	))
+	router.Handle("/api/clusters/{clusterName}/topology/journal", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxStateJournal)),
+	))
+	router.Handle("/api/clusters/{clusterName}/alerts/silences", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterAlerts)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAlertSilences)),
+	))
+	router.Handle("/api/clusters/{clusterName}/alerts/silences/{silenceId}/actions/expire", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterAlerts)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAlertSilenceExpire)),
+	))
+	router.Handle("/api/clusters/{clusterName}/audit", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterAudit)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterAudit)),
+	))
+	router.Handle("/api/clusters/{clusterName}/replication-remediations", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterAudit)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusterRemediations)),
+	))
 	//PROTECTED ENDPOINTS FOR TESTS
 
 	router.Handle("/api/clusters/{clusterName}/tests/actions/run/all", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterTest)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxTests)),
 	))
 	router.Handle("/api/clusters/{clusterName}/tests/actions/run/{testName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterTest)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxOneTest)),
 	))
 
 	// endpoint to fetch Cluster.DiffVariables
 	router.Handle("/api/clusters/{clusterName}/diffvariables", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerDiffVariables)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/users/add", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterGrant)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxAddClusterUser)),
 	))
@@ -492,6 +702,141 @@ func (repman *ReplicationManager) handlerMuxAlerts(w http.ResponseWriter, r *htt
 	}
 }
 
+// handlerMuxStateJournal returns the journaled state transitions filtered by
+// the from and to RFC3339 times, the key prefix, the server URL and a limit
+func (repman *ReplicationManager) handlerMuxStateJournal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil || mycluster.StateJournal == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	var filter state.JournalFilter
+	var err error
+	q := r.URL.Query()
+	if q.Get("from") != "" {
+		if filter.From, err = time.Parse(time.RFC3339, q.Get("from")); err != nil {
+			http.Error(w, "Invalid from: "+err.Error(), 400)
+			return
+		}
+	}
+	if q.Get("to") != "" {
+		if filter.To, err = time.Parse(time.RFC3339, q.Get("to")); err != nil {
+			http.Error(w, "Invalid to: "+err.Error(), 400)
+			return
+		}
+	}
+	if q.Get("limit") != "" {
+		if filter.Limit, err = strconv.Atoi(q.Get("limit")); err != nil {
+			http.Error(w, "Invalid limit: "+err.Error(), 400)
+			return
+		}
+	}
+	filter.ErrKey = q.Get("key")
+	filter.ServerUrl = q.Get("server")
+	transitions, err := mycluster.StateJournal.Query(filter)
+	if err != nil {
+		http.Error(w, "Journal error: "+err.Error(), 500)
+		return
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(transitions)
+	if err != nil {
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error encoding JSON: ", err)
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+// handlerMuxAlertSilences lists the silences on GET and creates one on POST,
+// the end of the silence is given as end time or as duration in seconds
+func (repman *ReplicationManager) handlerMuxAlertSilences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil || mycluster.AlertSilences == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	var res interface{}
+	if r.Method == http.MethodPost {
+		valid, user := repman.IsValidClusterACL(r, mycluster)
+		if !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		var req struct {
+			alert.Silence
+			Duration int64 `json:"duration"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Decode error: "+err.Error(), 400)
+			return
+		}
+		if req.End.IsZero() && req.Duration > 0 {
+			start := req.Start
+			if start.IsZero() {
+				start = time.Now()
+			}
+			req.End = start.Add(time.Duration(req.Duration) * time.Second)
+		}
+		req.CreatedBy = user
+		silence, err := mycluster.AlertSilences.Add(req.Silence)
+		if err != nil {
+			http.Error(w, "Silence error: "+err.Error(), 400)
+			return
+		}
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Alert silence %s created by %s for key %s server %s until %s", silence.Id, user, silence.ErrKey, silence.ServerUrl, silence.End.Format(time.RFC3339))
+		res = silence
+	} else {
+		res = mycluster.AlertSilences.List()
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err := e.Encode(res)
+	if err != nil {
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error encoding JSON: ", err)
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxAlertSilenceExpire(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil || mycluster.AlertSilences == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	valid, user := repman.IsValidClusterACL(r, mycluster)
+	if !valid {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	silence, err := mycluster.AlertSilences.Expire(vars["silenceId"])
+	if err == alert.ErrSilenceNotFound {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Silence error: "+err.Error(), 500)
+		return
+	}
+	mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Alert silence %s expired by %s", silence.Id, user)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(silence)
+	if err != nil {
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error encoding JSON: ", err)
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxRotateKeys(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -545,6 +890,47 @@ func (repman *ReplicationManager) handlerMuxFailover(w http.ResponseWriter, r *h
 	return
 }
 
+func (repman *ReplicationManager) handlerMuxSimulateFailover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	repman.encodeElectionSimulation(w, mycluster, mycluster.SimulateFailover())
+}
+
+func (repman *ReplicationManager) handlerMuxSimulateSwitchover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+		http.Error(w, "No valid ACL", 403)
+		return
+	}
+	repman.encodeElectionSimulation(w, mycluster, mycluster.SimulateSwitchover())
+}
+
+func (repman *ReplicationManager) encodeElectionSimulation(w http.ResponseWriter, mycluster *cluster.Cluster, sim cluster.ElectionSimulation) {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err := e.Encode(sim)
+	if err != nil {
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error encoding JSON: ", err)
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxClusterShardingAdd(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -751,7 +1137,253 @@ func (repman *ReplicationManager) handlerMuxServicesBootstrap(w http.ResponseWri
 	return
 }
 
-func (repman *ReplicationManager) handlerMuxServicesProvision(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxServicesProvision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		err := mycluster.Bootstrap()
+		if err != nil {
+			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error Bootstrap Micro Services + replication: %s", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxServicesUnprovision(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		mycluster.Unprovision()
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxServicesCancelRollingRestart(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		mycluster.CancelRollingRestart()
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxServicesCancelRollingReprov(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		mycluster.CancelRollingReprov()
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxSetSettingsDiscover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		err := mycluster.ConfigDiscovery()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxClusterResetFailoverControl(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		mycluster.ResetFailoverCtr()
+	} else {
+
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxSwitchover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Rest API receive switchover request")
+		savedPrefMaster := mycluster.GetPreferedMasterList()
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if mycluster.IsMasterFailed() {
+			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Master failed, cannot initiate switchover")
+			http.Error(w, "Master failed", http.StatusBadRequest)
+			return
+		}
+		r.ParseForm() // Parses the request body
+		newPrefMaster := r.Form.Get("prefmaster")
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "API force for prefered master: %s", newPrefMaster)
+		if mycluster.IsInHostList(newPrefMaster) {
+			mycluster.SetPrefMaster(newPrefMaster)
+		} else {
+			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Prefered master: not found in database servers %s", newPrefMaster)
+		}
+		mycluster.MasterFailover(false)
+		mycluster.SetPrefMaster(savedPrefMaster)
+
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+	return
+}
+
+func (repman *ReplicationManager) handlerMuxMaster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		m := mycluster.GetMaster()
+		var srvs *cluster.ServerMonitor
+		if m != nil {
+			data, _ := json.Marshal(m)
+
+			err := json.Unmarshal(data, &srvs)
+			if err != nil {
+				mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error decoding JSON: ", err)
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+			srvs.Pass = "XXXXXXXX"
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(srvs)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterCertificates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		certs, err := mycluster.GetClientCertificates()
+		if err != nil {
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		}
+		err = e.Encode(certs)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.Configurator.GetDBModuleTags())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterBackups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetBackups())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterBackupCatalog(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -760,20 +1392,20 @@ func (repman *ReplicationManager) handlerMuxServicesProvision(w http.ResponseWri
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		err := mycluster.Bootstrap()
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetBackupCatalog())
 		if err != nil {
-			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error Bootstrap Micro Services + replication: %s", err)
-			http.Error(w, err.Error(), 500)
+			http.Error(w, "Encoding error", 500)
 			return
 		}
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxServicesUnprovision(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBackupCatalogEntry(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -782,15 +1414,25 @@ func (repman *ReplicationManager) handlerMuxServicesUnprovision(w http.ResponseW
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		mycluster.Unprovision()
+		entry, err := mycluster.GetBackupCatalogEntry(vars["backupId"])
+		if err != nil {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(entry)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxServicesCancelRollingRestart(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBackupRetention(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -799,15 +1441,26 @@ func (repman *ReplicationManager) handlerMuxServicesCancelRollingRestart(w http.
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		mycluster.CancelRollingRestart()
+		dryrun := !strings.HasSuffix(r.URL.Path, "/actions/purge")
+		entries, err := mycluster.PurgeBackupRetention(dryrun)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(entries)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxServicesCancelRollingReprov(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBinlogArchiver(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -816,15 +1469,25 @@ func (repman *ReplicationManager) handlerMuxServicesCancelRollingReprov(w http.R
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		mycluster.CancelRollingReprov()
+		status := mycluster.GetBinlogArchiverStatus()
+		res := struct {
+			config.BinlogArchiverStatus
+			Lag float64 `json:"lag"`
+		}{status, status.GetLag(time.Now())}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(res)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxSetSettingsDiscover(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterPointInTimeJob(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -833,19 +1496,25 @@ func (repman *ReplicationManager) handlerMuxSetSettingsDiscover(w http.ResponseW
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		err := mycluster.ConfigDiscovery()
+		job := mycluster.GetPointInTimeJob()
+		if job == nil {
+			http.Error(w, "No point in time recovery job", 404)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(job)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			http.Error(w, "Encoding error", 500)
 			return
 		}
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxClusterResetFailoverControl(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterPointInTimeRecovery(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -854,16 +1523,36 @@ func (repman *ReplicationManager) handlerMuxClusterResetFailoverControl(w http.R
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		mycluster.ResetFailoverCtr()
+		var req config.PointInTimeRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Decode error :%s", err.Error()), 500)
+			return
+		}
+		var res interface{}
+		if strings.HasSuffix(r.URL.Path, "/actions/start") {
+			res, err = mycluster.JobPointInTimeRecovery(req)
+		} else {
+			res, err = mycluster.GetPointInTimePlan(req)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(res)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
 	} else {
-
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxSwitchover(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterPointInTimeStandaloneStop(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -872,110 +1561,110 @@ func (repman *ReplicationManager) handlerMuxSwitchover(w http.ResponseWriter, r
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Rest API receive switchover request")
-		savedPrefMaster := mycluster.GetPreferedMasterList()
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		if mycluster.IsMasterFailed() {
-			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Master failed, cannot initiate switchover")
-			http.Error(w, "Master failed", http.StatusBadRequest)
+		err := mycluster.StopPointInTimeStandalone()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
 			return
 		}
-		r.ParseForm() // Parses the request body
-		newPrefMaster := r.Form.Get("prefmaster")
-		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "API force for prefered master: %s", newPrefMaster)
-		if mycluster.IsInHostList(newPrefMaster) {
-			mycluster.SetPrefMaster(newPrefMaster)
-		} else {
-			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Prefered master: not found in database servers %s", newPrefMaster)
-		}
-		mycluster.MasterFailover(false)
-		mycluster.SetPrefMaster(savedPrefMaster)
-
 	} else {
 		http.Error(w, "No cluster", 500)
 		return
 	}
-	return
 }
 
-func (repman *ReplicationManager) handlerMuxMaster(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBackupCatalogAction(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
 	if mycluster != nil {
-		m := mycluster.GetMaster()
-		var srvs *cluster.ServerMonitor
-		if m != nil {
-			data, _ := json.Marshal(m)
-
-			err := json.Unmarshal(data, &srvs)
-			if err != nil {
-				mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error decoding JSON: ", err)
-				http.Error(w, "Encoding error", 500)
-				return
-			}
-			srvs.Pass = "XXXXXXXX"
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		var err error
+		switch vars["action"] {
+		case "pin":
+			err = mycluster.PinBackup(vars["backupId"])
+		case "unpin":
+			err = mycluster.UnpinBackup(vars["backupId"])
+		case "delete":
+			err = mycluster.DeleteBackup(vars["backupId"])
 		}
-		e := json.NewEncoder(w)
-		e.SetIndent("", "\t")
-		err := e.Encode(srvs)
 		if err != nil {
-			http.Error(w, "Encoding error", 500)
+			http.Error(w, err.Error(), 500)
 			return
 		}
 	} else {
-
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxClusterCertificates(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBackupCatalogRestore(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
 	if mycluster != nil {
-		e := json.NewEncoder(w)
-		e.SetIndent("", "\t")
-		certs, err := mycluster.GetClientCertificates()
-		if err != nil {
-			if err != nil {
-				http.Error(w, err.Error(), 500)
-				return
-			}
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
 		}
-		err = e.Encode(certs)
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node == nil {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+		err := mycluster.RestoreBackup(vars["backupId"], node)
 		if err != nil {
-			http.Error(w, "Encoding error", 500)
+			http.Error(w, err.Error(), 500)
 			return
 		}
 	} else {
-
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxClusterTags(w http.ResponseWriter, r *http.Request) {
+func (repman *ReplicationManager) handlerMuxClusterBackupSchema(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
 	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		var req config.SchemaBackupRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Decode error :%s", err.Error()), 500)
+			return
+		}
+		var meta *config.BackupMetadata
+		err = mycluster.RunManualBackup("schema", func() error {
+			meta, err = mycluster.JobBackupSchema(req)
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
 		e := json.NewEncoder(w)
 		e.SetIndent("", "\t")
-		err := e.Encode(mycluster.Configurator.GetDBModuleTags())
+		err = e.Encode(meta.GetCatalogEntry())
 		if err != nil {
 			http.Error(w, "Encoding error", 500)
 			return
 		}
 	} else {
-
 		http.Error(w, "No cluster", 500)
 		return
 	}
 }
 
-func (repman *ReplicationManager) handlerMuxClusterBackups(w http.ResponseWriter, r *http.Request) {
+// handlerMuxClusterBackupSchemaRestore loads a schema backup into the cluster
+// of the request, the user needs the restore grant on both clusters
+func (repman *ReplicationManager) handlerMuxClusterBackupSchemaRestore(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
 	mycluster := repman.getClusterByName(vars["clusterName"])
@@ -984,11 +1673,27 @@ func (repman *ReplicationManager) handlerMuxClusterBackups(w http.ResponseWriter
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
-		e := json.NewEncoder(w)
-		e.SetIndent("", "\t")
-		err := e.Encode(mycluster.GetBackups())
+		var req config.SchemaRestoreRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			http.Error(w, "Encoding error", 500)
+			http.Error(w, fmt.Sprintf("Decode error :%s", err.Error()), 500)
+			return
+		}
+		target := mycluster
+		if req.Cluster != "" {
+			target = repman.getClusterByName(req.Cluster)
+			if target == nil {
+				http.Error(w, "Target cluster not found", 500)
+				return
+			}
+			if valid, _ := repman.IsValidClusterACL(r, target); !valid {
+				http.Error(w, "No valid ACL on target cluster", 403)
+				return
+			}
+		}
+		err = mycluster.RestoreSchemaBackup(vars["backupId"], target, req)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
 			return
 		}
 	} else {
@@ -1138,7 +1843,7 @@ func (repman *ReplicationManager) handlerMuxSwitchGlobalSettings(w http.Response
 		valid, user := repman.IsValidClusterACL(r, mycluster)
 		if valid {
 			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "API receive switch global setting %s", setting)
-			repman.switchServerSetting(user, r.URL.Path, setting)
+			repman.switchServerSetting(user, setting)
 		} else {
 			http.Error(w, fmt.Sprintf("User doesn't have required ACL for global setting: %s", setting), 403)
 			return
@@ -1210,6 +1915,12 @@ func (repman *ReplicationManager) switchClusterSettings(mycluster *cluster.Clust
 		mycluster.SwitchSchedulerBackupLogical()
 	case "scheduler-db-servers-physical-backup":
 		mycluster.SwitchSchedulerBackupPhysical()
+	case "scheduler-db-servers-physical-backup-incremental":
+		mycluster.SwitchSchedulerBackupPhysicalIncremental()
+	case "scheduler-db-servers-physical-backup-differential":
+		mycluster.SwitchSchedulerBackupPhysicalDifferential()
+	case "scheduler-db-servers-backup-verify":
+		mycluster.SwitchSchedulerBackupVerify()
 	case "scheduler-db-servers-logs":
 		mycluster.SwitchSchedulerDatabaseLogs()
 	case "scheduler-jobs-ssh":
@@ -1276,6 +1987,8 @@ func (repman *ReplicationManager) switchClusterSettings(mycluster *cluster.Clust
 		mycluster.SwitchBackupBinlogs()
 	case "compress-backups":
 		mycluster.SwitchCompressBackups()
+	case "backup-encrypt":
+		mycluster.SwitchBackupEncrypt()
 	case "monitoring-pause":
 		mycluster.SwitchMonitoringPause()
 	case "monitoring-save-config":
@@ -1288,6 +2001,8 @@ func (repman *ReplicationManager) switchClusterSettings(mycluster *cluster.Clust
 		mycluster.SwitchMonitoringSchemaChange()
 	case "monitoring-capture":
 		mycluster.SwitchMonitoringCapture()
+	case "monitoring-state-journal":
+		mycluster.SwitchMonitoringStateJournal()
 	case "monitoring-innodb-status":
 		mycluster.SwitchMonitoringInnoDBStatus()
 	case "monitoring-variable-diff":
@@ -1376,6 +2091,8 @@ func (repman *ReplicationManager) switchClusterSettings(mycluster *cluster.Clust
 		mycluster.SwitchForceWriteConfig()
 	case "backup-keep-until-valid":
 		mycluster.SwitchBackupKeepUntilValid()
+	case "backup-retention":
+		mycluster.SwitchBackupRetention()
 	case "mail-smtp-tls-skip-verify":
 		mycluster.SwitchMailSmtpTlsSkipVerify()
 	default:
@@ -1443,10 +2160,9 @@ func (repman *ReplicationManager) handlerMuxSetGlobalSettings(w http.ResponseWri
 	if mycluster != nil {
 		valid, user := repman.IsValidClusterACL(r, mycluster)
 		if valid {
-			// || (user != "" && mycluster.IsURLPassACL(user, path, false)) {
 			//Set server scope
 			mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, "INFO", "Option '%s' is a shared values between clusters", setting)
-			repman.setServerSetting(user, r.URL.Path, setting, vars["settingValue"])
+			repman.setServerSetting(user, setting, vars["settingValue"])
 		} else {
 			http.Error(w, fmt.Sprintf("User doesn't have required ACL for global setting: %s. path: %s", setting, r.URL.Path), 403)
 			return
@@ -1509,6 +2225,12 @@ func (repman *ReplicationManager) setClusterSetting(mycluster *cluster.Cluster,
 		mycluster.SetBackupKeepWeekly(value)
 	case "backup-keep-yearly":
 		mycluster.SetBackupKeepYearly(value)
+	case "backup-verify-method":
+		mycluster.SetBackupVerifyMethod(value)
+	case "backup-verify-server":
+		mycluster.SetBackupVerifyServer(value)
+	case "backup-verify-row-count-tolerance":
+		mycluster.SetBackupVerifyRowTolerance(value)
 	case "backup-logical-type":
 		mycluster.SetBackupLogicalType(value)
 	case "backup-physical-type":
@@ -1612,6 +2334,12 @@ func (repman *ReplicationManager) setClusterSetting(mycluster *cluster.Cluster,
 		mycluster.SetSchedulerDbServersAnalyzeCron(value)
 	case "scheduler-db-servers-physical-backup-cron":
 		mycluster.SetSchedulerDbServersPhysicalBackupCron(value)
+	case "scheduler-db-servers-physical-backup-incremental-cron":
+		mycluster.SetSchedulerDbServersPhysicalBackupIncrementalCron(value)
+	case "scheduler-db-servers-physical-backup-differential-cron":
+		mycluster.SetSchedulerDbServersPhysicalBackupDifferentialCron(value)
+	case "scheduler-db-servers-backup-verify-cron":
+		mycluster.SetSchedulerDbServersBackupVerifyCron(value)
 	case "scheduler-rolling-reprov-cron":
 		mycluster.SetSchedulerRollingReprovCron(value)
 	case "scheduler-rolling-restart-cron":
@@ -1720,6 +2448,26 @@ func (repman *ReplicationManager) setClusterSetting(mycluster *cluster.Cluster,
 		mycluster.SetAlertTeamsState(value)
 	case "alert-teams-url":
 		mycluster.SetAlertTeamsUrl(value)
+	case "alert-webhook-url":
+		mycluster.SetAlertWebhookUrl(value)
+	case "alert-webhook-template":
+		mycluster.SetAlertWebhookTemplate(value)
+	case "alert-pagerduty-url":
+		mycluster.SetAlertPagerDutyUrl(value)
+	case "alert-pagerduty-routing-key":
+		mycluster.SetAlertPagerDutyRoutingKey(value)
+	case "alert-opsgenie-url":
+		mycluster.SetAlertOpsgenieUrl(value)
+	case "alert-opsgenie-api-key":
+		mycluster.SetAlertOpsgenieApiKey(value)
+	case "alert-routes":
+		err := mycluster.SetAlertRoutes(value)
+		if err != nil {
+			return err
+		}
+	case "alert-group-window":
+		val, _ := strconv.Atoi(value)
+		mycluster.SetAlertGroupWindow(val)
 	case "monitoring-alert-trigger":
 		mycluster.SetMonitoringAlertTriggerl(value)
 	case "mail-smtp-addr":
@@ -1774,6 +2522,16 @@ func (repman *ReplicationManager) setClusterSetting(mycluster *cluster.Cluster,
 		new_secret.Value = mycluster.Conf.BackupResticPassword
 		new_secret.OldValue = mycluster.Conf.GetDecryptedValue("backup-restic-password")
 		mycluster.Conf.Secrets["backup-restic-password"] = new_secret
+	case "backup-encryption-master-key":
+		val, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return errors.New("Unable to decode")
+		}
+		mycluster.Conf.BackupEncryptionMasterKey = string(val)
+		var new_secret config.Secret
+		new_secret.Value = mycluster.Conf.BackupEncryptionMasterKey
+		new_secret.OldValue = mycluster.Conf.GetDecryptedValue("backup-encryption-master-key")
+		mycluster.Conf.Secrets["backup-encryption-master-key"] = new_secret
 	case "backup-mydumper-options":
 		val, err := base64.StdEncoding.DecodeString(value)
 		if err != nil {
@@ -1961,23 +2719,21 @@ func (repman *ReplicationManager) switchRepmanSetting(name string) error {
 	return nil
 }
 
-func (repman *ReplicationManager) setServerSetting(user string, URL string, name string, value string) {
+func (repman *ReplicationManager) setServerSetting(user string, name string, value string) {
 	repman.setRepmanSetting(name, value)
 
 	for _, cl := range repman.Clusters {
-		//Don't print error with no valid ACL
-		if cl.IsURLPassACL(user, URL, false) {
+		if cl.APIUsers[user].Grants[config.GrantGlobalSettings] {
 			repman.setClusterSetting(cl, name, value)
 		}
 	}
 }
 
-func (repman *ReplicationManager) switchServerSetting(user string, URL string, name string) {
+func (repman *ReplicationManager) switchServerSetting(user string, name string) {
 	repman.switchRepmanSetting(name)
 
-	for cname, cl := range repman.Clusters {
-		//Don't print error with no valid ACL
-		if cl.IsURLPassACL(user, fmt.Sprintf(URL, cname), false) {
+	for _, cl := range repman.Clusters {
+		if cl.APIUsers[user].Grants[config.GrantGlobalSettings] {
 			repman.switchClusterSettings(cl, name)
 		}
 	}
@@ -2109,6 +2865,29 @@ func (repman *ReplicationManager) handlerMuxCrashes(w http.ResponseWriter, r *ht
 	}
 }
 
+func (repman *ReplicationManager) handlerMuxCrashTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster == nil {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+	crash := mycluster.GetCrashById(vars["crashId"])
+	if crash == nil || crash.Timeline == nil {
+		http.Error(w, "No timeline found for crash "+vars["crashId"], 404)
+		return
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err := e.Encode(crash.Timeline)
+	if err != nil {
+		mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "API Error encoding JSON: ", err)
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxOneTest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -2361,8 +3140,15 @@ func (repman *ReplicationManager) handlerMuxClusterMasterPhysicalBackup(w http.R
 			http.Error(w, "No valid ACL", 403)
 			return
 		}
+		err := mycluster.RunManualBackup("physical", func() error {
+			_, err := mycluster.GetMaster().JobBackupPhysical()
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		mycluster.GetMaster().JobBackupPhysical()
 	} else {
 		w.WriteHeader(http.StatusBadRequest)
 		io.WriteString(w, "No cluster found:"+vars["clusterName"])
@@ -2386,6 +3172,36 @@ func (repman *ReplicationManager) handlerMuxClusterOptimize(w http.ResponseWrite
 	}
 }
 
+// handlerMuxClusterBackupVerify starts the restore verification of the last
+// backup and returns the backup being verified
+func (repman *ReplicationManager) handlerMuxClusterBackupVerify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		meta := mycluster.GetBackupToVerify()
+		if meta == nil {
+			http.Error(w, "No completed backup to verify", 500)
+			return
+		}
+		go mycluster.JobBackupVerify()
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(meta)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxClusterSSTStop(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -2602,6 +3418,55 @@ func (repman *ReplicationManager) handlerMuxClusterSchemaChecksumAllTable(w http
 
 }
 
+func (repman *ReplicationManager) handlerMuxClusterErrantTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		list, err := mycluster.GetErrantTransactions(r.URL.Query().Get("server"))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err = e.Encode(list)
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxClusterChecksum(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		err := e.Encode(mycluster.GetChecksumStatus())
+		if err != nil {
+			http.Error(w, "Encoding error", 500)
+			return
+		}
+	} else {
+		http.Error(w, "No cluster", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxClusterSchemaChecksumTable(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
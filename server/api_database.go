@@ -77,279 +77,376 @@ func (repman *ReplicationManager) apiDatabaseProtectedHandler(router *mux.Router
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersPortBackup)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/processlist", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerProcesslist)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/variables", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowVariables)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerVariables)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowStatus)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStatus)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-delta", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowStatus)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStatusDelta)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/errorlog", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerErrorLog)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/slow-queries", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSlowLog)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-pfs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPFSStatements)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/digest-statements-slow", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPFSStatementsSlowLog)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/tables", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowSchema)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerTables)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/vtables", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowSchema)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerVTables)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/schemas", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBShowSchema)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSchemas)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/status-innodb", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs, config.GrantDBShowStatus)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerInnoDBStatus)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/all-slaves-status", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerAllSlavesStatus)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/master-status", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMasterStatus)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/service-opensvc", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvDBProvision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxGetDatabaseServiceConfig)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/meta-data-locks", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMetaDataLocks)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/query-response-time", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerQueryResponseTime)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBStart)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStart)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBStop)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStop)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/maintenance", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBMaintenance)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerMaintenance)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-maintenance", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBMaintenance)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSetMaintenance)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/del-maintenance", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBMaintenance)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerDelMaintenance)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/switchover", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterSwitchover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchover)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-prefered", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterFailover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSetPrefered)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-unrated", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterFailover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSetUnrated)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-ignored", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterFailover)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSetIgnored)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/unprovision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvDBUnprovision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerUnprovision)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/provision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvDBProvision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerProvision)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-physical", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupPhysical)),
 	))
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-physical-{strategy:incremental|differential}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupPhysicalIncremental)),
+	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-logical", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupLogical)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-error-log", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupErrorLog)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/backup-slowquery-log", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerBackupSlowQueryLog)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/optimize", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBMaintenance)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerOptimize)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reseed/{backupMethod}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerReseed)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/pitr", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerPITR)),
 	))
 
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/errant-transactions/inject", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerErrantTransactions)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/errant-transactions/reseed/{backupMethod}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBRestore)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerErrantTransactions)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/move-below/{sourceName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerRepoint)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/move-up", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerRepoint)),
+	))
+
+	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/swap-relay/{replicaName}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
+		negroni.HandlerFunc(repman.validateTokenMiddleware),
+		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerRepoint)),
+	))
+
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reseed-cancel", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess, config.GrantDBRestore)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerReseedCancel)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/job-cancel/{task}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServersTaskCancel)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-innodb-monitor", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSetInnoDBMonitor)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/wait-innodb-purge", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBMaintenance)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerWaitInnoDBPurge)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-capture", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBCapture, config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQueryCapture)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query-table", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQueryTable)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-slow-query", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSlowQuery)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-pfs-slow-query", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchPFSSlowQuery)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/set-long-query-time/{queryTime}", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSwitchSetLongQueryTime)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-read-only", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReadOnly)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchReadOnly)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-meta-data-locks", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchMetaDataLocks)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-query-response-time", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchQueryResponseTime)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/toogle-sql-error-log", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerSwitchSqlErrorLog)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-master", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetMaster)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-slave-all", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetSlaveAll)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/flush-logs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBBackup)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerFlushLogs)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/reset-pfs-queries", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBAnalyse)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerResetPFSQueries)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/start-slave", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication, config.GrantDBStart)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStartSlave)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/stop-slave", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication, config.GrantDBStop)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxServerStopSlave)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/skip-replication-event", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBReplication)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSkipReplicationEvent)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/actions/run-jobs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantClusterProcess)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxRunJobs)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-thread", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBKill)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryKillThread)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/kill-query", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBKill)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryKillQuery)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-pfs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryExplainPFS)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/explain-slowlog", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBLogs)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryExplainSlowLog)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-pfs", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBAnalyse, config.GrantDBOptimize)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryAnalyzePFS)),
 	))
 	router.Handle("/api/clusters/{clusterName}/servers/{serverName}/queries/{queryDigest}/actions/analyze-slowlog", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantDBAnalyse)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxQueryAnalyzePFS)),
 	))
@@ -516,7 +613,47 @@ func (repman *ReplicationManager) handlerMuxServerBackupPhysical(w http.Response
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
 		if node != nil {
-			node.JobBackupPhysical()
+			err := mycluster.RunManualBackup("physical", func() error {
+				_, err := node.JobBackupPhysical()
+				return err
+			})
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerBackupPhysicalIncremental(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		var strategy config.BackupStrategy = config.BackupStrategyIncremental
+		if vars["strategy"] == "differential" {
+			strategy = config.BackupStrategyDifferential
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			err := mycluster.RunManualBackup("incremental physical", func() error {
+				_, err := node.JobBackupPhysicalIncremental(strategy)
+				return err
+			})
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
 		} else {
 			http.Error(w, "Server Not Found", 500)
 			return
@@ -538,7 +675,10 @@ func (repman *ReplicationManager) handlerMuxServerBackupLogical(w http.ResponseW
 		}
 		node := mycluster.GetServerFromName(vars["serverName"])
 		if node != nil {
-			go node.JobBackupLogical()
+			if err := mycluster.StartManualBackup("logical", node.JobBackupLogical); err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
 		} else {
 			http.Error(w, "Server Not Found", 500)
 			return
@@ -663,6 +803,94 @@ func (repman *ReplicationManager) handlerMuxServerPITR(w http.ResponseWriter, r
 	}
 }
 
+func (repman *ReplicationManager) handlerMuxServerErrantTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			var req config.ErrantReconcileRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				http.Error(w, fmt.Sprintf("Decode error :%s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			var plan config.ErrantReconcilePlan
+			var err error
+			if vars["backupMethod"] != "" {
+				plan, err = mycluster.ReseedErrantTransactions(node, vars["backupMethod"], req.DryRun)
+			} else {
+				plan, err = mycluster.InjectErrantTransactions(node, req.DryRun)
+			}
+			if err != nil {
+				mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Errant transactions reconciliation of %s failed: %s", node.URL, err)
+				http.Error(w, fmt.Sprintf("Errant transactions error :%s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			e := json.NewEncoder(w)
+			e.SetIndent("", "\t")
+			if err := e.Encode(plan); err != nil {
+				http.Error(w, "Encoding error", 500)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxServerRepoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	mycluster := repman.getClusterByName(vars["clusterName"])
+	if mycluster != nil {
+		if valid, _ := repman.IsValidClusterACL(r, mycluster); !valid {
+			http.Error(w, "No valid ACL", 403)
+			return
+		}
+		node := mycluster.GetServerFromName(vars["serverName"])
+		if node != nil {
+			var err error
+			if vars["sourceName"] != "" {
+				source := mycluster.GetServerFromName(vars["sourceName"])
+				if source == nil {
+					http.Error(w, "Source Not Found", 500)
+					return
+				}
+				err = mycluster.RepointReplica(node, source)
+			} else if vars["replicaName"] != "" {
+				replica := mycluster.GetServerFromName(vars["replicaName"])
+				if replica == nil {
+					http.Error(w, "Replica Not Found", 500)
+					return
+				}
+				err = mycluster.SwapRelay(node, replica)
+			} else {
+				err = mycluster.MoveReplicaUp(node)
+			}
+			if err != nil {
+				mycluster.LogModulePrintf(mycluster.Conf.Verbose, config.ConstLogModTopology, config.LvlErr, "Repointing of %s failed: %s", node.URL, err)
+				http.Error(w, fmt.Sprintf("Repoint error :%s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			http.Error(w, "Server Not Found", 500)
+			return
+		}
+	} else {
+		http.Error(w, "Cluster Not Found", 500)
+		return
+	}
+}
+
 func (repman *ReplicationManager) handlerMuxServerReseedCancel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	vars := mux.Vars(r)
@@ -1817,7 +2045,13 @@ func (repman *ReplicationManager) handlerMuxServersPortBackup(w http.ResponseWri
 		}
 		node := mycluster.GetServerFromURL(vars["serverName"] + ":" + vars["serverPort"])
 		if node.IsDown() == false && node.IsMaintenance == false {
-			go node.JobBackupPhysical()
+			err := mycluster.StartManualBackup("physical", func() error {
+				_, err := node.JobBackupPhysical()
+				return err
+			})
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+			}
 			return
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -12,24 +12,29 @@ import (
 
 	"github.com/codegangsta/negroni"
 	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/config"
 )
 
 func (repman *ReplicationManager) apiProxyProtectedHandler(router *mux.Router) {
 	//PROTECTED ENDPOINTS FOR PROXIES
 
 	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/unprovision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvProxyUnprovision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyUnprovision)),
 	))
 	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/provision", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProvProxyProvision)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyProvision)),
 	))
 	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/stop", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProxyStop)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyStop)),
 	))
 	router.Handle("/api/clusters/{clusterName}/proxies/{proxyName}/actions/start", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants(config.GrantProxyStart)),
 		negroni.HandlerFunc(repman.validateTokenMiddleware),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxProxyStart)),
 	))
@@ -0,0 +1,43 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeGrantsKey struct{}
+
+// routeGrants declares the grants of a route, IsValidClusterACL passes the
+// users having any of them on the cluster and denies the routes without
+// declaration. A route declaring no grant is open to every user of the cluster.
+// It comes before validateTokenMiddleware, which checks personal access tokens
+// on them.
+func (repman *ReplicationManager) routeGrants(grants ...string) func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		next(w, r.WithContext(context.WithValue(r.Context(), routeGrantsKey{}, grants)))
+	}
+}
+
+func getRouteGrants(r *http.Request) ([]string, bool) {
+	grants, ok := r.Context().Value(routeGrantsKey{}).([]string)
+	return grants, ok
+}
+
+// getTokenGroups returns the OAuth groups stored in the token claims
+func getTokenGroups(userinfo map[string]interface{}) []string {
+	var groups []string
+	if list, ok := userinfo["Groups"].([]interface{}); ok {
+		for _, g := range list {
+			if group, ok := g.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
@@ -0,0 +1,167 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/request"
+	"github.com/gorilla/mux"
+	"github.com/signal18/replication-manager/config"
+	"github.com/signal18/replication-manager/utils/apitoken"
+)
+
+// TokenForm is the request of a personal access token, ExpiresIn is in seconds
+// and is capped by api-token-max-ttl
+type TokenForm struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int64    `json:"expiresIn"`
+}
+
+// TokenResponse returns the secret of a created token, it is shown only once
+type TokenResponse struct {
+	Token  apitoken.Token `json:"token"`
+	Secret string         `json:"secret"`
+}
+
+// getAPIToken validates a personal access token bearer, ok is false when the
+// request carries a JWT instead
+func (repman *ReplicationManager) getAPIToken(r *http.Request) (apitoken.Token, bool, error) {
+	bearer, err := request.AuthorizationHeaderExtractor.ExtractToken(r)
+	if err != nil || !apitoken.IsToken(bearer) {
+		return apitoken.Token{}, false, nil
+	}
+	if repman.APITokens == nil {
+		return apitoken.Token{}, true, apitoken.ErrTokenNotFound
+	}
+	tok, err := repman.APITokens.Validate(bearer)
+	return tok, true, err
+}
+
+// isValidTokenRoute checks a personal access token on the grants declared by
+// the route, against its user on the cluster of the route or on any cluster
+// when the route has none. Routes without declaration do not take tokens.
+func (repman *ReplicationManager) isValidTokenRoute(r *http.Request, tok apitoken.Token) bool {
+	grants, declared := getRouteGrants(r)
+	if !declared {
+		return false
+	}
+	if mycluster := repman.getClusterByName(mux.Vars(r)["clusterName"]); mycluster != nil {
+		return mycluster.IsValidTokenACL(tok, grants)
+	}
+	for _, c := range repman.Clusters {
+		if c.IsValidTokenACL(tok, grants) {
+			return true
+		}
+	}
+	return false
+}
+
+// getJWTUser returns the user of a JWT bearer, tokens can not be managed with a token
+func (repman *ReplicationManager) getJWTUser(r *http.Request) (string, error) {
+	if _, ok, _ := repman.getAPIToken(r); ok {
+		return "", errors.New("Personal access tokens can not manage tokens")
+	}
+	token, err := request.ParseFromRequest(r, request.AuthorizationHeaderExtractor, func(token *jwt.Token) (interface{}, error) {
+		vk, _ := jwt.ParseRSAPublicKeyFromPEM(verificationKey)
+		return vk, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userinfo := claims["CustomUserInfo"].(map[string]interface{})
+	user := userinfo["Name"].(string)
+	if profile, ok := userinfo["profile"].(string); ok && strings.Contains(profile, repman.Conf.OAuthProvider) {
+		user = userinfo["email"].(string)
+	}
+	return user, nil
+}
+
+func (repman *ReplicationManager) handlerMuxTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	user, err := repman.getJWTUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(repman.APITokens.List(user))
+	if err != nil {
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxAddToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	user, err := repman.getJWTUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var form TokenForm
+	err = json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		http.Error(w, "Error in request", http.StatusBadRequest)
+		return
+	}
+	grants := repman.Conf.GetGrantType()
+	for _, scope := range form.Scopes {
+		if _, ok := grants[scope]; !ok {
+			http.Error(w, "Unknown grant scope "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+	ttl := time.Duration(form.ExpiresIn) * time.Second
+	maxttl := time.Duration(repman.Conf.APITokenMaxTTL) * time.Hour
+	if maxttl > 0 && (ttl <= 0 || ttl > maxttl) {
+		ttl = maxttl
+	}
+	tok, secret, err := repman.APITokens.Create(user, form.Name, form.Scopes, ttl)
+	if err != nil {
+		http.Error(w, "Error creating token: "+err.Error(), 500)
+		return
+	}
+	repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "API token %s %s created for user %s", tok.Id, tok.Name, user)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	err = e.Encode(TokenResponse{Token: tok, Secret: secret})
+	if err != nil {
+		http.Error(w, "Encoding error", 500)
+		return
+	}
+}
+
+func (repman *ReplicationManager) handlerMuxRevokeToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	vars := mux.Vars(r)
+	user, err := repman.getJWTUser(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	tok, err := repman.APITokens.Revoke(vars["tokenId"], user)
+	if err == apitoken.ErrTokenNotFound {
+		http.Error(w, "No token "+vars["tokenId"], http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking token: "+err.Error(), 500)
+		return
+	}
+	repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "API token %s %s revoked by user %s", tok.Id, tok.Name, user)
+	fmt.Fprintf(w, "Token %s revoked", tok.Id)
+}
@@ -114,6 +114,7 @@ func (repman *ReplicationManager) httpserver() {
 	router.HandleFunc("/api/login-git", repman.loginHandler)
 
 	router.Handle("/api/clusters", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxClusters)),
 	))
 	router.Handle("/api/status", negroni.New(
@@ -176,10 +177,12 @@ func (repman *ReplicationManager) httpserver() {
 	))
 
 	router.Handle("/clusters/{clusterName}/sphinx-indexes", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSphinxIndexes)),
 	))
 
 	router.Handle("/api/clusters/{clusterName}/sphinx-indexes", negroni.New(
+		negroni.HandlerFunc(repman.routeGrants()),
 		negroni.Wrap(http.HandlerFunc(repman.handlerMuxSphinxIndexes)),
 	))
 
@@ -193,6 +196,7 @@ func (repman *ReplicationManager) httpserver() {
 	repman.apiDatabaseUnprotectedHandler(router)
 	if !repman.Conf.APIHttpsBind {
 		router.Handle("/api/monitor", negroni.New(
+			negroni.HandlerFunc(repman.routeGrants()),
 			negroni.Wrap(http.HandlerFunc(repman.handlerMuxReplicationManager)),
 		))
 		repman.apiClusterProtectedHandler(router)
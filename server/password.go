@@ -7,8 +7,10 @@
 package server
 
 import (
+	"bufio"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/signal18/replication-manager/utils/crypto"
@@ -23,6 +25,8 @@ var (
 func init() {
 	rootCmd.AddCommand(keygenCmd)
 	rootCmd.AddCommand(passwordCmd)
+	rootCmd.AddCommand(passwordHashCmd)
+	rootCmd.AddCommand(backupEncryptCmd)
 	keygenCmd.Flags().StringVar(&keyPath, "keypath", "/etc/replication-manager/.replication-manager.key", "Encryption key file path")
 	keygenCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite the previous key")
 	passwordCmd.Flags().StringVar(&keyPath, "keypath", "/etc/replication-manager/.replication-manager.key", "Encryption key file path")
@@ -63,3 +67,36 @@ var passwordCmd = &cobra.Command{
 		fmt.Println("Encrypted password hash:", "hash_"+p.CipherText)
 	},
 }
+
+var passwordHashCmd = &cobra.Command{
+	Use:   "password-hash",
+	Short: "Hash an API user password",
+	Long:  "Hash a clear text API user password with bcrypt to store it in api-credentials or api-credentials-external",
+	Run: func(cmd *cobra.Command, args []string) {
+		hash, err := crypto.HashPassword(strings.Join(args, " "))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("Password hash:", hash)
+	},
+}
+
+var backupEncryptCmd = &cobra.Command{
+	Use:    "backup-encrypt",
+	Short:  "Encrypt a backup stream",
+	Long:   "Encrypt the standard input to the standard output with the backup data key passed by the environment, used by the backup tools writing file by file",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := crypto.DecodeDataKey(os.Getenv(crypto.DataKeyEnv))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		out := bufio.NewWriterSize(os.Stdout, crypto.StreamChunkSize)
+		if err := crypto.EncryptStream(os.Stdin, out, key); err != nil {
+			log.Fatalln(err)
+		}
+		if err := out.Flush(); err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
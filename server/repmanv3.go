@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt"
 	"github.com/gorilla/handlers"
@@ -19,6 +20,7 @@ import (
 	"github.com/signal18/replication-manager/cluster"
 	"github.com/signal18/replication-manager/config"
 	v3 "github.com/signal18/replication-manager/repmanv3"
+	"github.com/signal18/replication-manager/utils/apitoken"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
@@ -85,20 +87,22 @@ func (s *ReplicationManager) StartServerV3(debug bool, router *mux.Router) error
 	if debug {
 		serverOpts = append(serverOpts, grpc.UnaryInterceptor(
 			grpc_middleware.ChainUnaryServer(
+				s.traceUnaryInterceptor,
 				s.unaryInterceptor,
 				// grpc_zap.UnaryServerInterceptor(s.log),
 			),
 		))
 		serverOpts = append(serverOpts, grpc.StreamInterceptor(
 			grpc_middleware.ChainStreamServer(
+				s.traceStreamInterceptor,
 				s.streamInterceptor,
 				// grpc_zap.StreamServerInterceptor(s.log),
 			),
 		))
 	} else {
 		serverOpts = append(serverOpts,
-			grpc.UnaryInterceptor(s.unaryInterceptor),
-			grpc.StreamInterceptor(s.streamInterceptor),
+			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(s.traceUnaryInterceptor, s.unaryInterceptor)),
+			grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(s.traceStreamInterceptor, s.streamInterceptor)),
 		)
 	}
 
@@ -244,6 +248,17 @@ func (s *ReplicationManager) getClusterAndUser(ctx context.Context, req v3.Conta
 	}
 
 	if len(auth[0]) > 6 && strings.ToUpper(auth[0][0:7]) == "BEARER " {
+		if apitoken.IsToken(auth[0][7:]) {
+			tok, err := s.APITokens.Validate(auth[0][7:])
+			if err != nil {
+				return cluster.APIUser{}, nil, fmt.Errorf("failed to validate api token: %w", err)
+			}
+			user, err := mycluster.GetTokenAPIUser(tok)
+			if err != nil {
+				return cluster.APIUser{}, nil, err
+			}
+			return user, mycluster, nil
+		}
 		token, err := jwt.Parse(auth[0][7:], func(token *jwt.Token) (interface{}, error) {
 			vk, _ := jwt.ParseRSAPublicKeyFromPEM(verificationKey)
 			return vk, nil
@@ -403,7 +418,10 @@ func (s *ReplicationManager) MasterPhysicalBackup(ctx context.Context, in *v3.Cl
 	if m == nil {
 		return nil, v3.NewErrorResource(codes.InvalidArgument, v3.ErrClusterMasterNotSet, "cluster", in.Name).Err()
 	}
-	_, err = m.JobBackupPhysical()
+	err = mycluster.RunManualBackup("physical", func() error {
+		_, err := m.JobBackupPhysical()
+		return err
+	})
 	return &emptypb.Empty{}, err
 }
 
@@ -431,6 +449,9 @@ func (s *ReplicationManager) GetSettingsForCluster(ctx context.Context, in *v3.C
 }
 
 func (s *ReplicationManager) SetActionForClusterSettings(ctx context.Context, in *v3.ClusterSetting) (res *emptypb.Empty, err error) {
+	defer func(start time.Time) {
+		s.auditGRPC(ctx, "SetActionForClusterSettings", in.GetCluster().GetName(), in, start, err)
+	}(time.Now())
 	user, mycluster, err := s.getClusterAndUser(ctx, in)
 	if err != nil {
 		return nil, err
@@ -531,6 +552,9 @@ func (s *ReplicationManager) SetActionForClusterSettings(ctx context.Context, in
 }
 
 func (s *ReplicationManager) PerformClusterAction(ctx context.Context, in *v3.ClusterAction) (res *emptypb.Empty, err error) {
+	defer func(start time.Time) {
+		s.auditGRPC(ctx, "PerformClusterAction", in.GetCluster().GetName(), in, start, err)
+	}(time.Now())
 	// WARNING: this one cannot be validated for ACL, as there is no cluster to validate against
 	// special case, the clustername doesn't exist yet
 	if in.Cluster.ClusterShardingName == "" {
@@ -603,7 +627,10 @@ func (s *ReplicationManager) PerformClusterAction(ctx context.Context, in *v3.Cl
 		if m == nil {
 			return nil, v3.NewErrorResource(codes.InvalidArgument, v3.ErrClusterMasterNotSet, "cluster", in.Cluster.Name).Err()
 		}
-		_, err = m.JobBackupPhysical()
+		err = mycluster.RunManualBackup("physical", func() error {
+			_, err := m.JobBackupPhysical()
+			return err
+		})
 	case v3.ClusterAction_OPTIMIZE:
 		mycluster.RollingOptimize()
 	case v3.ClusterAction_RESET_FAILOVER_CONTROL:
@@ -8,6 +8,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"flag"
@@ -55,6 +56,8 @@ import (
 	"github.com/signal18/replication-manager/opensvc"
 	"github.com/signal18/replication-manager/regtest"
 	"github.com/signal18/replication-manager/repmanv3"
+	"github.com/signal18/replication-manager/utils/apitoken"
+	"github.com/signal18/replication-manager/utils/audit"
 	"github.com/signal18/replication-manager/utils/githelper"
 	"github.com/signal18/replication-manager/utils/misc"
 	"github.com/signal18/replication-manager/utils/s18log"
@@ -107,6 +110,8 @@ type ReplicationManager struct {
 	ServerScopeList                                  map[string]bool             `json:"-"`
 	currentCluster                                   *cluster.Cluster            `json:"-"`
 	UserAuthTry                                      sync.Map                    `json:"-"`
+	APITokens                                        *apitoken.Store             `json:"-"`
+	AuditLog                                         *audit.Log                  `json:"-"`
 	OAuthAccessToken                                 *oauth2.Token               `json:"-"`
 	ViperConfig                                      *viper.Viper                `json:"-"`
 	tlog                                             s18log.TermLog
@@ -114,6 +119,7 @@ type ReplicationManager struct {
 	exitMsg                                          string
 	exit                                             bool
 	isStarted                                        bool
+	tracingShutdown                                  func(context.Context) error
 	Confs                                            map[string]config.Config
 	VersionConfs                                     map[string]*config.ConfVersion `json:"-"`
 	grpcServer                                       *grpc.Server                   `json:"-"`
@@ -297,6 +303,8 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.BoolVar(&conf.MonitorPFS, "monitoring-performance-schema", true, "Monitor performance schema")
 	flags.BoolVar(&conf.MonitorInnoDBStatus, "monitoring-innodb-status", true, "Monitor innodb status")
 	flags.StringVar(&conf.MonitorIgnoreErrors, "monitoring-ignore-errors", "", "Comma separated list of error or warning to ignore")
+	flags.BoolVar(&conf.MonitoringStateJournal, "monitoring-state-journal", true, "Journal every state open and resolve transition in statejournal.jsonl of the cluster working directory")
+	flags.IntVar(&conf.MonitoringStateJournalKeep, "monitoring-state-journal-keep", 30, "Days of state transitions kept in the state journal, 0 keeps them all")
 	flags.BoolVar(&conf.MonitorSchemaChange, "monitoring-schema-change", true, "Monitor schema change")
 	flags.StringVar(&conf.MonitorSchemaChangeScript, "monitoring-schema-change-script", "", "Monitor schema change external script")
 	flags.StringVar(&conf.MonitoringSSLCert, "monitoring-ssl-cert", "", "HTTPS & API TLS certificate")
@@ -324,7 +332,7 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.BoolVar(&conf.MonitorCapture, "monitoring-capture", true, "Enable capture on error for 5 monitor loops")
 	flags.StringVar(&conf.MonitorCaptureTrigger, "monitoring-capture-trigger", "ERR00076,ERR00041", "List of errno triggering capture mode")
 	flags.IntVar(&conf.MonitorCaptureFileKeep, "monitoring-capture-file-keep", 5, "Purge capture file keep that number of them")
-	flags.StringVar(&conf.MonitoringAlertTrigger, "monitoring-alert-trigger", "ERR00027,ERR00042,ERR00087", "List of errno triggering an alert to be send")
+	flags.StringVar(&conf.MonitoringAlertTrigger, "monitoring-alert-trigger", "ERR00027,ERR00042,ERR00087,ERR00097", "List of errno triggering an alert to be send")
 
 	flags.BoolVar(&conf.LogSQLInMonitoring, "log-sql-in-monitoring", false, "Log SQL queries send to servers in monitoring")
 
@@ -411,8 +419,13 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.BoolVar(&conf.MasterSlavePgStream, "replication-master-slave-pg-stream", false, "Postgres streaming replication")
 	flags.BoolVar(&conf.MasterSlavePgLogical, "replication-master-slave-pg-locgical", false, "Postgres logical replication")
 	flags.BoolVar(&conf.ReplicationNoRelay, "replication-master-slave-never-relay", true, "Do not allow relay server MSS MXS XXM RSM")
+	flags.IntVar(&conf.ReplicationRepointWaitTimeout, "replication-repoint-wait-timeout", 60, "Seconds to wait for the new source of a repointed replica to be ahead of it")
 	flags.StringVar(&conf.ReplicationErrorScript, "replication-error-script", "", "Replication error script")
 	flags.StringVar(&conf.ReplicationRestartOnSQLErrorMatch, "replication-restart-on-sqlerror-match", "", "Auto restart replication on SQL Error regexep")
+	flags.StringVar(&conf.ReplicationErrorPolicies, "replication-error-policies", "", "Remediation of replication SQL errors by error number, 1062=skip,1032=reapply,1146=resync, actions skip|reapply|resync|reseed-logicalbackup|reseed-physicalbackup|reseed-logicalmaster")
+	flags.IntVar(&conf.ReplicationErrorPolicyMaxActions, "replication-error-policy-max-actions", 5, "Maximum remediations of replication errors of a replica in replication-error-policy-window, 0 for no limit")
+	flags.IntVar(&conf.ReplicationErrorPolicyWindow, "replication-error-policy-window", 3600, "Time window in seconds of replication-error-policy-max-actions")
+	flags.IntVar(&conf.ReplicationErrorPolicyBinlogServerId, "replication-error-policy-binlog-server-id", 10002, "Server ID for reading the failed transactions in the binlogs of the source")
 
 	flags.StringVar(&conf.PreScript, "failover-pre-script", "", "Path of pre-failover script")
 	flags.StringVar(&conf.PostScript, "failover-post-script", "", "Path of post-failover script")
@@ -437,6 +450,11 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.IntVar(&conf.MaxFail, "failover-falsepositive-ping-counter", 5, "Failover after this number of ping failures (interval 1s)")
 	flags.IntVar(&conf.FailoverLogFileKeep, "failover-log-file-keep", 5, "Purge log files taken during failover")
 	flags.BoolVar(&conf.FailoverCheckDelayStat, "failover-check-delay-stat", false, "Use delay avg statistic for failover decision")
+	flags.StringVar(&conf.TopologyZones, "topology-zones", "", "Region and zone of database servers and proxies, host:port=region/zone list overriding the labels of the orchestrator agents")
+	flags.BoolVar(&conf.FailZonePreferLocal, "failover-zone-prefer-local", true, "Election prefers up to date candidates in the zone, then in the region of the master")
+	flags.BoolVar(&conf.FailZoneCrossRegion, "failover-zone-cross-region", true, "Automatic failover can promote a candidate in another region than the master, else a manual failover is needed")
+	flags.IntVar(&conf.FailZoneMin, "failover-zone-min", 0, "Automatic failover needs servers up in this number of zones, 0 to disable")
+	flags.BoolVar(&conf.FailZoneProxyCrossRegion, "failover-zone-proxy-cross-region", true, "Proxies route to a master in another region than the proxy")
 	flags.BoolVar(&conf.DelayStatCapture, "delay-stat-capture", false, "Capture hourly statistic for delay average")
 	flags.BoolVar(&conf.PrintDelayStat, "print-delay-stat", false, "Print captured delay statistic")
 	flags.BoolVar(&conf.PrintDelayStatHistory, "print-delay-stat-history", false, "Print captured delay statistic history")
@@ -471,6 +489,15 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.StringVar(&conf.TeamsProxyUrl, "alert-teams-proxy-url", "", "Proxy url for Teams Webhook")
 	flags.StringVar(&conf.TeamsAlertState, "alert-teams-state", "", "State Code for Teams Alert : ERR|WARN|INFO")
 
+	flags.StringVar(&conf.AlertWebhookURL, "alert-webhook-url", "", "Webhook URL receiving JSON state open and resolve events")
+	flags.StringVar(&conf.AlertWebhookTemplate, "alert-webhook-template", "", "Go template file name in the alert directory of the share directory or inline template for the webhook body, default is the JSON event")
+	flags.StringVar(&conf.AlertPagerDutyURL, "alert-pagerduty-url", "https://events.pagerduty.com/v2/enqueue", "PagerDuty compatible events API URL")
+	flags.StringVar(&conf.AlertPagerDutyRoutingKey, "alert-pagerduty-routing-key", "", "PagerDuty integration routing key for alerts")
+	flags.StringVar(&conf.AlertOpsgenieURL, "alert-opsgenie-url", "https://api.opsgenie.com/v2/alerts", "Opsgenie compatible alert API URL")
+	flags.StringVar(&conf.AlertOpsgenieApiKey, "alert-opsgenie-api-key", "", "Opsgenie API key for alerts")
+	flags.StringVar(&conf.AlertRoutes, "alert-routes", "", "Alert routing rules separated by ; each rule as key=ERR00027,WARN severity=ERROR server=host:port tag=tag channels=mail,pagerduty, unmatched states go to every channel")
+	flags.IntVar(&conf.AlertGroupWindow, "alert-group-window", 0, "Time in seconds to group the transitions of a flapping state into one notification, 0 disable grouping")
+
 	conf.CheckType = "tcp"
 	flags.BoolVar(&conf.CheckReplFilter, "check-replication-filters", true, "Check that possible master have equal replication filters")
 	flags.BoolVar(&conf.CheckBinFilter, "check-binlog-filters", true, "Check that possible master have equal binlog filters")
@@ -478,12 +505,24 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.BoolVar(&conf.RplChecks, "check-replication-state", true, "Check replication status when electing master server")
 	flags.BoolVar(&conf.RplCheckErrantTrx, "check-replication-errant-trx", true, "Check replication have no errant transaction in MySQL GTID")
 	flags.IntVar(&conf.CheckBinServerId, "check-binlog-server-id", 10000, "Server ID for checking binlogs timestamps")
+	flags.IntVar(&conf.ChecksumChunkTime, "checksum-chunk-time", 500, "Time in milliseconds targeted by the table checksum of a chunk, the chunk size adapts to it")
+	flags.IntVar(&conf.ChecksumChunkSize, "checksum-chunk-size", 1000, "Rows of the first chunk of a table checksum")
+	flags.IntVar(&conf.ChecksumChunkSizeMax, "checksum-chunk-size-max", 100000, "Maximum rows of a table checksum chunk")
+	flags.IntVar(&conf.ChecksumMaxDelay, "checksum-max-delay", 10, "Pause the table checksum while a replica is delayed by more seconds, 0 to disable")
+	flags.IntVar(&conf.ChecksumMaxThreadsRunning, "checksum-max-threads-running", 25, "Pause the table checksum while the master has more Threads_running, 0 to disable")
+	flags.StringVar(&conf.ChecksumRepair, "checksum-repair", "none", "Repair of the rows differing on a replica none|generate|apply, generate writes the statements to a file, apply runs them on the master through replication")
+	flags.IntVar(&conf.ChecksumSyncTimeout, "checksum-sync-timeout", 600, "Time in seconds waiting for a replica to replay a table checksum, the replica is not compared after")
 
 	flags.StringVar(&conf.APIPort, "api-port", "10005", "Rest API listen port")
 	flags.StringVar(&conf.APIUsers, "api-credentials", "admin:repman", "Rest API user list user:password,..")
 	flags.StringVar(&conf.APIUsersExternal, "api-credentials-external", "", "Rest API user list user:password,.. as dba:repman,foo:bar")
 	flags.StringVar(&conf.APIUsersACLAllow, "api-credentials-acl-allow", "admin:global cluster proxy db prov,dba:cluster proxy db,foo:", "User acl allow")
 	flags.StringVar(&conf.APIUsersACLDiscard, "api-credentials-acl-discard", "", "User acl discard")
+	flags.IntVar(&conf.APITokenMaxTTL, "api-token-max-ttl", 8760, "Maximum lifetime in hours of personal access tokens, 0 for no limit")
+	flags.BoolVar(&conf.APIAuditLog, "api-audit-log", true, "Record every mutating API and gRPC call in audit.jsonl of the working directory")
+	flags.StringVar(&conf.APIRoles, "api-roles", "", "Custom roles or built-in roles override role:grant grant,.. as grant prefixes like in api-credentials-acl-allow")
+	flags.StringVar(&conf.APIUsersRoles, "api-credentials-roles", "", "User roles user:role@cluster-pattern role,.. as dba:operator@prod-* viewer")
+	flags.StringVar(&conf.OAuthGroupRoles, "api-oauth-group-roles", "", "OAuth groups roles group:role@cluster-pattern role,..")
 	flags.StringVar(&conf.APIBind, "api-bind", "0.0.0.0", "Rest API bind ip")
 	flags.BoolVar(&conf.APIHttpsBind, "api-https-bind", false, "Bind API call to https Web UI will error with http")
 	flags.BoolVar(&conf.APISecureConfig, "api-credentials-secure-config", false, "Need JWT token to download config tar.gz")
@@ -643,6 +682,12 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 		flags.BoolVar(&conf.GraphiteBlacklist, "graphite-blacklist", false, "Enable Blacklist")
 		flags.StringVar(&conf.GraphiteWhitelistTemplate, "graphite-whitelist-template", "minimal", "Graphite default template for whitelist (none | minimal | grafana | all)")
 	}
+	flags.BoolVar(&conf.PrometheusLegacyMetrics, "prometheus-legacy-metrics", true, "Deprecated, export the database metrics of /api/prometheus also under their former names with the instance label only")
+	flags.StringVar(&conf.TracingExporter, "tracing-exporter", "", "OpenTelemetry traces of the monitoring loop, failovers and API calls (otlp | file), empty to disable")
+	flags.StringVar(&conf.TracingOTLPEndpoint, "tracing-otlp-endpoint", "localhost:4318", "OTLP/HTTP collector host:port")
+	flags.BoolVar(&conf.TracingOTLPInsecure, "tracing-otlp-insecure", true, "Send traces to the OTLP collector without TLS")
+	flags.StringVar(&conf.TracingFile, "tracing-file", "", "Traces file of the file exporter, default traces.jsonl in the working directory")
+	flags.Float64Var(&conf.TracingSampleRatio, "tracing-sample-ratio", 1, "Ratio of monitoring loops and API calls traced, between 0 and 1")
 	//	flags.BoolVar(&conf.Heartbeat, "heartbeat-table", false, "Heartbeat for active/passive or multi mrm setup")
 	if WithArbitrationClient == "ON" {
 		flags.BoolVar(&conf.Arbitration, "arbitration-external", false, "Multi moninitor sas arbitration")
@@ -660,12 +705,18 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.BoolVar(&conf.SchedulerReceiverUseSSL, "scheduler-db-servers-receiver-use-ssl", false, "Listner to send data to db node is use SSL")
 	flags.BoolVar(&conf.SchedulerBackupLogical, "scheduler-db-servers-logical-backup", true, "Schedule logical backup")
 	flags.BoolVar(&conf.SchedulerBackupPhysical, "scheduler-db-servers-physical-backup", false, "Schedule physical backup")
+	flags.BoolVar(&conf.SchedulerBackupPhysicalIncremental, "scheduler-db-servers-physical-backup-incremental", false, "Schedule incremental physical backups chained to the last physical backup")
+	flags.BoolVar(&conf.SchedulerBackupPhysicalDifferential, "scheduler-db-servers-physical-backup-differential", false, "Schedule differential physical backups chained to the last full physical backup")
 	flags.BoolVar(&conf.SchedulerDatabaseLogs, "scheduler-db-servers-logs", false, "Schedule database logs fetching")
 	flags.BoolVar(&conf.SchedulerDatabaseOptimize, "scheduler-db-servers-optimize", false, "Schedule database optimize")
 	flags.BoolVar(&conf.SchedulerDatabaseAnalyze, "scheduler-db-servers-analyze", true, "Schedule database analyze")
 
 	flags.StringVar(&conf.BackupLogicalCron, "scheduler-db-servers-logical-backup-cron", "0 0 1 * * 6", "Logical backup cron expression represents a set of times, using 6 space-separated fields.")
 	flags.StringVar(&conf.BackupPhysicalCron, "scheduler-db-servers-physical-backup-cron", "0 0 0 * * 0-4", "Physical backup cron expression represents a set of times, using 6 space-separated fields.")
+	flags.StringVar(&conf.BackupPhysicalIncrementalCron, "scheduler-db-servers-physical-backup-incremental-cron", "0 0 4/4 * * *", "Incremental physical backup cron expression represents a set of times, using 6 space-separated fields.")
+	flags.StringVar(&conf.BackupPhysicalDifferentialCron, "scheduler-db-servers-physical-backup-differential-cron", "0 0 0 * * 5-6", "Differential physical backup cron expression represents a set of times, using 6 space-separated fields.")
+	flags.BoolVar(&conf.SchedulerBackupVerify, "scheduler-db-servers-backup-verify", false, "Schedule restore verification of the last backup")
+	flags.StringVar(&conf.BackupVerifyCron, "scheduler-db-servers-backup-verify-cron", "0 0 6 * * 6", "Backup restore verification cron expression represents a set of times, using 6 space-separated fields.")
 	flags.StringVar(&conf.BackupDatabaseOptimizeCron, "scheduler-db-servers-optimize-cron", "0 0 3 1 * 5", "Optimize cron expression represents a set of times, using 6 space-separated fields.")
 	flags.StringVar(&conf.BackupDatabaseAnalyzeCron, "scheduler-db-servers-analyze-cron", "0 0 4 2 * *", "Analyze cron expression represents a set of times, using 6 space-separated fields.")
 	flags.StringVar(&conf.BackupDatabaseLogCron, "scheduler-db-servers-logs-cron", "0 0/10 * * * *", "Logs backup cron expression represents a set of times, using 6 space-separated fields.")
@@ -689,6 +740,13 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.IntVar(&conf.BackupLogicalLoadThreads, "backup-logical-load-threads", 2, "Number of threads to load database")
 	flags.IntVar(&conf.BackupLogicalDumpThreads, "backup-logical-dump-threads", 2, "Number of threads to dump database")
 	flags.BoolVar(&conf.BackupLogicalDumpSystemTables, "backup-logical-dump-system-tables", false, "Backup restore the mysql database")
+	flags.IntVar(&conf.BackupThrottleBandwidth, "backup-throttle-bandwidth", 0, "Bandwidth in MB/s shared by the backup streams of a cluster received by replication-manager, 0 for no limit")
+	flags.IntVar(&conf.BackupThrottleThreads, "backup-throttle-threads", 0, "Maximum IO threads of mydumper and myloader, 0 for the backup-logical-*-threads")
+	flags.IntVar(&conf.BackupThrottleMaxJobs, "backup-throttle-max-jobs", 0, "Maximum backup jobs of this replication-manager running at once, scheduled or requested by the API, 0 for no limit")
+	flags.IntVar(&conf.BackupThrottleMaxDelay, "backup-throttle-max-delay", 0, "Defer scheduled backups and pause backup streams while a replica is delayed by more seconds, 0 to disable")
+	flags.IntVar(&conf.BackupThrottleMaxThreadsRunning, "backup-throttle-max-threads-running", 0, "Defer scheduled backups and pause backup streams while the master has more Threads_running, 0 to disable")
+	flags.IntVar(&conf.BackupThrottleDeferTimeout, "backup-throttle-defer-timeout", 3600, "Seconds a scheduled backup waits for the throttle before it is skipped")
+	flags.IntVar(&conf.BackupThrottleMaxPause, "backup-throttle-max-pause", 600, "Seconds a backup stream can be paused by the throttle in total before it resumes, so the redo log does not wrap under mariabackup, 0 for no limit")
 	flags.StringVar(&conf.BackupLogicalType, "backup-logical-type", "mysqldump", "type of logical backup: river|mysqldump|mydumper")
 	flags.StringVar(&conf.BackupPhysicalType, "backup-physical-type", "xtrabackup", "type of physical backup: xtrabackup|mariabackup")
 	flags.BoolVar(&conf.BackupRestic, "backup-restic", false, "Use restic to archive and restore backups")
@@ -712,10 +770,24 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 	flags.IntVar(&conf.BackupKeepWeekly, "backup-keep-weekly", 4, "Keep this number of weekly backup")
 	flags.IntVar(&conf.BackupKeepMonthly, "backup-keep-monthly", 12, "Keep this number of monthly backup")
 	flags.IntVar(&conf.BackupKeepYearly, "backup-keep-yearly", 2, "Keep this number of yearly backup")
+	flags.BoolVar(&conf.BackupRetention, "backup-retention", false, "Keep the previous logical and physical backups in the backup directory and purge all backup types with the backup-keep-* policy")
+	flags.StringVar(&conf.BackupVerifyMethod, "backup-verify-method", "", "Backup method to verify logical|physical, the most recent backup when empty")
+	flags.StringVar(&conf.BackupVerifyServer, "backup-verify-server", "", "Replica host:port reseeded without replication to verify backups, a throwaway instance started from prov-db-binary-basedir when empty")
+	flags.IntVar(&conf.BackupVerifyRowTolerance, "backup-verify-row-count-tolerance", 20, "Percentage of difference allowed between the row count of a restored table and the count recorded at backup time, for the writes during the backup")
 
 	flags.StringVar(&conf.BackupSaveScript, "backup-save-script", "", "Customized backup save script")
 	flags.StringVar(&conf.BackupLoadScript, "backup-load-script", "", "Customized backup load script")
 	flags.BoolVar(&conf.CompressBackups, "compress-backups", false, "To compress backups")
+	flags.BoolVar(&conf.BackupEncrypt, "backup-encrypt", false, "Encrypt backups, dumps, physical streams and binary logs copies at rest with AES-256-GCM")
+	flags.StringVar(&conf.BackupEncryptionMasterKey, "backup-encryption-master-key", "", "Hex encoded 32 bytes master key wrapping the backup data keys, can be a vault path, derived from the key of monitoring-key-path when empty")
+	flags.StringVar(&conf.BackupStorage, "backup-storage", "", "Copy backups and binary logs to an object storage local|s3|azure, s3 uses the backup-streaming-* bucket and credentials")
+	flags.StringVar(&conf.BackupStoragePath, "backup-storage-path", "", "Directory of the local backup storage")
+	flags.StringVar(&conf.BackupStoragePrefix, "backup-storage-prefix", "", "Prefix of the backup objects in the bucket or container")
+	flags.IntVar(&conf.BackupStoragePartSize, "backup-storage-part-size", 16, "Size in MB of the parts of multipart uploads")
+	flags.StringVar(&conf.BackupStorageAzureAccount, "backup-storage-azure-account", "", "Azure storage account")
+	flags.StringVar(&conf.BackupStorageAzureKey, "backup-storage-azure-key", "", "Azure storage account key")
+	flags.StringVar(&conf.BackupStorageAzureContainer, "backup-storage-azure-container", "", "Azure blob container")
+	flags.StringVar(&conf.BackupStorageAzureEndpoint, "backup-storage-azure-endpoint", "", "Azure blob service endpoint, https://<account>.blob.core.windows.net when empty")
 
 	flags.BoolVar(&conf.BackupKeepUntilValid, "backup-keep-until-valid", false, "Backup will rename previous backup to .old before removing after new backup valid")
 	flags.StringVar(&conf.BackupMyDumperPath, "backup-mydumper-path", "/usr/bin/mydumper", "Path to mydumper binary")
@@ -729,6 +801,8 @@ func (repman *ReplicationManager) AddFlags(flags *pflag.FlagSet, conf *config.Co
 
 	flags.BoolVar(&conf.BackupBinlogs, "backup-binlogs", false, "Archive binlogs")
 	flags.IntVar(&conf.BackupBinlogsKeep, "backup-binlogs-keep", 10, "Number of master binlog to keep")
+	flags.BoolVar(&conf.BackupBinlogsArchiver, "backup-binlogs-archiver", false, "Stream the binary logs of the master to the backup directory as a replica, following the master after failover")
+	flags.IntVar(&conf.BackupBinlogsArchiverServerId, "backup-binlogs-archiver-server-id", 10001, "Server ID of the binary logs archiver replica connection")
 
 	//Using mysqlbinlog for PRO since it's using opensvc and k8s
 	if WithProvisioning == "ON" {
@@ -1755,6 +1829,14 @@ func (repman *ReplicationManager) Run() error {
 	repman.InitServicePlans()
 	repman.ServiceOrchestrators = repman.Conf.GetOrchestratorsProv()
 	repman.InitGrants()
+	repman.APITokens = apitoken.NewStore(repman.Conf.WorkingDir + "/apitokens.json")
+	if err := repman.APITokens.Load(); err != nil {
+		repman.Logrus.WithError(err).Errorf("Loading API tokens failed: %s", err)
+	}
+	if repman.Conf.APIAuditLog {
+		repman.AuditLog = audit.NewLog(repman.Conf.WorkingDir + "/audit.jsonl")
+	}
+	repman.InitTracing()
 	repman.ServiceRepos, err = repman.Conf.GetDockerRepos(repman.Conf.ShareDir+"/repo/repos.json", repman.Conf.Test)
 	if err != nil {
 		repman.Logrus.WithError(err).Errorf("Initialization docker repo failed: %s %s", repman.Conf.ShareDir+"/repo/repos.json", err)
@@ -2210,6 +2292,13 @@ func (repman *ReplicationManager) Stop() {
 		f.Close()
 	}
 	repman.Save()
+	if repman.tracingShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := repman.tracingShutdown(ctx); err != nil {
+			repman.Logrus.WithError(err).Errorf("Flushing traces failed: %s", err)
+		}
+		cancel()
+	}
 	if repman.Conf.GitUrl != "" {
 		go repman.PushConfigToGit(repman.Conf.Secrets["git-acces-token"].Value, repman.Conf.GitUsername, repman.Conf.WorkingDir)
 	}
@@ -2390,6 +2479,8 @@ func (repman *ReplicationManager) GetEncryptedValueFromMemory(key string) string
 		return strings.Join(tab_ApiUser, ",")
 	case "backup-restic-password":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("backup-restic-password"))
+	case "backup-encryption-master-key":
+		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("backup-encryption-master-key"))
 	case "haproxy-password":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("haproxy-password"))
 	case "maxscale-pass":
@@ -2411,12 +2502,18 @@ func (repman *ReplicationManager) GetEncryptedValueFromMemory(key string) string
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("backup-restic-aws-access-secret"))
 	case "backup-streaming-aws-access-secret":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("backup-streaming-aws-access-secret"))
+	case "backup-storage-azure-key":
+		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("backup-storage-azure-key"))
 	case "arbitration-external-secret":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("arbitration-external-secret"))
 	case "alert-pushover-user-token":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("alert-pushover-user-token"))
 	case "alert-pushover-app-token":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("alert-pushover-app-token"))
+	case "alert-pagerduty-routing-key":
+		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("alert-pagerduty-routing-key"))
+	case "alert-opsgenie-api-key":
+		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("alert-opsgenie-api-key"))
 	case "mail-smtp-password":
 		return repman.Conf.GetEncryptedString(repman.Conf.GetDecryptedValue("mail-smtp-password"))
 	case "api-oauth-client-secret":
@@ -0,0 +1,141 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Author: Stephane Varoqui  <svaroqui@gmail.com>
+// License: GNU General Public License, version 3. Redistribution/Reuse of this code is permitted under the GNU v3 license, as an additional term ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/signal18/replication-manager/config"
+	v3 "github.com/signal18/replication-manager/repmanv3"
+	"github.com/signal18/replication-manager/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// InitTracing starts the OpenTelemetry exporter of tracing-exporter
+func (repman *ReplicationManager) InitTracing() {
+	if repman.Conf.TracingExporter == tracing.ExporterNone {
+		return
+	}
+	file := repman.Conf.TracingFile
+	if file == "" {
+		file = repman.Conf.WorkingDir + "/traces.jsonl"
+	}
+	shutdown, err := tracing.Init(tracing.Options{
+		Exporter:    repman.Conf.TracingExporter,
+		Endpoint:    repman.Conf.TracingOTLPEndpoint,
+		Insecure:    repman.Conf.TracingOTLPInsecure,
+		File:        file,
+		SampleRatio: repman.Conf.TracingSampleRatio,
+		Version:     repman.Version,
+	})
+	if err != nil {
+		repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlErr, "Tracing initialization failed: %s", err)
+		return
+	}
+	repman.tracingShutdown = shutdown
+	repman.LogModulePrintf(repman.Conf.Verbose, config.ConstLogModGeneral, config.LvlInfo, "Tracing enabled with %s exporter", repman.Conf.TracingExporter)
+}
+
+// traceResponseWriter keeps the status of a traced response
+type traceResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (tw *traceResponseWriter) WriteHeader(code int) {
+	tw.status = code
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *traceResponseWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// traceMiddleware opens a span per API call named after the route template
+func (repman *ReplicationManager) traceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if repman.tracingShutdown == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		vars := mux.Vars(r)
+		attrs := []attribute.KeyValue{
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+		}
+		if vars["clusterName"] != "" {
+			attrs = append(attrs, attribute.String("cluster", vars["clusterName"]))
+		}
+		if vars["serverName"] != "" {
+			srv := vars["serverName"]
+			if vars["serverPort"] != "" {
+				srv += ":" + vars["serverPort"]
+			}
+			attrs = append(attrs, attribute.String("server", srv))
+		}
+		if vars["proxyName"] != "" {
+			attrs = append(attrs, attribute.String("proxy", vars["proxyName"]))
+		}
+		ctx, span := tracing.Start(r.Context(), r.Method+" "+route, attrs...)
+		defer span.End()
+		tw := &traceResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.status_code", tw.status))
+		if tw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(tw.status))
+		}
+	})
+}
+
+// traceGRPC opens a span per gRPC call, the cluster attribute is read from
+// the request message
+func (s *ReplicationManager) traceGRPC(ctx context.Context, method string, req interface{}) (context.Context, func(err error)) {
+	if s.tracingShutdown == nil {
+		return ctx, func(error) {}
+	}
+	attrs := []attribute.KeyValue{attribute.String("rpc.method", method)}
+	if cMsg, ok := req.(v3.ContainsClusterMessage); ok {
+		if c, err := cMsg.GetClusterMessage(); err == nil && c.Name != "" {
+			attrs = append(attrs, attribute.String("cluster", c.Name))
+		}
+	}
+	ctx, span := tracing.Start(ctx, method, attrs...)
+	return ctx, func(err error) {
+		span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(status.Code(err))))
+		tracing.End(span, err)
+	}
+}
+
+func (s *ReplicationManager) traceUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, end := s.traceGRPC(ctx, info.FullMethod, req)
+	resp, err := handler(ctx, req)
+	end(err)
+	return resp, err
+}
+
+func (s *ReplicationManager) traceStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, end := s.traceGRPC(stream.Context(), info.FullMethod, nil)
+	wrapped := grpc_middleware.WrapServerStream(stream)
+	wrapped.WrappedContext = ctx
+	err := handler(srv, wrapped)
+	end(err)
+	return err
+}
@@ -17,6 +17,45 @@ pauseJob()
  /usr/bin/mysql -u$USER -p$PASSWORD -e "select sleep(6);set sql_log_bin=0;UPDATE replication_manager_schema.jobs set result=LOAD_FILE('/tmp/dbjob.out') WHERE id='$ID';" &
 }
 
+# Value of a key=value job parameter
+jobParam()
+{
+ echo "$PARAMS" | tr ' ' '\n' | grep "^$1=" | cut -d= -f2-
+}
+
+incrementalOpts()
+{
+ local lsn=$(jobParam incremental-lsn)
+ if [ "$lsn" != "" ]; then
+  echo "--incremental --incremental-lsn=$lsn"
+ fi
+}
+
+# Extract the incremental backups sent after the full backup
+receiveChain()
+{
+ local count=$(jobParam chain)
+ rm -rf $BACKUPDIR.inc
+ for i in $(seq 1 ${count:-0}) ; do
+  mkdir -p $BACKUPDIR.inc/$i
+  socat -u TCP-LISTEN:4444,reuseaddr STDOUT | xbstream -x -C $BACKUPDIR.inc/$i
+ done
+}
+
+# Apply the incremental backups on the full backup and prepare it for export
+prepareChain()
+{
+ local count=$(jobParam chain)
+ if [ "${count:-0}" -gt 0 ]; then
+  xtrabackup --prepare --apply-log-only --target-dir=$BACKUPDIR || return 1
+  for i in $(seq 1 $count) ; do
+   xtrabackup --prepare --apply-log-only --target-dir=$BACKUPDIR --incremental-dir=$BACKUPDIR.inc/$i || return 1
+  done
+  rm -rf $BACKUPDIR.inc
+ fi
+ xtrabackup --prepare --export --target-dir=$BACKUPDIR
+}
+
 partialRestore()
 {
  /usr/bin/mysql -p$PASSWORD -u$USER -e "set sql_log_bin=0;install plugin BLACKHOLE soname 'ha_blackhole.so'"
@@ -58,6 +97,10 @@ do
 
  ADDRESS=($(echo $TASK | awk -F@ '{ print $2 }'))
  ID=($(echo $TASK | awk -F@ '{ print $1 }'))
+ PARAMS=""
+ if [ "$ID" != "" ]; then
+  PARAMS=$(echo "select params from replication_manager_schema.jobs WHERE id=$ID" | /usr/bin/mysql -p$PASSWORD -u$USER -N 2>/dev/null)
+ fi
  /usr/bin/mysql -uroot -p$PASSWORD -e "set sql_log_bin=0;UPDATE replication_manager_schema.jobs set done=1 WHERE task='$job';"
 
   if [ "$ADDRESS" == "" ]; then
@@ -83,7 +126,8 @@ do
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
        socat -u TCP-LISTEN:4444,reuseaddr STDOUT | xbstream -x -C $BACKUPDIR
-       xtrabackup --prepare --export --target-dir=$BACKUPDIR
+       receiveChain
+       prepareChain
        partialRestore
       ;;
       flashbackxtrabackup)
@@ -92,12 +136,13 @@ do
        echo "Waiting backup." >  /tmp/dbjob.out
        pauseJob
        socat -u TCP-LISTEN:4444,reuseaddr STDOUT | xbstream -x -C $BACKUPDIR
-       xtrabackup --prepare --export --target-dir=$BACKUPDIR
+       receiveChain
+       prepareChain
        partialRestore
       ;;
       xtrabackup)
        cd /docker-entrypoint-initdb.d
-       /usr/bin/innobackupex  --defaults-file=/etc/mysql/my.cnf --socket='/var/run/mysqld/mysqld.sock' --slave-info --no-version-check  --user=$USER --password=$PASSWORD --stream=xbstream /tmp/ | socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
+       /usr/bin/innobackupex  --defaults-file=/etc/mysql/my.cnf --socket='/var/run/mysqld/mysqld.sock' --slave-info --no-version-check  --user=$USER --password=$PASSWORD $(incrementalOpts) --stream=xbstream /tmp/ | socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
       ;;
       error)
        cat $ERROLOG| socat -u stdio TCP:$ADDRESS &>/tmp/dbjob.out
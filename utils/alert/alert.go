@@ -17,24 +17,127 @@ import (
 	"log"
 	"net/smtp"
 	"strings"
+	"time"
 
 	"github.com/jordan-wright/email"
 	"github.com/signal18/replication-manager/config"
 )
 
+// Alert types, a notifier choose the ones it can deliver
+const (
+	TypeLog     = "log"     // free text log line from LogPrintf
+	TypeState   = "state"   // opened or resolved state from the state machine
+	TypeMessage = "message" // explicit message with a subject
+)
+
+// IsRelayedLogLevel tells if a channel relays the log lines of a level, the
+// other lines are not dispatched
+func IsRelayedLogLevel(level string) bool {
+	switch level {
+	case "ERROR", "ALERT", "WARN", "START", "STATE":
+		return true
+	}
+	return false
+}
+
 type Alert struct {
+	Type        string
 	From        string
 	To          string
 	Instance    string
 	State       string
 	PrevState   string
 	Cluster     string
+	Monitor     string
 	Host        string
 	Destination string
 	User        string
 	Password    string
 	TlsVerify   bool
 	Resolved    bool
+	Level       string
+	Module      string
+	ErrKey      string
+	ErrType     string
+	Subject     string
+	Text        string
+	Timestamp   time.Time
+	Flaps       int
+}
+
+// Event is the structured view of a state alert sent to webhooks and incident APIs
+type Event struct {
+	Action      string    `json:"action"`
+	DedupKey    string    `json:"dedupKey"`
+	Cluster     string    `json:"cluster"`
+	Monitor     string    `json:"monitor"`
+	ErrKey      string    `json:"errKey"`
+	ErrType     string    `json:"errType"`
+	Description string    `json:"description"`
+	ServerUrl   string    `json:"serverUrl"`
+	Timestamp   time.Time `json:"timestamp"`
+	Flaps       int       `json:"flaps,omitempty"`
+}
+
+const (
+	EventOpen    = "open"
+	EventResolve = "resolve"
+)
+
+// DedupKey identify the same state on the same server across open and resolve
+func (a *Alert) DedupKey() string {
+	key := a.Cluster + "/" + a.ErrKey
+	if a.Host != "" {
+		key = key + "@" + a.Host
+	}
+	return key
+}
+
+func (a *Alert) Event() Event {
+	action := EventOpen
+	if a.Resolved {
+		action = EventResolve
+	}
+	ts := a.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return Event{
+		Action:      action,
+		DedupKey:    a.DedupKey(),
+		Cluster:     a.Cluster,
+		Monitor:     a.Monitor,
+		ErrKey:      a.ErrKey,
+		ErrType:     a.ErrType,
+		Description: a.State,
+		ServerUrl:   a.Host,
+		Timestamp:   ts,
+		Flaps:       a.Flaps,
+	}
+}
+
+// StateText returns a state alert as a line for the chat channels
+func (a *Alert) StateText() string {
+	if a.PrevState != "" {
+		return fmt.Sprintf("State of %s changed from %s to %s", a.Host, a.PrevState, a.State)
+	}
+	status := "OPENED"
+	if a.Resolved {
+		status = "RESOLV"
+	}
+	text := status + " " + a.ErrKey + " : " + a.State
+	if a.Host != "" {
+		text = text + " (" + a.Host + ")"
+	}
+	if a.Flaps > 0 {
+		text = text + fmt.Sprintf(", %d transitions grouped", a.Flaps)
+	}
+	return text
+}
+
+// IsStateError tells if a state alert opens an error
+func (a *Alert) IsStateError() bool {
+	return !a.Resolved && a.ErrType == "ERROR"
 }
 
 func (a *Alert) EmailMessage(msg string, subj string, Conf config.Config) error {
@@ -46,6 +149,9 @@ func (a *Alert) EmailMessage(msg string, subj string, Conf config.Config) error
 	if a.Host != "" {
 		host = "Host: " + a.Host + "\n"
 	}
+	if a.Flaps > 0 {
+		host = host + fmt.Sprintf("Flapping: %d transitions grouped\n", a.Flaps)
+	}
 	if msg == "" {
 		e.Subject = fmt.Sprintf("Replication-Manager@%s Alert - Cluster %s state change detected", Conf.MonitorAddress, a.Cluster)
 		text := fmt.Sprintf("Alert: State changed from %s to %s\nMonitor: %s\nCluster: %s\n%s", a.PrevState, a.State, Conf.MonitorAddress, a.Cluster, host)
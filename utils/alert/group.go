@@ -0,0 +1,74 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"sync"
+	"time"
+)
+
+// Grouper folds the transitions of a flapping state into one notification.
+// The first transition of a dedup key is sent at once, the following ones
+// inside the window are held and Flush sends the last one when the window is
+// over if it differs from what was sent.
+type Grouper struct {
+	window  time.Duration
+	entries map[string]*groupEntry
+	sync.Mutex
+}
+
+type groupEntry struct {
+	start time.Time
+	sent  Alert
+	last  Alert
+	count int
+}
+
+func NewGrouper(window time.Duration) *Grouper {
+	return &Grouper{window: window, entries: make(map[string]*groupEntry)}
+}
+
+// Add returns true when the alert has to be sent now
+func (g *Grouper) Add(a Alert, now time.Time) bool {
+	if g.window <= 0 {
+		return true
+	}
+	key := a.DedupKey()
+	g.Lock()
+	defer g.Unlock()
+	e, ok := g.entries[key]
+	if !ok || now.Sub(e.start) >= g.window {
+		g.entries[key] = &groupEntry{start: now, sent: a}
+		return true
+	}
+	e.last = a
+	e.count++
+	return false
+}
+
+// Flush returns the held alerts of the elapsed windows, with Flaps set to the
+// number of grouped transitions
+func (g *Grouper) Flush(now time.Time) []Alert {
+	var alerts []Alert
+	g.Lock()
+	defer g.Unlock()
+	for key, e := range g.entries {
+		if now.Sub(e.start) < g.window {
+			continue
+		}
+		if e.count > 0 && e.last.Resolved != e.sent.Resolved {
+			a := e.last
+			a.Flaps = e.count
+			alerts = append(alerts, a)
+		}
+		delete(g.entries, key)
+	}
+	return alerts
+}
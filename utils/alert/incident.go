@@ -0,0 +1,186 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// PagerDutyNotifier triggers and resolves incidents with the events v2 API,
+// the state dedup key is used so that a resolve closes the matching trigger
+type PagerDutyNotifier struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Component     string `json:"component,omitempty"`
+	Group         string `json:"group,omitempty"`
+	Class         string `json:"class,omitempty"`
+	CustomDetails Event  `json:"custom_details"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+func NewPagerDutyNotifier(url string, routingKey string) *PagerDutyNotifier {
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+	return &PagerDutyNotifier{url: url, routingKey: routingKey, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (n *PagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+func (n *PagerDutyNotifier) Notify(a Alert) error {
+	// Server state changes have no resolve, only the states open incidents
+	if a.Type != TypeState || a.ErrKey == "" {
+		return nil
+	}
+	body, err := json.Marshal(n.event(a.Event()))
+	if err != nil {
+		return err
+	}
+	return postJSON(n.client, n.url, nil, body)
+}
+
+func (n *PagerDutyNotifier) event(ev Event) pagerDutyEvent {
+	pe := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: "trigger",
+		DedupKey:    ev.DedupKey,
+	}
+	if ev.Action == EventResolve {
+		pe.EventAction = "resolve"
+		return pe
+	}
+	source := ev.ServerUrl
+	if source == "" {
+		source = ev.Cluster
+	}
+	pe.Payload = &pagerDutyPayload{
+		Summary:       ev.ErrKey + " " + ev.Description,
+		Source:        source,
+		Severity:      severity(ev.ErrType),
+		Component:     ev.ServerUrl,
+		Group:         ev.Cluster,
+		Class:         ev.ErrKey,
+		CustomDetails: ev,
+	}
+	return pe
+}
+
+// OpsgenieNotifier creates alerts with the state dedup key as alias and closes
+// them by alias on resolve
+type OpsgenieNotifier struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func NewOpsgenieNotifier(url string, apiKey string) *OpsgenieNotifier {
+	if url == "" {
+		url = DefaultOpsgenieURL
+	}
+	return &OpsgenieNotifier{url: strings.TrimSuffix(url, "/"), apiKey: apiKey, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (n *OpsgenieNotifier) Name() string {
+	return "opsgenie"
+}
+
+func (n *OpsgenieNotifier) Notify(a Alert) error {
+	// Server state changes have no resolve, only the states open incidents
+	if a.Type != TypeState || a.ErrKey == "" {
+		return nil
+	}
+	ev := a.Event()
+	headers := map[string]string{"Authorization": "GenieKey " + n.apiKey}
+	if ev.Action == EventResolve {
+		body, err := json.Marshal(opsgenieClose{Source: ev.Monitor, Note: "Resolved: " + ev.Description})
+		if err != nil {
+			return err
+		}
+		return postJSON(n.client, n.url+"/"+url.PathEscape(ev.DedupKey)+"/close?identifierType=alias", headers, body)
+	}
+	body, err := json.Marshal(n.alert(ev))
+	if err != nil {
+		return err
+	}
+	return postJSON(n.client, n.url, headers, body)
+}
+
+func (n *OpsgenieNotifier) alert(ev Event) opsgenieAlert {
+	priority := "P3"
+	if severity(ev.ErrType) == "error" {
+		priority = "P2"
+	}
+	return opsgenieAlert{
+		Message:     ev.ErrKey + " " + ev.Description,
+		Alias:       ev.DedupKey,
+		Description: ev.Description,
+		Source:      ev.Monitor,
+		Entity:      ev.ServerUrl,
+		Priority:    priority,
+		Tags:        []string{ev.Cluster, ev.ErrKey},
+		Details: map[string]string{
+			"cluster":   ev.Cluster,
+			"errKey":    ev.ErrKey,
+			"errType":   ev.ErrType,
+			"serverUrl": ev.ServerUrl,
+		},
+	}
+}
+
+// severity maps state ErrType to incident API severities
+func severity(errType string) string {
+	switch errType {
+	case "ERROR":
+		return "error"
+	case "WARNING":
+		return "warning"
+	}
+	return "info"
+}
@@ -0,0 +1,36 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import "github.com/signal18/replication-manager/config"
+
+// MailNotifier sends state alerts and explicit messages by SMTP, the SMTP
+// settings are read from the cluster configuration at each send
+type MailNotifier struct {
+	conf *config.Config
+}
+
+func NewMailNotifier(conf *config.Config) *MailNotifier {
+	return &MailNotifier{conf: conf}
+}
+
+func (n *MailNotifier) Name() string {
+	return "mail"
+}
+
+func (n *MailNotifier) Notify(a Alert) error {
+	switch a.Type {
+	case TypeState:
+		return a.EmailMessage("", "", *n.conf)
+	case TypeMessage:
+		return a.EmailMessage(a.Text, a.Subject, *n.conf)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/signal18/replication-manager/config"
+)
+
+// Notifier is an alert channel. Each notifier filters the alert types and
+// levels it relays and returns nil for the ones it ignores.
+type Notifier interface {
+	Name() string
+	Notify(a Alert) error
+}
+
+// Registry holds the notifiers of a cluster by name
+type Registry struct {
+	notifiers map[string]Notifier
+	sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{notifiers: make(map[string]Notifier)}
+}
+
+// NewRegistryFromConf registers every channel having its settings defined in the cluster configuration
+func NewRegistryFromConf(conf *config.Config) (*Registry, error) {
+	r := NewRegistry()
+	var errs []error
+	if conf.MailTo != "" {
+		r.Register(NewMailNotifier(conf))
+	}
+	if conf.SlackURL != "" {
+		r.Register(NewSlackNotifier(conf.SlackURL, conf.SlackChannel, conf.SlackUser))
+	}
+	if conf.PushoverAppToken != "" && conf.PushoverUserToken != "" {
+		r.Register(NewPushoverNotifier(conf.GetDecryptedValue("alert-pushover-app-token"), conf.GetDecryptedValue("alert-pushover-user-token")))
+	}
+	if conf.TeamsUrl != "" {
+		r.Register(NewTeamsNotifier(conf.TeamsUrl, conf.TeamsProxyUrl, conf.TeamsAlertState, conf.MonitorAddress))
+	}
+	if conf.AlertWebhookURL != "" {
+		n, err := NewWebhookNotifier(conf.AlertWebhookURL, conf.AlertWebhookTemplate, filepath.Join(conf.ShareDir, WebhookTemplateDir))
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			r.Register(n)
+		}
+	}
+	if conf.AlertPagerDutyRoutingKey != "" {
+		r.Register(NewPagerDutyNotifier(conf.AlertPagerDutyURL, conf.GetDecryptedValue("alert-pagerduty-routing-key")))
+	}
+	if conf.AlertOpsgenieApiKey != "" {
+		r.Register(NewOpsgenieNotifier(conf.AlertOpsgenieURL, conf.GetDecryptedValue("alert-opsgenie-api-key")))
+	}
+	return r, errors.Join(errs...)
+}
+
+// Register adds or replaces the notifier with the same name
+func (r *Registry) Register(n Notifier) {
+	r.Lock()
+	r.notifiers[n.Name()] = n
+	r.Unlock()
+}
+
+func (r *Registry) Unregister(name string) {
+	r.Lock()
+	delete(r.notifiers, name)
+	r.Unlock()
+}
+
+func (r *Registry) Get(name string) (Notifier, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	n, ok := r.notifiers[name]
+	return n, ok
+}
+
+func (r *Registry) Len() int {
+	r.RLock()
+	defer r.RUnlock()
+	return len(r.notifiers)
+}
+
+// Names returns the registered channels sorted by name
+func (r *Registry) Names() []string {
+	r.RLock()
+	names := make([]string, 0, len(r.notifiers))
+	for name := range r.notifiers {
+		names = append(names, name)
+	}
+	r.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Notify sends the alert to every registered notifier
+func (r *Registry) Notify(a Alert) error {
+	return r.NotifyChannels(a, r.Names())
+}
+
+// NotifyChannels sends the alert to the named notifiers, unknown names are reported as error
+func (r *Registry) NotifyChannels(a Alert, names []string) error {
+	var errs []error
+	for _, name := range names {
+		n, ok := r.Get(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("notifier %s not found", name))
+			continue
+		}
+		if err := n.Notify(a); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
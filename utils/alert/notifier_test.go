@@ -0,0 +1,250 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countNotifier struct {
+	name  string
+	count int
+}
+
+func (n *countNotifier) Name() string { return n.name }
+
+func (n *countNotifier) Notify(a Alert) error {
+	n.count++
+	return nil
+}
+
+func TestRegistryNotifyChannels(t *testing.T) {
+	r := NewRegistry()
+	a := &countNotifier{name: "a"}
+	b := &countNotifier{name: "b"}
+	r.Register(a)
+	r.Register(b)
+	if err := r.Notify(Alert{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.NotifyChannels(Alert{}, []string{"b", "missing"}); err == nil {
+		t.Fatal("expected error for unknown notifier")
+	}
+	if a.count != 1 || b.count != 2 {
+		t.Fatalf("unexpected counts a=%d b=%d", a.count, b.count)
+	}
+	r.Unregister("a")
+	if names := r.Names(); len(names) != 1 || names[0] != "b" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	n, err := NewWebhookNotifier(ts.URL, `{"key":{{json .DedupKey}},"action":"{{.Action}}"}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Alert{Type: TypeState, Cluster: "c1", ErrKey: "ERR00042", Host: "db1:3306", Resolved: true}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"key":"c1/ERR00042@db1:3306","action":"resolve"}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	body = ""
+	a.Type = TypeLog
+	if err := n.Notify(a); err != nil || body != "" {
+		t.Fatalf("log alert should be ignored, got %q %v", body, err)
+	}
+}
+
+func TestWebhookTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "webhook.tmpl"), []byte(`{"action":"{{.Action}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewWebhookNotifier("http://localhost", "webhook.tmpl", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := n.Body(Event{Action: "open"}); err != nil || string(body) != `{"action":"open"}` {
+		t.Fatalf("unexpected body %s %v", body, err)
+	}
+	for _, tmpl := range []string{filepath.Join(dir, "webhook.tmpl"), "../webhook.tmpl", "sub/../../etc/passwd", "missing.tmpl"} {
+		if _, err := NewWebhookNotifier("http://localhost", tmpl, dir); err == nil {
+			t.Errorf("template %s accepted", tmpl)
+		}
+	}
+}
+
+func TestPagerDutyDedup(t *testing.T) {
+	var events []pagerDutyEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		events = append(events, ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	n := NewPagerDutyNotifier(ts.URL, "rk")
+	a := Alert{Type: TypeState, Cluster: "c1", ErrKey: "ERR00027", ErrType: "ERROR", State: "master down", Host: "db1:3306"}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	a.Resolved = true
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if events[0].EventAction != "trigger" || events[1].EventAction != "resolve" {
+		t.Fatalf("unexpected actions %s %s", events[0].EventAction, events[1].EventAction)
+	}
+	if events[0].DedupKey != events[1].DedupKey || !strings.Contains(events[0].DedupKey, "ERR00027") {
+		t.Fatalf("dedup keys differ %s %s", events[0].DedupKey, events[1].DedupKey)
+	}
+	if events[0].Payload == nil || events[0].Payload.Severity != "error" {
+		t.Fatal("missing trigger payload severity")
+	}
+}
+
+func TestOpsgenieClose(t *testing.T) {
+	var path, auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	n := NewOpsgenieNotifier(ts.URL+"/v2/alerts", "key")
+	a := Alert{Type: TypeState, Cluster: "c1", ErrKey: "WARN0048", Resolved: true}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/alerts/c1/WARN0048/close" {
+		t.Fatalf("unexpected close path %s", path)
+	}
+	if auth != "GenieKey key" {
+		t.Fatalf("unexpected authorization %s", auth)
+	}
+}
+
+func TestRouteChannels(t *testing.T) {
+	routes, err := ParseRoutes("key=ERR00027,ERR00042 severity=ERROR channels=pagerduty,mail; server=db3:3306 channels=slack,mail;tag=gold channels=opsgenie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Alert{Type: TypeState, ErrKey: "ERR00042", ErrType: "ERROR", Host: "db3:3306"}
+	channels, ok := RouteChannels(routes, a, nil)
+	if !ok || strings.Join(channels, ",") != "pagerduty,mail,slack" {
+		t.Fatalf("unexpected channels %v", channels)
+	}
+	a.Host = "db1:3306"
+	a.ErrType = "WARNING"
+	if _, ok := RouteChannels(routes, a, nil); ok {
+		t.Fatal("no route should match")
+	}
+	if channels, _ := RouteChannels(routes, a, []string{"gold"}); strings.Join(channels, ",") != "opsgenie" {
+		t.Fatalf("unexpected tag channels %v", channels)
+	}
+	if _, err := ParseRoutes("key=ERR00042"); err == nil {
+		t.Fatal("expected error for route without channels")
+	}
+}
+
+func TestGrouperFlapping(t *testing.T) {
+	g := NewGrouper(time.Minute)
+	now := time.Now()
+	a := Alert{Type: TypeState, Cluster: "c1", ErrKey: "ERR00042", Host: "db1:3306"}
+	if !g.Add(a, now) {
+		t.Fatal("first transition must be sent")
+	}
+	a.Resolved = true
+	if g.Add(a, now.Add(time.Second)) {
+		t.Fatal("transition inside the window must be held")
+	}
+	a.Resolved = false
+	g.Add(a, now.Add(2*time.Second))
+	a.Resolved = true
+	g.Add(a, now.Add(3*time.Second))
+	if len(g.Flush(now.Add(30*time.Second))) != 0 {
+		t.Fatal("window is not over")
+	}
+	alerts := g.Flush(now.Add(time.Minute))
+	if len(alerts) != 1 || !alerts[0].Resolved || alerts[0].Flaps != 3 {
+		t.Fatalf("unexpected flush %+v", alerts)
+	}
+	if !g.Add(a, now.Add(time.Minute+time.Second)) {
+		t.Fatal("transition after the window must be sent")
+	}
+}
+
+func TestSilences(t *testing.T) {
+	ss := NewSilences(filepath.Join(t.TempDir(), "alertsilences.json"))
+	s, err := ss.Add(Silence{ErrKey: "ERR", ServerUrl: "db1:3306", End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ss.Matching(Alert{ErrKey: "ERR00042", Host: "db1:3306"}); !ok {
+		t.Fatal("alert should be silenced")
+	}
+	if _, ok := ss.Matching(Alert{ErrKey: "ERR00042", Host: "db2:3306"}); ok {
+		t.Fatal("other server should not be silenced")
+	}
+	loaded := NewSilences(ss.file)
+	if err := loaded.Load(); err != nil || len(loaded.List()) != 1 {
+		t.Fatalf("silences not persisted %v", err)
+	}
+	if _, err := loaded.Expire(s.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Matching(Alert{ErrKey: "ERR00042", Host: "db1:3306"}); ok {
+		t.Fatal("expired silence still active")
+	}
+	if _, err := loaded.Expire("missing"); err != ErrSilenceNotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStateText(t *testing.T) {
+	a := Alert{Type: TypeState, ErrKey: "ERR00042", ErrType: "ERROR", State: "Skip slave in election", Host: "db1:3306"}
+	if text := a.StateText(); text != "OPENED ERR00042 : Skip slave in election (db1:3306)" {
+		t.Errorf("state text %s", text)
+	}
+	if !a.IsStateError() {
+		t.Error("opened error state is not an error")
+	}
+	a.Resolved = true
+	if text := a.StateText(); !strings.HasPrefix(text, "RESOLV ") || a.IsStateError() {
+		t.Errorf("resolved state text %s", text)
+	}
+	s := Alert{Type: TypeState, Host: "db2:3306", PrevState: "Slave", State: "SlaveErr"}
+	if text := s.StateText(); text != "State of db2:3306 changed from Slave to SlaveErr" {
+		t.Errorf("server state text %s", text)
+	}
+	if err := NewPagerDutyNotifier("http://127.0.0.1:1", "rk").Notify(s); err != nil {
+		t.Errorf("server state change sent to incident API: %s", err)
+	}
+}
@@ -0,0 +1,60 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"github.com/signal18/replication-manager/utils/logrus/hooks/pushover"
+	log "github.com/sirupsen/logrus"
+)
+
+// PushoverNotifier relays alert and start log lines and the routed state
+// alerts to Pushover
+type PushoverNotifier struct {
+	logger *log.Logger
+}
+
+func NewPushoverNotifier(appToken string, userToken string) *PushoverNotifier {
+	logger := log.New()
+	logger.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	logger.AddHook(pushover.NewHook(appToken, userToken))
+	logger.SetLevel(log.WarnLevel)
+	return &PushoverNotifier{logger: logger}
+}
+
+func (n *PushoverNotifier) Name() string {
+	return "pushover"
+}
+
+func (n *PushoverNotifier) Notify(a Alert) error {
+	if a.Type == TypeState {
+		entry := n.logger.WithFields(log.Fields{"cluster": a.Cluster, "type": "state", "code": a.ErrKey, "channel": "Pushover"})
+		if a.IsStateError() {
+			entry.Error(a.StateText())
+		} else {
+			entry.Warn(a.StateText())
+		}
+		return nil
+	}
+	if a.Type != TypeLog {
+		return nil
+	}
+	fields := log.Fields{"cluster": a.Cluster, "type": "alert", "channel": "Pushover"}
+	if a.Module != "" {
+		fields["module"] = a.Module
+	}
+	switch a.Level {
+	case "ALERT":
+		n.logger.WithFields(fields).Error(a.Text)
+	case "START":
+		fields["type"] = "start"
+		n.logger.WithFields(fields).Warn(a.Text)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route selects the channels of the state alerts it matches. Empty criteria
+// match everything, values of the same criteria are alternatives.
+type Route struct {
+	Keys       []string `json:"keys"`       // ErrKey prefixes
+	Severities []string `json:"severities"` // ErrType ERROR|WARNING|INFO
+	Servers    []string `json:"servers"`    // server URL
+	Tags       []string `json:"tags"`       // cluster tags
+	Channels   []string `json:"channels"`
+}
+
+// ParseRoutes reads the alert-routes setting, routes are separated by ; and
+// made of field=value,value pairs separated by spaces:
+//
+//	key=ERR00027,ERR00042 severity=ERROR channels=pagerduty,mail; server=db3:3306 channels=slack
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	for _, def := range strings.Split(s, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		var r Route
+		for _, pair := range strings.Fields(def) {
+			field, value, ok := strings.Cut(pair, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("invalid alert route %q: expecting field=value in %q", def, pair)
+			}
+			values := strings.Split(value, ",")
+			switch field {
+			case "key":
+				r.Keys = append(r.Keys, values...)
+			case "severity":
+				r.Severities = append(r.Severities, values...)
+			case "server":
+				r.Servers = append(r.Servers, values...)
+			case "tag":
+				r.Tags = append(r.Tags, values...)
+			case "channels":
+				r.Channels = append(r.Channels, values...)
+			default:
+				return nil, fmt.Errorf("invalid alert route %q: unknown field %s", def, field)
+			}
+		}
+		if len(r.Channels) == 0 {
+			return nil, fmt.Errorf("invalid alert route %q: no channels", def)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+func (r *Route) Match(a Alert, tags []string) bool {
+	if len(r.Keys) > 0 && !matchAny(r.Keys, func(k string) bool { return strings.HasPrefix(a.ErrKey, k) }) {
+		return false
+	}
+	if len(r.Severities) > 0 && !matchAny(r.Severities, func(s string) bool { return strings.EqualFold(s, a.ErrType) }) {
+		return false
+	}
+	if len(r.Servers) > 0 && !matchAny(r.Servers, func(s string) bool { return s == a.Host }) {
+		return false
+	}
+	if len(r.Tags) > 0 && !matchAny(r.Tags, func(t string) bool {
+		return matchAny(tags, func(ct string) bool { return ct == t })
+	}) {
+		return false
+	}
+	return true
+}
+
+// RouteChannels returns the union of the channels of the matching routes,
+// ok is false when no route match and the alert goes to every channel
+func RouteChannels(routes []Route, a Alert, tags []string) (channels []string, ok bool) {
+	seen := make(map[string]bool)
+	for _, r := range routes {
+		if !r.Match(a, tags) {
+			continue
+		}
+		ok = true
+		for _, c := range r.Channels {
+			if !seen[c] {
+				seen[c] = true
+				channels = append(channels, c)
+			}
+		}
+	}
+	return channels, ok
+}
+
+func matchAny(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signal18/replication-manager/utils/misc"
+)
+
+// Silence mutes the state alerts of an ErrKey prefix and/or a server between
+// Start and End, empty criteria match everything
+type Silence struct {
+	Id        string    `json:"id"`
+	ErrKey    string    `json:"errKey"`
+	ServerUrl string    `json:"serverUrl"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (s *Silence) IsActive(now time.Time) bool {
+	return !now.Before(s.Start) && now.Before(s.End)
+}
+
+func (s *Silence) Match(a Alert, now time.Time) bool {
+	return s.IsActive(now) && strings.HasPrefix(a.ErrKey, s.ErrKey) && (s.ServerUrl == "" || s.ServerUrl == a.Host)
+}
+
+// Silences is the silence list of a cluster persisted as JSON in its working directory
+type Silences struct {
+	list []Silence
+	file string
+	sync.Mutex
+}
+
+var ErrSilenceNotFound = errors.New("silence not found")
+
+func NewSilences(file string) *Silences {
+	return &Silences{file: file}
+}
+
+// Load reads the persisted silences, a missing file is an empty list
+func (ss *Silences) Load() error {
+	content, err := os.ReadFile(ss.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ss.Lock()
+	defer ss.Unlock()
+	return json.Unmarshal(content, &ss.list)
+}
+
+func (ss *Silences) save() error {
+	content, err := json.MarshalIndent(ss.list, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ss.file, content, 0644)
+}
+
+// Add validates and stores a silence, Start default to now
+func (ss *Silences) Add(s Silence) (Silence, error) {
+	now := time.Now()
+	if s.Start.IsZero() {
+		s.Start = now
+	}
+	if s.End.IsZero() || !s.End.After(s.Start) {
+		return s, errors.New("silence end must be after start")
+	}
+	s.Id = misc.GetUUID()
+	s.CreatedAt = now
+	ss.Lock()
+	defer ss.Unlock()
+	ss.purge(now)
+	ss.list = append(ss.list, s)
+	return s, ss.save()
+}
+
+// Expire ends a silence now
+func (ss *Silences) Expire(id string) (Silence, error) {
+	now := time.Now()
+	ss.Lock()
+	defer ss.Unlock()
+	for i := range ss.list {
+		if ss.list[i].Id == id {
+			if ss.list[i].End.After(now) {
+				ss.list[i].End = now
+			}
+			return ss.list[i], ss.save()
+		}
+	}
+	return Silence{}, ErrSilenceNotFound
+}
+
+// List returns the silences sorted by start
+func (ss *Silences) List() []Silence {
+	ss.Lock()
+	list := make([]Silence, len(ss.list))
+	copy(list, ss.list)
+	ss.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	return list
+}
+
+// Matching returns the first active silence matching the alert
+func (ss *Silences) Matching(a Alert) (Silence, bool) {
+	now := time.Now()
+	ss.Lock()
+	defer ss.Unlock()
+	for _, s := range ss.list {
+		if s.Match(a, now) {
+			return s, true
+		}
+	}
+	return Silence{}, false
+}
+
+// purge drops the silences expired for more than a day
+func (ss *Silences) purge(now time.Time) {
+	list := ss.list[:0]
+	for _, s := range ss.list {
+		if now.Sub(s.End) < 24*time.Hour {
+			list = append(list, s)
+		}
+	}
+	ss.list = list
+}
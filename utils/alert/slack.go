@@ -0,0 +1,70 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"time"
+
+	"github.com/bluele/logrus_slack"
+	log "github.com/sirupsen/logrus"
+)
+
+// SlackNotifier relays warning and error log lines and the routed state
+// alerts to a Slack webhook
+type SlackNotifier struct {
+	logger *log.Logger
+}
+
+func NewSlackNotifier(url string, channel string, user string) *SlackNotifier {
+	logger := log.New()
+	logger.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	logger.AddHook(&logrus_slack.SlackHook{
+		HookURL:        url,
+		AcceptedLevels: logrus_slack.LevelThreshold(log.WarnLevel),
+		Channel:        channel,
+		IconEmoji:      ":ghost:",
+		Username:       user,
+		Timeout:        5 * time.Second, // request timeout for calling slack api
+	})
+	return &SlackNotifier{logger: logger}
+}
+
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Notify(a Alert) error {
+	if a.Type == TypeState {
+		entry := n.logger.WithFields(log.Fields{"cluster": a.Cluster, "type": "state", "code": a.ErrKey, "channel": "Slack"})
+		if a.IsStateError() {
+			entry.Error(a.StateText())
+		} else {
+			entry.Warn(a.StateText())
+		}
+		return nil
+	}
+	if a.Type != TypeLog {
+		return nil
+	}
+	fields := log.Fields{"cluster": a.Cluster, "type": "alert", "channel": "Slack"}
+	if a.Module != "" {
+		fields["module"] = a.Module
+	}
+	switch a.Level {
+	case "ERROR", "ALERT":
+		n.logger.WithFields(fields).Error(a.Text)
+	case "WARN":
+		n.logger.WithFields(fields).Warn(a.Text)
+	case "START":
+		fields["type"] = "start"
+		n.logger.WithFields(fields).Warn(a.Text)
+	}
+	return nil
+}
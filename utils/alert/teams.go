@@ -0,0 +1,108 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	teams "github.com/atc0005/go-teams-notify/v2"
+	"github.com/atc0005/go-teams-notify/v2/messagecard"
+)
+
+// TeamsNotifier relays log lines and the routed state alerts to a MS Teams
+// webhook, state log lines are filtered with the alert-teams-state code list
+type TeamsNotifier struct {
+	url        string
+	proxyUrl   string
+	alertState string
+	monitor    string
+}
+
+func NewTeamsNotifier(url string, proxyUrl string, alertState string, monitor string) *TeamsNotifier {
+	return &TeamsNotifier{url: url, proxyUrl: proxyUrl, alertState: alertState, monitor: monitor}
+}
+
+func (n *TeamsNotifier) Name() string {
+	return "teams"
+}
+
+func (n *TeamsNotifier) Notify(a Alert) error {
+	if a.Type == TypeState {
+		a.Level = "WARN"
+		if a.IsStateError() {
+			a.Level = "ALERT"
+		}
+		a.Text = a.StateText()
+		return n.send(a)
+	}
+	if a.Type != TypeLog {
+		return nil
+	}
+	switch a.Level {
+	case "ERROR", "ALERT", "WARN", "START":
+	case "STATE":
+		if !n.isAlertState(a.ErrKey) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return n.send(a)
+}
+
+func (n *TeamsNotifier) isAlertState(code string) bool {
+	if n.alertState == "" {
+		return false
+	}
+	for _, alertcode := range strings.Split(n.alertState, ",") {
+		if strings.Contains(code, alertcode) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *TeamsNotifier) send(a Alert) error {
+	mstClient := teams.NewTeamsClient()
+
+	if n.proxyUrl != "" {
+		proxyUrl, err := url.Parse(n.proxyUrl)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy URL %q: %w", n.proxyUrl, err)
+		}
+		// Create a copy of the default mstClient.HTTPClient
+		httpClient := mstClient.HTTPClient()
+		httpClient.Transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
+	}
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Replication-Manager alert. Monitor: " + n.monitor
+	switch a.Level {
+	case "ERROR":
+		msgCard.ThemeColor = "#4169e1"
+	case "ALERT":
+		msgCard.ThemeColor = "#b22222"
+	case "WARN":
+		msgCard.ThemeColor = "#112233"
+	}
+	prefix := "[" + a.Cluster + "] "
+	if a.Module != "" {
+		prefix = prefix + "[" + a.Module + "] "
+	}
+	msgCard.Text = prefix + a.Level + " - " + a.Text
+
+	if err := mstClient.Send(n.url, msgCard); err != nil {
+		return fmt.Errorf("failed to send MSTeams alert message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookNotifier posts state events to a generic HTTP endpoint. The body is
+// the JSON encoded Event unless a Go template is given, the template is
+// executed with the Event as data and can use the json function to quote values.
+type WebhookNotifier struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookTemplateDir is the directory of the share directory holding the
+// webhook template files
+const WebhookTemplateDir = "alert"
+
+// NewWebhookNotifier takes the template either as inline text, holding a
+// brace, or as the name of a file of dir. Template files can not be read out
+// of dir.
+func NewWebhookNotifier(url string, tmpl string, dir string) (*WebhookNotifier, error) {
+	n := &WebhookNotifier{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+	if tmpl == "" {
+		return n, nil
+	}
+	if !strings.Contains(tmpl, "{") {
+		if filepath.IsAbs(tmpl) || strings.Contains(tmpl, "..") {
+			return nil, fmt.Errorf("invalid webhook template %s: not a file name of %s", tmpl, dir)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, tmpl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		tmpl = string(content)
+	}
+	t, err := template.New("webhook").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	n.template = t
+	return n, nil
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(a Alert) error {
+	if a.Type != TypeState {
+		return nil
+	}
+	body, err := n.Body(a.Event())
+	if err != nil {
+		return err
+	}
+	return postJSON(n.client, n.url, nil, body)
+}
+
+// Body renders the request payload of an event
+func (n *WebhookNotifier) Body(ev Event) ([]byte, error) {
+	if n.template == nil {
+		return json.Marshal(ev)
+	}
+	var buf bytes.Buffer
+	if err := n.template.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("webhook template execution: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func postJSON(client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, string(msg))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix marks a personal access token in an Authorization Bearer header
+const Prefix = "rmpat_"
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenRevoked  = errors.New("token revoked")
+	ErrTokenExpired  = errors.New("token expired")
+)
+
+// Token is a personal access token of an API user, Scopes restrict the user
+// grants and an empty list keeps them all. Only the secret hash is stored.
+type Token struct {
+	Id        string    `json:"id"`
+	User      string    `json:"user"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Revoked   bool      `json:"revoked"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// HasScope tells if the token can use a grant
+func (t *Token) HasScope(grant string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == grant {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Token) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Store is the token list persisted as JSON in the working directory
+type Store struct {
+	list []Token
+	file string
+	sync.Mutex
+}
+
+func NewStore(file string) *Store {
+	return &Store{file: file}
+}
+
+// Load reads the persisted tokens, a missing file is an empty list
+func (ts *Store) Load() error {
+	content, err := os.ReadFile(ts.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ts.Lock()
+	defer ts.Unlock()
+	return json.Unmarshal(content, &ts.list)
+}
+
+func (ts *Store) save() error {
+	content, err := json.MarshalIndent(ts.list, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ts.file, content, 0600)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create adds a token and returns the secret, it can not be read back later.
+// A zero ttl never expires.
+func (ts *Store) Create(user string, name string, scopes []string, ttl time.Duration) (Token, string, error) {
+	id, err := randomHex(6)
+	if err != nil {
+		return Token{}, "", err
+	}
+	key, err := randomHex(24)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secret := Prefix + id + "_" + key
+	t := Token{Id: id, User: user, Name: name, Scopes: scopes, CreatedAt: time.Now(), Hash: hashSecret(secret)}
+	if ttl > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(ttl)
+	}
+	ts.Lock()
+	defer ts.Unlock()
+	ts.list = append(ts.list, t)
+	t.Hash = ""
+	return t, secret, ts.save()
+}
+
+// Revoke disables a token of a user, an empty user revokes any token
+func (ts *Store) Revoke(id string, user string) (Token, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	for i := range ts.list {
+		if ts.list[i].Id == id && (user == "" || ts.list[i].User == user) {
+			ts.list[i].Revoked = true
+			t := ts.list[i]
+			t.Hash = ""
+			return t, ts.save()
+		}
+	}
+	return Token{}, ErrTokenNotFound
+}
+
+// List returns the tokens of a user sorted by creation, an empty user lists all
+func (ts *Store) List(user string) []Token {
+	ts.Lock()
+	list := make([]Token, 0)
+	for _, t := range ts.list {
+		if user == "" || t.User == user {
+			t.Hash = ""
+			list = append(list, t)
+		}
+	}
+	ts.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Validate returns the token of a secret when it is neither revoked nor expired
+func (ts *Store) Validate(secret string) (Token, error) {
+	parts := strings.Split(strings.TrimPrefix(secret, Prefix), "_")
+	if !strings.HasPrefix(secret, Prefix) || len(parts) != 2 {
+		return Token{}, ErrTokenNotFound
+	}
+	hash := hashSecret(secret)
+	now := time.Now()
+	ts.Lock()
+	defer ts.Unlock()
+	for i := range ts.list {
+		t := &ts.list[i]
+		if t.Id != parts[0] || subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if t.Revoked {
+			return Token{}, ErrTokenRevoked
+		}
+		if t.IsExpired(now) {
+			return Token{}, ErrTokenExpired
+		}
+		t.LastUsed = now
+		res := *t
+		res.Hash = ""
+		return res, nil
+	}
+	return Token{}, ErrTokenNotFound
+}
+
+// IsToken tells if a bearer is a personal access token rather than a JWT
+func IsToken(bearer string) bool {
+	return strings.HasPrefix(bearer, Prefix)
+}
@@ -0,0 +1,55 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package apitoken
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	file := t.TempDir() + "/apitokens.json"
+	ts := NewStore(file)
+	tok, secret, err := ts.Create("backup", "nightly", []string{"db-backup"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsToken(secret) || tok.Hash != "" {
+		t.Fatalf("unexpected token %v %s", tok, secret)
+	}
+	reload := NewStore(file)
+	if err := reload.Load(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := reload.Validate(secret)
+	if err != nil || v.User != "backup" || !v.HasScope("db-backup") || v.HasScope("cluster-failover") {
+		t.Fatalf("validate failed %v %s", v, err)
+	}
+	if _, err := reload.Validate(secret + "x"); err != ErrTokenNotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := reload.Revoke(tok.Id, "other"); err != ErrTokenNotFound {
+		t.Errorf("revoked the token of another user: %v", err)
+	}
+	if _, err := reload.Revoke(tok.Id, "backup"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reload.Validate(secret); err != ErrTokenRevoked {
+		t.Errorf("expected revoked, got %v", err)
+	}
+	_, expired, _ := reload.Create("backup", "old", nil, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := reload.Validate(expired); err != ErrTokenExpired {
+		t.Errorf("expected expired, got %v", err)
+	}
+	if len(reload.List("backup")) != 2 || len(reload.List("other")) != 0 {
+		t.Errorf("unexpected list %v", reload.List(""))
+	}
+}
@@ -0,0 +1,196 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProtocolREST = "rest"
+	ProtocolGRPC = "grpc"
+
+	// ProtocolRemediation records the replication error remediations, Method
+	// is the action and Endpoint the replica
+	ProtocolRemediation = "remediation"
+
+	ResultOk    = "ok"
+	ResultError = "error"
+
+	Redacted = "********"
+)
+
+// secretWords are the parameter names whose value is never written
+var secretWords = []string{"password", "passwd", "pwd", "secret", "token", "credential", "key"}
+
+// Record is an audit entry of a mutating API call, Method is the HTTP verb,
+// the gRPC method or the remediation action and Status the HTTP or gRPC status
+// code
+type Record struct {
+	Time       time.Time         `json:"time"`
+	User       string            `json:"user"`
+	AuthMethod string            `json:"authMethod"`
+	SourceIP   string            `json:"sourceIp"`
+	Cluster    string            `json:"cluster"`
+	Protocol   string            `json:"protocol"`
+	Method     string            `json:"method"`
+	Endpoint   string            `json:"endpoint"`
+	Params     map[string]string `json:"params"`
+	Status     int               `json:"status"`
+	Result     string            `json:"result"`
+	Error      string            `json:"error,omitempty"`
+	Duration   int64             `json:"durationMs"`
+}
+
+// Filter selects records, Endpoint is a substring and zero values match everything
+type Filter struct {
+	From     time.Time
+	To       time.Time
+	User     string
+	Cluster  string
+	Endpoint string
+	Result   string
+	Limit    int
+}
+
+// Log is an append only JSONL file of the audit records, each write is synced
+type Log struct {
+	file string
+	sync.Mutex
+}
+
+func NewLog(file string) *Log {
+	return &Log{file: file}
+}
+
+func (l *Log) Append(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	l.Lock()
+	defer l.Unlock()
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Query returns the matching records in log order, with Limit the most recent
+// ones. The match function can hide records the caller is not allowed to see.
+func (l *Log) Query(filter Filter, match func(rec *Record) bool) ([]Record, error) {
+	res := make([]Record, 0)
+	l.Lock()
+	defer l.Unlock()
+	f, err := os.Open(l.file)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		if !filter.From.IsZero() && rec.Time.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && rec.Time.After(filter.To) {
+			break
+		}
+		if (filter.User != "" && rec.User != filter.User) ||
+			(filter.Cluster != "" && rec.Cluster != filter.Cluster) ||
+			(filter.Result != "" && rec.Result != filter.Result) ||
+			!strings.Contains(rec.Endpoint, filter.Endpoint) {
+			continue
+		}
+		if match != nil && !match(&rec) {
+			continue
+		}
+		res = append(res, rec)
+	}
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[len(res)-filter.Limit:]
+	}
+	return res, scanner.Err()
+}
+
+// IsSecret tells if a parameter name may carry a secret
+func IsSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactParams hides the secret parameters, a settingValue is hidden when its
+// settingName is a secret
+func RedactParams(params map[string]string) map[string]string {
+	res := make(map[string]string, len(params))
+	for k, v := range params {
+		if IsSecret(k) || (k == "settingValue" && IsSecret(params["settingName"])) {
+			v = Redacted
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// RedactBody returns a JSON body with the secret fields hidden, as is the value
+// of a setting whose name is a secret, other bodies are only described by their size
+func RedactBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "<" + strconv.Itoa(len(body)) + " bytes>"
+	}
+	res, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return ""
+	}
+	return string(res)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		name, _ := t["name"].(string)
+		for k, value := range t {
+			if IsSecret(k) || (k == "value" && IsSecret(name)) {
+				t[k] = Redacted
+			} else {
+				t[k] = redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+	}
+	return v
+}
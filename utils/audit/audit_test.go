@@ -0,0 +1,81 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogQuery(t *testing.T) {
+	l := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	l.Append(Record{Time: start, User: "admin", Cluster: "c1", Endpoint: "/api/clusters/c1/actions/switchover", Result: ResultOk})
+	l.Append(Record{Time: start.Add(time.Minute), User: "dba", Cluster: "c2", Endpoint: "/api/clusters/c2/settings/actions/set/verbose/true", Result: ResultError})
+	l.Append(Record{Time: start.Add(2 * time.Minute), User: "admin", Cluster: "c2", Endpoint: "/api/clusters/c2/actions/failover", Result: ResultOk})
+
+	all, err := l.Query(Filter{}, nil)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 records, got %d %v", len(all), err)
+	}
+	if res, _ := l.Query(Filter{User: "admin"}, nil); len(res) != 2 {
+		t.Errorf("expected 2 records of admin, got %d", len(res))
+	}
+	if res, _ := l.Query(Filter{Cluster: "c2", Result: ResultOk}, nil); len(res) != 1 || res[0].User != "admin" {
+		t.Errorf("expected the failover of c2, got %v", res)
+	}
+	if res, _ := l.Query(Filter{From: start.Add(30 * time.Second), To: start.Add(90 * time.Second)}, nil); len(res) != 1 || res[0].User != "dba" {
+		t.Errorf("expected the dba record in the time range, got %v", res)
+	}
+	if res, _ := l.Query(Filter{Limit: 1}, nil); len(res) != 1 || !strings.HasSuffix(res[0].Endpoint, "failover") {
+		t.Errorf("expected the last record with limit, got %v", res)
+	}
+	if res, _ := l.Query(Filter{}, func(rec *Record) bool { return rec.Cluster == "c1" }); len(res) != 1 {
+		t.Errorf("expected the match function to keep 1 record, got %d", len(res))
+	}
+}
+
+func TestRedact(t *testing.T) {
+	params := RedactParams(map[string]string{"clusterName": "c1", "settingName": "db-servers-credential", "settingValue": "root:secret"})
+	if params["settingValue"] != Redacted || params["clusterName"] != "c1" {
+		t.Errorf("secret setting value not redacted: %v", params)
+	}
+	params = RedactParams(map[string]string{"settingName": "verbose", "settingValue": "true", "vault-token": "s.xxx"})
+	if params["settingValue"] != "true" || params["vault-token"] != Redacted {
+		t.Errorf("unexpected redaction: %v", params)
+	}
+	body := RedactBody([]byte(`{"username":"bob","password":"secret","grants":["db"],"nested":{"apiKey":"k"}}`))
+	if strings.Contains(body, "secret") || strings.Contains(body, `"k"`) || !strings.Contains(body, "bob") {
+		t.Errorf("body not redacted: %s", body)
+	}
+	if body := RedactBody([]byte("not json")); body != "<8 bytes>" {
+		t.Errorf("unexpected raw body description: %s", body)
+	}
+}
+
+func TestRedactSettingBody(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := NewLog(file)
+	body := RedactBody([]byte(`{"cluster":{"name":"c1"},"action":"SET","setting":{"name":"DB_SERVERS_CREDENTIAL","value":"root:secret"}}`))
+	l.Append(Record{Cluster: "c1", Endpoint: "/signal18.replication_manager.v3.ClusterService/SetActionForClusterSettings", Params: map[string]string{"request": body}})
+	body = RedactBody([]byte(`{"cluster":{"name":"c1"},"action":"SET","setting":{"name":"FAILOVER_LIMIT","value":"3"}}`))
+	l.Append(Record{Cluster: "c1", Endpoint: "/signal18.replication_manager.v3.ClusterService/SetActionForClusterSettings", Params: map[string]string{"request": body}})
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "root:secret") {
+		t.Errorf("credential setting written to the audit log: %s", content)
+	}
+	if !strings.Contains(string(content), `\"value\":\"3\"`) || !strings.Contains(string(content), `\"name\":\"c1\"`) {
+		t.Errorf("setting not kept in the audit log: %s", content)
+	}
+}
@@ -24,3 +24,21 @@ func TestEncryptDecrypt(t *testing.T) {
 		t.Fatalf("Decrypted password %s differs from initial password", p.PlainText)
 	}
 }
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("repman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPasswordHash(hash) || IsPasswordHash("repman") {
+		t.Fatalf("Hash detection failed for %s", hash)
+	}
+	for i := 0; i < 2; i++ {
+		if !CheckPassword(hash, "repman") {
+			t.Fatal("Hashed password check failed")
+		}
+	}
+	if CheckPassword(hash, "other") || CheckPassword("repman", "other") || !CheckPassword("repman", "repman") {
+		t.Fatal("Password check accepted a wrong password")
+	}
+}
@@ -0,0 +1,49 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verified caches the bcrypt checks as the JWT token carries the password
+// checked again on each API call
+var verified sync.Map
+
+// HashPassword returns a salted bcrypt hash to store in api-credentials
+func HashPassword(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// IsPasswordHash tells if a stored password is a bcrypt hash
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword compares a password to a stored bcrypt hash or clear text password
+func CheckPassword(stored string, plain string) bool {
+	if !IsPasswordHash(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(plain)) == 1
+	}
+	sum := sha256.Sum256([]byte(stored + "\x00" + plain))
+	key := hex.EncodeToString(sum[:])
+	if _, ok := verified.Load(key); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(plain)) != nil {
+		return false
+	}
+	verified.Store(key, true)
+	return true
+}
@@ -0,0 +1,391 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Backup files are encrypted by chunks of StreamChunkSize bytes with
+// AES-256-GCM. The header carries the data key wrapped by the master key so a
+// file can be decrypted with the master key only:
+//
+//	magic | wrapped key length (uint16) | wrapped key | nonce prefix (4 bytes)
+//	chunk length (uint32) | sealed chunk ... sealed last chunk
+//
+// The nonce of a chunk is the prefix followed by the chunk counter, the last
+// chunk is sealed with a distinct additional data so a truncated file fails.
+const (
+	StreamAlgo      = "aes-256-gcm"
+	StreamChunkSize = 64 * 1024
+	streamMagic     = "RMBKENC1"
+	// DataKeyEnv passes a data key encoded by EncodeDataKey to the tools
+	// encrypting through the backup-encrypt command
+	DataKeyEnv = "REPLICATION_MANAGER_BACKUP_DATA_KEY"
+)
+
+var (
+	ErrStreamTruncated = errors.New("Encrypted stream is truncated")
+	ErrStreamHeader    = errors.New("Not an encrypted stream")
+)
+
+// DataKey is the key of an encrypted backup with its wrapped form stored in
+// the file header and the backup metadata
+type DataKey struct {
+	Key     []byte
+	Wrapped string
+}
+
+// NewDataKey generates a data key wrapped by the master key
+func NewDataKey(master []byte) (*DataKey, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := WrapKey(master, key)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{Key: key, Wrapped: wrapped}, nil
+}
+
+// DeriveKey derives a 32 bytes key for one usage from a secret with HKDF-SHA256
+// so the secret is never used as is by two different usages
+func DeriveKey(secret []byte, info string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeDataKey returns the data key and its wrapped form as one string
+func EncodeDataKey(key *DataKey) string {
+	return hex.EncodeToString(key.Key) + ":" + key.Wrapped
+}
+
+// DecodeDataKey returns the data key encoded by EncodeDataKey
+func DecodeDataKey(value string) (*DataKey, error) {
+	clear, wrapped, found := strings.Cut(value, ":")
+	if !found {
+		return nil, errors.New("Data key is not encoded")
+	}
+	key, err := hex.DecodeString(clear)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{Key: key, Wrapped: wrapped}, nil
+}
+
+// WrapKey encrypts a data key with the master key and returns it hex encoded
+func WrapKey(master []byte, key []byte) (string, error) {
+	gcm, err := newGCM(master)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, key, []byte(streamMagic))), nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey
+func UnwrapKey(master []byte, wrapped string) ([]byte, error) {
+	gcm, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := hex.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("Wrapped key is too short")
+	}
+	key, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(streamMagic))
+	if err != nil {
+		return nil, errors.New("Wrapped key does not match the master key")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type streamWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer encrypting to w with the data key, Close
+// must be called to write the last chunk and does not close w
+func NewEncryptWriter(w io.Writer, key *DataKey) (io.WriteCloser, error) {
+	gcm, err := newGCM(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := hex.DecodeString(key.Wrapped)
+	if err != nil {
+		return nil, err
+	}
+	sw := &streamWriter{w: w, gcm: gcm, prefix: make([]byte, 4), buf: make([]byte, 0, StreamChunkSize)}
+	if _, err := rand.Read(sw.prefix); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(streamMagic)+2+len(wrapped)+len(sw.prefix))
+	header = append(header, streamMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	header = append(header, sw.prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("Write on closed encrypted stream")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is only sealed when more data follows, the last one is sealed by Close
+		if len(sw.buf) == StreamChunkSize {
+			if err := sw.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		c := copy(sw.buf[len(sw.buf):StreamChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+c]
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	return sw.seal(true)
+}
+
+func (sw *streamWriter) seal(last bool) error {
+	sealed := sw.gcm.Seal(nil, streamNonce(sw.prefix, sw.counter), sw.buf, streamAad(last))
+	sw.counter++
+	sw.buf = sw.buf[:0]
+	length := binary.BigEndian.AppendUint32(make([]byte, 0, 4), uint32(len(sealed)))
+	if _, err := sw.w.Write(length); err != nil {
+		return err
+	}
+	_, err := sw.w.Write(sealed)
+	return err
+}
+
+func streamNonce(prefix []byte, counter uint64) []byte {
+	return binary.BigEndian.AppendUint64(append(make([]byte, 0, 12), prefix...), counter)
+}
+
+func streamAad(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type streamReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	last    bool
+}
+
+// NewDecryptReader returns a reader decrypting a stream written by
+// NewEncryptWriter, the data key is unwrapped from the header with the master key
+func NewDecryptReader(r io.Reader, master []byte) (io.Reader, error) {
+	magic := make([]byte, len(streamMagic)+2)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic[:len(streamMagic)]) != streamMagic {
+		return nil, ErrStreamHeader
+	}
+	wrapped := make([]byte, binary.BigEndian.Uint16(magic[len(streamMagic):]))
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return nil, ErrStreamHeader
+	}
+	key, err := UnwrapKey(master, hex.EncodeToString(wrapped))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sr := &streamReader{r: r, gcm: gcm, prefix: make([]byte, 4)}
+	if _, err := io.ReadFull(r, sr.prefix); err != nil {
+		return nil, ErrStreamHeader
+	}
+	return sr, nil
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.last {
+			return 0, io.EOF
+		}
+		if err := sr.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+func (sr *streamReader) open() error {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(sr.r, length); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrStreamTruncated
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length)
+	if size > StreamChunkSize+uint32(sr.gcm.Overhead()) {
+		return errors.New("Encrypted chunk is too large")
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrStreamTruncated
+		}
+		return err
+	}
+	nonce := streamNonce(sr.prefix, sr.counter)
+	plain, err := sr.gcm.Open(nil, nonce, sealed, streamAad(false))
+	if err != nil {
+		plain, err = sr.gcm.Open(nil, nonce, sealed, streamAad(true))
+		if err != nil {
+			return errors.New("Encrypted chunk authentication failed")
+		}
+		sr.last = true
+	}
+	sr.counter++
+	sr.buf = plain
+	return nil
+}
+
+// IsEncryptedStream tells if the buffered stream starts with an encryption header
+func IsEncryptedStream(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(streamMagic))
+	return err == nil && bytes.Equal(magic, []byte(streamMagic))
+}
+
+// IsEncryptedFile tells if a file starts with an encryption header
+func IsEncryptedFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	return IsEncryptedStream(bufio.NewReaderSize(file, 16))
+}
+
+// EncryptFile encrypts a file in place
+func EncryptFile(path string, key *DataKey) error {
+	return rewriteFile(path, func(in io.Reader, out io.Writer) error {
+		return EncryptStream(in, out, key)
+	})
+}
+
+// EncryptStream encrypts in to out with the data key
+func EncryptStream(in io.Reader, out io.Writer, key *DataKey) error {
+	ew, err := NewEncryptWriter(out, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, in); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// DecryptFile decrypts an encrypted file to dest
+func DecryptFile(path string, dest string, master []byte) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dr, err := NewDecryptReader(bufio.NewReader(in), master)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, dr); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+func rewriteFile(path string, transform func(in io.Reader, out io.Writer) error) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(out, StreamChunkSize)
+	err = transform(in, bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,136 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptStream(t *testing.T) {
+	master, err := Keygen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewDataKey(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 10, StreamChunkSize, 3*StreamChunkSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var out bytes.Buffer
+		ew, err := NewEncryptWriter(&out, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ew.Write(plain[:size/2])
+		ew.Write(plain[size/2:])
+		if err := ew.Close(); err != nil {
+			t.Fatal(err)
+		}
+		sealed := out.Bytes()
+		if !IsEncryptedStream(bufio.NewReader(bytes.NewReader(sealed))) {
+			t.Fatalf("size %d: header not detected", size)
+		}
+
+		dr, err := NewDecryptReader(bytes.NewReader(sealed), master)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted data differs", size)
+		}
+
+		dr, _ = NewDecryptReader(bytes.NewReader(sealed[:len(sealed)-20]), master)
+		if _, err := io.ReadAll(dr); err == nil {
+			t.Errorf("size %d: truncated stream not detected", size)
+		}
+	}
+
+	other, _ := Keygen()
+	var out bytes.Buffer
+	ew, _ := NewEncryptWriter(&out, key)
+	ew.Close()
+	if _, err := NewDecryptReader(bytes.NewReader(out.Bytes()), other); err == nil {
+		t.Error("stream decrypted with another master key")
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	master, _ := Keygen()
+	key, _ := NewDataKey(master)
+	path := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(path, []byte("binlog content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile(path, key); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedFile(path) {
+		t.Fatal("file not encrypted")
+	}
+	if err := DecryptFile(path, path+".plain", master); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path + ".plain")
+	if string(content) != "binlog content" {
+		t.Errorf("decrypted file differs: %s", content)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	secret, err := Keygen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DeriveKey(secret, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 || bytes.Equal(key, secret) {
+		t.Fatalf("derived key must be 32 bytes and differ from the secret")
+	}
+	if again, _ := DeriveKey(secret, "backup"); !bytes.Equal(key, again) {
+		t.Errorf("derived key is not stable")
+	}
+	if other, _ := DeriveKey(secret, "other"); bytes.Equal(key, other) {
+		t.Errorf("derived keys of two usages are equal")
+	}
+}
+
+func TestEncodeDataKey(t *testing.T) {
+	master, err := Keygen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewDataKey(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeDataKey(EncodeDataKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Key, key.Key) || decoded.Wrapped != key.Wrapped {
+		t.Fatalf("decoded data key differs")
+	}
+	if _, err := DecodeDataKey(""); err == nil {
+		t.Errorf("empty data key decoded")
+	}
+}
@@ -1992,6 +1992,78 @@ func GetSchemasMap(db *sqlx.DB) (map[string]string, string, error) {
 	return schemas, query, nil
 }
 
+// GetTableRowCounts returns the exact row count of the user tables by
+// schema.table, counted in one repeatable read snapshot
+func GetTableRowCounts(db *sqlx.DB) (map[string]int64, string, error) {
+	query := "SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_TYPE='BASE TABLE' AND TABLE_SCHEMA NOT IN('information_schema','mysql','performance_schema','sys','replication_manager_schema') AND TABLE_SCHEMA NOT LIKE '#%'"
+	logs := query
+	tx, err := db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, logs, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Queryx(query)
+	if err != nil {
+		return nil, logs, errors.New("Could not get tables : " + err.Error())
+	}
+	var names [][2]string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			rows.Close()
+			return nil, logs, err
+		}
+		names = append(names, [2]string{schema, table})
+	}
+	rows.Close()
+	tables := make(map[string]int64)
+	for _, name := range names {
+		query := "SELECT COUNT(*) FROM " + quoteIdentifier(name[0]) + "." + quoteIdentifier(name[1])
+		logs += "\n" + query
+		var count int64
+		if err := tx.QueryRowx(query).Scan(&count); err != nil {
+			return tables, logs, fmt.Errorf("Could not count rows of %s.%s: %s", name[0], name[1], err)
+		}
+		tables[name[0]+"."+name[1]] = count
+	}
+	return tables, logs, nil
+}
+
+// GetSchemasWithObjects returns the schemas among schemas holding views,
+// triggers, routines or events
+func GetSchemasWithObjects(db *sqlx.DB, schemas []string) ([]string, string, error) {
+	names := make([]string, 0)
+	if len(schemas) == 0 {
+		return names, "", nil
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(schemas)), ",")
+	query := "SELECT TABLE_SCHEMA FROM information_schema.VIEWS WHERE TABLE_SCHEMA IN (" + in + ")" +
+		" UNION SELECT TRIGGER_SCHEMA FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA IN (" + in + ")" +
+		" UNION SELECT ROUTINE_SCHEMA FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA IN (" + in + ")" +
+		" UNION SELECT EVENT_SCHEMA FROM information_schema.EVENTS WHERE EVENT_SCHEMA IN (" + in + ")"
+	args := make([]interface{}, 0, 4*len(schemas))
+	for i := 0; i < 4; i++ {
+		for _, schema := range schemas {
+			args = append(args, schema)
+		}
+	}
+	err := db.Select(&names, query, args...)
+	return names, query, err
+}
+
+// GetSchemaTables returns the tables and views of a schema
+func GetSchemaTables(db *sqlx.DB, schema string) ([]string, string, error) {
+	names := make([]string, 0)
+	query := "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?"
+	err := db.Select(&names, query, schema)
+	return names, query, err
+}
+
+// quoteIdentifier returns a schema or table name quoted for SQL statements
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
 func GetVariableByName(db *sqlx.DB, name string, myver *version.Version) (string, string, error) {
 	var value string
 	source := GetVariableSource(db, myver)
@@ -12,6 +12,7 @@ package gtid
 import (
 	"fmt"
 	"hash/crc64"
+	"math"
 	"regexp"
 	"sort"
 	"strconv"
@@ -221,3 +222,62 @@ func (gl List) Equal(glcomp *List) bool {
 	}
 	return false
 }
+
+// Contains tells if the MariaDB GTID list reached the sequence of each domain
+// of glcomp, whatever the server of the GTID
+func (gl List) Contains(glcomp *List) bool {
+	for _, c := range *glcomp {
+		reached := false
+		for _, g := range gl {
+			if g.DomainID == c.DomainID && g.SeqNo >= c.SeqNo {
+				reached = true
+				break
+			}
+		}
+		if !reached {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpandMySQLSet returns the GTIDs of a MySQL GTID set, uuid:1-3:5 gives
+// uuid:1, uuid:2, uuid:3 and uuid:5. Tags of MySQL 8.4 are kept, a set of
+// more than max GTIDs is refused.
+func ExpandMySQLSet(s string, max int) ([]string, error) {
+	var gtids []string
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return gtids, nil
+	}
+	for _, uuidSet := range strings.Split(s, ",") {
+		parts := strings.Split(uuidSet, ":")
+		prefix := parts[0]
+		for _, part := range parts[1:] {
+			bounds := strings.SplitN(part, "-", 2)
+			start, err := strconv.ParseUint(bounds[0], 10, 64)
+			if err != nil {
+				// A tag applies to the intervals following it
+				prefix = parts[0] + ":" + part
+				continue
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseUint(bounds[1], 10, 64); err != nil || end < start {
+					return nil, fmt.Errorf("Invalid GTID interval %s", part)
+				}
+			}
+			// GTID numbers go from 1 to 2^63-1, the bounds keep seq from wrapping
+			if start == 0 || end >= math.MaxInt64 {
+				return nil, fmt.Errorf("Invalid GTID interval %s", part)
+			}
+			if end-start >= uint64(max-len(gtids)) {
+				return nil, fmt.Errorf("GTID set %s has more than %d transactions", s, max)
+			}
+			for seq := start; seq <= end; seq++ {
+				gtids = append(gtids, prefix+":"+strconv.FormatUint(seq, 10))
+			}
+		}
+	}
+	return gtids, nil
+}
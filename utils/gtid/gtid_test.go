@@ -44,3 +44,57 @@ func TestEqualGtid(t *testing.T) {
 	re := list1.Equal(list2)
 	t.Log("Comparison returned ", re)
 }
+
+func TestExpandMySQLSet(t *testing.T) {
+	gtids, err := ExpandMySQLSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3:5,\n8c4a0c1e-71ca-11e1-9e33-c80aa9429562:orders:7", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:2",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:3",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:5",
+		"8c4a0c1e-71ca-11e1-9e33-c80aa9429562:orders:7",
+	}
+	if len(gtids) != len(expected) {
+		t.Fatalf("Expanded %v", gtids)
+	}
+	for i := range expected {
+		if gtids[i] != expected[i] {
+			t.Errorf("GTID %d is %s, expected %s", i, gtids[i], expected[i])
+		}
+	}
+	if gtids, err := ExpandMySQLSet("", 10); err != nil || len(gtids) != 0 {
+		t.Errorf("Empty set expanded to %v, %v", gtids, err)
+	}
+	if _, err := ExpandMySQLSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100", 10); err == nil {
+		t.Error("Set over the maximum expanded")
+	}
+	for _, set := range []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:0-3",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:18446744073709551615",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-18446744073709551615",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:9223372036854775800-9223372036854775807",
+	} {
+		if _, err := ExpandMySQLSet(set, 10); err == nil {
+			t.Errorf("Invalid set %s expanded", set)
+		}
+	}
+}
+
+func TestContainsGtid(t *testing.T) {
+	list := NewList("0-1-100,1-2-50")
+	if !list.Contains(NewList("0-3-100,1-2-49")) {
+		t.Error("0-1-100,1-2-50 should contain 0-3-100,1-2-49")
+	}
+	if list.Contains(NewList("0-1-101")) {
+		t.Error("0-1-100,1-2-50 should not contain 0-1-101")
+	}
+	if list.Contains(NewList("2-1-1")) {
+		t.Error("0-1-100,1-2-50 should not contain domain 2")
+	}
+	if !list.Contains(NewList("")) {
+		t.Error("Any list should contain an empty list")
+	}
+}
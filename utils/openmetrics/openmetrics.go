@@ -0,0 +1,204 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+// Package openmetrics writes typed and labeled metric families in the
+// OpenMetrics text format or in the Prometheus 0.0.4 text format
+package openmetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeGauge     = "gauge"
+	TypeCounter   = "counter"
+	TypeHistogram = "histogram"
+
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+func L(name string, value string) Label {
+	return Label{Name: name, Value: value}
+}
+
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named metric family, all its samples are written together
+type Family struct {
+	Name    string
+	Type    string
+	Help    string
+	Samples []Sample
+}
+
+// Add appends a sample, counters get the _total suffix
+func (f *Family) Add(value float64, labels ...Label) {
+	suffix := ""
+	if f.Type == TypeCounter {
+		suffix = "_total"
+	}
+	f.Samples = append(f.Samples, Sample{Suffix: suffix, Labels: labels, Value: value})
+}
+
+// AddHistogram appends the buckets, count and sum of a histogram
+func (f *Family) AddHistogram(h *Histogram, labels ...Label) {
+	buckets, count, sum := h.Snapshot()
+	var cumul uint64
+	for i, le := range h.Bounds {
+		cumul += buckets[i]
+		f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: append(append([]Label{}, labels...), L("le", formatFloat(le))), Value: float64(cumul)})
+	}
+	f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: append(append([]Label{}, labels...), L("le", "+Inf")), Value: float64(count)})
+	f.Samples = append(f.Samples, Sample{Suffix: "_count", Labels: labels, Value: float64(count)})
+	f.Samples = append(f.Samples, Sample{Suffix: "_sum", Labels: labels, Value: sum})
+}
+
+// Exposition groups the families of a scrape in creation order
+type Exposition struct {
+	families []*Family
+	index    map[string]*Family
+}
+
+func NewExposition() *Exposition {
+	return &Exposition{index: make(map[string]*Family)}
+}
+
+// Family returns the family of a name and creates it on first use
+func (e *Exposition) Family(name string, typ string, help string) *Family {
+	name = SanitizeName(name)
+	if f, ok := e.index[name]; ok {
+		return f
+	}
+	f := &Family{Name: name, Type: typ, Help: help}
+	e.index[name] = f
+	e.families = append(e.families, f)
+	return f
+}
+
+// Write outputs the families with samples, openMetrics selects the
+// OpenMetrics format ending with # EOF
+func (e *Exposition) Write(w io.Writer, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range e.families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		name := f.Name
+		if !openMetrics && f.Type == TypeCounter {
+			name += "_total"
+		}
+		bw.WriteString("# HELP " + name + " " + escapeHelp(f.Help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + f.Type + "\n")
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(SanitizeName(l.Name) + "=\"" + escapeValue(l.Value) + "\"")
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatFloat(s.Value) + "\n")
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// Histogram counts observations in fixed buckets, it is safe for concurrent use
+type Histogram struct {
+	Bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+	sync.Mutex
+}
+
+func NewHistogram(bounds ...float64) *Histogram {
+	sort.Float64s(bounds)
+	return &Histogram{Bounds: bounds, buckets: make([]uint64, len(bounds))}
+}
+
+// Observe counts a value, a nil histogram ignores it
+func (h *Histogram) Observe(v float64) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.count++
+	h.sum += v
+	for i, le := range h.Bounds {
+		if v <= le {
+			h.buckets[i]++
+			return
+		}
+	}
+}
+
+// Snapshot returns the per bucket counts, the total count and the sum
+func (h *Histogram) Snapshot() ([]uint64, uint64, float64) {
+	h.Lock()
+	defer h.Unlock()
+	return append([]uint64{}, h.buckets...), h.count, h.sum
+}
+
+// SanitizeName replaces the characters not allowed in metric and label names
+func SanitizeName(name string) string {
+	var sb strings.Builder
+	for i, c := range name {
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func escapeValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
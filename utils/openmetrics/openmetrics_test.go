@@ -0,0 +1,63 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package openmetrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	e := NewExposition()
+	up := e.Family("replication_manager_server_up", TypeGauge, "Server is up")
+	up.Add(1, L("cluster", "c1"), L("server", "db1:3306"))
+	e.Family("replication_manager_failover", TypeCounter, "Failovers").Add(2, L("cluster", "c1"))
+	e.Family("replication_manager_server_up", TypeGauge, "").Add(0, L("cluster", "c1"), L("server", `db"2`))
+	e.Family("empty", TypeGauge, "No sample")
+	h := NewHistogram(1, 5)
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(10)
+	e.Family("replication_manager_delay_seconds", TypeHistogram, "Delay").AddHistogram(h, L("cluster", "c1"))
+
+	var b bytes.Buffer
+	e.Write(&b, true)
+	expected := `# HELP replication_manager_server_up Server is up
+# TYPE replication_manager_server_up gauge
+replication_manager_server_up{cluster="c1",server="db1:3306"} 1
+replication_manager_server_up{cluster="c1",server="db\"2"} 0
+# HELP replication_manager_failover Failovers
+# TYPE replication_manager_failover counter
+replication_manager_failover_total{cluster="c1"} 2
+# HELP replication_manager_delay_seconds Delay
+# TYPE replication_manager_delay_seconds histogram
+replication_manager_delay_seconds_bucket{cluster="c1",le="1"} 1
+replication_manager_delay_seconds_bucket{cluster="c1",le="5"} 2
+replication_manager_delay_seconds_bucket{cluster="c1",le="+Inf"} 3
+replication_manager_delay_seconds_count{cluster="c1"} 3
+replication_manager_delay_seconds_sum{cluster="c1"} 13.5
+# EOF
+`
+	if b.String() != expected {
+		t.Errorf("unexpected exposition:\n%s", b.String())
+	}
+
+	b.Reset()
+	e.Write(&b, false)
+	if bytes.Contains(b.Bytes(), []byte("# EOF")) || !bytes.Contains(b.Bytes(), []byte("# TYPE replication_manager_failover_total counter")) {
+		t.Errorf("unexpected text format:\n%s", b.String())
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	if n := SanitizeName("mysql_global_status_com-select.x"); n != "mysql_global_status_com_select_x" {
+		t.Errorf("unexpected name %s", n)
+	}
+	if n := SanitizeName("1abc"); n != "_abc" {
+		t.Errorf("unexpected name %s", n)
+	}
+}
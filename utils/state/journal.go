@@ -0,0 +1,225 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TransitionOpen    = "open"
+	TransitionResolve = "resolve"
+)
+
+// Time between two prunings of the transitions over the retention
+const journalPruneInterval = time.Hour
+
+// Transition is a journal record of a state opening or resolving, Duration in
+// seconds is set on resolve when the opening was journaled
+type Transition struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	ErrKey    string    `json:"errKey"`
+	ErrType   string    `json:"errType"`
+	ErrDesc   string    `json:"errDesc"`
+	ErrFrom   string    `json:"errFrom"`
+	ServerUrl string    `json:"serverUrl"`
+	Opened    time.Time `json:"opened,omitempty"`
+	Duration  int64     `json:"duration"`
+}
+
+// JournalFilter selects transitions, ErrKey is a prefix and zero values match everything
+type JournalFilter struct {
+	From      time.Time
+	To        time.Time
+	ErrKey    string
+	ServerUrl string
+	Limit     int
+}
+
+// Journal is an append only JSONL file of the state transitions, the
+// transitions older than keep are pruned
+type Journal struct {
+	file   string
+	opened map[string]time.Time
+	keep   time.Duration
+	pruned time.Time
+	sync.Mutex
+}
+
+// NewJournal reads back the file to know the states still open for the durations
+func NewJournal(file string) *Journal {
+	j := &Journal{file: file, opened: make(map[string]time.Time)}
+	j.scan(func(t Transition) bool {
+		if t.Action == TransitionOpen {
+			if _, ok := j.opened[t.key()]; !ok {
+				j.opened[t.key()] = t.Time
+			}
+		} else {
+			delete(j.opened, t.key())
+		}
+		return true
+	})
+	return j
+}
+
+func (t *Transition) key() string {
+	return t.ErrKey + "@" + t.ServerUrl
+}
+
+// SetKeep sets the retention of the transitions, 0 keeps them all
+func (j *Journal) SetKeep(keep time.Duration) {
+	j.Lock()
+	defer j.Unlock()
+	j.keep = keep
+}
+
+func (j *Journal) Open(s State, at time.Time) error {
+	t := newTransition(s, TransitionOpen, at)
+	j.Lock()
+	defer j.Unlock()
+	if _, ok := j.opened[t.key()]; !ok {
+		j.opened[t.key()] = at
+	}
+	return j.append(t)
+}
+
+func (j *Journal) Resolve(s State, at time.Time) error {
+	t := newTransition(s, TransitionResolve, at)
+	j.Lock()
+	defer j.Unlock()
+	if opened, ok := j.opened[t.key()]; ok {
+		t.Opened = opened
+		t.Duration = int64(at.Sub(opened).Seconds())
+		delete(j.opened, t.key())
+	}
+	return j.append(t)
+}
+
+func newTransition(s State, action string, at time.Time) Transition {
+	return Transition{
+		Time:      at,
+		Action:    action,
+		ErrKey:    s.ErrKey,
+		ErrType:   s.ErrType,
+		ErrDesc:   s.ErrDesc,
+		ErrFrom:   s.ErrFrom,
+		ServerUrl: s.ServerUrl,
+	}
+}
+
+func (j *Journal) append(t Transition) error {
+	line, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if j.keep > 0 && time.Since(j.pruned) > journalPruneInterval {
+		j.pruned = time.Now()
+		if err := j.prune(j.pruned.Add(-j.keep)); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(j.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// prune rewrites the journal without the transitions before the time given,
+// the openings of the states still open are kept for their durations
+func (j *Journal) prune(before time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.file), filepath.Base(j.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	err = j.scan(func(t Transition) bool {
+		opened, ok := j.opened[t.key()]
+		if t.Time.Before(before) && !(ok && t.Action == TransitionOpen && t.Time.Equal(opened)) {
+			return true
+		}
+		line, err := json.Marshal(t)
+		if err == nil {
+			w.Write(append(line, '\n'))
+		}
+		return true
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.file)
+}
+
+// Query returns the matching transitions in journal order, with Limit the most recent ones
+func (j *Journal) Query(filter JournalFilter) ([]Transition, error) {
+	res := make([]Transition, 0)
+	j.Lock()
+	defer j.Unlock()
+	err := j.scan(func(t Transition) bool {
+		if !filter.From.IsZero() && t.Time.Before(filter.From) {
+			return true
+		}
+		if !filter.To.IsZero() && t.Time.After(filter.To) {
+			return false
+		}
+		if !strings.HasPrefix(t.ErrKey, filter.ErrKey) {
+			return true
+		}
+		if filter.ServerUrl != "" && t.ServerUrl != filter.ServerUrl {
+			return true
+		}
+		res = append(res, t)
+		return true
+	})
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[len(res)-filter.Limit:]
+	}
+	return res, err
+}
+
+// scan calls fn for each record until it returns false, undecodable lines are skipped
+func (j *Journal) scan(fn func(t Transition) bool) error {
+	f, err := os.Open(j.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var t Transition
+		if json.Unmarshal(scanner.Bytes(), &t) != nil {
+			continue
+		}
+		if !fn(t) {
+			break
+		}
+	}
+	return scanner.Err()
+}
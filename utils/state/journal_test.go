@@ -0,0 +1,77 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalQuery(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "statejournal.jsonl")
+	j := NewJournal(file)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	db1 := State{ErrKey: "WARN0048", ErrType: "WARNING", ErrDesc: "No semisync", ServerUrl: "db1:3306"}
+	db2 := State{ErrKey: "ERR00042", ErrType: "ERROR", ErrDesc: "Slave SQL thread stopped", ServerUrl: "db2:3306"}
+	j.Open(db1, start)
+	j.Open(db2, start.Add(time.Minute))
+
+	// reopen the file to check durations survive a restart
+	j = NewJournal(file)
+	j.Resolve(db1, start.Add(90*time.Second))
+	j.Resolve(db2, start.Add(2*time.Minute))
+
+	all, err := j.Query(JournalFilter{})
+	if err != nil || len(all) != 4 {
+		t.Fatalf("expected 4 transitions got %d %v", len(all), err)
+	}
+	if all[2].Action != TransitionResolve || all[2].Duration != 90 {
+		t.Fatalf("unexpected resolve %+v", all[2])
+	}
+	res, _ := j.Query(JournalFilter{ErrKey: "ERR", ServerUrl: "db2:3306"})
+	if len(res) != 2 || res[1].Duration != 60 {
+		t.Fatalf("unexpected key filter %+v", res)
+	}
+	res, _ = j.Query(JournalFilter{From: start.Add(30 * time.Second), To: start.Add(100 * time.Second)})
+	if len(res) != 2 {
+		t.Fatalf("unexpected range filter %+v", res)
+	}
+	res, _ = j.Query(JournalFilter{Limit: 1})
+	if len(res) != 1 || res[0].ErrKey != "ERR00042" || res[0].Action != TransitionResolve {
+		t.Fatalf("unexpected limit %+v", res)
+	}
+}
+
+func TestJournalPrune(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "statejournal.jsonl")
+	j := NewJournal(file)
+	start := time.Now().Add(-48 * time.Hour)
+	db1 := State{ErrKey: "WARN0048", ErrType: "WARNING", ErrDesc: "No semisync", ServerUrl: "db1:3306"}
+	db2 := State{ErrKey: "ERR00042", ErrType: "ERROR", ErrDesc: "Slave SQL thread stopped", ServerUrl: "db2:3306"}
+	j.Open(db1, start)
+	j.Resolve(db1, start.Add(time.Minute))
+	j.Open(db2, start.Add(2*time.Minute))
+
+	j.SetKeep(24 * time.Hour)
+	j.Open(db1, time.Now())
+	all, err := j.Query(JournalFilter{})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected the opening still open and the new one got %+v %v", all, err)
+	}
+	if all[0].ErrKey != "ERR00042" || all[1].ErrKey != "WARN0048" {
+		t.Fatalf("unexpected pruned journal %+v", all)
+	}
+
+	// the duration of the state still open survives the pruning and a restart
+	j = NewJournal(file)
+	j.Resolve(db2, start.Add(3*time.Minute))
+	res, _ := j.Query(JournalFilter{ErrKey: "ERR"})
+	if len(res) != 2 || res[1].Duration != 60 {
+		t.Fatalf("unexpected resolve after pruning %+v", res)
+	}
+}
@@ -0,0 +1,237 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// azureBackend stores the objects as block blobs of an Azure container, the
+// Bucket of the configuration is the container, AccessKey and SecretKey the
+// storage account and its key
+type azureBackend struct {
+	conf      Config
+	container azblob.ContainerURL
+}
+
+func newAzure(conf Config) (Backend, error) {
+	if conf.Bucket == "" || conf.AccessKey == "" {
+		return nil, errors.New("Missing Azure storage account or container")
+	}
+	cred, err := azblob.NewSharedKeyCredential(conf.AccessKey, conf.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + conf.AccessKey + ".blob.core.windows.net"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/") + "/" + conf.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	p := azblob.NewPipeline(cred, azblob.PipelineOptions{})
+	return &azureBackend{conf: conf, container: azblob.NewContainerURL(*u, p)}, nil
+}
+
+func (b *azureBackend) Type() string {
+	return TypeAzure
+}
+
+func (b *azureBackend) blob(key string) azblob.BlockBlobURL {
+	return b.container.NewBlockBlobURL(objectKey(b.conf, key))
+}
+
+func (b *azureBackend) Put(ctx context.Context, key string, r io.Reader) (*Object, error) {
+	return b.put(ctx, key, r, false)
+}
+
+func (b *azureBackend) PutFile(ctx context.Context, key string, path string) (*Object, error) {
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if isUploaded(ctx, b, key, info.Size(), checksum) {
+		return b.Stat(ctx, key)
+	}
+	return b.put(ctx, key, file, true)
+}
+
+func (b *azureBackend) put(ctx context.Context, key string, r io.Reader, resume bool) (*Object, error) {
+	blob := b.blob(key)
+	putObject := func(data []byte) error {
+		sum := md5.Sum(data)
+		_, err := blob.Upload(ctx, bytes.NewReader(data), azblob.BlobHTTPHeaders{ContentMD5: sum[:]}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+		return err
+	}
+	open := func() (multipartUpload, error) {
+		return &azureUpload{blob: blob, ids: make(map[int]string)}, nil
+	}
+	size, checksum, err := putParts(ctx, r, b.conf.PartSize, resume, putObject, open)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{Key: key, Size: size, Checksum: checksum}
+	if !strings.HasSuffix(key, ChecksumSuffix) {
+		if err := putChecksum(ctx, b, key, checksum); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (b *azureBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := b.blob(key).Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, azureError(err)
+	}
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+func (b *azureBackend) Stat(ctx context.Context, key string) (*Object, error) {
+	props, err := b.blob(key).GetProperties(ctx, azblob.BlobAccessConditions{})
+	if err != nil {
+		return nil, azureError(err)
+	}
+	return &Object{Key: key, Size: props.ContentLength(), Checksum: getChecksum(ctx, b, key), ModTime: props.LastModified()}, nil
+}
+
+func (b *azureBackend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	objects := make([]*Object, 0)
+	base := objectKey(b.conf, "")
+	if base != "" {
+		base += "/"
+	}
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := b.container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: objectKey(b.conf, prefix)})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			key := strings.TrimPrefix(item.Name, base)
+			if strings.HasSuffix(key, ChecksumSuffix) {
+				continue
+			}
+			obj := &Object{Key: key, ModTime: item.Properties.LastModified}
+			if item.Properties.ContentLength != nil {
+				obj.Size = *item.Properties.ContentLength
+			}
+			objects = append(objects, obj)
+		}
+		marker = resp.NextMarker
+	}
+	for _, obj := range objects {
+		obj.Checksum = getChecksum(ctx, b, obj.Key)
+	}
+	return objects, nil
+}
+
+func (b *azureBackend) Delete(ctx context.Context, key string) error {
+	for _, k := range []string{key, key + ChecksumSuffix} {
+		_, err := b.blob(k).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+		if err != nil && azureError(err) != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// azureUpload stages the parts as uncommitted blocks of the blob, the blocks
+// left by an interrupted upload are kept by the service until the next
+// commit or a week
+type azureUpload struct {
+	blob azblob.BlockBlobURL
+	ids  map[int]string
+}
+
+// azureBlockID names the block of a part with its number and MD5, the block
+// list does not return the MD5 of the uncommitted blocks so a resumed upload
+// reads it from the name to only keep the blocks holding the same bytes
+func azureBlockID(num int, md5sum []byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d-%s", num, hex.EncodeToString(md5sum))))
+}
+
+func (u *azureUpload) Parts(ctx context.Context) (map[int]uploadedPart, error) {
+	parts := make(map[int]uploadedPart)
+	list, err := u.blob.GetBlockList(ctx, azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		if azureError(err) == ErrNotFound {
+			return parts, nil
+		}
+		return nil, err
+	}
+	for _, block := range list.UncommittedBlocks {
+		id, err := base64.StdEncoding.DecodeString(block.Name)
+		if err != nil {
+			continue
+		}
+		// Blocks named without MD5 are staged again
+		prefix, sum, ok := strings.Cut(string(id), "-")
+		if !ok || len(sum) != 2*md5.Size {
+			continue
+		}
+		num, err := strconv.Atoi(prefix)
+		if err != nil {
+			continue
+		}
+		parts[num] = uploadedPart{Size: int64(block.Size), MD5: sum}
+		u.ids[num] = block.Name
+	}
+	return parts, nil
+}
+
+func (u *azureUpload) UploadPart(ctx context.Context, num int, data []byte, md5sum []byte) error {
+	id := azureBlockID(num, md5sum)
+	if _, err := u.blob.StageBlock(ctx, id, bytes.NewReader(data), azblob.LeaseAccessConditions{}, md5sum); err != nil {
+		return err
+	}
+	u.ids[num] = id
+	return nil
+}
+
+func (u *azureUpload) Complete(ctx context.Context, parts int) error {
+	ids := make([]string, 0, parts)
+	for num := 1; num <= parts; num++ {
+		ids = append(ids, u.ids[num])
+	}
+	_, err := u.blob.CommitBlockList(ctx, ids, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+	return err
+}
+
+// Abort leaves the uncommitted blocks to the garbage collection of the service
+func (u *azureUpload) Abort(ctx context.Context) error {
+	return nil
+}
+
+func azureError(err error) error {
+	if serr, ok := err.(azblob.StorageError); ok && serr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+		return ErrNotFound
+	}
+	return err
+}
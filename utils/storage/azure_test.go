@@ -0,0 +1,227 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAzure serves the blob operations used by the Azure backend for one
+// container, blocks are kept uncommitted until the block list is committed
+type fakeAzure struct {
+	sync.Mutex
+	container string
+	blobs     map[string][]byte
+	blocks    map[string]map[string][]byte
+	staged    int
+	uploads   int
+}
+
+func newFakeAzure(container string) (*fakeAzure, *httptest.Server) {
+	f := &fakeAzure{container: container, blobs: make(map[string][]byte), blocks: make(map[string]map[string][]byte)}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeAzure) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	query := r.URL.Query()
+	path := strings.TrimPrefix(r.URL.Path, "/"+f.container)
+	if path == "" && query.Get("comp") == "list" {
+		f.list(w, query.Get("prefix"))
+		return
+	}
+	name := strings.TrimPrefix(path, "/")
+	body, _ := io.ReadAll(r.Body)
+	if sum := r.Header.Get("Content-MD5"); sum != "" && sum != md5Base64(body) {
+		f.error(w, http.StatusBadRequest, "Md5Mismatch")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		if f.blocks[name] == nil {
+			f.blocks[name] = make(map[string][]byte)
+		}
+		f.blocks[name][query.Get("blockid")] = body
+		f.staged++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			f.error(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var blob []byte
+		for _, id := range list.Latest {
+			block, ok := f.blocks[name][id]
+			if !ok {
+				f.error(w, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			blob = append(blob, block...)
+		}
+		f.blobs[name] = blob
+		delete(f.blocks, name)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		f.blobs[name] = body
+		delete(f.blocks, name)
+		f.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && query.Get("comp") == "blocklist":
+		blocks, ok := f.blocks[name]
+		if _, exists := f.blobs[name]; !ok && !exists {
+			f.error(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		ids := make([]string, 0, len(blocks))
+		for id := range blocks {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		fmt.Fprint(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><BlockList><CommittedBlocks></CommittedBlocks><UncommittedBlocks>")
+		for _, id := range ids {
+			fmt.Fprintf(w, "<Block><Name>%s</Name><Size>%d</Size></Block>", id, len(blocks[id]))
+		}
+		fmt.Fprint(w, "</UncommittedBlocks></BlockList>")
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		blob, ok := f.blobs[name]
+		if !ok {
+			f.error(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(blob)
+		}
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			f.error(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		f.error(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+func (f *fakeAzure) list(w http.ResponseWriter, prefix string) {
+	names := make([]string, 0, len(f.blobs))
+	for name := range f.blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><EnumerationResults ContainerName=\"%s\"><Prefix>%s</Prefix><Blobs>", f.container, prefix)
+	for _, name := range names {
+		fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType></Properties></Blob>", name, time.Now().UTC().Format(http.TimeFormat), len(f.blobs[name]))
+	}
+	fmt.Fprint(w, "</Blobs><NextMarker /></EnumerationResults>")
+}
+
+func newTestAzure(t *testing.T) (*fakeAzure, Backend) {
+	f, srv := newFakeAzure("backups")
+	t.Cleanup(srv.Close)
+	b, err := New(Config{
+		Type:      TypeAzure,
+		Endpoint:  srv.URL,
+		Bucket:    "backups",
+		AccessKey: "account",
+		SecretKey: base64.StdEncoding.EncodeToString([]byte("secret")),
+		Prefix:    "replication-manager-test",
+		PartSize:  minPartSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, b
+}
+
+func TestAzureBackend(t *testing.T) {
+	_, b := newTestAzure(t)
+	testBackend(t, b)
+}
+
+func TestAzurePutFileResume(t *testing.T) {
+	ctx := context.Background()
+	f, b := newTestAzure(t)
+	data := make([]byte, 2*minPartSize+123)
+	rand.Read(data)
+	src := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(src, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted upload staged the first part and a stale second part of the same size
+	up := &azureUpload{blob: b.(*azureBackend).blob("c1/mysql-bin.000001"), ids: make(map[int]string)}
+	first := md5.Sum(data[:minPartSize])
+	if err := up.UploadPart(ctx, 1, data[:minPartSize], first[:]); err != nil {
+		t.Fatal(err)
+	}
+	stale := append([]byte(nil), data[minPartSize:2*minPartSize]...)
+	stale[0] ^= 0xff
+	second := md5.Sum(stale)
+	if err := up.UploadPart(ctx, 2, stale, second[:]); err != nil {
+		t.Fatal(err)
+	}
+	f.staged = 0
+
+	obj, err := b.PutFile(ctx, "c1/mysql-bin.000001", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.staged != 2 {
+		t.Fatalf("Resume staged %d blocks, expected the stale part and the last one", f.staged)
+	}
+	if obj.Size != int64(len(data)) || obj.Checksum != checksum(data) {
+		t.Fatalf("PutFile returned %+v", obj)
+	}
+	if !bytes.Equal(f.blobs["replication-manager-test/c1/mysql-bin.000001"], data) {
+		t.Fatal("Resumed blob differs from the file")
+	}
+	if got := string(f.blobs["replication-manager-test/c1/mysql-bin.000001"+ChecksumSuffix]); got != checksum(data) {
+		t.Fatalf("Checksum sidecar holds %q", got)
+	}
+
+	// A file already uploaded is not sent again
+	f.staged, f.uploads = 0, 0
+	if _, err := b.PutFile(ctx, "c1/mysql-bin.000001", src); err != nil {
+		t.Fatal(err)
+	}
+	if f.staged != 0 || f.uploads != 0 {
+		t.Fatalf("Uploaded file sent again with %d blocks and %d uploads", f.staged, f.uploads)
+	}
+}
@@ -0,0 +1,214 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localBackend stores the objects as files of a directory, a file is written
+// to a .part file renamed when complete
+type localBackend struct {
+	conf Config
+	root string
+}
+
+func newLocal(conf Config) (Backend, error) {
+	if conf.Path == "" {
+		return nil, errors.New("Missing local storage path")
+	}
+	root := filepath.Join(conf.Path, filepath.FromSlash(conf.Prefix))
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &localBackend{conf: conf, root: root}, nil
+}
+
+func (b *localBackend) Type() string {
+	return TypeLocal
+}
+
+func (b *localBackend) path(key string) (string, error) {
+	p := filepath.Join(b.root, filepath.FromSlash(strings.TrimLeft(key, "/")))
+	if p != b.root && !strings.HasPrefix(p, b.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("Key %s out of the storage path", key)
+	}
+	return p, nil
+}
+
+func (b *localBackend) Put(ctx context.Context, key string, r io.Reader) (*Object, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(p+".part", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, h), r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(p+".part", p)
+	}
+	if err != nil {
+		os.Remove(p + ".part")
+		return nil, err
+	}
+	obj := &Object{Key: key, Size: size, Checksum: hex.EncodeToString(h.Sum(nil))}
+	if !strings.HasSuffix(key, ChecksumSuffix) {
+		if err := putChecksum(ctx, b, key, obj.Checksum); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func (b *localBackend) PutFile(ctx context.Context, key string, path string) (*Object, error) {
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if isUploaded(ctx, b, key, info.Size(), checksum) {
+		return b.Stat(ctx, key)
+	}
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, err
+	}
+
+	// Resume after the bytes of an interrupted copy, copy again when they differ
+	var offset int64
+	if part, err := os.Stat(p + ".part"); err == nil && part.Size() <= info.Size() {
+		offset = part.Size()
+	}
+	if err := b.copyFrom(path, p+".part", offset); err != nil {
+		return nil, err
+	}
+	if sum, err := FileChecksum(p + ".part"); err != nil || sum != checksum {
+		if offset == 0 {
+			os.Remove(p + ".part")
+			return nil, fmt.Errorf("Checksum mismatch copying %s", path)
+		}
+		if err := b.copyFrom(path, p+".part", 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Rename(p+".part", p); err != nil {
+		return nil, err
+	}
+	if err := putChecksum(ctx, b, key, checksum); err != nil {
+		return nil, err
+	}
+	return &Object{Key: key, Size: info.Size(), Checksum: checksum, ModTime: info.ModTime()}, nil
+}
+
+func (b *localBackend) copyFrom(src string, dst string, offset int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(dst, flags, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (b *localBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (b *localBackend) Stat(ctx context.Context, key string) (*Object, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Key: key, Size: info.Size(), Checksum: getChecksum(ctx, b, key), ModTime: info.ModTime()}, nil
+}
+
+func (b *localBackend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	objects := make([]*Object, 0)
+	err := filepath.Walk(b.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || strings.HasSuffix(p, ChecksumSuffix) || strings.HasSuffix(p, ".part") {
+			return err
+		}
+		key := filepath.ToSlash(strings.TrimPrefix(p, b.root+string(filepath.Separator)))
+		if strings.HasPrefix(key, strings.TrimLeft(prefix, "/")) {
+			objects = append(objects, &Object{Key: key, Size: info.Size(), Checksum: getChecksum(ctx, b, key), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func (b *localBackend) Delete(ctx context.Context, key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(p + ChecksumSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Backend stores the objects in a bucket of an S3 compatible service
+type s3Backend struct {
+	conf   Config
+	client *s3.S3
+}
+
+func newS3(conf Config) (Backend, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("Missing S3 bucket")
+	}
+	awsconf := &aws.Config{
+		Region:           aws.String(conf.Region),
+		Credentials:      credentials.NewStaticCredentials(conf.AccessKey, conf.SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+	if conf.Endpoint != "" {
+		awsconf.Endpoint = aws.String(conf.Endpoint)
+	}
+	sess, err := session.NewSession(awsconf)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Backend{conf: conf, client: s3.New(sess)}, nil
+}
+
+func (b *s3Backend) Type() string {
+	return TypeS3
+}
+
+func (b *s3Backend) Put(ctx context.Context, key string, r io.Reader) (*Object, error) {
+	return b.put(ctx, key, r, false)
+}
+
+func (b *s3Backend) PutFile(ctx context.Context, key string, path string) (*Object, error) {
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if isUploaded(ctx, b, key, info.Size(), checksum) {
+		return b.Stat(ctx, key)
+	}
+	return b.put(ctx, key, file, true)
+}
+
+func (b *s3Backend) put(ctx context.Context, key string, r io.Reader, resume bool) (*Object, error) {
+	name := objectKey(b.conf, key)
+	putObject := func(data []byte) error {
+		sum := md5Base64(data)
+		_, err := b.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:     aws.String(b.conf.Bucket),
+			Key:        aws.String(name),
+			Body:       bytes.NewReader(data),
+			ContentMD5: aws.String(sum),
+		})
+		return err
+	}
+	open := func() (multipartUpload, error) {
+		return b.openUpload(ctx, name, resume)
+	}
+	size, checksum, err := putParts(ctx, r, b.conf.PartSize, resume, putObject, open)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{Key: key, Size: size, Checksum: checksum}
+	if !strings.HasSuffix(key, ChecksumSuffix) {
+		if err := putChecksum(ctx, b, key, checksum); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// openUpload returns the last interrupted multipart upload of the object
+// when resume is set, a new multipart upload otherwise
+func (b *s3Backend) openUpload(ctx context.Context, name string, resume bool) (multipartUpload, error) {
+	if resume {
+		out, err := b.client.ListMultipartUploadsWithContext(ctx, &s3.ListMultipartUploadsInput{
+			Bucket: aws.String(b.conf.Bucket),
+			Prefix: aws.String(name),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var last *s3.MultipartUpload
+		for _, upload := range out.Uploads {
+			if aws.StringValue(upload.Key) == name && (last == nil || aws.TimeValue(upload.Initiated).After(aws.TimeValue(last.Initiated))) {
+				last = upload
+			}
+		}
+		if last != nil {
+			return &s3Upload{b: b, name: name, id: aws.StringValue(last.UploadId)}, nil
+		}
+	}
+	out, err := b.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(b.conf.Bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Upload{b: b, name: name, id: aws.StringValue(out.UploadId)}, nil
+}
+
+func (b *s3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.conf.Bucket),
+		Key:    aws.String(objectKey(b.conf, key)),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return out.Body, nil
+}
+
+func (b *s3Backend) Stat(ctx context.Context, key string) (*Object, error) {
+	out, err := b.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.conf.Bucket),
+		Key:    aws.String(objectKey(b.conf, key)),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return &Object{Key: key, Size: aws.Int64Value(out.ContentLength), Checksum: getChecksum(ctx, b, key), ModTime: aws.TimeValue(out.LastModified)}, nil
+}
+
+func (b *s3Backend) List(ctx context.Context, prefix string) ([]*Object, error) {
+	objects := make([]*Object, 0)
+	base := objectKey(b.conf, "")
+	if base != "" {
+		base += "/"
+	}
+	err := b.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.conf.Bucket),
+		Prefix: aws.String(objectKey(b.conf, prefix)),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, item := range page.Contents {
+			key := strings.TrimPrefix(aws.StringValue(item.Key), base)
+			if strings.HasSuffix(key, ChecksumSuffix) {
+				continue
+			}
+			objects = append(objects, &Object{Key: key, Size: aws.Int64Value(item.Size), ModTime: aws.TimeValue(item.LastModified)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		obj.Checksum = getChecksum(ctx, b, obj.Key)
+	}
+	return objects, nil
+}
+
+func (b *s3Backend) Delete(ctx context.Context, key string) error {
+	for _, name := range []string{objectKey(b.conf, key), objectKey(b.conf, key+ChecksumSuffix)} {
+		_, err := b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(b.conf.Bucket),
+			Key:    aws.String(name),
+		})
+		if err != nil && s3Error(err) != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// s3Upload is a multipart upload of an object
+type s3Upload struct {
+	b     *s3Backend
+	name  string
+	id    string
+	etags map[int]string
+}
+
+func (u *s3Upload) Parts(ctx context.Context) (map[int]uploadedPart, error) {
+	parts := make(map[int]uploadedPart)
+	u.etags = make(map[int]string)
+	err := u.b.client.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(u.b.conf.Bucket),
+		Key:      aws.String(u.name),
+		UploadId: aws.String(u.id),
+	}, func(page *s3.ListPartsOutput, last bool) bool {
+		for _, part := range page.Parts {
+			num := int(aws.Int64Value(part.PartNumber))
+			etag := aws.StringValue(part.ETag)
+			u.etags[num] = etag
+			parts[num] = uploadedPart{Size: aws.Int64Value(part.Size), MD5: strings.Trim(etag, "\"")}
+		}
+		return true
+	})
+	return parts, err
+}
+
+func (u *s3Upload) UploadPart(ctx context.Context, num int, data []byte, md5sum []byte) error {
+	out, err := u.b.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(u.b.conf.Bucket),
+		Key:        aws.String(u.name),
+		UploadId:   aws.String(u.id),
+		PartNumber: aws.Int64(int64(num)),
+		Body:       bytes.NewReader(data),
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(md5sum)),
+	})
+	if err != nil {
+		return err
+	}
+	if u.etags == nil {
+		u.etags = make(map[int]string)
+	}
+	u.etags[num] = aws.StringValue(out.ETag)
+	return nil
+}
+
+func (u *s3Upload) Complete(ctx context.Context, parts int) error {
+	completed := make([]*s3.CompletedPart, 0, parts)
+	for num := 1; num <= parts; num++ {
+		completed = append(completed, &s3.CompletedPart{PartNumber: aws.Int64(int64(num)), ETag: aws.String(u.etags[num])})
+	}
+	_, err := u.b.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.b.conf.Bucket),
+		Key:             aws.String(u.name),
+		UploadId:        aws.String(u.id),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	_, err := u.b.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(u.b.conf.Bucket),
+		Key:      aws.String(u.name),
+		UploadId: aws.String(u.id),
+	})
+	return err
+}
+
+func s3Error(err error) error {
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 404 {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeS3Upload struct {
+	key       string
+	initiated time.Time
+	parts     map[int][]byte
+}
+
+// fakeS3 serves the object and multipart operations used by the S3 backend
+// for one bucket with path style requests
+type fakeS3 struct {
+	sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	uploads map[string]*fakeS3Upload
+	next    int
+	parts   int
+	puts    int
+}
+
+func newFakeS3(bucket string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{bucket: bucket, objects: make(map[string][]byte), uploads: make(map[string]*fakeS3Upload)}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return "\"" + hex.EncodeToString(sum[:]) + "\""
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	query := r.URL.Query()
+	path := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	key := strings.TrimPrefix(path, "/")
+	if key == "" {
+		if _, ok := query["uploads"]; ok {
+			f.listUploads(w, query.Get("prefix"))
+		} else {
+			f.listObjects(w, query.Get("prefix"))
+		}
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if sum := r.Header.Get("Content-MD5"); sum != "" && sum != md5Base64(body) {
+		f.error(w, http.StatusBadRequest, "BadDigest")
+		return
+	}
+	id := query.Get("uploadId")
+	upload := f.uploads[id]
+	if id != "" && upload == nil {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	_, initiate := query["uploads"]
+	switch {
+	case r.Method == http.MethodPost && initiate:
+		f.next++
+		id = "upload" + strconv.Itoa(f.next)
+		f.uploads[id] = &fakeS3Upload{key: key, initiated: time.Now(), parts: make(map[int][]byte)}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", f.bucket, key, id)
+	case r.Method == http.MethodPut && id != "":
+		num, _ := strconv.Atoi(query.Get("partNumber"))
+		upload.parts[num] = body
+		f.parts++
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet && id != "":
+		nums := make([]int, 0, len(upload.parts))
+		for num := range upload.parts {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		fmt.Fprintf(w, "<ListPartsResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId><IsTruncated>false</IsTruncated>", f.bucket, key, id)
+		for _, num := range nums {
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size></Part>", num, etag(upload.parts[num]), len(upload.parts[num]))
+		}
+		fmt.Fprint(w, "</ListPartsResult>")
+	case r.Method == http.MethodPost && id != "":
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			f.error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var object []byte
+		for _, part := range complete.Parts {
+			data, ok := upload.parts[part.PartNumber]
+			if !ok || etag(data) != part.ETag {
+				f.error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			object = append(object, data...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, id)
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>", f.bucket, key, etag(object))
+	case r.Method == http.MethodDelete && id != "":
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.puts++
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", etag(object))
+		if r.Method == http.MethodGet {
+			w.Write(object)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) listObjects(w http.ResponseWriter, prefix string) {
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated>", f.bucket, prefix, len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified><ETag>%s</ETag></Contents>", key, len(f.objects[key]), time.Now().UTC().Format(time.RFC3339), etag(f.objects[key]))
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func (f *fakeS3) listUploads(w http.ResponseWriter, prefix string) {
+	fmt.Fprintf(w, "<ListMultipartUploadsResult><Bucket>%s</Bucket><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>", f.bucket, prefix)
+	for id, upload := range f.uploads {
+		if strings.HasPrefix(upload.key, prefix) {
+			fmt.Fprintf(w, "<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>", upload.key, id, upload.initiated.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	fmt.Fprint(w, "</ListMultipartUploadsResult>")
+}
+
+func newTestS3(t *testing.T) (*fakeS3, Backend) {
+	f, srv := newFakeS3("backups")
+	t.Cleanup(srv.Close)
+	b, err := New(Config{
+		Type:      TypeS3,
+		Endpoint:  srv.URL,
+		Region:    "us-east-1",
+		Bucket:    "backups",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "replication-manager-test",
+		PartSize:  minPartSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, b
+}
+
+func TestS3Backend(t *testing.T) {
+	_, b := newTestS3(t)
+	testBackend(t, b)
+}
+
+func TestS3PutFileResume(t *testing.T) {
+	ctx := context.Background()
+	f, b := newTestS3(t)
+	data := make([]byte, 2*minPartSize+123)
+	rand.Read(data)
+	src := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(src, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted upload stored the first part and a stale second part of the same size
+	up, err := b.(*s3Backend).openUpload(ctx, "replication-manager-test/c1/mysql-bin.000001", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := md5.Sum(data[:minPartSize])
+	if err := up.UploadPart(ctx, 1, data[:minPartSize], first[:]); err != nil {
+		t.Fatal(err)
+	}
+	stale := append([]byte(nil), data[minPartSize:2*minPartSize]...)
+	stale[0] ^= 0xff
+	second := md5.Sum(stale)
+	if err := up.UploadPart(ctx, 2, stale, second[:]); err != nil {
+		t.Fatal(err)
+	}
+	f.parts = 0
+
+	obj, err := b.PutFile(ctx, "c1/mysql-bin.000001", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.parts != 2 {
+		t.Fatalf("Resume uploaded %d parts, expected the stale part and the last one", f.parts)
+	}
+	if len(f.uploads) != 0 {
+		t.Fatalf("%d multipart uploads left", len(f.uploads))
+	}
+	if obj.Size != int64(len(data)) || obj.Checksum != checksum(data) {
+		t.Fatalf("PutFile returned %+v", obj)
+	}
+	if !bytes.Equal(f.objects["replication-manager-test/c1/mysql-bin.000001"], data) {
+		t.Fatal("Resumed object differs from the file")
+	}
+	if got := string(f.objects["replication-manager-test/c1/mysql-bin.000001"+ChecksumSuffix]); got != checksum(data) {
+		t.Fatalf("Checksum sidecar holds %q", got)
+	}
+
+	// A file already uploaded is not sent again
+	f.parts, f.puts = 0, 0
+	if _, err := b.PutFile(ctx, "c1/mysql-bin.000001", src); err != nil {
+		t.Fatal(err)
+	}
+	if f.parts != 0 || f.puts != 0 {
+		t.Fatalf("Uploaded file sent again with %d parts and %d puts", f.parts, f.puts)
+	}
+
+	// A streamed upload failing is aborted
+	if _, err := b.Put(ctx, "c1/dump.sql.gz", io.MultiReader(bytes.NewReader(data), &failingReader{})); err == nil {
+		t.Fatal("Put did not fail")
+	}
+	if len(f.uploads) != 0 {
+		t.Fatal("Failed streamed upload not aborted")
+	}
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
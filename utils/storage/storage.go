@@ -0,0 +1,266 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backends a backup can be copied to. Objects are written by parts of
+// PartSize bytes, the parts of an upload from a file are kept when the
+// transfer fails so the next upload of the same file resumes after the last
+// part stored. The sha256 of an object is stored next to it in a sidecar
+// object named with ChecksumSuffix.
+const (
+	TypeLocal       = "local"
+	TypeS3          = "s3"
+	TypeAzure       = "azure"
+	ChecksumSuffix  = ".sha256"
+	DefaultPartSize = 16 * 1024 * 1024
+	minPartSize     = 5 * 1024 * 1024
+)
+
+var ErrNotFound = errors.New("Object not found")
+
+// Config describes a backend, an S3 bucket or an Azure container with the
+// access key and secret of the account, or a local directory given by Path
+type Config struct {
+	Type      string
+	Path      string
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	PartSize  int64
+}
+
+// Object describes a stored object
+type Object struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum"`
+	ModTime  time.Time `json:"modTime"`
+}
+
+// Backend stores backup objects
+type Backend interface {
+	Type() string
+	// Put streams a reader to an object
+	Put(ctx context.Context, key string, r io.Reader) (*Object, error)
+	// PutFile uploads a local file, resuming an interrupted upload of the same file
+	PutFile(ctx context.Context, key string, path string) (*Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	List(ctx context.Context, prefix string) ([]*Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the backend of a configuration
+func New(conf Config) (Backend, error) {
+	if conf.PartSize <= 0 {
+		conf.PartSize = DefaultPartSize
+	}
+	if conf.PartSize < minPartSize && conf.Type != TypeLocal {
+		conf.PartSize = minPartSize
+	}
+	conf.Prefix = strings.Trim(conf.Prefix, "/")
+	switch conf.Type {
+	case TypeLocal:
+		return newLocal(conf)
+	case TypeS3:
+		return newS3(conf)
+	case TypeAzure:
+		return newAzure(conf)
+	}
+	return nil, fmt.Errorf("Unknown storage type %q", conf.Type)
+}
+
+func objectKey(conf Config, key string) string {
+	key = strings.TrimLeft(key, "/")
+	if conf.Prefix == "" {
+		return key
+	}
+	return conf.Prefix + "/" + key
+}
+
+// FileChecksum returns the hex encoded sha256 of a file
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadedPart is a part of an interrupted upload, MD5 is empty when the
+// store does not return it
+type uploadedPart struct {
+	Size int64
+	MD5  string
+}
+
+// multipartUpload is an upload by parts numbered from 1
+type multipartUpload interface {
+	Parts(ctx context.Context) (map[int]uploadedPart, error)
+	UploadPart(ctx context.Context, num int, data []byte, md5sum []byte) error
+	Complete(ctx context.Context, parts int) error
+	Abort(ctx context.Context) error
+}
+
+// putParts reads r by parts, an object smaller than a part is written with
+// putObject, larger ones with the multipart upload returned by open. The
+// parts already stored by an interrupted upload are skipped when resume is
+// set, the upload is aborted on error otherwise.
+func putParts(ctx context.Context, r io.Reader, partSize int64, resume bool, putObject func([]byte) error, open func() (multipartUpload, error)) (int64, string, error) {
+	h := sha256.New()
+	buf := make([]byte, partSize)
+	var size int64
+
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		h.Write(buf[:n])
+		if err := putObject(buf[:n]); err != nil {
+			return 0, "", err
+		}
+		return int64(n), hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	up, err := open()
+	if err != nil {
+		return 0, "", err
+	}
+	fail := func(err error) (int64, string, error) {
+		if !resume {
+			up.Abort(ctx)
+		}
+		return 0, "", err
+	}
+	done := make(map[int]uploadedPart)
+	if resume {
+		if done, err = up.Parts(ctx); err != nil {
+			return fail(err)
+		}
+	}
+
+	num := 0
+	for n > 0 {
+		num++
+		data := buf[:n]
+		h.Write(data)
+		size += int64(n)
+		sum := md5.Sum(data)
+		if part, ok := done[num]; !ok || part.Size != int64(n) || (part.MD5 != "" && part.MD5 != hex.EncodeToString(sum[:])) {
+			if err := up.UploadPart(ctx, num, data, sum[:]); err != nil {
+				return fail(err)
+			}
+		}
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fail(err)
+		}
+	}
+	if err := up.Complete(ctx, num); err != nil {
+		return fail(err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// putChecksum stores the sidecar object with the checksum of an object
+func putChecksum(ctx context.Context, b Backend, key string, checksum string) error {
+	_, err := b.Put(ctx, key+ChecksumSuffix, bytes.NewBufferString(checksum))
+	return err
+}
+
+// getChecksum reads the checksum of an object from its sidecar object
+func getChecksum(ctx context.Context, b Backend, key string) string {
+	r, err := b.Get(ctx, key+ChecksumSuffix)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, 128))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// isUploaded tells if an object already holds the content of a file
+func isUploaded(ctx context.Context, b Backend, key string, size int64, checksum string) bool {
+	obj, err := b.Stat(ctx, key)
+	return err == nil && obj.Size == size && obj.Checksum == checksum
+}
+
+// Writer streams the bytes written to an object of a backend. A failed
+// upload does not fail the writes so the writer can be combined with the
+// local copy of a backup, the error is returned by Close.
+type Writer struct {
+	pw     *io.PipeWriter
+	done   chan struct{}
+	object *Object
+	err    error
+}
+
+// NewWriter starts the upload of an object
+func NewWriter(ctx context.Context, b Backend, key string) *Writer {
+	pr, pw := io.Pipe()
+	w := &Writer{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		w.object, w.err = b.Put(ctx, key, pr)
+		pr.CloseWithError(w.err)
+		if w.err == nil {
+			// Drain a reader the backend did not read to the end
+			io.Copy(io.Discard, pr)
+		}
+	}()
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.pw != nil {
+		if _, err := w.pw.Write(p); err != nil {
+			w.pw = nil
+		}
+	}
+	return len(p), nil
+}
+
+// Close ends the upload and waits for it
+func (w *Writer) Close() (*Object, error) {
+	if w.pw != nil {
+		w.pw.Close()
+	}
+	<-w.done
+	return w.object, w.err
+}
+
+func md5Base64(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,231 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	data := make([]byte, 2*minPartSize+123)
+	rand.Read(data)
+
+	obj, err := b.Put(ctx, "c1/db1_3306/dump.sql.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != int64(len(data)) || obj.Checksum != checksum(data) {
+		t.Fatalf("Put returned size %d checksum %s", obj.Size, obj.Checksum)
+	}
+	if _, err := b.Put(ctx, "c1/db1_3306/binary-logs.meta.json", bytes.NewBufferString("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := b.Get(ctx, "c1/db1_3306/dump.sql.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get returned %d bytes, error %v", len(got), err)
+	}
+
+	stat, err := b.Stat(ctx, "c1/db1_3306/dump.sql.gz")
+	if err != nil || stat.Size != int64(len(data)) || stat.Checksum != checksum(data) {
+		t.Fatalf("Stat returned %+v, error %v", stat, err)
+	}
+
+	objects, err := b.List(ctx, "c1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("List returned %d objects, expected 2 without the checksums", len(objects))
+	}
+
+	if err := b.Delete(ctx, "c1/db1_3306/dump.sql.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Stat(ctx, "c1/db1_3306/dump.sql.gz"); err != ErrNotFound {
+		t.Fatalf("Stat of a deleted object returned %v", err)
+	}
+	if _, err := b.Get(ctx, "c1/db1_3306/missing"); err != ErrNotFound {
+		t.Fatalf("Get of a missing object returned %v", err)
+	}
+	b.Delete(ctx, "c1/db1_3306/binary-logs.meta.json")
+}
+
+func TestLocalBackend(t *testing.T) {
+	b, err := New(Config{Type: TypeLocal, Path: t.TempDir(), Prefix: "/backups/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+
+	if _, err := b.Put(context.Background(), "../../escape", bytes.NewBufferString("x")); err == nil {
+		t.Fatal("Put accepted a key out of the storage path")
+	}
+}
+
+func TestLocalPutFileResume(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	b, err := New(Config{Type: TypeLocal, Path: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 100000)
+	rand.Read(data)
+	src := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	if err := os.WriteFile(src, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted copy left the first bytes
+	os.MkdirAll(filepath.Join(root, "c1"), 0700)
+	if err := os.WriteFile(filepath.Join(root, "c1", "mysql-bin.000001.part"), data[:40000], 0600); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := b.PutFile(ctx, "c1/mysql-bin.000001", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Checksum != checksum(data) {
+		t.Fatalf("PutFile returned checksum %s", obj.Checksum)
+	}
+	got, _ := os.ReadFile(filepath.Join(root, "c1", "mysql-bin.000001"))
+	if !bytes.Equal(got, data) {
+		t.Fatal("Resumed copy differs from the file")
+	}
+
+	// A stale part file with other bytes is copied again
+	data[10] ^= 0xff
+	os.WriteFile(src, data, 0600)
+	os.WriteFile(filepath.Join(root, "c1", "mysql-bin.000001.part"), got[:40000], 0600)
+	if _, err := b.PutFile(ctx, "c1/mysql-bin.000001", src); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = os.ReadFile(filepath.Join(root, "c1", "mysql-bin.000001"))
+	if !bytes.Equal(got, data) {
+		t.Fatal("Copy over a stale part differs from the file")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	b, err := New(Config{Type: TypeLocal, Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter(context.Background(), b, "stream.xbstream")
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+	obj, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != 11 || obj.Checksum != checksum([]byte("hello world")) {
+		t.Fatalf("Writer stored %+v", obj)
+	}
+
+	// Writes do not fail when the upload does
+	w = NewWriter(context.Background(), b, "../escape")
+	if n, err := w.Write([]byte("data")); n != 4 || err != nil {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if _, err := w.Close(); err == nil {
+		t.Fatal("Close did not return the upload error")
+	}
+}
+
+// memUpload is a multipart upload failing after a number of parts
+type memUpload struct {
+	parts    map[int][]byte
+	failAt   int
+	uploaded int
+	complete int
+}
+
+func (u *memUpload) Parts(ctx context.Context) (map[int]uploadedPart, error) {
+	parts := make(map[int]uploadedPart)
+	for num, data := range u.parts {
+		sum := md5.Sum(data)
+		parts[num] = uploadedPart{Size: int64(len(data)), MD5: hex.EncodeToString(sum[:])}
+	}
+	return parts, nil
+}
+
+func (u *memUpload) UploadPart(ctx context.Context, num int, data []byte, md5sum []byte) error {
+	if u.failAt > 0 && num == u.failAt {
+		return errors.New("connection reset")
+	}
+	u.parts[num] = append([]byte(nil), data...)
+	u.uploaded++
+	return nil
+}
+
+func (u *memUpload) Complete(ctx context.Context, parts int) error {
+	u.complete = parts
+	return nil
+}
+
+func (u *memUpload) Abort(ctx context.Context) error {
+	u.parts = make(map[int][]byte)
+	return nil
+}
+
+func TestPutPartsResume(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 5*1024+10)
+	rand.Read(data)
+	up := &memUpload{parts: make(map[int][]byte), failAt: 4}
+	open := func() (multipartUpload, error) { return up, nil }
+	putObject := func([]byte) error { return errors.New("unexpected single put") }
+
+	if _, _, err := putParts(ctx, bytes.NewReader(data), 1024, true, putObject, open); err == nil {
+		t.Fatal("putParts did not fail")
+	}
+	if len(up.parts) != 3 {
+		t.Fatalf("%d parts kept after the failure, expected 3", len(up.parts))
+	}
+
+	up.failAt = 0
+	up.uploaded = 0
+	size, sum, err := putParts(ctx, bytes.NewReader(data), 1024, true, putObject, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.uploaded != 3 || up.complete != 6 {
+		t.Fatalf("Resume uploaded %d parts and completed %d, expected 3 and 6", up.uploaded, up.complete)
+	}
+	if size != int64(len(data)) || sum != checksum(data) {
+		t.Fatalf("putParts returned size %d checksum %s", size, sum)
+	}
+
+	// Without resume the parts are dropped on failure
+	up = &memUpload{parts: make(map[int][]byte), failAt: 2}
+	putParts(ctx, bytes.NewReader(data), 1024, false, putObject, open)
+	if len(up.parts) != 0 {
+		t.Fatal("Parts kept after a failed upload without resume")
+	}
+}
@@ -0,0 +1,104 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+// Package throttle limits the bandwidth of a stream and pauses it on demand
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// PausePoll is the interval a paused reader checks if it can resume
+var PausePoll = time.Second
+
+// Bucket is a token bucket of the bytes per second returned by Limit shared
+// by the readers drawing from it, the streams of a bucket share its
+// bandwidth. The bucket keeps no credit so an idle or slow stream does not
+// earn a burst.
+type Bucket struct {
+	sync.Mutex
+	limit  func() int64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket, a nil limit or a limit of 0 does not limit
+func NewBucket(limit func() int64) *Bucket {
+	return &Bucket{limit: limit}
+}
+
+// Limit returns the bytes per second of the bucket, 0 when unlimited
+func (b *Bucket) Limit() int64 {
+	if b.limit == nil {
+		return 0
+	}
+	return b.limit()
+}
+
+// Take draws n bytes from the bucket and returns how long the caller waits
+// for them
+func (b *Bucket) Take(n int) time.Duration {
+	rate := b.Limit()
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	if rate <= 0 {
+		b.tokens = 0
+		b.last = time.Time{}
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	}
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// Reader reads from its bucket and blocks while Paused returns true. The
+// limit and the pause are read before each read so they can change during
+// the stream. The sender of a network stream is slowed down or paused by the
+// TCP window once the reader stops reading.
+type Reader struct {
+	r      io.Reader
+	bucket *Bucket
+	paused func() bool
+}
+
+// NewReader returns a throttled reader with its own bucket, a nil limit or a
+// limit of 0 reads at full speed and a nil paused never pauses
+func NewReader(r io.Reader, limit func() int64, paused func() bool) *Reader {
+	return NewBucketReader(r, NewBucket(limit), paused)
+}
+
+// NewBucketReader returns a throttled reader drawing from a shared bucket
+func NewBucketReader(r io.Reader, bucket *Bucket, paused func() bool) *Reader {
+	return &Reader{r: r, bucket: bucket, paused: paused}
+}
+
+func (t *Reader) Read(p []byte) (int, error) {
+	for t.paused != nil && t.paused() {
+		time.Sleep(PausePoll)
+	}
+	rate := t.bucket.Limit()
+	if rate <= 0 {
+		return t.r.Read(p)
+	}
+	// Reads stay under one second of budget to keep the stream smooth
+	if int64(len(p)) > rate {
+		p = p[:rate]
+	}
+	n, err := t.r.Read(p)
+	if wait := t.bucket.Take(n); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
@@ -0,0 +1,71 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReaderLimit(t *testing.T) {
+	data := make([]byte, 40*1024)
+	r := NewReader(bytes.NewReader(data), func() int64 { return 100 * 1024 }, nil)
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	if err != nil || len(got) != len(data) {
+		t.Fatalf("read %d bytes, error %v", len(got), err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("40KB at 100KB/s read in %s", elapsed)
+	}
+
+	r = NewReader(bytes.NewReader(data), nil, nil)
+	start = time.Now()
+	io.ReadAll(r)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited read took %s", elapsed)
+	}
+}
+
+func TestReaderPause(t *testing.T) {
+	PausePoll = 10 * time.Millisecond
+	defer func() { PausePoll = time.Second }()
+	polls := 0
+	r := NewReader(bytes.NewBufferString("data"), nil, func() bool {
+		polls++
+		return polls <= 5
+	})
+	got, err := io.ReadAll(r)
+	if err != nil || string(got) != "data" {
+		t.Fatalf("read %q, error %v", got, err)
+	}
+	if polls < 6 {
+		t.Errorf("read before the pause ended, %d polls", polls)
+	}
+}
+
+func TestBucketShared(t *testing.T) {
+	data := make([]byte, 20*1024)
+	bucket := NewBucket(func() int64 { return 100 * 1024 })
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.ReadAll(NewBucketReader(bytes.NewReader(data), bucket, nil))
+		}()
+	}
+	wg.Wait()
+	// Two streams of 20KB share 100KB/s
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("2x20KB at 100KB/s read in %s", elapsed)
+	}
+}
@@ -0,0 +1,53 @@
+// replication-manager - Replication Manager Monitoring and CLI for MariaDB and MySQL
+// Copyright 2017-2021 SIGNAL18 CLOUD SAS
+// Authors: Guillaume Lefranc <guillaume@signal18.io>
+//          Stephane Varoqui  <svaroqui@gmail.com>
+// This source code is licensed under the GNU General Public License, version 3.
+// Redistribution/Reuse of this code is permitted under the GNU v3 license, as
+// an additional term, ALL code must carry the original Author(s) credit in comment form.
+// See LICENSE in this directory for the integral text.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Init(Options{Exporter: ExporterFile, File: file, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tc Context
+	ctx, root := Start(tc.Get(), "Cluster.Monitor", attribute.String("cluster", "c1"))
+	tc.Set(ctx)
+	_, child := Start(tc.Get(), "ServerMonitor.Refresh", attribute.String("server", "db1:3306"))
+	End(child, errors.New("timeout"))
+	End(root, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Cluster.Monitor", "ServerMonitor.Refresh", "db1:3306", "timeout"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("trace file does not contain %s", want)
+		}
+	}
+}
+
+func TestUnknownExporter(t *testing.T) {
+	if _, err := Init(Options{Exporter: "zipkin"}); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}